@@ -0,0 +1,79 @@
+// seehuhn.de/go/sfnt - a library for reading and writing font files
+// Copyright (C) 2026  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package sfnt
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/image/font/gofont/goregular"
+
+	"seehuhn.de/go/postscript/funit"
+
+	"seehuhn.de/go/sfnt/cff"
+	"seehuhn.de/go/sfnt/parser"
+)
+
+// TestAutoHintConverted checks that the CFF autohinter produces plausible
+// hints for a font converted from TrueType outlines.
+func TestAutoHintConverted(t *testing.T) {
+	orig, err := Read(bytes.NewReader(goregular.TTF), parser.NewBudget(int64(len(goregular.TTF))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmap, err := orig.CMapTable.GetBest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	otf := orig.ConvertToCFF()
+	outlines := otf.Outlines.(*cff.Outlines)
+	outlines.AutoHint(otf.FontMatrix)
+
+	private := outlines.Private[0]
+	for _, y := range []funit.Int16{0, otf.XHeight, otf.CapHeight} {
+		if !slices.Contains(private.BlueValues, y) {
+			t.Errorf("no alignment zone at %d: %v", y, private.BlueValues)
+		}
+	}
+	if private.StdHW <= 0 || private.StdVW <= 0 {
+		t.Errorf("invalid standard stem widths %g, %g", private.StdHW, private.StdVW)
+	}
+
+	H := outlines.Glyphs[cmap.Lookup('H')]
+	if len(H.VStem) != 4 {
+		t.Errorf("H: got vertical stems %v, want two stems", H.VStem)
+	}
+	numMasks := 0
+	for _, g := range outlines.Glyphs {
+		for _, cmd := range g.Cmds {
+			if cmd.Op == cff.OpHintMask {
+				numMasks++
+			}
+		}
+	}
+	if numMasks == 0 {
+		t.Error("no hint replacement used")
+	}
+
+	res := roundTrip(t, otf)
+	if diff := cmp.Diff(outlines.Glyphs, res.Outlines.(*cff.Outlines).Glyphs); diff != "" {
+		t.Errorf("glyphs differ after round trip (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,814 @@
+// seehuhn.de/go/sfnt - a library for reading and writing font files
+// Copyright (C) 2026  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package cff
+
+import (
+	"cmp"
+	"math"
+	"slices"
+
+	"seehuhn.de/go/geom/matrix"
+	"seehuhn.de/go/geom/vec"
+	"seehuhn.de/go/postscript/funit"
+	"seehuhn.de/go/postscript/type1"
+
+	"seehuhn.de/go/sfnt/glyph"
+)
+
+// AutoHint adds hints to all glyphs.  The argument fontMatrix is the
+// top-level font matrix of the font, which is used to determine the size of
+// the em square.
+//
+// For each private dictionary, the alignment zones (BlueValues and
+// OtherBlues) and the standard stem widths (StdHW and StdVW) are replaced by
+// values found by analysing the glyph outlines.  Then [Glyph.AutoHint] is
+// used to add stem hints to every glyph.
+func (o *Outlines) AutoHint(fontMatrix matrix.Matrix) {
+	fdGlyphs := make([][]*Glyph, len(o.Private))
+	for gid, g := range o.Glyphs {
+		fd := 0
+		if o.FDSelect != nil {
+			fd = o.FDSelect(glyph.ID(gid))
+		}
+		if fd >= 0 && fd < len(fdGlyphs) && g != nil {
+			fdGlyphs[fd] = append(fdGlyphs[fd], g)
+		}
+	}
+
+	for fd, glyphs := range fdGlyphs {
+		M := o.FDMatrix(fd, fontMatrix)
+		unitsPerEm := 1000.0
+		if M[3] != 0 {
+			unitsPerEm = math.Abs(1 / M[3])
+		}
+
+		private := o.Private[fd]
+		if private == nil {
+			private = &type1.PrivateDict{}
+			o.Private[fd] = private
+		}
+		private.BlueValues, private.OtherBlues = findBlues(glyphs, unitsPerEm)
+		if private.BlueScale == 0 {
+			private.BlueScale = 0.039625
+		}
+		if private.BlueShift == 0 {
+			private.BlueShift = 7
+		}
+		if private.BlueFuzz == 0 {
+			private.BlueFuzz = 1
+		}
+		// The height of every alignment zone must be less than 1/BlueScale.
+		maxHeight := 0.0
+		for _, blues := range [][]funit.Int16{private.BlueValues, private.OtherBlues} {
+			for i := 0; i+1 < len(blues); i += 2 {
+				maxHeight = max(maxHeight, float64(blues[i+1]-blues[i]))
+			}
+		}
+		if maxHeight*private.BlueScale >= 1 {
+			private.BlueScale = 0.99 / maxHeight
+		}
+
+		hWidths := make(map[float64]int)
+		vWidths := make(map[float64]int)
+		for _, g := range glyphs {
+			g.AutoHint(private, unitsPerEm)
+			for i := 0; i+1 < len(g.HStem); i += 2 {
+				if w := g.HStem[i+1] - g.HStem[i]; w > 0 {
+					hWidths[w]++
+				}
+			}
+			for i := 0; i+1 < len(g.VStem); i += 2 {
+				vWidths[g.VStem[i+1]-g.VStem[i]]++
+			}
+		}
+		private.StdHW = mostFrequent(hWidths)
+		private.StdVW = mostFrequent(vWidths)
+	}
+}
+
+// AutoHint replaces the hints of the glyph by automatically generated ones.
+//
+// Horizontal and vertical stems are found by pairing opposite edges of the
+// outline, where an edge is either a horizontal (vertical) line segment, or
+// a point where the outline has a horizontal (vertical) tangent and
+// attains a local extremum.  Edges in the alignment zones of the private
+// dictionary which are not part of a stem are hinted using ghost stems.
+// If some of the stems overlap, hintmask operators are inserted to switch
+// between non-overlapping sets of stems.
+//
+// The argument unitsPerEm gives the size of the em square in glyph space
+// units.  It is used to determine the maximal stem width.
+func (g *Glyph) AutoHint(private *type1.PrivateDict, unitsPerEm float64) {
+	g.HStem = nil
+	g.VStem = nil
+	g.Cmds = slices.DeleteFunc(g.Cmds, func(cmd GlyphOp) bool {
+		return cmd.Op == OpHintMask || cmd.Op == OpCntrMask
+	})
+
+	contours := getHintContours(g.Cmds)
+	if len(contours) == 0 {
+		return
+	}
+	flip := hintArea(contours) < 0
+
+	hEdges := findEdges(contours, hFrame, flip, unitsPerEm)
+	vEdges := findEdges(contours, vFrame, flip, unitsPerEm)
+	hStems := pairEdges(hEdges, unitsPerEm)
+	vStems := pairEdges(vEdges, unitsPerEm)
+	if private != nil {
+		hStems = addGhostStems(hStems, hEdges, private)
+	}
+	for _, s := range vStems {
+		// convert back from the rotated coordinate system
+		s.lo, s.hi = -s.hi, -s.lo
+		s.vertical = true
+	}
+
+	// The Type 2 charstring format allows at most 96 stem hints.
+	if n := len(hStems) + len(vStems); n > maxStemHints {
+		all := append(slices.Clone(hStems), vStems...)
+		slices.SortStableFunc(all, func(a, b *stem) int {
+			return cmp.Compare(len(b.points), len(a.points))
+		})
+		keep := make(map[*stem]bool)
+		for _, s := range all[:maxStemHints] {
+			keep[s] = true
+		}
+		drop := func(s *stem) bool { return !keep[s] }
+		hStems = slices.DeleteFunc(hStems, drop)
+		vStems = slices.DeleteFunc(vStems, drop)
+	}
+
+	sortStems := func(stems []*stem) {
+		slices.SortFunc(stems, func(a, b *stem) int {
+			if c := cmp.Compare(a.start(), b.start()); c != 0 {
+				return c
+			}
+			return cmp.Compare(a.end(), b.end())
+		})
+	}
+	sortStems(hStems)
+	sortStems(vStems)
+	for _, s := range hStems {
+		g.HStem = append(g.HStem, s.start(), s.end())
+	}
+	for _, s := range vStems {
+		g.VStem = append(g.VStem, s.start(), s.end())
+	}
+
+	stems := append(hStems, vStems...)
+	conflicts := false
+	for i, s := range stems {
+		for _, t := range stems[:i] {
+			if s.conflicts(t) {
+				conflicts = true
+			}
+		}
+	}
+	if conflicts {
+		g.addHintMasks(stems)
+	}
+}
+
+// maxStemHints is the maximal number of stem hints allowed in a Type 2
+// charstring.
+const maxStemHints = 96
+
+// addHintMasks inserts hintmask operators into the glyph, such that at
+// every point the active stems do not overlap, and such that the stems
+// which control a point are active when the point is drawn.
+func (g *Glyph) addHintMasks(stems []*stem) {
+	pointStems := make(map[int][]int)
+	for k, s := range stems {
+		for _, i := range s.points {
+			pointStems[i] = append(pointStems[i], k)
+		}
+	}
+	for _, ks := range pointStems {
+		slices.Sort(ks)
+	}
+
+	// nextUse[k] is the index of the next command which uses stem k.
+	nextUse := make([]int, len(stems))
+
+	var res []GlyphOp
+	var active []int
+	compatible := func(set []int, k int) bool {
+		for _, j := range set {
+			if j == k || stems[j].conflicts(stems[k]) {
+				return false
+			}
+		}
+		return true
+	}
+	for i, cmd := range g.Cmds {
+		need := pointStems[i]
+		missing := i == 0
+		for _, k := range need {
+			if !slices.Contains(active, k) {
+				missing = true
+			}
+		}
+		if missing {
+			var set []int
+			for _, k := range need {
+				if compatible(set, k) {
+					set = append(set, k)
+				}
+			}
+			for _, k := range active {
+				if compatible(set, k) {
+					set = append(set, k)
+				}
+			}
+
+			// Add the remaining stems in the order they are needed.
+			for k := range stems {
+				nextUse[k] = len(g.Cmds)
+				for _, j := range stems[k].points {
+					if j >= i && j < nextUse[k] {
+						nextUse[k] = j
+					}
+				}
+			}
+			order := make([]int, len(stems))
+			for k := range order {
+				order[k] = k
+			}
+			slices.SortStableFunc(order, func(a, b int) int {
+				return cmp.Compare(nextUse[a], nextUse[b])
+			})
+			for _, k := range order {
+				if compatible(set, k) {
+					set = append(set, k)
+				}
+			}
+
+			mask := make([]float64, (len(stems)+7)/8)
+			for _, k := range set {
+				mask[k/8] += float64(int(1) << (7 - k%8))
+			}
+			res = append(res, GlyphOp{Op: OpHintMask, Args: mask})
+			active = set
+		}
+		res = append(res, cmd)
+	}
+	g.Cmds = res
+}
+
+// A stem is a pair of opposite edges.  For horizontal stems, lo and hi are
+// y coordinates, for vertical stems they are x coordinates.
+type stem struct {
+	lo, hi float64
+
+	vertical bool
+
+	// ghost is +1 for a ghost stem at a top edge (at hi), and -1 for a ghost
+	// stem at a bottom edge (at lo).  For ordinary stems, ghost is 0.
+	ghost int
+
+	// points lists the indices of the glyph commands whose end points are
+	// controlled by the stem.
+	points []int
+}
+
+// start returns the first value used for the stem in a charstring.
+func (s *stem) start() float64 {
+	switch s.ghost {
+	case 1:
+		return s.hi
+	case -1:
+		return s.lo + 21
+	default:
+		return s.lo
+	}
+}
+
+// end returns the second value used for the stem in a charstring.
+func (s *stem) end() float64 {
+	switch s.ghost {
+	case 1:
+		return s.hi - 20
+	case -1:
+		return s.lo
+	default:
+		return s.hi
+	}
+}
+
+// conflicts reports whether two stems of the same direction overlap.
+func (s *stem) conflicts(t *stem) bool {
+	if s.vertical != t.vertical {
+		return false
+	}
+	lo1, hi1 := min(s.start(), s.end()), max(s.start(), s.end())
+	lo2, hi2 := min(t.start(), t.end()), max(t.start(), t.end())
+	return lo1 <= hi2 && lo2 <= hi1
+}
+
+// A hintEdge is a part of the glyph outline which can be controlled by a stem
+// hint.  Coordinates are given in the coordinate system of a hintFrame,
+// so that edges are always horizontal.
+type hintEdge struct {
+	pos    float64 // the position of the edge
+	lo, hi float64 // the extent of the edge along the edge direction
+
+	// top is true if the glyph is filled on the side of the edge with
+	// smaller coordinates.
+	top bool
+
+	round bool
+
+	// isMin and isMax are set if the edge is at the minimum (maximum)
+	// coordinate of its contour.
+	isMin, isMax bool
+
+	points []int
+}
+
+// A hintFrame maps glyph coordinates to a coordinate system where the edges
+// of interest are horizontal.
+type hintFrame func(vec.Vec2) vec.Vec2
+
+func hFrame(p vec.Vec2) vec.Vec2 { return p }
+
+// vFrame rotates the glyph by 90 degrees clockwise.  Since this is a
+// rotation, the orientation of the outline is preserved.
+func vFrame(p vec.Vec2) vec.Vec2 { return vec.Vec2{X: p.Y, Y: -p.X} }
+
+// hintSegment is a segment of a closed glyph outline.
+type hintSegment struct {
+	// p holds the start point, the control points (for curves),
+	// and the end point of the segment.
+	p []vec.Vec2
+
+	// cmd is the index in Glyph.Cmds of the command which ends at the end
+	// point of the segment.
+	cmd int
+}
+
+func (s hintSegment) isCurve() bool { return len(s.p) == 4 }
+
+// getHintContours splits the glyph outline into closed contours.
+func getHintContours(cmds []GlyphOp) [][]hintSegment {
+	var contours [][]hintSegment
+	var cur []hintSegment
+	var start, pos vec.Vec2
+	startCmd := -1
+	closeContour := func() {
+		if startCmd < 0 {
+			return
+		}
+		if pos != start {
+			cur = append(cur, hintSegment{p: []vec.Vec2{pos, start}, cmd: startCmd})
+		} else if len(cur) > 0 {
+			// The last explicit segment ends at the start point.  We
+			// associate this point with the initial moveto.
+			cur[len(cur)-1].cmd = startCmd
+		}
+		if len(cur) > 1 {
+			contours = append(contours, cur)
+		}
+		cur = nil
+	}
+	for i, cmd := range cmds {
+		switch cmd.Op {
+		case OpMoveTo:
+			closeContour()
+			start = vec.Vec2{X: cmd.Args[0], Y: cmd.Args[1]}
+			pos = start
+			startCmd = i
+		case OpLineTo:
+			next := vec.Vec2{X: cmd.Args[0], Y: cmd.Args[1]}
+			if next != pos {
+				cur = append(cur, hintSegment{p: []vec.Vec2{pos, next}, cmd: i})
+			}
+			pos = next
+		case OpCurveTo:
+			p1 := vec.Vec2{X: cmd.Args[0], Y: cmd.Args[1]}
+			p2 := vec.Vec2{X: cmd.Args[2], Y: cmd.Args[3]}
+			p3 := vec.Vec2{X: cmd.Args[4], Y: cmd.Args[5]}
+			cur = append(cur, hintSegment{p: []vec.Vec2{pos, p1, p2, p3}, cmd: i})
+			pos = p3
+		}
+	}
+	closeContour()
+	return contours
+}
+
+// hintArea returns the signed area enclosed by the control polygons of the
+// contours.  The result is positive, if the outer contours are oriented
+// counter-clockwise.
+func hintArea(contours [][]hintSegment) float64 {
+	var area float64
+	for _, contour := range contours {
+		for _, seg := range contour {
+			for i := 1; i < len(seg.p); i++ {
+				a, b := seg.p[i-1], seg.p[i]
+				area += a.X*b.Y - a.Y*b.X
+			}
+		}
+	}
+	return area / 2
+}
+
+// Tolerances used for detecting edges.
+const (
+	edgeSlope     = 0.05  // max. slope of a line segment forming an edge
+	edgeMinLength = 0.005 // min. length of a line segment, in em units
+	maxStemWidth  = 0.3   // max. width of a stem, in em units
+	blueOvershoot = 0.04  // max. height of an alignment zone, in em units
+)
+
+// findEdges finds the edges of the glyph outline which are horizontal in the
+// given frame.  If flip is set, the outer contours of the glyph are
+// oriented clockwise.
+func findEdges(contours [][]hintSegment, frame hintFrame, flip bool, unitsPerEm float64) []*hintEdge {
+	var edges []*hintEdge
+	for _, contour := range contours {
+		n := len(contour)
+		seg := make([][]vec.Vec2, n)
+		yMin, yMax := math.Inf(1), math.Inf(-1)
+		for i, s := range contour {
+			seg[i] = make([]vec.Vec2, len(s.p))
+			for j, p := range s.p {
+				seg[i][j] = frame(p)
+			}
+			yMin = min(yMin, seg[i][0].Y)
+			yMax = max(yMax, seg[i][0].Y)
+		}
+
+		first := len(edges)
+		for i, s := range contour {
+			p := seg[i]
+			prev := contour[(i+n-1)%n]
+
+			// line segments
+			if !s.isCurve() {
+				d := p[1].Sub(p[0])
+				if math.Abs(d.Y) <= edgeSlope*math.Abs(d.X) &&
+					math.Abs(d.X) >= edgeMinLength*unitsPerEm {
+					edges = append(edges, &hintEdge{
+						pos:    math.Round((p[0].Y + p[1].Y) / 2),
+						lo:     min(p[0].X, p[1].X),
+						hi:     max(p[0].X, p[1].X),
+						top:    (d.X < 0) != flip,
+						points: []int{prev.cmd, s.cmd},
+					})
+				}
+			}
+
+			// extrema at the start point of curves
+			q := seg[(i+n-1)%n]
+			if !s.isCurve() && len(q) != 4 {
+				continue
+			}
+			P := p[0]
+			in := tangentIn(q)
+			out := tangentOut(p)
+			if math.Abs(in.Y) > edgeSlope*math.Abs(in.X) ||
+				math.Abs(out.Y) > edgeSlope*math.Abs(out.X) ||
+				in.X*out.X <= 0 {
+				continue
+			}
+			before := q[0].Y - P.Y
+			after := p[len(p)-1].Y - P.Y
+			if before*after < 0 || before == 0 && after == 0 {
+				// not a local extremum
+				continue
+			}
+			a := P.Add(in.Mul(-0.5))
+			b := P.Add(out.Mul(0.5))
+			edges = append(edges, &hintEdge{
+				pos:    P.Y,
+				lo:     min(a.X, b.X, P.X),
+				hi:     max(a.X, b.X, P.X),
+				top:    (out.X < 0) != flip,
+				round:  true,
+				points: []int{prev.cmd},
+			})
+		}
+		for _, e := range edges[first:] {
+			e.isMin = e.pos <= yMin+0.5
+			e.isMax = e.pos >= yMax-0.5
+		}
+	}
+	return edges
+}
+
+// tangentIn returns the direction in which the segment p arrives at its
+// end point.
+func tangentIn(p []vec.Vec2) vec.Vec2 {
+	n := len(p)
+	for i := n - 2; i >= 0; i-- {
+		if d := p[n-1].Sub(p[i]); d != (vec.Vec2{}) {
+			return d
+		}
+	}
+	return vec.Vec2{}
+}
+
+// tangentOut returns the direction in which the segment p leaves its start
+// point.
+func tangentOut(p []vec.Vec2) vec.Vec2 {
+	for i := 1; i < len(p); i++ {
+		if d := p[i].Sub(p[0]); d != (vec.Vec2{}) {
+			return d
+		}
+	}
+	return vec.Vec2{}
+}
+
+// pairEdges finds stems by pairing bottom edges with top edges.  Pairs of
+// edges which overlap are used greedily, starting with the narrowest stems.
+func pairEdges(edges []*hintEdge, unitsPerEm float64) []*stem {
+	type pair struct {
+		bottom, top *hintEdge
+		width       float64
+	}
+	var pairs []pair
+	for _, b := range edges {
+		if b.top {
+			continue
+		}
+		for _, t := range edges {
+			if !t.top {
+				continue
+			}
+			w := t.pos - b.pos
+			if w <= 0 || w > maxStemWidth*unitsPerEm {
+				continue
+			}
+			if min(b.hi, t.hi) <= max(b.lo, t.lo) {
+				continue
+			}
+			pairs = append(pairs, pair{b, t, w})
+		}
+	}
+	slices.SortStableFunc(pairs, func(a, b pair) int {
+		return cmp.Compare(a.width, b.width)
+	})
+
+	used := make(map[*hintEdge]bool)
+	stemIdx := make(map[[2]float64]*stem)
+	var stems []*stem
+	for _, p := range pairs {
+		if used[p.bottom] || used[p.top] {
+			continue
+		}
+		used[p.bottom] = true
+		used[p.top] = true
+
+		key := [2]float64{p.bottom.pos, p.top.pos}
+		s := stemIdx[key]
+		if s == nil {
+			s = &stem{lo: p.bottom.pos, hi: p.top.pos}
+			stemIdx[key] = s
+			stems = append(stems, s)
+		}
+		s.points = append(s.points, p.bottom.points...)
+		s.points = append(s.points, p.top.points...)
+	}
+
+	// Edges at the same position as a stem edge are controlled by the stem,
+	// too.
+	for _, e := range edges {
+		if used[e] {
+			continue
+		}
+		for _, s := range stems {
+			if e.top && e.pos == s.hi || !e.top && e.pos == s.lo {
+				s.points = append(s.points, e.points...)
+				used[e] = true
+				break
+			}
+		}
+	}
+	return stems
+}
+
+// addGhostStems adds ghost stems for edges in the alignment zones which are
+// not controlled by a stem.
+func addGhostStems(stems []*stem, edges []*hintEdge, private *type1.PrivateDict) []*stem {
+	fuzz := float64(private.BlueFuzz)
+	inZone := func(y float64, top bool) bool {
+		for i := 0; i+1 < len(private.BlueValues); i += 2 {
+			isBottom := i == 0
+			lo := float64(private.BlueValues[i]) - fuzz
+			hi := float64(private.BlueValues[i+1]) + fuzz
+			if isBottom != top && y >= lo && y <= hi {
+				return true
+			}
+		}
+		for i := 0; i+1 < len(private.OtherBlues); i += 2 {
+			lo := float64(private.OtherBlues[i]) - fuzz
+			hi := float64(private.OtherBlues[i+1]) + fuzz
+			if !top && y >= lo && y <= hi {
+				return true
+			}
+		}
+		return false
+	}
+
+	covered := func(e *hintEdge) bool {
+		for _, s := range stems {
+			if s.ghost == 0 && (e.pos == s.lo || e.pos == s.hi) {
+				return true
+			}
+		}
+		return false
+	}
+
+	ghosts := make(map[float64]*stem)
+	for _, e := range edges {
+		if covered(e) || !inZone(e.pos, e.top) || !(e.isMax && e.top || e.isMin && !e.top) {
+			continue
+		}
+		s := ghosts[e.pos]
+		if s == nil {
+			s = &stem{lo: e.pos, hi: e.pos, ghost: -1}
+			if e.top {
+				s.ghost = 1
+			}
+			ghosts[e.pos] = s
+			stems = append(stems, s)
+		}
+		s.points = append(s.points, e.points...)
+	}
+	return stems
+}
+
+// findBlues determines alignment zones from the outlines of the given
+// glyphs.  The first zone in blueValues is the baseline zone, the other
+// zones in blueValues are top zones.  The zones in otherBlues are bottom
+// zones below the baseline.
+//
+// Zones are found by collecting the top and bottom edges of all contours.
+// The flat position of each zone is a position where many glyphs have a
+// straight edge, the overshoot is the most common position of round edges
+// close to the flat position.
+func findBlues(glyphs []*Glyph, unitsPerEm float64) (blueValues, otherBlues []funit.Int16) {
+	flatTop := make(map[float64]int)
+	roundTop := make(map[float64]int)
+	flatBottom := make(map[float64]int)
+	roundBottom := make(map[float64]int)
+	numGlyphs := 0
+	for _, g := range glyphs {
+		contours := getHintContours(g.Cmds)
+		if len(contours) == 0 {
+			continue
+		}
+		numGlyphs++
+		flip := hintArea(contours) < 0
+		seen := make(map[edgeKey]bool)
+		for _, e := range findEdges(contours, hFrame, flip, unitsPerEm) {
+			pos := math.Round(e.pos)
+			key := edgeKey{pos, e.top, e.round}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			switch {
+			case e.top && e.isMax && e.round:
+				roundTop[pos]++
+			case e.top && e.isMax:
+				flatTop[pos]++
+			case !e.top && e.isMin && e.round:
+				roundBottom[pos]++
+			case !e.top && e.isMin:
+				flatBottom[pos]++
+			}
+		}
+	}
+	if numGlyphs == 0 {
+		return []funit.Int16{0, 0}, nil
+	}
+	minCount := max(2, numGlyphs/25)
+	zoneHeight := blueOvershoot * unitsPerEm
+
+	type zone struct{ lo, hi float64 }
+	var zones []zone // in order of priority
+	overlaps := func(z zone) bool {
+		for _, y := range zones {
+			if z.lo <= y.hi+3 && y.lo <= z.hi+3 {
+				return true
+			}
+		}
+		return false
+	}
+
+	// baseline
+	base := 0.0
+	if pos, count := mode(flatBottom, -0.02*unitsPerEm, 0.02*unitsPerEm); count > 0 {
+		base = pos
+	}
+	baseline := zone{base, base}
+	if pos, count := mode(roundBottom, base-zoneHeight, base); count >= minCount {
+		baseline.lo = pos
+	}
+	zones = append(zones, baseline)
+
+	// top zones
+	numTop := 0
+	for _, pos := range peaks(flatTop, 0.1*unitsPerEm, math.Inf(1), minCount) {
+		z := zone{pos, pos}
+		if o, count := mode(roundTop, pos, pos+zoneHeight); count >= minCount {
+			z.hi = o
+		}
+		if numTop < 6 && !overlaps(z) {
+			zones = append(zones, z)
+			numTop++
+		}
+	}
+	slices.SortFunc(zones[1:], func(a, b zone) int { return cmp.Compare(a.lo, b.lo) })
+	for _, z := range zones {
+		blueValues = append(blueValues, funit.Int16(z.lo), funit.Int16(z.hi))
+	}
+
+	// bottom zones below the baseline
+	var other []zone
+	for _, pos := range peaks(flatBottom, math.Inf(-1), -0.1*unitsPerEm, minCount) {
+		z := zone{pos, pos}
+		if o, count := mode(roundBottom, pos-zoneHeight, pos); count >= minCount {
+			z.lo = o
+		}
+		if len(other) < 5 && !overlaps(z) {
+			zones = append(zones, z)
+			other = append(other, z)
+		}
+	}
+	slices.SortFunc(other, func(a, b zone) int { return cmp.Compare(a.lo, b.lo) })
+	for _, z := range other {
+		otherBlues = append(otherBlues, funit.Int16(z.lo), funit.Int16(z.hi))
+	}
+
+	return blueValues, otherBlues
+}
+
+type edgeKey struct {
+	pos        float64
+	top, round bool
+}
+
+// mode returns the most frequent value in the range [lo, hi], together with
+// its count.  Ties are broken in favour of values further away from zero.
+func mode(hist map[float64]int, lo, hi float64) (float64, int) {
+	var best float64
+	bestCount := 0
+	for pos, count := range hist {
+		if pos < lo || pos > hi {
+			continue
+		}
+		if count > bestCount || count == bestCount && math.Abs(pos) > math.Abs(best) {
+			best = pos
+			bestCount = count
+		}
+	}
+	return best, bestCount
+}
+
+// peaks returns the values in the range [lo, hi] which occur at least
+// minCount times, ordered by decreasing frequency.
+func peaks(hist map[float64]int, lo, hi float64, minCount int) []float64 {
+	var res []float64
+	for pos, count := range hist {
+		if pos >= lo && pos <= hi && count >= minCount {
+			res = append(res, pos)
+		}
+	}
+	slices.SortFunc(res, func(a, b float64) int {
+		if c := cmp.Compare(hist[b], hist[a]); c != 0 {
+			return c
+		}
+		return cmp.Compare(a, b)
+	})
+	return res
+}
+
+// mostFrequent returns the most frequent value in hist, or 0 if hist is
+// empty.  Ties are broken in favour of smaller values.
+func mostFrequent(hist map[float64]int) float64 {
+	var best float64
+	bestCount := 0
+	for w, count := range hist {
+		if count > bestCount || count == bestCount && w < best {
+			best = w
+			bestCount = count
+		}
+	}
+	return best
+}
@@ -0,0 +1,237 @@
+// seehuhn.de/go/sfnt - a library for reading and writing font files
+// Copyright (C) 2026  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package cff
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"seehuhn.de/go/postscript/funit"
+	"seehuhn.de/go/postscript/type1"
+
+	"seehuhn.de/go/sfnt/glyph"
+	"seehuhn.de/go/sfnt/parser"
+)
+
+// addRect adds a rectangle to the glyph.  If ccw is true, the rectangle is
+// drawn counter-clockwise.
+func addRect(g *Glyph, x0, y0, x1, y1 float64, ccw bool) {
+	g.MoveTo(x0, y0)
+	if ccw {
+		g.LineTo(x1, y0)
+		g.LineTo(x1, y1)
+		g.LineTo(x0, y1)
+	} else {
+		g.LineTo(x0, y1)
+		g.LineTo(x1, y1)
+		g.LineTo(x1, y0)
+	}
+}
+
+// makeH returns an "H"-shaped glyph.
+func makeH(ccw bool) *Glyph {
+	g := NewGlyph("H", 600)
+	if ccw {
+		g.MoveTo(100, 0)
+		g.LineTo(180, 0)
+		g.LineTo(180, 300)
+		g.LineTo(420, 300)
+		g.LineTo(420, 0)
+		g.LineTo(500, 0)
+		g.LineTo(500, 700)
+		g.LineTo(420, 700)
+		g.LineTo(420, 360)
+		g.LineTo(180, 360)
+		g.LineTo(180, 700)
+		g.LineTo(100, 700)
+	} else {
+		g.MoveTo(100, 0)
+		g.LineTo(100, 700)
+		g.LineTo(180, 700)
+		g.LineTo(180, 360)
+		g.LineTo(420, 360)
+		g.LineTo(420, 700)
+		g.LineTo(500, 700)
+		g.LineTo(500, 0)
+		g.LineTo(420, 0)
+		g.LineTo(420, 300)
+		g.LineTo(180, 300)
+		g.LineTo(180, 0)
+	}
+	return g
+}
+
+// makeO returns an "o"-shaped glyph with round top and bottom, where the
+// outer contour has extrema at y=-10 and y=510.
+func makeO() *Glyph {
+	g := NewGlyph("o", 600)
+	ellipse := func(cx, cy, rx, ry float64, ccw bool) {
+		const k = 0.5523
+		g.MoveTo(cx, cy-ry)
+		if ccw {
+			g.CurveTo(cx+k*rx, cy-ry, cx+rx, cy-k*ry, cx+rx, cy)
+			g.CurveTo(cx+rx, cy+k*ry, cx+k*rx, cy+ry, cx, cy+ry)
+			g.CurveTo(cx-k*rx, cy+ry, cx-rx, cy+k*ry, cx-rx, cy)
+			g.CurveTo(cx-rx, cy-k*ry, cx-k*rx, cy-ry, cx, cy-ry)
+		} else {
+			g.CurveTo(cx-k*rx, cy-ry, cx-rx, cy-k*ry, cx-rx, cy)
+			g.CurveTo(cx-rx, cy+k*ry, cx-k*rx, cy+ry, cx, cy+ry)
+			g.CurveTo(cx+k*rx, cy+ry, cx+rx, cy+k*ry, cx+rx, cy)
+			g.CurveTo(cx+rx, cy-k*ry, cx+k*rx, cy-ry, cx, cy-ry)
+		}
+	}
+	ellipse(300, 250, 250, 260, true)
+	ellipse(300, 250, 170, 200, false)
+	return g
+}
+
+var testPrivate = &type1.PrivateDict{
+	BlueValues: []funit.Int16{-10, 0, 500, 510, 700, 710},
+	BlueScale:  0.039625,
+	BlueShift:  7,
+	BlueFuzz:   1,
+}
+
+func TestAutoHintStems(t *testing.T) {
+	for _, ccw := range []bool{true, false} {
+		g := makeH(ccw)
+		g.AutoHint(testPrivate, 1000)
+
+		wantH := []float64{21, 0, 300, 360, 700, 680}
+		wantV := []float64{100, 180, 420, 500}
+		if !slices.Equal(g.HStem, wantH) {
+			t.Errorf("ccw=%t: HStem = %v, want %v", ccw, g.HStem, wantH)
+		}
+		if !slices.Equal(g.VStem, wantV) {
+			t.Errorf("ccw=%t: VStem = %v, want %v", ccw, g.VStem, wantV)
+		}
+		for _, cmd := range g.Cmds {
+			if cmd.Op == OpHintMask {
+				t.Errorf("ccw=%t: unexpected hintmask", ccw)
+			}
+		}
+	}
+}
+
+func TestAutoHintRound(t *testing.T) {
+	g := makeO()
+	g.AutoHint(testPrivate, 1000)
+
+	wantH := []float64{-10, 50, 450, 510}
+	wantV := []float64{50, 130, 470, 550}
+	if !slices.Equal(g.HStem, wantH) {
+		t.Errorf("HStem = %v, want %v", g.HStem, wantH)
+	}
+	if !slices.Equal(g.VStem, wantV) {
+		t.Errorf("VStem = %v, want %v", g.VStem, wantV)
+	}
+}
+
+func TestAutoHintMask(t *testing.T) {
+	// Two overlapping vertical bars, drawn as separate contours, give
+	// conflicting vertical stems.
+	g := NewGlyph("bars", 600)
+	addRect(g, 100, 0, 200, 300, true)
+	addRect(g, 150, 400, 260, 700, true)
+	g.AutoHint(nil, 1000)
+
+	wantV := []float64{100, 200, 150, 260}
+	if !slices.Equal(g.VStem, wantV) {
+		t.Errorf("VStem = %v, want %v", g.VStem, wantV)
+	}
+	nH := len(g.HStem) / 2
+
+	var masks [][]float64
+	for _, cmd := range g.Cmds {
+		if cmd.Op == OpHintMask {
+			masks = append(masks, cmd.Args)
+		}
+	}
+	if len(masks) != 2 {
+		t.Fatalf("got %d hintmasks, want 2", len(masks))
+	}
+	if g.Cmds[0].Op != OpHintMask {
+		t.Error("first command is not a hintmask")
+	}
+	for i, mask := range masks {
+		first := int(mask[0])>>(7-nH)&1 != 0
+		second := int(mask[0])>>(6-nH)&1 != 0
+		if first == second {
+			t.Errorf("mask %d: %v selects both or neither vertical stem", i, mask)
+		}
+	}
+
+	// check that the hints can be encoded and decoded
+	f := &Font{
+		FontInfo: &type1.FontInfo{FontName: "Test", FontMatrix: defaultFontMatrix},
+		Outlines: &Outlines{
+			Glyphs:   []*Glyph{NewGlyph(".notdef", 500), g},
+			Private:  []*type1.PrivateDict{{BlueValues: []funit.Int16{0, 0}}},
+			FDSelect: func(glyph.ID) int { return 0 },
+		},
+	}
+	buf := &bytes.Buffer{}
+	err := f.Write(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := Read(bytes.NewReader(buf.Bytes()), parser.NewBudget(int64(buf.Len())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(g, out.Glyphs[1]); diff != "" {
+		t.Errorf("glyph differs after round trip (-want +got):\n%s", diff)
+	}
+}
+
+func TestAutoHintOutlines(t *testing.T) {
+	x := NewGlyph("x", 500)
+	addRect(x, 50, 0, 450, 500, true)
+	z := NewGlyph("z", 500)
+	addRect(z, 50, 0, 450, 500, true)
+	H := makeH(true)
+	I := NewGlyph("I", 300)
+	addRect(I, 100, 0, 180, 700, true)
+	O := makeO()
+	O2 := makeO()
+	O2.Name = "o2"
+
+	o := &Outlines{
+		Glyphs:   []*Glyph{NewGlyph(".notdef", 500), x, z, H, I, O, O2},
+		Private:  []*type1.PrivateDict{{}},
+		FDSelect: func(glyph.ID) int { return 0 },
+	}
+	o.AutoHint(defaultFontMatrix)
+
+	private := o.Private[0]
+	wantBlues := []funit.Int16{-10, 0, 500, 510, 700, 700}
+	if !slices.Equal(private.BlueValues, wantBlues) {
+		t.Errorf("BlueValues = %v, want %v", private.BlueValues, wantBlues)
+	}
+	if private.StdVW != 80 {
+		t.Errorf("StdVW = %v, want 80", private.StdVW)
+	}
+	if private.BlueScale*10 >= 1 {
+		t.Errorf("BlueScale %v too large", private.BlueScale)
+	}
+	if len(H.HStem) == 0 || len(O.HStem) == 0 {
+		t.Error("glyphs were not hinted")
+	}
+}
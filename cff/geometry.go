@@ -0,0 +1,123 @@
+// seehuhn.de/go/sfnt - a library for reading and writing font files
+// Copyright (C) 2026  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package cff
+
+import (
+	"math"
+	"slices"
+
+	"seehuhn.de/go/geom/vec"
+
+	"seehuhn.de/go/sfnt/internal/outline"
+)
+
+// RemoveOverlaps replaces the contours of the glyph by contours which
+// enclose the same area, but which neither intersect each other nor
+// themselves.  Outer contours of the result run counter-clockwise, holes
+// run clockwise.  Coordinates are rounded to integers.
+//
+// If the outline is changed, hint masks are removed from the glyph.
+// The stem hints are kept.
+func (g *Glyph) RemoveOverlaps() {
+	g.setContours(outline.Union(outline.FromPath(g.Path())), true)
+}
+
+// CorrectDirection reverses contours as needed, so that outer contours run
+// counter-clockwise and holes run clockwise, as required for CFF fonts.
+// A contour is considered to be a hole if it lies inside an odd number of
+// other contours.
+//
+// If any contours are reversed, hint masks are removed from the glyph.
+func (g *Glyph) CorrectDirection() {
+	g.setContours(outline.Orient(outline.FromPath(g.Path()), true), false)
+}
+
+// RemoveDegenerate removes segments of length zero, replaces straight
+// curves by lines, removes redundant points on straight lines, and removes
+// contours which enclose no area.
+//
+// If the outline is changed, hint masks are removed from the glyph.
+func (g *Glyph) RemoveDegenerate() {
+	g.setContours(outline.RemoveDegenerate(outline.FromPath(g.Path())), false)
+}
+
+// AddExtrema splits curves at their horizontal and vertical extrema, so
+// that all extreme points of the outline are end points of segments.
+// Coordinates are rounded to integers.
+//
+// If the outline is changed, hint masks are removed from the glyph.
+func (g *Glyph) AddExtrema() {
+	g.setContours(outline.AddExtrema(outline.FromPath(g.Path())), true)
+}
+
+// setContours replaces the outline of the glyph.  If the new outline
+// differs from the old one, hint masks and counter masks are removed.
+func (g *Glyph) setContours(cc []outline.Contour, round bool) {
+	cmds := contourCmds(cc, round)
+	old := contourCmds(outline.FromPath(g.Path()), false)
+	equal := slices.EqualFunc(cmds, old, func(a, b GlyphOp) bool {
+		return a.Op == b.Op && slices.Equal(a.Args, b.Args)
+	})
+	if !equal {
+		g.Cmds = cmds
+	}
+}
+
+// contourCmds converts contours to CFF glyph commands.  Quadratic curves are
+// converted to cubic ones.  If round is true, coordinates are rounded to
+// integers.
+func contourCmds(cc []outline.Contour, round bool) []GlyphOp {
+	r := func(v vec.Vec2) vec.Vec2 {
+		if round {
+			return vec.Vec2{X: math.Round(v.X), Y: math.Round(v.Y)}
+		}
+		return v
+	}
+
+	var cmds []GlyphOp
+	for _, c := range cc {
+		if len(c) == 0 {
+			continue
+		}
+		start := r(c[0].Start())
+		cmds = append(cmds, GlyphOp{Op: OpMoveTo, Args: []float64{start.X, start.Y}})
+		cur := start
+		for i, s := range c {
+			end := r(s.End())
+			switch len(s) {
+			case 2:
+				if end == cur || i == len(c)-1 && end == start {
+					// CFF closes contours automatically.
+					continue
+				}
+				cmds = append(cmds, GlyphOp{Op: OpLineTo, Args: []float64{end.X, end.Y}})
+			case 3:
+				c1 := r(s[0].Add(s[1].Sub(s[0]).Mul(2.0 / 3)))
+				c2 := r(s[2].Add(s[1].Sub(s[2]).Mul(2.0 / 3)))
+				cmds = append(cmds, GlyphOp{Op: OpCurveTo, Args: []float64{c1.X, c1.Y, c2.X, c2.Y, end.X, end.Y}})
+			case 4:
+				c1, c2 := r(s[1]), r(s[2])
+				if c1 == cur && c2 == end && end == cur {
+					continue
+				}
+				cmds = append(cmds, GlyphOp{Op: OpCurveTo, Args: []float64{c1.X, c1.Y, c2.X, c2.Y, end.X, end.Y}})
+			}
+			cur = end
+		}
+	}
+	return cmds
+}
@@ -0,0 +1,127 @@
+// seehuhn.de/go/sfnt - a library for reading and writing font files
+// Copyright (C) 2026  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package cff
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// addSquare adds a square to the glyph.  The square runs counter-clockwise
+// if ccw is true.
+func addSquare(g *Glyph, x, y, size float64, ccw bool) {
+	g.MoveTo(x, y)
+	if ccw {
+		g.LineTo(x+size, y)
+		g.LineTo(x+size, y+size)
+		g.LineTo(x, y+size)
+	} else {
+		g.LineTo(x, y+size)
+		g.LineTo(x+size, y+size)
+		g.LineTo(x+size, y)
+	}
+}
+
+func TestGlyphRemoveOverlaps(t *testing.T) {
+	g := NewGlyph("test", 500)
+	g.HStem = []float64{0, 100}
+	addSquare(g, 0, 0, 100, true)
+	g.Cmds = append(g.Cmds, GlyphOp{Op: OpHintMask, Args: []float64{1}})
+	addSquare(g, 50, 50, 100, true)
+	g.RemoveOverlaps()
+
+	want := []GlyphOp{
+		{Op: OpMoveTo, Args: []float64{0, 0}},
+		{Op: OpLineTo, Args: []float64{100, 0}},
+		{Op: OpLineTo, Args: []float64{100, 50}},
+		{Op: OpLineTo, Args: []float64{150, 50}},
+		{Op: OpLineTo, Args: []float64{150, 150}},
+		{Op: OpLineTo, Args: []float64{50, 150}},
+		{Op: OpLineTo, Args: []float64{50, 100}},
+		{Op: OpLineTo, Args: []float64{0, 100}},
+	}
+	if d := cmp.Diff(want, g.Cmds); d != "" {
+		t.Errorf("unexpected commands (-want +got):\n%s", d)
+	}
+	if len(g.HStem) != 2 {
+		t.Error("stem hints removed")
+	}
+
+	// a glyph without overlaps is not changed
+	g = NewGlyph("test", 500)
+	addSquare(g, 0, 0, 100, true)
+	g.Cmds = append(g.Cmds, GlyphOp{Op: OpHintMask, Args: []float64{1}})
+	addSquare(g, 200, 0, 100, true)
+	before := append([]GlyphOp(nil), g.Cmds...)
+	g.RemoveOverlaps()
+	if d := cmp.Diff(before, g.Cmds); d != "" {
+		t.Errorf("glyph changed (-want +got):\n%s", d)
+	}
+}
+
+func TestGlyphCorrectDirection(t *testing.T) {
+	g := NewGlyph("test", 500)
+	addSquare(g, 0, 0, 300, false)
+	addSquare(g, 100, 100, 100, false)
+	g.CorrectDirection()
+
+	want := NewGlyph("test", 500)
+	want.MoveTo(0, 0)
+	want.LineTo(300, 0)
+	want.LineTo(300, 300)
+	want.LineTo(0, 300)
+	addSquare(want, 100, 100, 100, false)
+	if d := cmp.Diff(want.Cmds, g.Cmds); d != "" {
+		t.Errorf("unexpected commands (-want +got):\n%s", d)
+	}
+}
+
+func TestGlyphRemoveDegenerate(t *testing.T) {
+	g := NewGlyph("test", 500)
+	g.MoveTo(0, 0)
+	g.LineTo(50, 0)
+	g.LineTo(50, 0)
+	g.CurveTo(60, 0, 90, 0, 100, 0)
+	g.LineTo(100, 100)
+	g.LineTo(0, 100)
+	g.MoveTo(200, 0)
+	g.LineTo(300, 0)
+	g.RemoveDegenerate()
+
+	want := NewGlyph("test", 500)
+	addSquare(want, 0, 0, 100, true)
+	if d := cmp.Diff(want.Cmds, g.Cmds); d != "" {
+		t.Errorf("unexpected commands (-want +got):\n%s", d)
+	}
+}
+
+func TestGlyphAddExtrema(t *testing.T) {
+	g := NewGlyph("test", 500)
+	g.MoveTo(0, 0)
+	g.CurveTo(0, 100, 100, 100, 100, 0)
+	g.AddExtrema()
+
+	want := []GlyphOp{
+		{Op: OpMoveTo, Args: []float64{0, 0}},
+		{Op: OpCurveTo, Args: []float64{0, 50, 25, 75, 50, 75}},
+		{Op: OpCurveTo, Args: []float64{75, 75, 100, 50, 100, 0}},
+	}
+	if d := cmp.Diff(want, g.Cmds); d != "" {
+		t.Errorf("unexpected commands (-want +got):\n%s", d)
+	}
+}
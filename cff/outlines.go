@@ -75,6 +75,11 @@ type Outlines struct {
 	//
 	// This is only used for CID-keyed fonts.
 	FontMatrices []matrix.Matrix
+
+	// Subroutinize, if set, makes [Font.Write] move repeated charstring
+	// fragments into global and local subroutines.  This makes the font
+	// file smaller, but writing the font takes longer.
+	Subroutinize bool
 }
 
 // IsCIDKeyed returns true if the font is a CID-keyed font.
@@ -0,0 +1,585 @@
+// seehuhn.de/go/sfnt - a library for reading and writing font files
+// Copyright (C) 2026  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package cff
+
+import (
+	"cmp"
+	"slices"
+
+	"seehuhn.de/go/postscript/funit"
+
+	"seehuhn.de/go/sfnt/glyph"
+)
+
+// maxSubrNesting is the maximal nesting depth of subroutine calls permitted
+// by the Type 2 charstring format.
+const maxSubrNesting = 10
+
+// subrRounds is the number of passes of the subroutinizer.  Every pass
+// increases the nesting depth of subroutine calls by at most one, so this
+// must not exceed maxSubrNesting.
+const subrRounds = 4
+
+// Estimated sizes used when deciding whether a fragment should be turned
+// into a subroutine:
+//   - A call uses one or two bytes for the subroutine number, plus one byte
+//     for the callsubr or callgsubr operator.
+//   - A subroutine uses one byte for the final return operator, plus up to
+//     two bytes in the offset array of the INDEX.
+//
+// If a fragment of n bytes occurs k times, moving it into a subroutine
+// saves approximately n*k - (3*k + n + 3) bytes.
+const (
+	subrCallCost     = 3
+	subrOverheadCost = 3
+)
+
+// subroutinize moves repeated fragments of the charstrings cc into
+// subroutines.  The function fdSelect gives the private dictionary used by
+// each glyph, and numFD is the number of private dictionaries.  The function
+// returns the new charstrings, the global subroutines, and the local
+// subroutines for each private dictionary.
+//
+// Charstrings are only split at instruction boundaries, where an
+// instruction is an operator together with its arguments.  Thus the
+// argument stack is empty whenever a subroutine is called or returns, and a
+// hintmask operator is never separated from its mask bytes.  The final
+// endchar operator of each charstring is never moved into a subroutine.
+//
+// Subroutines which are used by glyphs from more than one private
+// dictionary are stored in the global subrs INDEX, all other subroutines
+// are stored in the local subrs INDEX of their private dictionary.
+func subroutinize(cc cffIndex, fdSelect FDSelectFn, numFD int) (cffIndex, cffIndex, []cffIndex) {
+	s := &subroutinizer{
+		numGlyphs: len(cc),
+		tails:     make([][]byte, len(cc)),
+	}
+	tokenID := make(map[string]int32)
+	for i, code := range cc {
+		instr, tail, ok := splitCharString(code)
+		if !ok {
+			return cc, nil, make([]cffIndex, numFD)
+		}
+		s.tails[i] = tail
+		seq := make([]int32, len(instr))
+		for j, b := range instr {
+			id, seen := tokenID[string(b)]
+			if !seen {
+				id = int32(len(s.instr))
+				tokenID[string(b)] = id
+				s.instr = append(s.instr, b)
+			}
+			seq[j] = id
+		}
+		s.seqs = append(s.seqs, seq)
+	}
+	s.numBase = len(s.instr)
+
+	for range subrRounds {
+		if !s.round() {
+			break
+		}
+	}
+	s.inlineSingleUse()
+
+	newCC, gsubrs, lsubrs := s.encode(fdSelect, numFD)
+
+	oldSize := 0
+	for _, code := range cc {
+		oldSize += len(code)
+	}
+	newSize := 0
+	for _, code := range newCC {
+		newSize += len(code)
+	}
+	for _, subr := range gsubrs {
+		newSize += len(subr) + 2
+	}
+	for _, subrs := range lsubrs {
+		for _, subr := range subrs {
+			newSize += len(subr) + 2
+		}
+	}
+	if newSize >= oldSize {
+		return cc, nil, make([]cffIndex, numFD)
+	}
+	return newCC, gsubrs, lsubrs
+}
+
+// subroutinizer holds the state of the subroutinization pass.
+//
+// Charstrings are represented as sequences of tokens.  Tokens below numBase
+// represent instructions from the original charstrings, token numBase+j
+// represents a call to subroutine j.
+type subroutinizer struct {
+	instr     [][]byte // instructions, indexed by token
+	numBase   int
+	numGlyphs int
+
+	// seqs contains the charstrings of the glyphs, followed by the bodies
+	// of the subroutines.
+	seqs [][]int32
+
+	// tails contains the final endchar instruction of each glyph, or nil.
+	tails [][]byte
+}
+
+func (s *subroutinizer) cost(tok int32) int {
+	if tok < 0 {
+		return 0
+	}
+	if int(tok) < s.numBase {
+		return len(s.instr[tok])
+	}
+	return subrCallCost
+}
+
+// round performs one pass of the subroutinizer.  Repeated fragments are
+// found using a suffix array of all current charstrings and subroutine
+// bodies, and are then greedily turned into new subroutines.
+// The function returns false if no new subroutines were created.
+func (s *subroutinizer) round() bool {
+	// Concatenate all sequences.  The separators are negative and unique,
+	// so that no repeated fragment can extend across sequence boundaries.
+	var text []int32
+	starts := make([]int, len(s.seqs))
+	for i, seq := range s.seqs {
+		starts[i] = len(text)
+		text = append(text, seq...)
+		text = append(text, -1-int32(i))
+	}
+	n := len(text)
+	prefix := make([]int, n+1)
+	for i, tok := range text {
+		prefix[i+1] = prefix[i] + s.cost(tok)
+	}
+
+	sa := suffixArray(text)
+	lcp := lcpArray(text, sa)
+
+	type candidate struct {
+		lb, rb, length int
+		savings        int
+	}
+	var cands []candidate
+	addCandidate := func(length, lb, rb int) {
+		k := rb - lb + 1
+		p := int(sa[lb])
+		size := prefix[p+length] - prefix[p]
+		savings := k*size - (k*subrCallCost + size + subrOverheadCost)
+		if savings > 0 {
+			cands = append(cands, candidate{lb, rb, length, savings})
+		}
+	}
+
+	// Enumerate the lcp-intervals, which correspond to the internal nodes
+	// of the suffix tree.
+	type interval struct{ lcp, lb int }
+	stack := []interval{{0, 0}}
+	for i := 1; i <= n; i++ {
+		l := 0
+		if i < n {
+			l = int(lcp[i])
+		}
+		lb := i - 1
+		for l < stack[len(stack)-1].lcp {
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			addCandidate(top.lcp, top.lb, i-1)
+			lb = top.lb
+		}
+		if l > stack[len(stack)-1].lcp {
+			stack = append(stack, interval{l, lb})
+		}
+	}
+
+	slices.SortStableFunc(cands, func(a, b candidate) int {
+		if c := cmp.Compare(b.savings, a.savings); c != 0 {
+			return c
+		}
+		return cmp.Compare(b.length, a.length)
+	})
+
+	numSubrs := len(s.seqs) - s.numGlyphs
+	covered := make([]bool, n)
+	callAt := make(map[int]int32)
+	var bodies [][]int32
+	var occ []int
+candidateLoop:
+	for _, c := range cands {
+		if numSubrs+len(bodies) >= 65535 {
+			break
+		}
+
+		occ = occ[:0]
+		for _, p := range sa[c.lb : c.rb+1] {
+			occ = append(occ, int(p))
+		}
+		slices.Sort(occ)
+		size := prefix[occ[0]+c.length] - prefix[occ[0]]
+
+		// Occurrences which overlap fragments already chosen in this round
+		// are skipped.
+		var use []int
+	occLoop:
+		for _, p := range occ {
+			for j := p; j < p+c.length; j++ {
+				if covered[j] {
+					continue occLoop
+				}
+			}
+			if len(use) > 0 && use[len(use)-1]+c.length > p {
+				continue
+			}
+			use = append(use, p)
+		}
+		k := len(use)
+		if k*size-(k*subrCallCost+size+subrOverheadCost) <= 0 {
+			continue candidateLoop
+		}
+
+		tok := int32(s.numBase + numSubrs + len(bodies))
+		for _, p := range use {
+			for j := p; j < p+c.length; j++ {
+				covered[j] = true
+			}
+			callAt[p] = tok
+		}
+		bodies = append(bodies, slices.Clone(text[use[0]:use[0]+c.length]))
+	}
+	if len(bodies) == 0 {
+		return false
+	}
+
+	for i, seq := range s.seqs {
+		var res []int32
+		for j := 0; j < len(seq); {
+			p := starts[i] + j
+			if tok, ok := callAt[p]; ok {
+				res = append(res, tok)
+				j += len(bodies[int(tok)-s.numBase-numSubrs])
+				continue
+			}
+			res = append(res, seq[j])
+			j++
+		}
+		s.seqs[i] = res
+	}
+	s.seqs = append(s.seqs, bodies...)
+	return true
+}
+
+// inlineSingleUse replaces calls to subroutines which are used only once
+// by the body of the subroutine.  Such subroutines can remain after later
+// rounds have moved the calling fragments into new subroutines.
+func (s *subroutinizer) inlineSingleUse() {
+	numSubrs := len(s.seqs) - s.numGlyphs
+	for {
+		uses := make([]int, numSubrs)
+		for _, seq := range s.seqs {
+			for _, tok := range seq {
+				if int(tok) >= s.numBase {
+					uses[int(tok)-s.numBase]++
+				}
+			}
+		}
+
+		changed := false
+		for i, seq := range s.seqs {
+			if !slices.ContainsFunc(seq, func(tok int32) bool {
+				return int(tok) >= s.numBase && uses[int(tok)-s.numBase] == 1
+			}) {
+				continue
+			}
+			var res []int32
+			for _, tok := range seq {
+				j := int(tok) - s.numBase
+				if j >= 0 && uses[j] == 1 && s.numGlyphs+j != i {
+					res = append(res, s.seqs[s.numGlyphs+j]...)
+					uses[j] = 0
+					changed = true
+				} else {
+					res = append(res, tok)
+				}
+			}
+			s.seqs[i] = res
+		}
+		if !changed {
+			break
+		}
+	}
+}
+
+// encode converts the token sequences back into charstrings and assigns the
+// subroutines to the global and local subrs INDEXes.  Unused subroutines
+// are omitted.
+func (s *subroutinizer) encode(fdSelect FDSelectFn, numFD int) (cffIndex, cffIndex, []cffIndex) {
+	numSubrs := len(s.seqs) - s.numGlyphs
+
+	// Find the private dictionaries from which each subroutine is used.
+	const (
+		unused   = -1
+		multiple = -2
+	)
+	fd := make([]int, numSubrs)
+	uses := make([]int, numSubrs)
+	for j := range fd {
+		fd[j] = unused
+	}
+	var mark func(j, d int)
+	mark = func(j, d int) {
+		switch {
+		case fd[j] == unused:
+			fd[j] = d
+		case fd[j] != d && fd[j] != multiple:
+			fd[j] = multiple
+		default:
+			return
+		}
+		for _, tok := range s.seqs[s.numGlyphs+j] {
+			if int(tok) >= s.numBase {
+				mark(int(tok)-s.numBase, fd[j])
+			}
+		}
+	}
+	for i, seq := range s.seqs {
+		for _, tok := range seq {
+			if int(tok) < s.numBase {
+				continue
+			}
+			j := int(tok) - s.numBase
+			uses[j]++
+			if i < s.numGlyphs {
+				d := 0
+				if fdSelect != nil {
+					d = fdSelect(glyph.ID(i))
+				}
+				mark(j, d)
+			}
+		}
+	}
+
+	// Frequently used subroutines get small numbers, which can be encoded
+	// more compactly.
+	groups := make([][]int, numFD+1) // the last group is for gsubrs
+	for j := range numSubrs {
+		switch fd[j] {
+		case unused:
+			continue
+		case multiple:
+			groups[numFD] = append(groups[numFD], j)
+		default:
+			groups[fd[j]] = append(groups[fd[j]], j)
+		}
+	}
+	callCode := make([][]byte, numSubrs)
+	for g, group := range groups {
+		slices.SortStableFunc(group, func(a, b int) int {
+			return cmp.Compare(uses[b], uses[a])
+		})
+		bias := subrBias(len(group))
+		op := t2callsubr
+		if g == numFD {
+			op = t2callgsubr
+		}
+		for idx, j := range group {
+			callCode[j] = append(encodeInt(funit.Int16(idx-bias)), op.Bytes()...)
+		}
+	}
+
+	encodeSeq := func(seq []int32) []byte {
+		var code []byte
+		for _, tok := range seq {
+			if int(tok) < s.numBase {
+				code = append(code, s.instr[tok]...)
+			} else {
+				code = append(code, callCode[int(tok)-s.numBase]...)
+			}
+		}
+		return code
+	}
+
+	cc := make(cffIndex, s.numGlyphs)
+	for i := range s.numGlyphs {
+		cc[i] = append(encodeSeq(s.seqs[i]), s.tails[i]...)
+	}
+	subrs := make([]cffIndex, numFD+1)
+	for g, group := range groups {
+		for _, j := range group {
+			body := encodeSeq(s.seqs[s.numGlyphs+j])
+			subrs[g] = append(subrs[g], append(body, t2return.Bytes()...))
+		}
+	}
+	return cc, subrs[numFD], subrs[:numFD]
+}
+
+// subrBias returns the bias used for subroutine numbers, if a subrs INDEX
+// contains n subroutines.
+func subrBias(n int) int {
+	switch {
+	case n < 1240:
+		return 107
+	case n < 33900:
+		return 1131
+	default:
+		return 32768
+	}
+}
+
+// splitCharString splits a Type 2 charstring into instructions, where
+// each instruction consists of the arguments, the operator, and (for the
+// hintmask and cntrmask operators) the mask bytes.  The final endchar
+// instruction, if any, is returned separately as tail.  The last return
+// value is false, if the charstring contains subroutine calls or cannot be
+// parsed.
+func splitCharString(code []byte) (instr [][]byte, tail []byte, ok bool) {
+	nArgs := 0
+	nStems := 0
+	start := 0
+	pos := 0
+	for pos < len(code) {
+		b0 := code[pos]
+		switch {
+		case b0 == 28:
+			pos += 3
+			nArgs++
+			continue
+		case b0 >= 32 && b0 <= 246:
+			pos++
+			nArgs++
+			continue
+		case b0 >= 247 && b0 <= 254:
+			pos += 2
+			nArgs++
+			continue
+		case b0 == 255:
+			pos += 5
+			nArgs++
+			continue
+		}
+
+		op := t2op(b0)
+		pos++
+		if b0 == 12 {
+			if pos >= len(code) {
+				return nil, nil, false
+			}
+			op = op<<8 | t2op(code[pos])
+			pos++
+		}
+		switch op {
+		case t2callsubr, t2callgsubr, t2return:
+			return nil, nil, false
+		case t2endchar:
+			if pos != len(code) {
+				return nil, nil, false
+			}
+			return instr, code[start:], true
+		case t2hstem, t2vstem, t2hstemhm, t2vstemhm:
+			nStems += nArgs / 2
+		case t2hintmask, t2cntrmask:
+			nStems += nArgs / 2
+			pos += (nStems + 7) / 8
+		}
+		if pos > len(code) {
+			return nil, nil, false
+		}
+		instr = append(instr, code[start:pos])
+		start = pos
+		nArgs = 0
+	}
+	if start != len(code) {
+		return nil, nil, false
+	}
+	return instr, nil, true
+}
+
+// suffixArray returns the suffix array of text, computed using prefix
+// doubling.
+func suffixArray(text []int32) []int32 {
+	n := len(text)
+	sa := make([]int32, n)
+	for i := range sa {
+		sa[i] = int32(i)
+	}
+	if n == 0 {
+		return sa
+	}
+	slices.SortFunc(sa, func(a, b int32) int {
+		return cmp.Compare(text[a], text[b])
+	})
+	rank := make([]int32, n)
+	for i := 1; i < n; i++ {
+		rank[sa[i]] = rank[sa[i-1]]
+		if text[sa[i]] != text[sa[i-1]] {
+			rank[sa[i]]++
+		}
+	}
+
+	tmp := make([]int32, n)
+	for k := 1; int(rank[sa[n-1]]) < n-1; k *= 2 {
+		second := func(i int32) int32 {
+			if int(i)+k < n {
+				return rank[int(i)+k]
+			}
+			return -1
+		}
+		slices.SortFunc(sa, func(a, b int32) int {
+			if c := cmp.Compare(rank[a], rank[b]); c != 0 {
+				return c
+			}
+			return cmp.Compare(second(a), second(b))
+		})
+		tmp[sa[0]] = 0
+		for i := 1; i < n; i++ {
+			tmp[sa[i]] = tmp[sa[i-1]]
+			if rank[sa[i]] != rank[sa[i-1]] || second(sa[i]) != second(sa[i-1]) {
+				tmp[sa[i]]++
+			}
+		}
+		copy(rank, tmp)
+	}
+	return sa
+}
+
+// lcpArray computes the longest common prefix array for the given suffix
+// array, using Kasai's algorithm.  Entry i gives the length of the longest
+// common prefix of the suffixes sa[i-1] and sa[i].
+func lcpArray(text []int32, sa []int32) []int32 {
+	n := len(text)
+	rank := make([]int32, n)
+	for i, p := range sa {
+		rank[p] = int32(i)
+	}
+	lcp := make([]int32, n)
+	h := 0
+	for i := range n {
+		if rank[i] == 0 {
+			h = 0
+			continue
+		}
+		j := int(sa[rank[i]-1])
+		for i+h < n && j+h < n && text[i+h] == text[j+h] {
+			h++
+		}
+		lcp[rank[i]] = int32(h)
+		if h > 0 {
+			h--
+		}
+	}
+	return lcp
+}
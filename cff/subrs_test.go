@@ -0,0 +1,258 @@
+// seehuhn.de/go/sfnt - a library for reading and writing font files
+// Copyright (C) 2026  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package cff
+
+import (
+	"bytes"
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"seehuhn.de/go/geom/matrix"
+	"seehuhn.de/go/postscript/cid"
+	"seehuhn.de/go/postscript/funit"
+	"seehuhn.de/go/postscript/type1"
+
+	"seehuhn.de/go/sfnt/glyph"
+	"seehuhn.de/go/sfnt/parser"
+)
+
+// makeSubrsTestFont returns a font where all glyphs share some outline
+// fragments, and where the glyphs for each private dictionary share
+// additional fragments.  If numFD > 1, the font is CID-keyed.
+func makeSubrsTestFont(numFD int) *Font {
+	const numGlyphs = 60
+
+	fdSelect := func(gid glyph.ID) int { return int(gid) % numFD }
+	info := &type1.FontInfo{
+		FontName:   "Test",
+		FontMatrix: defaultFontMatrix,
+	}
+	outlines := &Outlines{
+		FDSelect: fdSelect,
+	}
+	for range numFD {
+		outlines.Private = append(outlines.Private, &type1.PrivateDict{
+			BlueValues: []funit.Int16{-10, 0, 500, 510},
+			BlueScale:  0.039625,
+			BlueShift:  7,
+			BlueFuzz:   1,
+		})
+	}
+	if numFD > 1 {
+		outlines.ROS = &cid.SystemInfo{Registry: "Adobe", Ordering: "Identity"}
+		outlines.GIDToCID = make([]cid.CID, numGlyphs)
+		for i := range outlines.GIDToCID {
+			outlines.GIDToCID[i] = cid.CID(i)
+		}
+		outlines.FontMatrices = make([]matrix.Matrix, numFD)
+		for i := range outlines.FontMatrices {
+			outlines.FontMatrices[i] = matrix.Identity
+		}
+	}
+
+	for gid := range numGlyphs {
+		g := &Glyph{
+			Width: float64(500 + 100*(gid%3)),
+		}
+		if numFD == 1 {
+			g.Name = "g" + string(rune('A'+gid%26)) + string(rune('a'+gid/26))
+			if gid == 0 {
+				g.Name = ".notdef"
+			}
+		}
+		if gid > 0 {
+			d := fdSelect(glyph.ID(gid))
+			x := float64(10 * (gid % 7))
+			g.HStem = []float64{0, 20, 480, 500}
+			g.VStem = []float64{x + 50, x + 80, x + 200, x + 230}
+			g.Cmds = append(g.Cmds, GlyphOp{Op: OpHintMask, Args: []float64{0xE0}})
+
+			// a fragment shared by all glyphs
+			g.MoveTo(x+50, 0)
+			g.LineTo(x+80, 0)
+			g.LineTo(x+80, 200)
+			g.CurveTo(x+100, 250, x+150, 250, x+200, 200)
+			g.LineTo(x+200, 0)
+			g.LineTo(x+230, 0)
+			g.LineTo(x+230, 500)
+			g.LineTo(x+50, 500)
+
+			g.Cmds = append(g.Cmds, GlyphOp{Op: OpHintMask, Args: []float64{0x90}})
+
+			// a fragment shared by the glyphs of one private dictionary
+			y := float64(20 * (gid % 5))
+			g.MoveTo(x+300, y)
+			step := float64(15 + 10*d)
+			for i := range 6 {
+				g.LineTo(x+300+float64(i+1)*step, y+step*float64(i%2))
+			}
+			g.LineTo(x+300, y+200)
+
+			// a fragment unique to this glyph
+			g.MoveTo(float64(gid), float64(3*gid))
+			g.LineTo(float64(gid+13), float64(3*gid+7))
+			g.LineTo(float64(gid), float64(3*gid+17))
+		}
+		outlines.Glyphs = append(outlines.Glyphs, g)
+	}
+	if numFD == 1 {
+		outlines.Encoding = StandardEncoding(outlines.Glyphs)
+	}
+
+	return &Font{FontInfo: info, Outlines: outlines}
+}
+
+func TestSubroutinize(t *testing.T) {
+	for _, numFD := range []int{1, 3} {
+		in := makeSubrsTestFont(numFD)
+
+		plain := &bytes.Buffer{}
+		err := in.Write(plain)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		in.Subroutinize = true
+		subr := &bytes.Buffer{}
+		err = in.Write(subr)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if subr.Len() >= plain.Len() {
+			t.Errorf("%d FDs: subroutinized size %d >= plain size %d",
+				numFD, subr.Len(), plain.Len())
+		}
+
+		out, err := Read(bytes.NewReader(subr.Bytes()), parser.NewBudget(int64(subr.Len())))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(in.Glyphs, out.Glyphs); diff != "" {
+			t.Errorf("%d FDs: glyphs differ (-want +got):\n%s", numFD, diff)
+		}
+	}
+}
+
+func TestSubroutinizeGlobalLocal(t *testing.T) {
+	const numFD = 3
+	f := makeSubrsTestFont(numFD)
+	cc, _, _, err := f.encodeCharStrings()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newCC, gsubrs, lsubrs := subroutinize(cc, f.FDSelect, numFD)
+	if len(newCC) != len(cc) {
+		t.Fatalf("got %d charstrings, want %d", len(newCC), len(cc))
+	}
+	if len(gsubrs) == 0 {
+		t.Error("no global subroutines")
+	}
+	if len(lsubrs) != numFD {
+		t.Fatalf("got %d local subrs INDEXes, want %d", len(lsubrs), numFD)
+	}
+	for i, subrs := range lsubrs {
+		if len(subrs) == 0 {
+			t.Errorf("no local subroutines for FD %d", i)
+		}
+	}
+}
+
+func TestSplitCharString(t *testing.T) {
+	g := &Glyph{
+		Width: 600,
+		HStem: []float64{0, 20, 480, 500},
+		VStem: []float64{50, 80},
+	}
+	g.Cmds = append(g.Cmds, GlyphOp{Op: OpHintMask, Args: []float64{0xC0}})
+	g.MoveTo(50, 0)
+	g.LineTo(80, 0)
+	g.Cmds = append(g.Cmds, GlyphOp{Op: OpHintMask, Args: []float64{0x20}})
+	g.LineTo(80, 500)
+	code, err := g.encodeCharString(0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	instr, tail, ok := splitCharString(code)
+	if !ok {
+		t.Fatal("splitCharString failed")
+	}
+	if !bytes.Equal(tail, t2endchar.Bytes()) {
+		t.Errorf("tail = % x, want % x", tail, t2endchar.Bytes())
+	}
+	if joined := append(bytes.Join(instr, nil), tail...); !bytes.Equal(joined, code) {
+		t.Errorf("instructions % x do not add up to % x", instr, code)
+	}
+
+	// Every instruction must end with an operator, or with the mask bytes
+	// of a hintmask operator.
+	var ops []byte
+	for _, b := range instr {
+		last := b[len(b)-1]
+		if len(b) >= 2 && b[len(b)-2] == byte(t2hintmask) {
+			last = b[len(b)-2]
+		}
+		ops = append(ops, last)
+	}
+	want := []byte{
+		byte(t2hstemhm), byte(t2hintmask), byte(t2hmoveto),
+		byte(t2hlineto), byte(t2hintmask), byte(t2vlineto),
+	}
+	if !slices.Equal(ops, want) {
+		t.Errorf("operators = %v, want %v", ops, want)
+	}
+}
+
+func TestSuffixArray(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for range 100 {
+		n := rng.Intn(50)
+		text := make([]int32, n)
+		for i := range text {
+			text[i] = int32(rng.Intn(4)) - 1
+		}
+
+		want := make([]int32, n)
+		for i := range want {
+			want[i] = int32(i)
+		}
+		slices.SortFunc(want, func(a, b int32) int {
+			return slices.Compare(text[a:], text[b:])
+		})
+		sa := suffixArray(text)
+		if !slices.Equal(sa, want) {
+			t.Fatalf("suffixArray(%v) = %v, want %v", text, sa, want)
+		}
+
+		lcp := lcpArray(text, sa)
+		for i := 1; i < n; i++ {
+			a, b := text[sa[i-1]:], text[sa[i]:]
+			l := 0
+			for l < len(a) && l < len(b) && a[l] == b[l] {
+				l++
+			}
+			if int(lcp[i]) != l {
+				t.Fatalf("lcp[%d] = %d, want %d", i, lcp[i], l)
+			}
+		}
+	}
+}
@@ -30,7 +30,9 @@ import (
 // The returned outlines share the private dictionaries and glyph data with the
 // original font.
 func (o *Outlines) Subset(glyphs []glyph.ID) *Outlines {
-	subset := &Outlines{}
+	subset := &Outlines{
+		Subroutinize: o.Subroutinize,
+	}
 
 	// transfer the glyphs
 	subset.Glyphs = make([]*Glyph, len(glyphs))
@@ -32,6 +32,13 @@ func (f *Font) Write(w io.Writer) error {
 		return err
 	}
 
+	numFonts := len(f.Private)
+	var gsubrs cffIndex
+	lsubrs := make([]cffIndex, numFonts)
+	if f.Subroutinize {
+		charStrings, gsubrs, lsubrs = subroutinize(charStrings, f.FDSelect, numFonts)
+	}
+
 	var blobs [][]byte
 	strings := &cffStrings{}
 
@@ -76,7 +83,7 @@ func (f *Font) Write(w io.Writer) error {
 	blobs = append(blobs, nil)
 
 	// section 4: global subr INDEX
-	blobs = append(blobs, cffIndex{}.encode())
+	blobs = append(blobs, gsubrs.encode())
 
 	// section 5: encodings
 	secEncodings := -1
@@ -133,7 +140,6 @@ func (f *Font) Write(w io.Writer) error {
 	blobs = append(blobs, charStrings.encode())
 
 	// section 9: font DICT INDEX
-	numFonts := len(f.Private)
 	fontDicts := make([]cffDict, numFonts)
 	if f.ROS != nil {
 		for i := range fontDicts {
@@ -157,10 +163,15 @@ func (f *Font) Write(w io.Writer) error {
 		blobs = append(blobs, nil)
 	}
 
-	// section 11: subrs INDEX
-	// TODO(voss): only write this section, if subroutines are present?
-	secSubrsIndex := len(blobs)
-	blobs = append(blobs, cffIndex{}.encode())
+	// section 11: local subrs INDEXes
+	secSubrsIndex := make([]int, numFonts)
+	for i, subrs := range lsubrs {
+		secSubrsIndex[i] = -1
+		if len(subrs) > 0 {
+			secSubrsIndex[i] = len(blobs)
+			blobs = append(blobs, subrs.encode())
+		}
+	}
 
 	numSections := len(blobs)
 
@@ -182,8 +193,9 @@ func (f *Font) Write(w io.Writer) error {
 		var fontDictIndex cffIndex
 		for i := range numFonts {
 			secPrivateDict := secPrivateDicts[i]
-			// TODO(voss): only write this key, if subroutines are present?
-			privateDicts[i][opSubrs] = []any{offs[secSubrsIndex] - offs[secPrivateDict]}
+			if secSubrs := secSubrsIndex[i]; secSubrs >= 0 {
+				privateDicts[i][opSubrs] = []any{offs[secSubrs] - offs[secPrivateDict]}
+			}
 			blobs[secPrivateDict] = privateDicts[i].encode(strings)
 			pdSize := len(blobs[secPrivateDict])
 			pdDesc := []any{int32(pdSize), offs[secPrivateDict]}
@@ -294,26 +306,6 @@ func (f *Font) encodeCharStrings() (cffIndex, float64, float64, error) {
 		return nil, 0, 0, invalidSince("missing .notdef glyph")
 	}
 
-	// TODO(voss): re-introduce subroutines.
-	//
-	// Size used for a subroutine:
-	//   - an entry in the subrs and gsubrs INDEX takes
-	//     up to 4 bytes, plus the size of the subroutine
-	//   - the subrouting must finish with t2return
-	//     or t2endchar (1 byte)
-	//   - calling the subroutine uses k+1 bytes, where
-	//     k=1 for the first 215 subroutines of each type, and
-	//     k=2 for the next 2048 subroutines of each type.
-	// An approximation could be the following:
-	//   - if n bytes occur k times, this uses n*k bytes
-	//   - if the n bytes are turned into a subroutine, this uses
-	//     approximately k*2 + n + 3 or k*3 + n + 4 bytes.
-	//   - the savings are n*k - k*2 - n - 3 = (n-2)*(k-1)-5
-	//     or n*k - k*3 - n - 4 = (n-3)*(k-1)-7 bytes.
-	//
-	// http://www.allisons.org/ll/AlgDS/Tree/Suffix/
-	// https://stackoverflow.com/questions/9452701/ukkonens-suffix-tree-algorithm-in-plain-english
-
 	cc := make(cffIndex, numGlyphs)
 	defaultWidth, nominalWidth := f.selectWidths()
 	for i, glyph := range f.Glyphs {
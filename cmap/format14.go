@@ -0,0 +1,229 @@
+// seehuhn.de/go/sfnt - a library for reading and writing font files
+// Copyright (C) 2026  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package cmap
+
+import (
+	"cmp"
+	"errors"
+	"maps"
+	"slices"
+
+	"seehuhn.de/go/sfnt/glyph"
+)
+
+// VariationSequence is a Unicode variation sequence, consisting of a base
+// character followed by a variation selector.
+type VariationSequence struct {
+	Base     rune
+	Selector rune
+}
+
+// IsVariationSelector reports whether r is a Unicode variation selector.
+func IsVariationSelector(r rune) bool {
+	return r >= 0x180B && r <= 0x180D || r == 0x180F || // Mongolian
+		r >= 0xFE00 && r <= 0xFE0F ||
+		r >= 0xE0100 && r <= 0xE01EF
+}
+
+// Format14 represents a format 14 cmap subtable, which maps Unicode
+// variation sequences to glyphs.
+// https://learn.microsoft.com/en-us/typography/opentype/spec/cmap#format-14-unicode-variation-sequences
+type Format14 struct {
+	// Default lists the variation sequences which are displayed using the
+	// glyph of the base character from the Unicode cmap subtable.
+	Default map[VariationSequence]bool
+
+	// NonDefault maps variation sequences to glyphs.
+	NonDefault map[VariationSequence]glyph.ID
+}
+
+// GetFormat14 decodes the Unicode variation sequences subtable of the cmap
+// table.
+func (ss Table) GetFormat14() (*Format14, error) {
+	data, ok := ss[Key{PlatformID: 0, EncodingID: 5}]
+	if !ok {
+		return nil, errors.New("cmap: no such subtable")
+	}
+	return decodeFormat14(data)
+}
+
+func decodeFormat14(data []byte) (*Format14, error) {
+	if len(data) < 10 || data[0] != 0 || data[1] != 14 {
+		return nil, errMalformedSubtable
+	}
+
+	numRecords := uint32(data[6])<<24 | uint32(data[7])<<16 | uint32(data[8])<<8 | uint32(data[9])
+	if uint64(numRecords)*11 > uint64(len(data)-10) {
+		return nil, errMalformedSubtable
+	}
+
+	u24 := func(b []byte) rune {
+		return rune(b[0])<<16 | rune(b[1])<<8 | rune(b[2])
+	}
+	u32 := func(b []byte) uint32 {
+		return uint32(b[0])<<24 | uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3])
+	}
+
+	res := &Format14{
+		Default:    make(map[VariationSequence]bool),
+		NonDefault: make(map[VariationSequence]glyph.ID),
+	}
+	size := 0
+	var prevSelector rune
+	for i := range int(numRecords) {
+		base := 10 + i*11
+		selector := u24(data[base:])
+		if i > 0 && selector <= prevSelector {
+			return nil, errMalformedSubtable
+		}
+		prevSelector = selector
+
+		if o := u32(data[base+3:]); o != 0 {
+			if o > uint32(len(data)-4) {
+				return nil, errMalformedSubtable
+			}
+			n := u32(data[o:])
+			if uint64(n)*4 > uint64(len(data))-uint64(o)-4 {
+				return nil, errMalformedSubtable
+			}
+			for j := range n {
+				p := o + 4 + j*4
+				start := u24(data[p:])
+				count := rune(data[p+3])
+				size += int(count) + 1
+				if start+count > 0x10FFFF || size > 65536 {
+					// avoid excessive memory allocation from malformed
+					// subtables
+					return nil, errMalformedSubtable
+				}
+				for r := start; r <= start+count; r++ {
+					res.Default[VariationSequence{r, selector}] = true
+				}
+			}
+		}
+
+		if o := u32(data[base+7:]); o != 0 {
+			if o > uint32(len(data)-4) {
+				return nil, errMalformedSubtable
+			}
+			n := u32(data[o:])
+			if uint64(n)*5 > uint64(len(data))-uint64(o)-4 {
+				return nil, errMalformedSubtable
+			}
+			for j := range n {
+				p := o + 4 + j*5
+				r := u24(data[p:])
+				gid := glyph.ID(data[p+3])<<8 | glyph.ID(data[p+4])
+				res.NonDefault[VariationSequence{r, selector}] = gid
+			}
+		}
+	}
+
+	return res, nil
+}
+
+// Lookup returns the glyph for a variation sequence.  The argument cmap must
+// be the Unicode cmap subtable of the font, which is used for sequences
+// which are displayed using the default glyph.  The second return value is
+// false, if the sequence is not contained in the subtable.
+func (f *Format14) Lookup(seq VariationSequence, cmap Subtable) (glyph.ID, bool) {
+	if gid, ok := f.NonDefault[seq]; ok {
+		return gid, true
+	}
+	if f.Default[seq] && cmap != nil {
+		return cmap.Lookup(seq.Base), true
+	}
+	return 0, false
+}
+
+// Encode returns the binary form of the subtable.
+func (f *Format14) Encode() []byte {
+	selectors := make(map[rune]bool)
+	for seq := range f.Default {
+		selectors[seq.Selector] = true
+	}
+	for seq := range f.NonDefault {
+		selectors[seq.Selector] = true
+	}
+	sortedSelectors := slices.Sorted(maps.Keys(selectors))
+
+	numRecords := len(sortedSelectors)
+	out := make([]byte, 10+numRecords*11)
+	out[1] = 14
+	out[6] = byte(numRecords >> 24)
+	out[7] = byte(numRecords >> 16)
+	out[8] = byte(numRecords >> 8)
+	out[9] = byte(numRecords)
+
+	put24 := func(b []byte, x rune) {
+		b[0], b[1], b[2] = byte(x>>16), byte(x>>8), byte(x)
+	}
+	put32 := func(b []byte, x uint32) {
+		b[0], b[1], b[2], b[3] = byte(x>>24), byte(x>>16), byte(x>>8), byte(x)
+	}
+
+	for i, selector := range sortedSelectors {
+		rec := 10 + i*11
+		put24(out[rec:], selector)
+
+		var defaults []rune
+		for seq := range f.Default {
+			if seq.Selector == selector {
+				defaults = append(defaults, seq.Base)
+			}
+		}
+		if len(defaults) > 0 {
+			slices.Sort(defaults)
+			put32(out[rec+3:], uint32(len(out)))
+			countPos := len(out)
+			out = append(out, 0, 0, 0, 0)
+			numRanges := 0
+			for j := 0; j < len(defaults); {
+				k := j + 1
+				for k < len(defaults) && k-j < 256 && defaults[k] == defaults[k-1]+1 {
+					k++
+				}
+				out = append(out, byte(defaults[j]>>16), byte(defaults[j]>>8), byte(defaults[j]), byte(k-j-1))
+				numRanges++
+				j = k
+			}
+			put32(out[countPos:], uint32(numRanges))
+		}
+
+		var mappings []VariationSequence
+		for seq := range f.NonDefault {
+			if seq.Selector == selector {
+				mappings = append(mappings, seq)
+			}
+		}
+		if len(mappings) > 0 {
+			slices.SortFunc(mappings, func(a, b VariationSequence) int {
+				return cmp.Compare(a.Base, b.Base)
+			})
+			put32(out[rec+7:], uint32(len(out)))
+			out = append(out, 0, 0, 0, 0)
+			put32(out[len(out)-4:], uint32(len(mappings)))
+			for _, seq := range mappings {
+				gid := f.NonDefault[seq]
+				out = append(out, byte(seq.Base>>16), byte(seq.Base>>8), byte(seq.Base), byte(gid>>8), byte(gid))
+			}
+		}
+	}
+
+	put32(out[2:], uint32(len(out)))
+	return out
+}
@@ -0,0 +1,111 @@
+// seehuhn.de/go/sfnt - a library for reading and writing font files
+// Copyright (C) 2026  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package cmap
+
+import (
+	"reflect"
+	"testing"
+
+	"seehuhn.de/go/sfnt/glyph"
+)
+
+func TestFormat14(t *testing.T) {
+	uni := Format4{'A': 1, 0x8FBB: 2}
+	f := &Format14{
+		Default: map[VariationSequence]bool{
+			{'A', 0xFE00}:     true,
+			{'B', 0xFE00}:     true,
+			{'C', 0xFE00}:     true,
+			{0x8FBB, 0xE0100}: true,
+		},
+		NonDefault: map[VariationSequence]glyph.ID{
+			{'A', 0xFE01}:     3,
+			{0x8FBB, 0xE0101}: 4,
+		},
+	}
+
+	data := f.Encode()
+	g, err := decodeFormat14(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(f, g) {
+		t.Errorf("round trip failed: %v != %v", f, g)
+	}
+
+	cases := []struct {
+		seq VariationSequence
+		gid glyph.ID
+		ok  bool
+	}{
+		{VariationSequence{'A', 0xFE00}, 1, true},
+		{VariationSequence{'A', 0xFE01}, 3, true},
+		{VariationSequence{'A', 0xFE02}, 0, false},
+		{VariationSequence{0x8FBB, 0xE0100}, 2, true},
+		{VariationSequence{0x8FBB, 0xE0101}, 4, true},
+		{VariationSequence{'D', 0xFE00}, 0, false},
+	}
+	for _, c := range cases {
+		gid, ok := g.Lookup(c.seq, uni)
+		if gid != c.gid || ok != c.ok {
+			t.Errorf("Lookup(%v) = %d, %t, want %d, %t", c.seq, gid, ok, c.gid, c.ok)
+		}
+	}
+}
+
+func TestIsVariationSelector(t *testing.T) {
+	for _, r := range []rune{0x180B, 0x180F, 0xFE00, 0xFE0F, 0xE0100, 0xE01EF} {
+		if !IsVariationSelector(r) {
+			t.Errorf("%04X is a variation selector", r)
+		}
+	}
+	for _, r := range []rune{'A', 0x180E, 0xFDFF, 0xFE10, 0xE00FF, 0xE01F0} {
+		if IsVariationSelector(r) {
+			t.Errorf("%04X is not a variation selector", r)
+		}
+	}
+}
+
+func FuzzFormat14(f *testing.F) {
+	f.Add((&Format14{}).Encode())
+	f.Add((&Format14{
+		Default: map[VariationSequence]bool{
+			{'A', 0xFE00}: true,
+			{'B', 0xFE00}: true,
+		},
+		NonDefault: map[VariationSequence]glyph.ID{
+			{'A', 0xFE01}: 3,
+		},
+	}).Encode())
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		c1, err := decodeFormat14(data)
+		if err != nil {
+			return
+		}
+
+		data2 := c1.Encode()
+		c2, err := decodeFormat14(data2)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(c1, c2) {
+			t.Error("not equal")
+		}
+	})
+}
@@ -61,7 +61,7 @@ var decoders = map[uint16]func([]byte, func(int) rune) (Subtable, error){
 	10: notImplemented, // TODO(voss): implement
 	12: decodeFormat12,
 	13: notImplemented, // TODO(voss): implement
-	14: notImplemented, // TODO(voss): implement
+	14: notImplemented, // not a Subtable, see Table.GetFormat14
 }
 
 func notImplemented([]byte, func(int) rune) (Subtable, error) {
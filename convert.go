@@ -0,0 +1,392 @@
+// seehuhn.de/go/sfnt - a library for reading and writing font files
+// Copyright (C) 2026  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package sfnt
+
+import (
+	"math"
+
+	"seehuhn.de/go/geom/matrix"
+	"seehuhn.de/go/geom/path"
+	"seehuhn.de/go/geom/vec"
+	"seehuhn.de/go/postscript/funit"
+	"seehuhn.de/go/postscript/type1"
+
+	"seehuhn.de/go/sfnt/cff"
+	"seehuhn.de/go/sfnt/glyf"
+	"seehuhn.de/go/sfnt/glyph"
+	"seehuhn.de/go/sfnt/maxp"
+)
+
+// ConvertToCFF returns a copy of the font, where TrueType glyph outlines
+// are replaced by CFF outlines.  If the font already uses CFF outlines,
+// the font is returned unchanged.
+//
+// Quadratic curves are converted to cubic curves, composite glyphs are
+// flattened, and the contour direction is reversed to follow the CFF
+// conventions.  TrueType instructions are dropped, and the values in the
+// private dictionary are estimated from the glyph shapes.  Coordinates are
+// rounded to integers.
+func (f *Font) ConvertToCFF() *Font {
+	o, ok := f.Outlines.(*glyf.Outlines)
+	if !ok {
+		return f
+	}
+
+	names := f.MakeGlyphNames()
+	glyphs := make([]*cff.Glyph, len(o.Glyphs))
+	for i := range o.Glyphs {
+		gid := glyph.ID(i)
+		g := &cff.Glyph{Name: names[gid]}
+		if o.Widths != nil {
+			g.Width = float64(o.Widths[gid])
+		}
+		for _, c := range getContours(o.Path(gid)) {
+			c.reverse().appendCFF(g)
+		}
+		glyphs[gid] = g
+	}
+
+	outlines := &cff.Outlines{
+		Glyphs:   glyphs,
+		Private:  []*type1.PrivateDict{f.makePrivateDict()},
+		FDSelect: func(glyph.ID) int { return 0 },
+	}
+	outlines.MakeSimple(nil)
+
+	res := f.Clone()
+	res.Outlines = outlines
+	return res
+}
+
+// ConvertToGlyf returns a copy of the font, where CFF glyph outlines are
+// replaced by TrueType outlines.  If the font already uses TrueType outlines,
+// the font is returned unchanged.
+//
+// Cubic curves are approximated by quadratic curves, such that the distance
+// between the original and the approximating curve is at most tolerance
+// font design units.  If tolerance is not positive, a value of 1 is used.
+// The contour direction is reversed to follow the TrueType conventions.
+// Hints are dropped, and the glyph names are stored in the "post" table.
+func (f *Font) ConvertToGlyf(tolerance float64) *Font {
+	o, ok := f.Outlines.(*cff.Outlines)
+	if !ok {
+		return f
+	}
+	if tolerance <= 0 {
+		tolerance = 1
+	}
+
+	upem := float64(f.UnitsPerEm)
+	n := len(o.Glyphs)
+	glyphs := make(glyf.Glyphs, n)
+	widths := make([]funit.Uint16, n)
+	var names []string
+	info := &maxp.TTFInfo{
+		MaxZones: 1,
+	}
+	for i, cg := range o.Glyphs {
+		gid := glyph.ID(i)
+		widths[gid] = funit.Uint16(math.Round(max(f.GlyphWidthPDF(gid)*upem/1000, 0)))
+		if cg.Name != "" {
+			if names == nil {
+				names = make([]string, n)
+			}
+			names[gid] = cg.Name
+		}
+
+		// Transform the outlines to font design units, where there
+		// are UnitsPerEm units per em.
+		M := o.GlyphMatrix(f.FontMatrix, gid).Scale(upem, upem)
+		var contours []glyf.Contour
+		numPoints := 0
+		for _, c := range getContours(o.Path(gid).Transform(M)) {
+			cc := c.reverse().ttContour(tolerance)
+			if len(cc) < 2 {
+				continue
+			}
+			contours = append(contours, cc)
+			numPoints += len(cc)
+		}
+		if contours == nil {
+			continue
+		}
+		g := (&glyf.SimpleUnpacked{Contours: contours}).AsGlyph()
+		glyphs[gid] = &g
+		info.MaxPoints = max(info.MaxPoints, uint16(min(numPoints, math.MaxUint16)))
+		info.MaxContours = max(info.MaxContours, uint16(min(len(contours), math.MaxUint16)))
+	}
+
+	res := f.Clone()
+	res.Outlines = &glyf.Outlines{
+		Glyphs: glyphs,
+		Widths: widths,
+		Names:  names,
+		Tables: map[string][]byte{},
+		Maxp:   info,
+	}
+	res.FontMatrix = matrix.Matrix{1 / upem, 0, 0, 1 / upem, 0, 0}
+	return res
+}
+
+// makePrivateDict estimates the values for a CFF private dictionary
+// from the shapes of a few reference glyphs.
+func (f *Font) makePrivateDict() *type1.PrivateDict {
+	priv := &type1.PrivateDict{
+		BlueScale: 0.039625,
+		BlueShift: 7,
+		BlueFuzz:  1,
+	}
+
+	cmap, err := f.CMapTable.GetBest()
+	if err != nil {
+		return priv
+	}
+	bbox := func(r rune) (funit.Rect16, bool) {
+		gid := cmap.Lookup(r)
+		if gid == 0 {
+			return funit.Rect16{}, false
+		}
+		b := f.GlyphBBox(gid)
+		return b, !b.IsZero()
+	}
+
+	// The alignment zones extend from the flat edges of glyphs like "x"
+	// and "H" to the overshoot of round glyphs like "o" and "O".
+	var blues []funit.Int16
+	if o, ok := bbox('o'); ok {
+		blues = append(blues, min(o.LLy, 0), 0)
+		if x := f.XHeight; x > 0 && o.URy >= x {
+			blues = append(blues, x, o.URy)
+		}
+	}
+	if o, ok := bbox('O'); ok && len(blues) > 0 {
+		if c := f.CapHeight; c > 0 && o.URy >= c && c > blues[len(blues)-1] {
+			blues = append(blues, c, o.URy)
+		}
+	}
+	priv.BlueValues = blues
+
+	// The stem widths are taken from "l" and the hyphen.
+	if l, ok := bbox('l'); ok {
+		priv.StdVW = float64(l.Dx())
+	}
+	if h, ok := bbox('-'); ok {
+		priv.StdHW = float64(h.Dy())
+	}
+
+	return priv
+}
+
+// A contour is a closed sub-path of a glyph outline.
+type contour struct {
+	start vec.Vec2
+	segs  []segment
+}
+
+// A segment is a part of a contour.  The last point in pts is the end
+// point, the remaining points are control points.
+type segment struct {
+	cmd path.Command
+	pts []vec.Vec2
+}
+
+func (s segment) end() vec.Vec2 {
+	return s.pts[len(s.pts)-1]
+}
+
+// getContours splits a path into contours.  All contours are closed
+// explicitly, so that the last segment ends at the start point.
+func getContours(p path.Path) []*contour {
+	var res []*contour
+	var cur *contour
+	finish := func() {
+		if cur == nil {
+			return
+		}
+		if len(cur.segs) > 0 {
+			if cur.segs[len(cur.segs)-1].end() != cur.start {
+				cur.segs = append(cur.segs, segment{cmd: path.CmdLineTo, pts: []vec.Vec2{cur.start}})
+			}
+			res = append(res, cur)
+		}
+		cur = nil
+	}
+	for cmd, pts := range p {
+		switch cmd {
+		case path.CmdMoveTo:
+			finish()
+			cur = &contour{start: pts[0]}
+		case path.CmdClose:
+			finish()
+		default:
+			if cur == nil {
+				continue
+			}
+			cur.segs = append(cur.segs, segment{cmd: cmd, pts: append([]vec.Vec2(nil), pts...)})
+		}
+	}
+	finish()
+	return res
+}
+
+// reverse returns a new contour which traverses the same curve in the
+// opposite direction.
+func (c *contour) reverse() *contour {
+	res := &contour{start: c.start}
+	for i := len(c.segs) - 1; i >= 0; i-- {
+		s := c.segs[i]
+		var from vec.Vec2
+		if i > 0 {
+			from = c.segs[i-1].end()
+		} else {
+			from = c.start
+		}
+		pts := make([]vec.Vec2, 0, len(s.pts))
+		for k := len(s.pts) - 2; k >= 0; k-- {
+			pts = append(pts, s.pts[k])
+		}
+		pts = append(pts, from)
+		res.segs = append(res.segs, segment{cmd: s.cmd, pts: pts})
+	}
+	return res
+}
+
+// appendCFF appends the contour to a CFF glyph.  Quadratic segments are
+// converted to cubic ones.
+func (c *contour) appendCFF(g *cff.Glyph) {
+	r := func(v vec.Vec2) (float64, float64) {
+		return math.Round(v.X), math.Round(v.Y)
+	}
+	g.MoveTo(r(c.start))
+	cur := c.start
+	for i, s := range c.segs {
+		switch s.cmd {
+		case path.CmdLineTo:
+			if i == len(c.segs)-1 {
+				// CFF closes contours automatically.
+				break
+			}
+			g.LineTo(r(s.pts[0]))
+		case path.CmdQuadTo:
+			c1 := cur.Add(s.pts[0].Sub(cur).Mul(2.0 / 3))
+			c2 := s.pts[1].Add(s.pts[0].Sub(s.pts[1]).Mul(2.0 / 3))
+			x1, y1 := r(c1)
+			x2, y2 := r(c2)
+			x3, y3 := r(s.pts[1])
+			g.CurveTo(x1, y1, x2, y2, x3, y3)
+		case path.CmdCubeTo:
+			x1, y1 := r(s.pts[0])
+			x2, y2 := r(s.pts[1])
+			x3, y3 := r(s.pts[2])
+			g.CurveTo(x1, y1, x2, y2, x3, y3)
+		}
+		cur = s.end()
+	}
+}
+
+// ttContour converts the contour to a TrueType contour.  Cubic segments
+// are approximated by quadratic segments, with an error of at most tol.
+func (c *contour) ttContour(tol float64) glyf.Contour {
+	var pts glyf.Contour
+	add := func(v vec.Vec2, onCurve bool) {
+		p := glyf.Point{
+			X:       funit.Int16(math.Round(v.X)),
+			Y:       funit.Int16(math.Round(v.Y)),
+			OnCurve: onCurve,
+		}
+		if onCurve && len(pts) > 0 && pts[len(pts)-1] == p {
+			return // skip zero-length segments
+		}
+		pts = append(pts, p)
+	}
+
+	add(c.start, true)
+	cur := c.start
+	for _, s := range c.segs {
+		switch s.cmd {
+		case path.CmdLineTo:
+			add(s.pts[0], true)
+		case path.CmdQuadTo:
+			add(s.pts[0], false)
+			add(s.pts[1], true)
+		case path.CmdCubeTo:
+			for _, q := range cubicToQuadratic(cur, s.pts[0], s.pts[1], s.pts[2], tol) {
+				add(q[0], false)
+				add(q[1], true)
+			}
+		}
+		cur = s.end()
+	}
+	if n := len(pts); n > 1 && pts[n-1] == pts[0] {
+		pts = pts[:n-1]
+	}
+
+	// Remove on-curve points which are implied by the neighbouring
+	// off-curve points.
+	n := len(pts)
+	res := pts[:0:0]
+	for i, p := range pts {
+		if i > 0 && p.OnCurve {
+			prev, next := pts[i-1], pts[(i+1)%n]
+			if !prev.OnCurve && !next.OnCurve &&
+				2*p.X == prev.X+next.X && 2*p.Y == prev.Y+next.Y {
+				continue
+			}
+		}
+		res = append(res, p)
+	}
+	return res
+}
+
+// cubicToQuadratic approximates a cubic Bézier curve by a sequence of
+// quadratic curves.  The result lists the control point and the end point
+// for each quadratic curve.
+func cubicToQuadratic(p0, p1, p2, p3 vec.Vec2, tol float64) [][2]vec.Vec2 {
+	// For the best single quadratic approximation, the error is at most
+	// sqrt(3)/36 * |p3 - 3*p2 + 3*p1 - p0|.  When the curve is split into
+	// n pieces, the error decreases like 1/n^3.
+	d := p3.Sub(p2.Mul(3)).Add(p1.Mul(3)).Sub(p0)
+	err := math.Sqrt(3) / 36 * d.Length()
+	n := 1
+	if err > tol {
+		n = min(int(math.Ceil(math.Cbrt(err/tol))), 64)
+	}
+
+	// blossom evaluates the polar form of the cubic.
+	blossom := func(t1, t2, t3 float64) vec.Vec2 {
+		lerp := func(a, b vec.Vec2, t float64) vec.Vec2 {
+			return a.Add(b.Sub(a).Mul(t))
+		}
+		a := lerp(p0, p1, t1)
+		b := lerp(p1, p2, t1)
+		c := lerp(p2, p3, t1)
+		return lerp(lerp(a, b, t2), lerp(b, c, t2), t3)
+	}
+
+	res := make([][2]vec.Vec2, n)
+	for i := range n {
+		t0 := float64(i) / float64(n)
+		t1 := float64(i+1) / float64(n)
+		q0 := blossom(t0, t0, t0)
+		q1 := blossom(t0, t0, t1)
+		q2 := blossom(t0, t1, t1)
+		q3 := blossom(t1, t1, t1)
+		ctrl := q1.Add(q2).Mul(3).Sub(q0).Sub(q3).Mul(0.25)
+		res[i] = [2]vec.Vec2{ctrl, q3}
+	}
+	return res
+}
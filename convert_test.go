@@ -0,0 +1,222 @@
+// seehuhn.de/go/sfnt - a library for reading and writing font files
+// Copyright (C) 2026  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package sfnt
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"golang.org/x/image/font/gofont/goregular"
+
+	"seehuhn.de/go/geom/path"
+	"seehuhn.de/go/geom/rect"
+	"seehuhn.de/go/geom/vec"
+
+	"seehuhn.de/go/sfnt/cff"
+	"seehuhn.de/go/sfnt/glyf"
+	"seehuhn.de/go/sfnt/glyph"
+	"seehuhn.de/go/sfnt/parser"
+)
+
+func TestConvertOutlines(t *testing.T) {
+	orig, err := Read(bytes.NewReader(goregular.TTF), parser.NewBudget(int64(len(goregular.TTF))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmap, err := orig.CMapTable.GetBest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gidO := cmap.Lookup('o')
+
+	otf := roundTrip(t, orig.ConvertToCFF())
+	cffOutlines, ok := otf.Outlines.(*cff.Outlines)
+	if !ok {
+		t.Fatalf("wrong outline type %T", otf.Outlines)
+	}
+	if name := cffOutlines.Glyphs[gidO].Name; name != "o" {
+		t.Errorf("wrong glyph name %q", name)
+	}
+	if cffOutlines.Private[0].StdVW <= 0 || len(cffOutlines.Private[0].BlueValues) == 0 {
+		t.Errorf("private dictionary not set: %v", cffOutlines.Private[0])
+	}
+	// CFF outer contours are counter-clockwise.
+	if a := area(flatten(otf.Outlines.Path(gidO))[:1]); a <= 0 {
+		t.Errorf("wrong contour direction for CFF: %g", a)
+	}
+	compareWidths(t, orig, otf)
+	compareOutlines(t, orig, otf)
+
+	ttf := roundTrip(t, otf.ConvertToGlyf(0.5))
+	glyfOutlines, ok := ttf.Outlines.(*glyf.Outlines)
+	if !ok {
+		t.Fatalf("wrong outline type %T", ttf.Outlines)
+	}
+	if len(glyfOutlines.Names) != ttf.NumGlyphs() || glyfOutlines.Names[gidO] != "o" {
+		t.Error("glyph names not preserved")
+	}
+	if glyfOutlines.Maxp == nil || glyfOutlines.Maxp.MaxPoints == 0 {
+		t.Error("maxp not set")
+	}
+	// TrueType outer contours are clockwise.
+	if a := area(flatten(ttf.Outlines.Path(gidO))[:1]); a >= 0 {
+		t.Errorf("wrong contour direction for TrueType: %g", a)
+	}
+	compareWidths(t, otf, ttf)
+	compareOutlines(t, otf, ttf)
+}
+
+// roundTrip writes the font to a buffer and reads it back.
+func roundTrip(t *testing.T, f *Font) *Font {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	_, err := f.Write(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := Read(bytes.NewReader(buf.Bytes()), parser.NewBudget(int64(buf.Len())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+func compareWidths(t *testing.T, a, b *Font) {
+	t.Helper()
+	if a.NumGlyphs() != b.NumGlyphs() {
+		t.Fatalf("glyph count %d != %d", a.NumGlyphs(), b.NumGlyphs())
+	}
+	for i := range a.NumGlyphs() {
+		gid := glyph.ID(i)
+		if wa, wb := a.GlyphWidth(gid), b.GlyphWidth(gid); wa != wb {
+			t.Errorf("glyph %d: width %g != %g", gid, wa, wb)
+		}
+	}
+}
+
+// compareOutlines checks that the glyphs have approximately the same
+// extent and enclosed area.  Since the conversion reverses the contour
+// direction, the areas have opposite signs.
+func compareOutlines(t *testing.T, a, b *Font) {
+	t.Helper()
+	for i := range a.NumGlyphs() {
+		gid := glyph.ID(i)
+		ca, cb := flatten(a.Outlines.Path(gid)), flatten(b.Outlines.Path(gid))
+
+		ba, bb := extent(ca), extent(cb)
+		if math.Abs(ba.LLx-bb.LLx) > 1 || math.Abs(ba.LLy-bb.LLy) > 1 ||
+			math.Abs(ba.URx-bb.URx) > 1 || math.Abs(ba.URy-bb.URy) > 1 {
+			t.Errorf("glyph %d: extent %v != %v", gid, ba, bb)
+		}
+
+		areaA, areaB := area(ca), area(cb)
+		if math.Abs(areaA+areaB) > 3e-3*math.Abs(areaA)+10 {
+			t.Errorf("glyph %d: area %g != %g", gid, areaA, -areaB)
+		}
+	}
+}
+
+// flatten approximates every curve in a path by 16 straight line segments.
+// The result contains one polygon for every contour.
+func flatten(p path.Path) [][]vec.Vec2 {
+	var res [][]vec.Vec2
+	for cmd, pts := range p.ToCubic() {
+		switch cmd {
+		case path.CmdMoveTo:
+			res = append(res, []vec.Vec2{pts[0]})
+		case path.CmdLineTo:
+			res[len(res)-1] = append(res[len(res)-1], pts[0])
+		case path.CmdCubeTo:
+			poly := res[len(res)-1]
+			p0 := poly[len(poly)-1]
+			for k := 1; k <= 16; k++ {
+				u := float64(k) / 16
+				poly = append(poly, p0.Mul((1-u)*(1-u)*(1-u)).
+					Add(pts[0].Mul(3*(1-u)*(1-u)*u)).
+					Add(pts[1].Mul(3*(1-u)*u*u)).
+					Add(pts[2].Mul(u*u*u)))
+			}
+			res[len(res)-1] = poly
+		}
+	}
+	return res
+}
+
+// area returns the signed area enclosed by the polygons.
+func area(polys [][]vec.Vec2) float64 {
+	res := 0.0
+	for _, poly := range polys {
+		for i, p := range poly {
+			q := poly[(i+1)%len(poly)]
+			res += p.X*q.Y - q.X*p.Y
+		}
+	}
+	return res / 2
+}
+
+// extent returns the bounding box of the polygons.
+func extent(polys [][]vec.Vec2) rect.Rect {
+	var res rect.Rect
+	first := true
+	for _, poly := range polys {
+		for _, p := range poly {
+			if first {
+				res = rect.Rect{LLx: p.X, LLy: p.Y, URx: p.X, URy: p.Y}
+				first = false
+			}
+			res.LLx = min(res.LLx, p.X)
+			res.LLy = min(res.LLy, p.Y)
+			res.URx = max(res.URx, p.X)
+			res.URy = max(res.URy, p.Y)
+		}
+	}
+	return res
+}
+
+func TestCubicToQuadratic(t *testing.T) {
+	p0 := vec.Vec2{X: 0, Y: 0}
+	p1 := vec.Vec2{X: 0, Y: 100}
+	p2 := vec.Vec2{X: 100, Y: 100}
+	p3 := vec.Vec2{X: 100, Y: 0}
+	for _, tol := range []float64{10, 1, 0.1} {
+		quads := cubicToQuadratic(p0, p1, p2, p3, tol)
+		if end := quads[len(quads)-1][1]; end != p3 {
+			t.Errorf("wrong end point %v", end)
+		}
+
+		// compare the curves at a few points
+		n := len(quads)
+		start := p0
+		for i, q := range quads {
+			for _, s := range []float64{0.25, 0.5, 0.75} {
+				u := (float64(i) + s) / float64(n)
+				cubic := p0.Mul((1 - u) * (1 - u) * (1 - u)).
+					Add(p1.Mul(3 * (1 - u) * (1 - u) * u)).
+					Add(p2.Mul(3 * (1 - u) * u * u)).
+					Add(p3.Mul(u * u * u))
+				quad := start.Mul((1 - s) * (1 - s)).
+					Add(q[0].Mul(2 * (1 - s) * s)).
+					Add(q[1].Mul(s * s))
+				if d := cubic.Sub(quad).Length(); d > tol {
+					t.Errorf("tol %g: error %g at %g", tol, d, u)
+				}
+			}
+			start = q[1]
+		}
+	}
+}
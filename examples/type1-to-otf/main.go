@@ -164,6 +164,8 @@ func readType1(fname string, afm *afm.Metrics) (*sfnt.Font, error) {
 		Private:  []*type1.PrivateDict{t1Info.Private},
 		FDSelect: func(glyph.ID) int { return 0 },
 		Encoding: encoding,
+
+		Subroutinize: true,
 	}
 
 	width := os2.WidthNormal // TODO(voss)
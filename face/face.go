@@ -0,0 +1,226 @@
+// seehuhn.de/go/sfnt - a library for reading and writing font files
+// Copyright (C) 2026  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package face implements the [font.Face] interface for [sfnt.Font] values.
+//
+// This allows to render text using a [font.Drawer].  Glyph outlines are
+// rendered using the [seehuhn.de/go/sfnt/raster] package, and kerning
+// information is taken from the GPOS table of the font.  For fonts with a
+// "kern" table, the kerning pairs are converted to GPOS lookups when the
+// font is read.
+package face
+
+import (
+	"image"
+	"math"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/text/language"
+
+	"seehuhn.de/go/sfnt"
+	"seehuhn.de/go/sfnt/cmap"
+	"seehuhn.de/go/sfnt/glyph"
+	"seehuhn.de/go/sfnt/raster"
+)
+
+// Options specifies how a font is rendered.
+type Options struct {
+	// Size is the font size in points.  If this is zero, 12 is used.
+	Size float64
+
+	// DPI is the resolution in dots per inch.  If this is zero, 72 is used.
+	DPI float64
+
+	// Hinting selects how glyph metrics are rounded.  If this is not
+	// [font.HintingNone], advance widths, kerning values and the font
+	// metrics are rounded to whole pixels.  Glyph outlines are not
+	// grid-fitted.
+	Hinting font.Hinting
+}
+
+// Face renders the glyphs of a font at a given size.
+//
+// Like all [font.Face] implementations, a Face is not safe for concurrent
+// use.
+type Face struct {
+	font    *sfnt.Font
+	cmap    cmap.Subtable
+	layout  *sfnt.Layouter
+	cache   *raster.Cache
+	ppem    float64
+	hinting font.Hinting
+}
+
+var _ font.Face = (*Face)(nil)
+
+// New returns a new font face for the given font.
+// If opt is nil, default options are used.
+func New(f *sfnt.Font, opt *Options) (*Face, error) {
+	if opt == nil {
+		opt = &Options{}
+	}
+	size := opt.Size
+	if size == 0 {
+		size = 12
+	}
+	dpi := opt.DPI
+	if dpi == 0 {
+		dpi = 72
+	}
+
+	cmap, err := f.CMapTable.GetBest()
+	if err != nil {
+		return nil, err
+	}
+	// Only kerning is applied; glyph substitutions are not used, since
+	// [font.Face] maps individual runes to glyphs.
+	layout, err := f.NewLayouter(language.Und, map[string]bool{}, map[string]bool{"kern": true})
+	if err != nil {
+		return nil, err
+	}
+
+	ppem := size * dpi / 72
+	return &Face{
+		font:    f,
+		cmap:    cmap,
+		layout:  layout,
+		cache:   raster.NewCache(f.Outlines, f.FontMatrix, ppem),
+		ppem:    ppem,
+		hinting: opt.Hinting,
+	}, nil
+}
+
+// Close implements the [font.Face] interface.
+func (fc *Face) Close() error {
+	return nil
+}
+
+// Glyph implements the [font.Face] interface.
+func (fc *Face) Glyph(dot fixed.Point26_6, r rune) (dr image.Rectangle, mask image.Image, maskp image.Point, advance fixed.Int26_6, ok bool) {
+	gid := fc.cmap.Lookup(r)
+	if gid == 0 {
+		return image.Rectangle{}, nil, image.Point{}, 0, false
+	}
+
+	x := float64(dot.X) / 64
+	y := float64(dot.Y) / 64
+	m, pos := fc.cache.Glyph(gid, x, y)
+	return m.Rect.Add(pos), m, m.Rect.Min, fc.advance(gid), true
+}
+
+// GlyphBounds implements the [font.Face] interface.
+func (fc *Face) GlyphBounds(r rune) (bounds fixed.Rectangle26_6, advance fixed.Int26_6, ok bool) {
+	gid := fc.cmap.Lookup(r)
+	if gid == 0 {
+		return fixed.Rectangle26_6{}, 0, false
+	}
+
+	bbox := fc.font.Outlines.GlyphBBoxPDF(fc.font.FontMatrix, gid)
+	if !bbox.IsZero() {
+		// PDF glyph space uses 1000 units per em, with the y-axis
+		// pointing up.
+		s := fc.ppem / 1000 * 64
+		bounds = fixed.Rectangle26_6{
+			Min: fixed.Point26_6{
+				X: fixed.Int26_6(math.Floor(bbox.LLx * s)),
+				Y: fixed.Int26_6(math.Floor(-bbox.URy * s)),
+			},
+			Max: fixed.Point26_6{
+				X: fixed.Int26_6(math.Ceil(bbox.URx * s)),
+				Y: fixed.Int26_6(math.Ceil(-bbox.LLy * s)),
+			},
+		}
+	}
+	return bounds, fc.advance(gid), true
+}
+
+// GlyphAdvance implements the [font.Face] interface.
+func (fc *Face) GlyphAdvance(r rune) (advance fixed.Int26_6, ok bool) {
+	gid := fc.cmap.Lookup(r)
+	if gid == 0 {
+		return 0, false
+	}
+	return fc.advance(gid), true
+}
+
+// Kern implements the [font.Face] interface.
+// The result is the change of the advance width of the glyph for r0,
+// caused by the GPOS "kern" feature when r1 follows.
+func (fc *Face) Kern(r0, r1 rune) fixed.Int26_6 {
+	gid0 := fc.cmap.Lookup(r0)
+	if gid0 == 0 || fc.cmap.Lookup(r1) == 0 {
+		return 0
+	}
+
+	seq := fc.layout.Layout(string([]rune{r0, r1}))
+	if len(seq) != 2 || seq[0].GID != gid0 {
+		return 0
+	}
+	upem := float64(fc.font.UnitsPerEm)
+	base := fc.font.GlyphWidthPDF(gid0) * upem / 1000
+	delta := float64(seq[0].Advance) - math.Round(base)
+	return fc.scale(delta / upem)
+}
+
+// Metrics implements the [font.Face] interface.
+//
+// The ascent, descent and line gap are taken from the Ascent, Descent and
+// LineGap fields of the font.  When a font is read, these are set from the
+// typographic metrics in the "OS/2" table, if present.  The implementation
+// in golang.org/x/image/font/opentype uses the "hhea" table instead, which
+// for many fonts gives a larger ascent and descent and no line gap.  For Go
+// Regular at 24 pixels per em, the ascent is 18.5 pixels here and 22.7
+// pixels there, while the line height is the same in both cases.
+func (fc *Face) Metrics() font.Metrics {
+	f := fc.font
+	upem := float64(f.UnitsPerEm)
+	em := func(v float64) fixed.Int26_6 {
+		return fc.scale(v / upem)
+	}
+
+	ascent := em(float64(f.Ascent))
+	descent := em(-float64(f.Descent))
+	lineGap := em(float64(f.LineGap))
+
+	angle := f.ItalicAngle * math.Pi / 180
+	return font.Metrics{
+		Height:    ascent + descent + lineGap,
+		Ascent:    ascent,
+		Descent:   descent,
+		XHeight:   em(float64(f.XHeight)),
+		CapHeight: em(float64(f.CapHeight)),
+		CaretSlope: image.Point{
+			X: int(math.Round(-1000 * math.Sin(angle))),
+			Y: int(math.Round(1000 * math.Cos(angle))),
+		},
+	}
+}
+
+// advance returns the advance width of a glyph.
+func (fc *Face) advance(gid glyph.ID) fixed.Int26_6 {
+	return fc.scale(fc.font.GlyphWidthPDF(gid) / 1000)
+}
+
+// scale converts a length in em units to 26.6 pixel units.
+// If hinting is enabled, the result is rounded to whole pixels.
+func (fc *Face) scale(v float64) fixed.Int26_6 {
+	px := v * fc.ppem
+	if fc.hinting != font.HintingNone {
+		return fixed.Int26_6(math.Round(px) * 64)
+	}
+	return fixed.Int26_6(math.Round(px * 64))
+}
@@ -0,0 +1,202 @@
+// seehuhn.de/go/sfnt - a library for reading and writing font files
+// Copyright (C) 2026  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package face
+
+import (
+	"bytes"
+	"image"
+	"testing"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goitalic"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+
+	"seehuhn.de/go/sfnt"
+	"seehuhn.de/go/sfnt/opentype/gtab/builder"
+	"seehuhn.de/go/sfnt/parser"
+)
+
+func readFont(t *testing.T, data []byte) *sfnt.Font {
+	t.Helper()
+	f, err := sfnt.Read(bytes.NewReader(data), parser.NewBudget(int64(len(data))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+// TestCompare compares the metrics with the ones from the font.Face
+// implementation in golang.org/x/image/font/opentype.
+func TestCompare(t *testing.T) {
+	f := readFont(t, goregular.TTF)
+	ref, err := opentype.Parse(goregular.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, hinting := range []font.Hinting{font.HintingNone, font.HintingFull} {
+		fc, err := New(f, &Options{Size: 11, DPI: 96, Hinting: hinting})
+		if err != nil {
+			t.Fatal(err)
+		}
+		refFace, err := opentype.NewFace(ref, &opentype.FaceOptions{Size: 11, DPI: 96, Hinting: hinting})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The ascent and descent differ, since sfnt.Font uses the
+		// typographic values from the "OS/2" table, while the reference
+		// implementation uses the "hhea" table.
+		m, refM := fc.Metrics(), refFace.Metrics()
+		if abs(m.Height-refM.Height) > 1 ||
+			abs(m.XHeight-refM.XHeight) > 1 || abs(m.CapHeight-refM.CapHeight) > 1 {
+			t.Errorf("hinting %d: metrics %v, expected %v", hinting, m, refM)
+		}
+
+		for _, r := range "AVago,1é" {
+			adv, ok := fc.GlyphAdvance(r)
+			refAdv, refOK := refFace.GlyphAdvance(r)
+			if !ok || !refOK {
+				t.Fatalf("missing glyph for %q", r)
+			}
+			if abs(adv-refAdv) > 1 {
+				t.Errorf("hinting %d, %q: advance %d, expected %d", hinting, r, adv, refAdv)
+			}
+
+			bounds, adv2, ok := fc.GlyphBounds(r)
+			refBounds, _, _ := refFace.GlyphBounds(r)
+			if !ok || adv2 != adv {
+				t.Errorf("%q: inconsistent advance", r)
+			}
+			if hinting == font.HintingNone && !near(bounds, refBounds) {
+				t.Errorf("%q: bounds %v, expected %v", r, bounds, refBounds)
+			}
+		}
+	}
+}
+
+func TestMissing(t *testing.T) {
+	fc, err := New(readFont(t, goregular.TTF), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const r = '\U0001F600'
+	if _, ok := fc.GlyphAdvance(r); ok {
+		t.Error("GlyphAdvance: unexpected glyph")
+	}
+	if _, _, ok := fc.GlyphBounds(r); ok {
+		t.Error("GlyphBounds: unexpected glyph")
+	}
+	if _, _, _, _, ok := fc.Glyph(fixed.Point26_6{}, r); ok {
+		t.Error("Glyph: unexpected glyph")
+	}
+}
+
+func TestKern(t *testing.T) {
+	f := readFont(t, goregular.TTF)
+	gpos, err := builder.ParseInfo(f, `
+	GPOS2: "AV" -> dx-100
+	feature kern: 0
+	script DFLT dflt: 0
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Gpos = gpos
+
+	fc, err := New(f, &Options{Size: float64(f.UnitsPerEm) / 64})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// At this size, one font design unit is 1/64 pixel.
+	if k := fc.Kern('A', 'V'); k != -100 {
+		t.Errorf("Kern(A, V) = %d, expected -100", k)
+	}
+	if k := fc.Kern('V', 'A'); k != 0 {
+		t.Errorf("Kern(V, A) = %d, expected 0", k)
+	}
+}
+
+func TestItalic(t *testing.T) {
+	fc, err := New(readFont(t, goitalic.TTF), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slope := fc.Metrics().CaretSlope
+	if slope.X <= 0 || slope.Y <= 0 {
+		t.Errorf("unexpected caret slope %v", slope)
+	}
+}
+
+// TestDrawer renders a string using a font.Drawer.
+func TestDrawer(t *testing.T) {
+	fc, err := New(readFont(t, goregular.TTF), &Options{Size: 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img := image.NewAlpha(image.Rect(0, 0, 200, 40))
+	d := &font.Drawer{
+		Dst:  img,
+		Src:  image.Opaque,
+		Face: fc,
+		Dot:  fixed.P(10, 30),
+	}
+	bounds, _ := d.BoundString("Hello")
+	d.DrawString("Hello")
+
+	var ink image.Rectangle
+	for y := img.Rect.Min.Y; y < img.Rect.Max.Y; y++ {
+		for x := img.Rect.Min.X; x < img.Rect.Max.X; x++ {
+			if img.AlphaAt(x, y).A > 0 {
+				ink = ink.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	if ink.Empty() {
+		t.Fatal("no ink")
+	}
+	outer := image.Rect(bounds.Min.X.Floor()-1, bounds.Min.Y.Floor()-1,
+		bounds.Max.X.Ceil()+1, bounds.Max.Y.Ceil()+1)
+	if !ink.In(outer) {
+		t.Errorf("ink %v outside of bounds %v", ink, outer)
+	}
+
+	// The advance of the drawer matches the sum of the glyph advances.
+	var total fixed.Int26_6
+	for _, r := range "Hello" {
+		adv, _ := fc.GlyphAdvance(r)
+		total += adv
+	}
+	if d.Dot.X != fixed.I(10)+total {
+		t.Errorf("dot at %d, expected %d", d.Dot.X, fixed.I(10)+total)
+	}
+}
+
+func near(a, b fixed.Rectangle26_6) bool {
+	return abs(a.Min.X-b.Min.X) <= 2 && abs(a.Min.Y-b.Min.Y) <= 2 &&
+		abs(a.Max.X-b.Max.X) <= 2 && abs(a.Max.Y-b.Max.Y) <= 2
+}
+
+func abs(x fixed.Int26_6) fixed.Int26_6 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,486 @@
+// seehuhn.de/go/sfnt - a library for reading and writing font files
+// Copyright (C) 2026  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package autohint adds TrueType instructions to glyf outlines.
+//
+// The autohinter works in the spirit of ttfautohint: glyphs are assigned to
+// scripts using the cmap table, and for each script the alignment zones
+// (baseline, x-height, cap-height, ascender and descender) are measured
+// from a set of reference characters.  The outline of every simple glyph is
+// then analysed for horizontal edges and stems.  The generated glyph
+// programs align edges to the alignment zones, keep the widths of stems
+// consistent, and interpolate all remaining points.
+//
+// Only the vertical direction is hinted.  This gives good results with
+// modern rasterizers, which use anti-aliasing or subpixel rendering in the
+// horizontal direction.
+package autohint
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"unicode"
+
+	"seehuhn.de/go/sfnt/cmap"
+	"seehuhn.de/go/sfnt/glyf"
+	"seehuhn.de/go/sfnt/glyf/ttasm"
+	"seehuhn.de/go/sfnt/maxp"
+)
+
+// Hint adds TrueType instructions to the simple glyphs in o.
+//
+// The "fpgm", "prep", "cvt " and "gasp" tables in o.Tables, and the
+// instructions of all simple glyphs are replaced.  Instructions are removed
+// from composite glyphs; their components are hinted individually.  The maxp
+// information in o.Maxp is updated.
+//
+// The argument unitsPerEm gives the number of font design units per em.
+// The cmap subtable is used to assign glyphs to scripts and to find the
+// reference characters for the alignment zones.  If cmap is nil, all glyphs
+// are hinted without alignment zones.
+func Hint(o *glyf.Outlines, unitsPerEm uint16, cmap cmap.Subtable) error {
+	if unitsPerEm == 0 {
+		return errors.New("autohint: invalid unitsPerEm")
+	}
+	upem := float64(unitsPerEm)
+
+	// unpack all simple glyphs
+	outlines := make([]*glyf.SimpleUnpacked, len(o.Glyphs))
+	for gid, g := range o.Glyphs {
+		if g == nil {
+			continue
+		}
+		if cg, ok := g.Data.(glyf.CompositeGlyph); ok {
+			o.Glyphs[gid] = stripComposite(g, cg)
+			continue
+		}
+		sg, ok := g.Data.(glyf.SimpleGlyph)
+		if !ok {
+			continue
+		}
+		u, err := sg.Unpack()
+		if err != nil {
+			return fmt.Errorf("autohint: glyph %d: %w", gid, err)
+		}
+		outlines[gid] = u
+	}
+
+	// Assign glyphs to scripts, and measure the alignment zones.
+	glyphScript := make([]int, len(o.Glyphs))
+	if cmap != nil {
+		assigned := make([]bool, len(o.Glyphs))
+		low, high := cmap.CodeRange()
+		for r := low; r <= high; r++ {
+			gid := cmap.Lookup(r)
+			if gid == 0 || int(gid) >= len(o.Glyphs) || assigned[gid] {
+				continue
+			}
+			for i, s := range scripts {
+				if unicode.Is(s.table, r) {
+					glyphScript[gid] = i
+					assigned[gid] = true
+					break
+				}
+			}
+		}
+	}
+
+	var cvt []int16
+	metrics := make([]*scriptMetrics, len(scripts))
+	for i, s := range scripts {
+		m := &scriptMetrics{fuzz: zoneFuzz * upem}
+		metrics[i] = m
+		if cmap != nil {
+			for _, spec := range s.blues {
+				z, ok := measureZone(spec, cmap, outlines, upem)
+				if !ok || m.hasZone(z, upem) {
+					continue
+				}
+				z.refCVT = len(cvt)
+				z.ovsCVT = len(cvt) + 1
+				cvt = append(cvt, int16(z.ref), int16(z.overshoot))
+				m.zones = append(m.zones, z)
+			}
+		}
+	}
+
+	// Find the standard stem width for each script.
+	widths := make([]map[float64]int, len(scripts))
+	for i := range widths {
+		widths[i] = make(map[float64]int)
+	}
+	glyphStems := make([][]*stem, len(o.Glyphs))
+	glyphEdges := make([][]*edge, len(o.Glyphs))
+	for gid, u := range outlines {
+		if u == nil {
+			continue
+		}
+		edges := findEdges(u.Contours, upem)
+		stems := pairEdges(edges, upem)
+		glyphEdges[gid] = edges
+		glyphStems[gid] = stems
+		for _, s := range stems {
+			widths[glyphScript[gid]][s.width()]++
+		}
+	}
+	for i, m := range metrics {
+		m.stdWidth = mostFrequent(widths[i])
+		m.stdCVT = -1
+		if m.stdWidth > 0 {
+			m.stdCVT = len(cvt)
+			cvt = append(cvt, int16(m.stdWidth))
+		}
+	}
+
+	// generate the glyph programs
+	maxStack := fpgmStackDepth
+	maxInstructions := 0
+	for gid, u := range outlines {
+		if u == nil {
+			continue
+		}
+		asm, depth := glyphProgram(glyphEdges[gid], glyphStems[gid], metrics[glyphScript[gid]])
+		code, err := ttasm.Assemble(asm)
+		if err != nil {
+			return fmt.Errorf("autohint: glyph %d: %w", gid, err)
+		}
+		u.Instructions = code
+		g := u.AsGlyph()
+		o.Glyphs[gid] = &g
+		maxStack = max(maxStack, depth)
+		maxInstructions = max(maxInstructions, len(code))
+	}
+
+	fpgm, err := ttasm.Assemble(fpgmSource)
+	if err != nil {
+		return fmt.Errorf("autohint: fpgm: %w", err)
+	}
+	prep, err := ttasm.Assemble(prepProgram(metrics))
+	if err != nil {
+		return fmt.Errorf("autohint: prep: %w", err)
+	}
+	cvtData := make([]byte, 2*len(cvt))
+	for i, v := range cvt {
+		binary.BigEndian.PutUint16(cvtData[2*i:], uint16(v))
+	}
+
+	if o.Tables == nil {
+		o.Tables = make(map[string][]byte)
+	}
+	o.Tables["fpgm"] = fpgm
+	o.Tables["prep"] = prep
+	o.Tables["cvt "] = cvtData
+	o.Tables["gasp"] = gaspTable
+
+	if o.Maxp == nil {
+		o.Maxp = &maxp.TTFInfo{}
+		for _, u := range outlines {
+			if u == nil {
+				continue
+			}
+			n := 0
+			for _, c := range u.Contours {
+				n += len(c)
+			}
+			o.Maxp.MaxPoints = max(o.Maxp.MaxPoints, uint16(min(n, math.MaxUint16)))
+			o.Maxp.MaxContours = max(o.Maxp.MaxContours, uint16(min(len(u.Contours), math.MaxUint16)))
+		}
+	}
+	o.Maxp.MaxZones = max(o.Maxp.MaxZones, 1)
+	o.Maxp.MaxTwilightPoints = 0
+	o.Maxp.MaxStorage = 0
+	o.Maxp.MaxFunctionDefs = numFunctions
+	o.Maxp.MaxInstructionDefs = 0
+	o.Maxp.MaxStackElements = uint16(min(maxStack, math.MaxUint16))
+	o.Maxp.MaxSizeOfInstructions = uint16(min(maxInstructions, math.MaxUint16))
+
+	return nil
+}
+
+// stripComposite returns a copy of a composite glyph, without instructions.
+func stripComposite(g *glyf.Glyph, d glyf.CompositeGlyph) *glyf.Glyph {
+	comps := make([]glyf.GlyphComponent, len(d.Components))
+	for i, c := range d.Components {
+		c.Flags &^= glyf.FlagWeHaveInstructions
+		comps[i] = c
+	}
+	return &glyf.Glyph{
+		Rect16: g.Rect16,
+		Data:   glyf.CompositeGlyph{Components: comps},
+	}
+}
+
+// A script describes the reference characters used to find the alignment
+// zones for a writing system.
+type script struct {
+	table *unicode.RangeTable
+	blues []blueSpec
+}
+
+// blueSpec describes one alignment zone.  The zone is found by measuring
+// the top (or bottom) extreme of the glyphs for the given characters.
+type blueSpec struct {
+	chars string
+	top   bool
+}
+
+// scripts lists the supported scripts.  Glyphs which do not belong to any
+// of these scripts are hinted using the alignment zones of the first entry.
+var scripts = []script{
+	{
+		table: unicode.Latin,
+		blues: []blueSpec{
+			{"HEZLOCUSxzroesc", false}, // baseline
+			{"xzroesc", true},          // x-height
+			{"THEZOCQS", true},         // cap-height
+			{"bdhkl", true},            // ascender
+			{"pqgjy", false},           // descender
+		},
+	},
+	{
+		table: unicode.Cyrillic,
+		blues: []blueSpec{
+			{"БВЕШЗОСЭхпншезос", false},
+			{"хпншезос", true},
+			{"БВЕПЗОСЭ", true},
+			{"руф", false},
+		},
+	},
+	{
+		table: unicode.Greek,
+		blues: []blueSpec{
+			{"ΒΔΖΞΘΟαειοπστω", false},
+			{"αειοπστω", true},
+			{"ΓΒΕΖΘΟΩ", true},
+			{"βδζθλξ", true},
+			{"βγημρφχψ", false},
+		},
+	},
+}
+
+// scriptMetrics holds the alignment zones and the standard stem width of a
+// script.
+type scriptMetrics struct {
+	zones    []*zone
+	stdWidth float64
+	stdCVT   int // -1 if there is no standard width
+
+	// fuzz is the tolerance used when matching edges to zones,
+	// in font design units.
+	fuzz float64
+}
+
+// A zone is an alignment zone.  For top zones, overshoot >= ref,
+// for bottom zones, overshoot <= ref.
+type zone struct {
+	top            bool
+	ref, overshoot float64
+	refCVT, ovsCVT int
+}
+
+// hasZone reports whether m already has a zone close to z.
+func (m *scriptMetrics) hasZone(z *zone, upem float64) bool {
+	for _, y := range m.zones {
+		if y.top == z.top && math.Abs(y.ref-z.ref) < zoneFuzz*upem {
+			return true
+		}
+	}
+	return false
+}
+
+// match returns the zone which applies to an edge, or nil if the edge is
+// not in any alignment zone.
+func (m *scriptMetrics) match(e *edge) *zone {
+	fuzz := m.fuzz
+	for _, z := range m.zones {
+		if z.top != e.top {
+			continue
+		}
+		lo, hi := min(z.ref, z.overshoot)-fuzz, max(z.ref, z.overshoot)+fuzz
+		if e.pos >= lo && e.pos <= hi {
+			return z
+		}
+	}
+	return nil
+}
+
+// zoneFuzz is the tolerance (in em units) used when matching edges to
+// alignment zones.
+const zoneFuzz = 1.0 / 40
+
+// measureZone determines an alignment zone from the extreme points of the
+// reference glyphs.  The reference position of the zone is the average
+// position of flat extrema, the overshoot is the average position of round
+// extrema.
+func measureZone(spec blueSpec, cmap cmap.Subtable, outlines []*glyf.SimpleUnpacked, upem float64) (*zone, bool) {
+	var flat, round []float64
+	for _, r := range spec.chars {
+		gid := cmap.Lookup(r)
+		if gid == 0 || int(gid) >= len(outlines) || outlines[gid] == nil {
+			continue
+		}
+		edges := findEdges(outlines[gid].Contours, upem)
+		var best *edge
+		for _, e := range edges {
+			if e.top != spec.top || !e.extreme {
+				continue
+			}
+			if best == nil || spec.top && e.pos > best.pos || !spec.top && e.pos < best.pos {
+				best = e
+			}
+		}
+		if best == nil {
+			continue
+		}
+		if best.round {
+			round = append(round, best.pos)
+		} else {
+			flat = append(flat, best.pos)
+		}
+	}
+	if len(flat) == 0 && len(round) == 0 {
+		return nil, false
+	}
+
+	z := &zone{top: spec.top}
+	switch {
+	case len(flat) > 0 && len(round) > 0:
+		z.ref = math.Round(mean(flat))
+		z.overshoot = math.Round(mean(round))
+	case len(flat) > 0:
+		z.ref = math.Round(mean(flat))
+		z.overshoot = z.ref
+	default:
+		z.ref = math.Round(mean(round))
+		z.overshoot = z.ref
+	}
+	if spec.top && z.overshoot < z.ref || !spec.top && z.overshoot > z.ref {
+		z.overshoot = z.ref
+	}
+	return z, true
+}
+
+func mean(x []float64) float64 {
+	var sum float64
+	for _, v := range x {
+		sum += v
+	}
+	return sum / float64(len(x))
+}
+
+// mostFrequent returns the most frequent value in hist, or 0 if hist is
+// empty.  Ties are broken in favour of smaller values.
+func mostFrequent(hist map[float64]int) float64 {
+	var best float64
+	bestCount := 0
+	for w, count := range hist {
+		if count > bestCount || count == bestCount && w < best {
+			best = w
+			bestCount = count
+		}
+	}
+	return best
+}
+
+// Functions defined in the font program.
+const (
+	// funcRoundZone rounds an alignment zone.  The arguments are the cvt
+	// indices of the reference position and of the overshoot.  Overshoots
+	// smaller than 3/4 pixel are suppressed.
+	funcRoundZone = 0
+
+	// funcAnchor positions a point by interpolating between two reference
+	// points, and then rounds the result.  The arguments are the point,
+	// the lower reference point and the upper reference point.
+	funcAnchor = 1
+
+	numFunctions = 2
+)
+
+// fpgmStackDepth is the maximal stack depth used by the font program and
+// the control value program.
+const fpgmStackDepth = 8
+
+const fpgmSource = `
+PUSH 0
+FDEF
+  # stack: ref ovs
+  DUP
+  RCVT
+  PUSH 3
+  CINDEX
+  RCVT
+  SUB
+  DUP
+  ABS
+  PUSH 48
+  LT
+  IF
+    POP
+    PUSH 0
+  ELSE
+    ROUND[00]
+  EIF
+  PUSH 3
+  CINDEX
+  RCVT
+  ROUND[00]
+  ADD
+  WCVTP
+  DUP
+  RCVT
+  ROUND[00]
+  WCVTP
+ENDF
+
+PUSH 1
+FDEF
+  # stack: p lo hi
+  SRP2
+  SRP1
+  DUP
+  IP
+  MDAP[1]
+ENDF
+`
+
+// prepProgram returns the control value program in assembly language.
+func prepProgram(metrics []*scriptMetrics) string {
+	b := &strings.Builder{}
+	b.WriteString("# enable smart dropout control\n")
+	b.WriteString("PUSH 511\nSCANCTRL\nPUSH 4\nSCANTYPE\n")
+	b.WriteString("# only use the standard stem width if it is within half a pixel\n")
+	b.WriteString("PUSH 32\nSCVTCI\n")
+	b.WriteString("# round the alignment zones\n")
+	for _, m := range metrics {
+		for _, z := range m.zones {
+			fmt.Fprintf(b, "PUSH %d %d %d\nCALL\n", z.refCVT, z.ovsCVT, funcRoundZone)
+		}
+	}
+	return b.String()
+}
+
+// gaspTable enables grid-fitting and anti-aliasing at all sizes.
+// The table has version 1, with a single range for all sizes.
+var gaspTable = []byte{
+	0x00, 0x01, // version
+	0x00, 0x01, // numRanges
+	0xFF, 0xFF, // rangeMaxPPEM
+	0x00, 0x0F, // GRIDFIT | DOGRAY | SYMMETRIC_GRIDFIT | SYMMETRIC_SMOOTHING
+}
@@ -0,0 +1,312 @@
+// seehuhn.de/go/sfnt - a library for reading and writing font files
+// Copyright (C) 2026  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package autohint
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/math/fixed"
+
+	"seehuhn.de/go/postscript/funit"
+	"seehuhn.de/go/sfnt"
+	"seehuhn.de/go/sfnt/glyf"
+	"seehuhn.de/go/sfnt/glyf/hinting"
+	"seehuhn.de/go/sfnt/glyph"
+	"seehuhn.de/go/sfnt/parser"
+)
+
+func TestFindEdges(t *testing.T) {
+	// A clockwise rectangle, as used for outer contours in TrueType fonts.
+	contours := []glyf.Contour{{
+		{X: 100, Y: 0, OnCurve: true},
+		{X: 100, Y: 700, OnCurve: true},
+		{X: 200, Y: 700, OnCurve: true},
+		{X: 200, Y: 0, OnCurve: true},
+	}}
+	edges := findEdges(contours, 1000)
+	if len(edges) != 2 {
+		t.Fatalf("got %d edges, want 2", len(edges))
+	}
+	bottom, top := edges[0], edges[1]
+	if bottom.pos != 0 || bottom.top || !bottom.extreme || bottom.round {
+		t.Errorf("wrong bottom edge %+v", bottom)
+	}
+	if top.pos != 700 || !top.top || !top.extreme || top.round {
+		t.Errorf("wrong top edge %+v", top)
+	}
+
+	stems := pairEdges(edges, 1000)
+	if len(stems) != 0 {
+		t.Errorf("got %d stems, want 0", len(stems))
+	}
+
+	// A horizontal bar, like the crossbar of an 'H'.
+	contours[0] = glyf.Contour{
+		{X: 100, Y: 300, OnCurve: true},
+		{X: 100, Y: 380, OnCurve: true},
+		{X: 500, Y: 380, OnCurve: true},
+		{X: 500, Y: 300, OnCurve: true},
+	}
+	stems = pairEdges(findEdges(contours, 1000), 1000)
+	if len(stems) != 1 || stems[0].bottom.pos != 300 || stems[0].top.pos != 380 {
+		t.Errorf("wrong stems %v", stems)
+	}
+}
+
+// TestGoRegular hints the Go Regular font, replacing the original
+// instructions, and checks that the baseline and the x-height are aligned
+// to the pixel grid.
+func TestGoRegular(t *testing.T) {
+	f, err := sfnt.Read(bytes.NewReader(goregular.TTF), parser.NewBudget(int64(len(goregular.TTF))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	outlines := f.Outlines.(*glyf.Outlines)
+	cmap, err := f.CMapTable.GetBest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = Hint(outlines, f.UnitsPerEm, cmap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"fpgm", "prep", "cvt ", "gasp"} {
+		if len(outlines.Tables[name]) == 0 {
+			t.Errorf("missing %q table", name)
+		}
+	}
+
+	in := hinting.New(outlines, f.UnitsPerEm)
+	for _, ppem := range []int{7, 10, 12, 16, 23, 40} {
+		if err := in.SetPPEM(ppem); err != nil {
+			t.Fatal(err)
+		}
+		for gid := range outlines.Glyphs {
+			_, err := in.Glyph(glyph.ID(gid))
+			if err != nil {
+				t.Fatalf("ppem %d, glyph %d: %v", ppem, gid, err)
+			}
+		}
+
+		for _, r := range "xzoH" {
+			g, err := in.Glyph(cmap.Lookup(r))
+			if err != nil {
+				t.Fatal(err)
+			}
+			bottom, top := extent(g)
+			if r == 'o' {
+				// round glyphs may overshoot the baseline at large sizes
+				bottom = 0
+			}
+			if bottom != 0 || top%64 != 0 {
+				t.Errorf("ppem %d, %q: vertical extent %s to %s not aligned", ppem, r, bottom, top)
+			}
+		}
+
+		// The crossbar of the 'H' must be aligned to the grid and
+		// must be at least one pixel thick.
+		g, err := in.Glyph(cmap.Lookup('H'))
+		if err != nil {
+			t.Fatal(err)
+		}
+		bottom, top := extent(g)
+		var ys []fixed.Int26_6
+		for _, c := range g.Contours {
+			for _, p := range c {
+				if p.Y > bottom && p.Y < top {
+					ys = append(ys, p.Y)
+				}
+			}
+		}
+		if len(ys) == 0 {
+			t.Fatalf("ppem %d: no crossbar found", ppem)
+		}
+		lo, hi := ys[0], ys[0]
+		for _, y := range ys {
+			if y%64 != 0 {
+				t.Errorf("ppem %d: crossbar edge %s not aligned", ppem, y)
+			}
+			lo = min(lo, y)
+			hi = max(hi, y)
+		}
+		if hi-lo < 64 {
+			t.Errorf("ppem %d: crossbar too thin (%s)", ppem, hi-lo)
+		}
+	}
+
+	// check that the hinted font can be written and read back
+	buf := &bytes.Buffer{}
+	_, err = f.Write(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f2, err := sfnt.Read(bytes.NewReader(buf.Bytes()), parser.NewBudget(int64(buf.Len())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	outlines2 := f2.Outlines.(*glyf.Outlines)
+	for _, name := range []string{"fpgm", "prep", "cvt ", "gasp"} {
+		if !bytes.Equal(outlines.Tables[name], outlines2.Tables[name]) {
+			t.Errorf("%q table changed in round trip", name)
+		}
+	}
+}
+
+// TestNoCMap checks that fonts can be hinted without a cmap table.
+func TestNoCMap(t *testing.T) {
+	u := &glyf.SimpleUnpacked{
+		Contours: []glyf.Contour{{
+			{X: 100, Y: 300, OnCurve: true},
+			{X: 100, Y: 380, OnCurve: true},
+			{X: 500, Y: 380, OnCurve: true},
+			{X: 500, Y: 300, OnCurve: true},
+		}},
+	}
+	g := u.AsGlyph()
+	o := &glyf.Outlines{
+		Glyphs: glyf.Glyphs{nil, &g},
+		Widths: []funit.Uint16{500, 600},
+	}
+	err := Hint(o, 1000, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.Maxp == nil || o.Maxp.MaxPoints != 4 {
+		t.Errorf("wrong maxp information %+v", o.Maxp)
+	}
+
+	in := hinting.New(o, 1000)
+	if err := in.SetPPEM(12); err != nil {
+		t.Fatal(err)
+	}
+	h, err := in.Glyph(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range h.Contours[0] {
+		if p.Y%64 != 0 {
+			t.Errorf("point %v not aligned", p)
+		}
+	}
+}
+
+// TestExtent checks that hinting moves the top and bottom of a glyph by not
+// much more than one pixel.  This catches rounding errors which accumulate
+// over several stems, and stems which lose a pixel.
+func TestExtent(t *testing.T) {
+	f, err := sfnt.Read(bytes.NewReader(goregular.TTF), parser.NewBudget(int64(len(goregular.TTF))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	outlines := f.Outlines.(*glyf.Outlines)
+	cmap, err := f.CMapTable.GetBest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// record the unhinted extent of all simple glyphs and their contours
+	type span struct{ bottom, top float64 }
+	orig := make(map[int]span)
+	contours := make(map[int][]span)
+	for gid, g := range outlines.Glyphs {
+		if g == nil {
+			continue
+		}
+		sg, ok := g.Data.(glyf.SimpleGlyph)
+		if !ok {
+			continue
+		}
+		u, err := sg.Unpack()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(u.Contours) == 0 {
+			continue
+		}
+		all := span{math.Inf(1), math.Inf(-1)}
+		for _, c := range u.Contours {
+			s := span{math.Inf(1), math.Inf(-1)}
+			for _, p := range c {
+				s.bottom = min(s.bottom, float64(p.Y))
+				s.top = max(s.top, float64(p.Y))
+			}
+			contours[gid] = append(contours[gid], s)
+			all.bottom = min(all.bottom, s.bottom)
+			all.top = max(all.top, s.top)
+		}
+		orig[gid] = all
+	}
+
+	err = Hint(outlines, f.UnitsPerEm, cmap)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	in := hinting.New(outlines, f.UnitsPerEm)
+	for _, ppem := range []int{8, 12, 16, 24, 48} {
+		if err := in.SetPPEM(ppem); err != nil {
+			t.Fatal(err)
+		}
+		scale := float64(ppem) / float64(f.UnitsPerEm)
+		for gid, s := range orig {
+			g, err := in.Glyph(glyph.ID(gid))
+			if err != nil {
+				t.Fatal(err)
+			}
+			bottom, top := extent(g)
+			db := float64(bottom)/64 - s.bottom*scale
+			dt := float64(top)/64 - s.top*scale
+			if math.Abs(db) > 1.1 || math.Abs(dt) > 1.1 {
+				t.Errorf("ppem %d, glyph %d: extent %.2f to %.2f changed to %s to %s",
+					ppem, gid, s.bottom*scale, s.top*scale, bottom, top)
+			}
+		}
+	}
+
+	// The bars of "=" must keep their height at large sizes.
+	gid := cmap.Lookup('=')
+	if err := in.SetPPEM(48); err != nil {
+		t.Fatal(err)
+	}
+	g, err := in.Glyph(gid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scale := 48 / float64(f.UnitsPerEm)
+	for i, s := range contours[int(gid)] {
+		bottom, top := extent(&hinting.Glyph{Contours: g.Contours[i : i+1]})
+		want := math.Round((s.top - s.bottom) * scale)
+		if got := float64(top-bottom) / 64; got != want {
+			t.Errorf("bar %d of \"=\": height %g, expected %g", i, got, want)
+		}
+	}
+}
+
+func extent(g *hinting.Glyph) (bottom, top fixed.Int26_6) {
+	bottom, top = g.Contours[0][0].Y, g.Contours[0][0].Y
+	for _, c := range g.Contours {
+		for _, p := range c {
+			bottom = min(bottom, p.Y)
+			top = max(top, p.Y)
+		}
+	}
+	return bottom, top
+}
@@ -0,0 +1,402 @@
+// seehuhn.de/go/sfnt - a library for reading and writing font files
+// Copyright (C) 2026  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package autohint
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+
+	"seehuhn.de/go/sfnt/glyf"
+)
+
+// An edge is a horizontal part of a glyph outline, formed by consecutive
+// points with the same y-coordinate.  Edges from different contours at the
+// same height and with the same fill side are merged.
+type edge struct {
+	pos float64 // y-coordinate, in font design units
+
+	// top is true if the glyph is filled below the edge.
+	top bool
+
+	// round is true if the edge is the extremum of a curve, rather than a
+	// straight line segment.
+	round bool
+
+	// extreme is true if the edge is a local extremum of its contour.
+	extreme bool
+
+	// lo and hi give the horizontal extent of the edge.
+	lo, hi float64
+
+	// points lists the indices of the points on the edge.  Points are
+	// numbered consecutively over all contours of the glyph.
+	points []int
+}
+
+// A stem is a horizontal stroke of a glyph, delimited by two edges.
+type stem struct {
+	bottom, top *edge
+}
+
+func (s *stem) width() float64 {
+	return s.top.pos - s.bottom.pos
+}
+
+// minFlatLength is the minimal length (in em units) of straight edges.
+const minFlatLength = 1.0 / 50
+
+// maxStemWidth is the maximal width (in em units) of stems.
+const maxStemWidth = 0.3
+
+// findEdges returns the horizontal edges of a glyph outline, ordered by
+// position.
+func findEdges(contours []glyf.Contour, upem float64) []*edge {
+	// TrueType outlines normally have clockwise outer contours, but we
+	// determine the orientation from the outline in case it is reversed.
+	var area float64
+	for _, c := range contours {
+		for i, p := range c {
+			q := c[(i+1)%len(c)]
+			area += float64(p.X)*float64(q.Y) - float64(q.X)*float64(p.Y)
+		}
+	}
+	ccw := area > 0
+
+	var edges []*edge
+	base := 0
+	for _, c := range contours {
+		n := len(c)
+		if n < 2 {
+			base += n
+			continue
+		}
+
+		// find a point which starts a new run
+		start := -1
+		for i := range n {
+			if c[i].Y != c[(i+n-1)%n].Y {
+				start = i
+				break
+			}
+		}
+		if start < 0 {
+			// all points at the same height
+			base += n
+			continue
+		}
+
+		for k := 0; k < n; {
+			i := (start + k) % n
+			length := 1
+			for length < n && c[(i+length)%n].Y == c[i].Y {
+				length++
+			}
+			k += length
+
+			first := c[i]
+			last := c[(i+length-1)%n]
+			prev := c[(i+n-1)%n]
+			next := c[(i+length)%n]
+
+			e := &edge{
+				pos: float64(first.Y),
+				lo:  math.Inf(+1),
+				hi:  math.Inf(-1),
+			}
+			numOn := 0
+			for j := range length {
+				p := c[(i+j)%n]
+				if p.OnCurve {
+					numOn++
+				}
+				e.lo = min(e.lo, float64(p.X))
+				e.hi = max(e.hi, float64(p.X))
+				e.points = append(e.points, base+(i+j)%n)
+			}
+
+			dx := float64(last.X - first.X)
+			if dx == 0 {
+				dx = float64(next.X - prev.X)
+			}
+			if dx == 0 {
+				continue
+			}
+			e.top = (dx > 0) != ccw
+
+			below := prev.Y < first.Y && next.Y < first.Y
+			above := prev.Y > first.Y && next.Y > first.Y
+			e.extreme = below || above
+
+			flat := numOn >= 2 && e.hi-e.lo >= minFlatLength*upem
+			if !flat {
+				if !e.extreme || numOn == 0 {
+					continue
+				}
+				e.round = true
+			}
+			edges = append(edges, e)
+		}
+		base += n
+	}
+
+	// merge edges at the same height
+	slices.SortStableFunc(edges, func(a, b *edge) int {
+		if a.pos != b.pos {
+			if a.pos < b.pos {
+				return -1
+			}
+			return 1
+		}
+		if a.top != b.top {
+			if b.top {
+				return -1
+			}
+			return 1
+		}
+		return 0
+	})
+	var res []*edge
+	for _, e := range edges {
+		if len(res) > 0 {
+			prev := res[len(res)-1]
+			if prev.pos == e.pos && prev.top == e.top {
+				prev.round = prev.round && e.round
+				prev.extreme = prev.extreme || e.extreme
+				prev.lo = min(prev.lo, e.lo)
+				prev.hi = max(prev.hi, e.hi)
+				prev.points = append(prev.points, e.points...)
+				continue
+			}
+		}
+		res = append(res, e)
+	}
+	return res
+}
+
+// pairEdges combines edges into stems.  Each stem consists of a bottom edge
+// and a top edge above it, which overlap horizontally.  Narrow stems are
+// preferred, and every edge is used for at most one stem.
+func pairEdges(edges []*edge, upem float64) []*stem {
+	var candidates []*stem
+	slack := upem / 20
+	for _, b := range edges {
+		if b.top {
+			continue
+		}
+		for _, t := range edges {
+			if !t.top || t.pos <= b.pos || t.pos-b.pos > maxStemWidth*upem {
+				continue
+			}
+			if min(b.hi, t.hi)-max(b.lo, t.lo) < -slack {
+				continue
+			}
+			candidates = append(candidates, &stem{bottom: b, top: t})
+		}
+	}
+	slices.SortStableFunc(candidates, func(a, b *stem) int {
+		wa, wb := a.width(), b.width()
+		switch {
+		case wa < wb:
+			return -1
+		case wa > wb:
+			return 1
+		}
+		return 0
+	})
+
+	used := make(map[*edge]bool)
+	var stems []*stem
+	for _, s := range candidates {
+		if used[s.bottom] || used[s.top] {
+			continue
+		}
+		used[s.bottom] = true
+		used[s.top] = true
+		stems = append(stems, s)
+	}
+	slices.SortFunc(stems, func(a, b *stem) int {
+		switch {
+		case a.bottom.pos < b.bottom.pos:
+			return -1
+		case a.bottom.pos > b.bottom.pos:
+			return 1
+		}
+		return 0
+	})
+	return stems
+}
+
+// glyphProgram returns the instructions for a glyph in assembly language,
+// together with the maximal stack depth used.
+func glyphProgram(edges []*edge, stems []*stem, m *scriptMetrics) (string, int) {
+	if len(edges) == 0 {
+		return "", 0
+	}
+	p := &program{}
+	p.op("SVTCA[0]")
+
+	anchored := make(map[*edge]bool)
+	var anchors []*edge
+
+	// align edges to the alignment zones
+	for _, e := range edges {
+		z := m.match(e)
+		if z == nil {
+			continue
+		}
+		cvt := z.refCVT
+		if e.round {
+			cvt = z.ovsCVT
+		}
+		p.push(e.points[0], cvt)
+		p.op("MIAP[1]")
+		p.align(e.points[1:])
+		anchored[e] = true
+		anchors = append(anchors, e)
+	}
+
+	// The lowest and highest edges are rounded to the grid, so that the
+	// remaining stems can be interpolated between fixed edges.
+	for _, e := range []*edge{edges[0], edges[len(edges)-1]} {
+		if anchored[e] {
+			continue
+		}
+		p.push(e.points[0])
+		p.op("MDAP[1]")
+		p.push(e.points[0])
+		p.op("SRP0")
+		p.align(e.points[1:])
+		anchored[e] = true
+		anchors = append(anchors, e)
+	}
+
+	// stems with one edge already anchored
+	for _, s := range stems {
+		var from, to *edge
+		switch {
+		case anchored[s.bottom] && !anchored[s.top]:
+			from, to = s.bottom, s.top
+		case anchored[s.top] && !anchored[s.bottom]:
+			from, to = s.top, s.bottom
+		default:
+			continue
+		}
+		p.push(from.points[0])
+		p.op("SRP0")
+		p.link(to, s.width(), m)
+		anchored[to] = true
+		anchors = append(anchors, to)
+	}
+
+	// The remaining stems are interpolated between the anchored edges.
+	// Stems positioned here are not used as references, so that rounding
+	// errors do not accumulate.
+	for _, s := range stems {
+		if anchored[s.bottom] || anchored[s.top] {
+			continue
+		}
+
+		var below, above *edge
+		for _, a := range anchors {
+			if a.pos <= s.bottom.pos && (below == nil || a.pos > below.pos) {
+				below = a
+			}
+			if a.pos >= s.top.pos && (above == nil || a.pos < above.pos) {
+				above = a
+			}
+		}
+		b := s.bottom.points[0]
+		switch {
+		case below != nil && above != nil:
+			p.push(b, below.points[0], above.points[0], funcAnchor)
+			p.op("CALL")
+		case below != nil || above != nil:
+			ref := below
+			if ref == nil {
+				ref = above
+			}
+			p.push(ref.points[0])
+			p.op("SRP0")
+			p.push(b)
+			p.op("MDRP[10100]")
+		default:
+			p.push(b)
+			p.op("MDAP[1]")
+		}
+		p.push(b)
+		p.op("SRP0")
+		p.align(s.bottom.points[1:])
+		p.link(s.top, s.width(), m)
+		anchored[s.bottom] = true
+		anchored[s.top] = true
+	}
+
+	p.op("IUP[0]")
+	return p.String(), p.depth
+}
+
+// program is used to build a glyph program in assembly language.
+type program struct {
+	strings.Builder
+	depth int
+}
+
+func (p *program) op(name string) {
+	p.WriteString(name)
+	p.WriteByte('\n')
+}
+
+func (p *program) push(args ...int) {
+	p.WriteString("PUSH")
+	for _, a := range args {
+		fmt.Fprintf(p, " %d", a)
+	}
+	p.WriteByte('\n')
+	p.depth = max(p.depth, len(args))
+}
+
+// align moves the given points to the height of the reference point rp0.
+func (p *program) align(points []int) {
+	switch len(points) {
+	case 0:
+		return
+	case 1:
+		p.push(points[0])
+	default:
+		p.push(append(slices.Clone(points), len(points))...)
+		p.op("SLOOP")
+	}
+	p.op("ALIGNRP")
+}
+
+// link positions the edge e relative to the reference point rp0, at a
+// distance of width design units.  If the width is close to the standard
+// stem width of the script, the standard width is used.  After the call,
+// rp0 is set to the first point of e.
+func (p *program) link(e *edge, width float64, m *scriptMetrics) {
+	first := e.points[0]
+	if m.stdCVT >= 0 && math.Abs(width-m.stdWidth) <= 0.2*m.stdWidth {
+		p.push(first, m.stdCVT)
+		p.op("MIRP[11101]")
+	} else {
+		p.push(first)
+		p.op("MDRP[11101]")
+	}
+	p.align(e.points[1:])
+}
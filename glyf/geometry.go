@@ -0,0 +1,186 @@
+// seehuhn.de/go/sfnt - a library for reading and writing font files
+// Copyright (C) 2026  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package glyf
+
+import (
+	"math"
+	"slices"
+
+	"seehuhn.de/go/geom/vec"
+	"seehuhn.de/go/postscript/funit"
+
+	"seehuhn.de/go/sfnt/internal/outline"
+)
+
+// RemoveOverlaps replaces the contours of the glyph by contours which
+// enclose the same area, but which neither intersect each other nor
+// themselves.  Outer contours of the result run clockwise, holes run
+// counter-clockwise.
+//
+// If the outline is changed, the instructions of the glyph are removed,
+// since the point numbers are no longer valid.
+func (sd *SimpleUnpacked) RemoveOverlaps() {
+	cc := outline.Union(outline.FromPath(sd.Path()))
+	for i, c := range cc {
+		cc[i] = c.Reverse()
+	}
+	sd.setContours(cc, false)
+}
+
+// CorrectDirection reverses contours as needed, so that outer contours run
+// clockwise and holes run counter-clockwise, as required for TrueType
+// fonts.  A contour is considered to be a hole if it lies inside an odd
+// number of other contours.
+//
+// If any contours are reversed, the instructions of the glyph are removed.
+func (sd *SimpleUnpacked) CorrectDirection() {
+	cc := make([]outline.Contour, len(sd.Contours))
+	for i, c := range sd.Contours {
+		u := &SimpleUnpacked{Contours: []Contour{c}}
+		if oc := outline.FromPath(u.Path()); len(oc) == 1 {
+			cc[i] = oc[0]
+		}
+	}
+
+	changed := false
+	for i, wrong := range outline.Misoriented(cc, false) {
+		if !wrong {
+			continue
+		}
+		c := slices.Clone(sd.Contours[i])
+		slices.Reverse(c)
+		sd.Contours[i] = c
+		changed = true
+	}
+	if changed {
+		sd.Instructions = nil
+	}
+}
+
+// RemoveDegenerate removes segments of length zero, replaces straight
+// curves by lines, removes redundant points on straight lines, and removes
+// contours which enclose no area.
+//
+// If the outline is changed, the instructions of the glyph are removed.
+func (sd *SimpleUnpacked) RemoveDegenerate() {
+	sd.setContours(outline.RemoveDegenerate(outline.FromPath(sd.Path())), false)
+}
+
+// AddExtrema adds on-curve points at the horizontal and vertical extrema of
+// all curves.
+//
+// If the outline is changed, the instructions of the glyph are removed.
+func (sd *SimpleUnpacked) AddExtrema() {
+	sd.setContours(outline.AddExtrema(outline.FromPath(sd.Path())), true)
+}
+
+// setContours replaces the contours of the glyph.  Coordinates are rounded
+// to integers.  If the new contours differ from the old ones, the
+// instructions are removed.
+func (sd *SimpleUnpacked) setContours(cc []outline.Contour, keepExtrema bool) {
+	explicit := make(map[vec.Vec2]bool)
+	for _, c := range sd.Contours {
+		for _, p := range c {
+			if p.OnCurve {
+				explicit[vec.Vec2{X: float64(p.X), Y: float64(p.Y)}] = true
+			}
+		}
+	}
+
+	var res []Contour
+	for _, c := range cc {
+		if tc := toContour(c, explicit, keepExtrema); len(tc) > 1 {
+			res = append(res, tc)
+		}
+	}
+	if slices.EqualFunc(res, sd.Contours, sameContour) {
+		return
+	}
+	sd.Contours = res
+	sd.Instructions = nil
+}
+
+// sameContour reports whether a and b describe the same contour, possibly
+// with different start points.
+func sameContour(a, b Contour) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	n := len(a)
+	for k := range n {
+		if slices.Equal(a[k:], b[:n-k]) && slices.Equal(a[:k], b[n-k:]) {
+			return true
+		}
+	}
+	return n == 0
+}
+
+// toContour converts a contour made of lines and quadratic Bézier curves
+// into a TrueType contour.  On-curve points which are implied by the
+// neighbouring off-curve points are omitted, unless they are contained in
+// explicit.  If keepExtrema is set, implied points at horizontal and
+// vertical extrema are kept.
+func toContour(c outline.Contour, explicit map[vec.Vec2]bool, keepExtrema bool) Contour {
+	type point struct {
+		v       vec.Vec2
+		onCurve bool
+	}
+	var pts []point
+	for _, s := range c {
+		pts = append(pts, point{s.Start(), true})
+		for _, ctrl := range s[1 : len(s)-1] {
+			pts = append(pts, point{ctrl, false})
+		}
+	}
+
+	// The implied points are identified before rounding, so that implied
+	// points with non-integer coordinates are preserved.
+	n := len(pts)
+	var res Contour
+	for i, p := range pts {
+		q := Point{
+			X:       funit.Int16(math.Round(p.v.X)),
+			Y:       funit.Int16(math.Round(p.v.Y)),
+			OnCurve: p.onCurve,
+		}
+		if p.onCurve {
+			prev, next := pts[(i+n-1)%n], pts[(i+1)%n]
+			if !prev.onCurve && !next.onCurve &&
+				vec.Middle(prev.v, next.v).Sub(p.v).Length() < 1e-6 &&
+				!explicit[p.v] &&
+				!(keepExtrema && (prev.v.X == next.v.X || prev.v.Y == next.v.Y)) {
+				continue
+			}
+			if len(res) > 0 && res[len(res)-1] == q {
+				continue // skip zero-length segments
+			}
+		}
+		res = append(res, q)
+	}
+	if n := len(res); n > 1 && res[n-1] == res[0] {
+		res = res[:n-1]
+	}
+
+	// start the contour with an on-curve point
+	for i, p := range res {
+		if p.OnCurve {
+			res = slices.Concat(res[i:], res[:i])
+			break
+		}
+	}
+	return res
+}
@@ -0,0 +1,252 @@
+// seehuhn.de/go/sfnt - a library for reading and writing font files
+// Copyright (C) 2026  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package glyf
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/image/font/gofont/goregular"
+
+	"seehuhn.de/go/postscript/funit"
+
+	"seehuhn.de/go/sfnt/header"
+	"seehuhn.de/go/sfnt/internal/outline"
+)
+
+// square returns a clockwise square contour.
+func square(x, y, size funit.Int16) Contour {
+	return Contour{
+		{X: x, Y: y, OnCurve: true},
+		{X: x, Y: y + size, OnCurve: true},
+		{X: x + size, Y: y + size, OnCurve: true},
+		{X: x + size, Y: y, OnCurve: true},
+	}
+}
+
+func area(sd *SimpleUnpacked) float64 {
+	var a float64
+	for _, c := range outline.FromPath(sd.Path()) {
+		a += c.Area()
+	}
+	return a
+}
+
+func TestRemoveOverlaps(t *testing.T) {
+	sd := &SimpleUnpacked{
+		Contours:     []Contour{square(0, 0, 100), square(50, 50, 100)},
+		Instructions: []byte{0x00},
+	}
+	sd.RemoveOverlaps()
+	want := []Contour{{
+		{X: 0, Y: 0, OnCurve: true},
+		{X: 0, Y: 100, OnCurve: true},
+		{X: 50, Y: 100, OnCurve: true},
+		{X: 50, Y: 150, OnCurve: true},
+		{X: 150, Y: 150, OnCurve: true},
+		{X: 150, Y: 50, OnCurve: true},
+		{X: 100, Y: 50, OnCurve: true},
+		{X: 100, Y: 0, OnCurve: true},
+	}}
+	if len(sd.Contours) != 1 || !isRotation(sd.Contours[0], want[0]) {
+		t.Errorf("unexpected contours %v", sd.Contours)
+	}
+	if sd.Instructions != nil {
+		t.Error("instructions not removed")
+	}
+
+	// a glyph without overlaps is not changed
+	sd = &SimpleUnpacked{
+		Contours:     []Contour{square(0, 0, 100), square(200, 0, 100)},
+		Instructions: []byte{0x00},
+	}
+	sd.RemoveOverlaps()
+	if sd.Instructions == nil {
+		t.Error("instructions removed")
+	}
+
+	// on-curve points between two off-curve points are kept, if they
+	// are present in the input
+	round := Contour{
+		{X: 0, Y: 50, OnCurve: true},
+		{X: 0, Y: 100},
+		{X: 50, Y: 100, OnCurve: true},
+		{X: 100, Y: 100},
+		{X: 100, Y: 50, OnCurve: true},
+		{X: 100, Y: 0},
+		{X: 50, Y: 0, OnCurve: true},
+		{X: 0, Y: 0},
+	}
+	sd = &SimpleUnpacked{
+		Contours:     []Contour{round},
+		Instructions: []byte{0x00},
+	}
+	sd.RemoveOverlaps()
+	if d := cmp.Diff([]Contour{round}, sd.Contours); d != "" {
+		t.Error(d)
+	}
+	if sd.Instructions == nil {
+		t.Error("instructions removed")
+	}
+}
+
+// isRotation reports whether a and b describe the same contour, possibly
+// with different start points.
+func isRotation(a, b Contour) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k := range b {
+		ok := true
+		for i := range a {
+			if a[i] != b[(i+k)%len(b)] {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+func TestCorrectDirection(t *testing.T) {
+	outer := square(0, 0, 300)
+	inner := square(100, 100, 100)
+	reversed := func(c Contour) Contour {
+		res := make(Contour, len(c))
+		for i, p := range c {
+			res[len(c)-1-i] = p
+		}
+		return res
+	}
+
+	// The outer contour has the wrong direction, the hole is correct.
+	sd := &SimpleUnpacked{
+		Contours:     []Contour{reversed(outer), reversed(inner)},
+		Instructions: []byte{0x00},
+	}
+	sd.CorrectDirection()
+	want := []Contour{outer, reversed(inner)}
+	if d := cmp.Diff(want, sd.Contours); d != "" {
+		t.Errorf("unexpected contours (-want +got):\n%s", d)
+	}
+	if sd.Instructions != nil {
+		t.Error("instructions not removed")
+	}
+
+	sd.Instructions = []byte{0x00}
+	sd.CorrectDirection()
+	if sd.Instructions == nil {
+		t.Error("instructions removed")
+	}
+}
+
+func TestRemoveDegenerate(t *testing.T) {
+	sd := &SimpleUnpacked{
+		Contours: []Contour{
+			{
+				{X: 0, Y: 0, OnCurve: true},
+				{X: 0, Y: 50, OnCurve: true}, // redundant
+				{X: 0, Y: 100, OnCurve: true},
+				{X: 0, Y: 100, OnCurve: true}, // duplicate
+				{X: 100, Y: 100, OnCurve: true},
+				{X: 100, Y: 50, OnCurve: false}, // straight curve
+				{X: 100, Y: 0, OnCurve: true},
+			},
+			{
+				// no area
+				{X: 200, Y: 0, OnCurve: true},
+				{X: 300, Y: 0, OnCurve: true},
+			},
+		},
+	}
+	sd.RemoveDegenerate()
+	want := []Contour{square(0, 0, 100)}
+	if len(sd.Contours) != 1 || !isRotation(sd.Contours[0], want[0]) {
+		t.Errorf("unexpected contours %v", sd.Contours)
+	}
+}
+
+func TestAddExtrema(t *testing.T) {
+	sd := &SimpleUnpacked{
+		Contours: []Contour{{
+			{X: 0, Y: 0, OnCurve: true},
+			{X: 50, Y: 100, OnCurve: false},
+			{X: 100, Y: 0, OnCurve: true},
+		}},
+	}
+	sd.AddExtrema()
+	want := Contour{
+		{X: 0, Y: 0, OnCurve: true},
+		{X: 25, Y: 50, OnCurve: false},
+		{X: 50, Y: 50, OnCurve: true},
+		{X: 75, Y: 50, OnCurve: false},
+		{X: 100, Y: 0, OnCurve: true},
+	}
+	if len(sd.Contours) != 1 || !isRotation(sd.Contours[0], want) {
+		t.Errorf("unexpected contours %v", sd.Contours)
+	}
+}
+
+// TestGeometryGoRegular applies the outline operations to all glyphs of the
+// Go Regular font, and checks that the enclosed area does not change.
+func TestGeometryGoRegular(t *testing.T) {
+	r := bytes.NewReader(goregular.TTF)
+	header, err := header.Read(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	glyfData, err := header.ReadTableBytes(r, "glyf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	locaData, err := header.ReadTableBytes(r, "loca")
+	if err != nil {
+		t.Fatal(err)
+	}
+	glyphs, err := Decode(&Encoded{GlyfData: glyfData, LocaData: locaData})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for gid, g := range glyphs {
+		if g == nil {
+			continue
+		}
+		sg, ok := g.Data.(SimpleGlyph)
+		if !ok {
+			continue
+		}
+		sd, err := sg.Unpack()
+		if err != nil {
+			t.Fatal(err)
+		}
+		before := math.Abs(area(sd))
+		sd.CorrectDirection()
+		sd.RemoveDegenerate()
+		sd.AddExtrema()
+		sd.RemoveOverlaps()
+		after := math.Abs(area(sd))
+		if math.Abs(after-before) > 1e-3*before+10 {
+			t.Errorf("glyph %d: area changed from %g to %g", gid, before, after)
+		}
+	}
+}
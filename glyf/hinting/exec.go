@@ -0,0 +1,799 @@
+// seehuhn.de/go/sfnt - a library for reading and writing font files
+// Copyright (C) 2026  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package hinting
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	// maxCallDepth limits the nesting of function calls.
+	maxCallDepth = 64
+
+	// maxSteps limits the number of instructions executed by a single
+	// program, to guard against infinite loops.
+	maxSteps = 1 << 22
+)
+
+// execError is used to abort the execution of a program.
+type execError struct {
+	err error
+}
+
+// fail aborts the execution of the current program.
+func fail(err error) {
+	panic(execError{err})
+}
+
+func (in *Interpreter) push(v int32) {
+	if len(in.stack) >= in.maxStack {
+		fail(errStackOverflow)
+	}
+	in.stack = append(in.stack, v)
+}
+
+func (in *Interpreter) pop() int32 {
+	n := len(in.stack)
+	if n == 0 {
+		fail(errStackUnderflow)
+	}
+	v := in.stack[n-1]
+	in.stack = in.stack[:n-1]
+	return v
+}
+
+func (in *Interpreter) pushBool(b bool) {
+	if b {
+		in.push(1)
+	} else {
+		in.push(0)
+	}
+}
+
+// point checks that p is a valid point index for the zone referenced by
+// zone pointer zp[i], and returns the zone.
+func (in *Interpreter) point(i int, p int32) *zone {
+	z := in.zp[i]
+	if p < 0 || int(p) >= len(z.cur) {
+		fail(fmt.Errorf("invalid point %d in zone %d", p, in.gs.zp[i]))
+	}
+	return z
+}
+
+func (in *Interpreter) resetZonePointers() {
+	for i, k := range in.gs.zp {
+		in.zp[i] = in.zones[k]
+	}
+}
+
+func (in *Interpreter) setZonePointer(i int, k int32) {
+	if k != 0 && k != 1 {
+		fail(fmt.Errorf("invalid zone %d", k))
+	}
+	in.gs.zp[i] = k
+	in.zp[i] = in.zones[k]
+}
+
+// run executes the instructions in code.  Execution errors cause a panic
+// with an execError value, which is converted to an error here.
+func (in *Interpreter) run(code []byte, depth int) (err error) {
+	pc := 0
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(execError)
+			if !ok {
+				panic(r)
+			}
+			if depth > 0 {
+				// pass the error on to the outermost call
+				panic(e)
+			}
+			err = fmt.Errorf("offset %d: %w", pc, e.err)
+		}
+	}()
+	in.exec(code, &pc, depth)
+	return nil
+}
+
+// exec executes the instructions in code.
+func (in *Interpreter) exec(code []byte, pcp *int, depth int) {
+	if depth > maxCallDepth {
+		fail(errTooDeep)
+	}
+	gs := &in.gs
+
+	pc := 0
+	for pc < len(code) {
+		in.steps++
+		if in.steps > maxSteps {
+			fail(errTooManySteps)
+		}
+		if depth == 0 {
+			*pcp = pc
+		}
+		op := code[pc]
+		next := pc + 1
+
+		switch {
+		case op == 0x00 || op == 0x01: // SVTCA[a]
+			axis := yAxis
+			if op&1 != 0 {
+				axis = xAxis
+			}
+			gs.pv, gs.fv, gs.dv = axis, axis, axis
+
+		case op == 0x02 || op == 0x03: // SPVTCA[a]
+			axis := yAxis
+			if op&1 != 0 {
+				axis = xAxis
+			}
+			gs.pv, gs.dv = axis, axis
+
+		case op == 0x04 || op == 0x05: // SFVTCA[a]
+			axis := yAxis
+			if op&1 != 0 {
+				axis = xAxis
+			}
+			gs.fv = axis
+
+		case op >= 0x06 && op <= 0x09: // SPVTL[a], SFVTL[a]
+			p2 := in.pop()
+			p1 := in.pop()
+			z1 := in.point(1, p1)
+			z2 := in.point(2, p2)
+			v := in.lineVector(z1.cur[p1], z2.cur[p2], op&1 != 0)
+			if op < 0x08 {
+				gs.pv, gs.dv = v, v
+			} else {
+				gs.fv = v
+			}
+
+		case op == 0x0A || op == 0x0B: // SPVFS, SFVFS
+			y := int32(int16(in.pop()))
+			x := int32(int16(in.pop()))
+			v := normalize(x, y)
+			if op == 0x0A {
+				gs.pv, gs.dv = v, v
+			} else {
+				gs.fv = v
+			}
+
+		case op == 0x0C: // GPV
+			in.push(gs.pv.x)
+			in.push(gs.pv.y)
+
+		case op == 0x0D: // GFV
+			in.push(gs.fv.x)
+			in.push(gs.fv.y)
+
+		case op == 0x0E: // SFVTPV
+			gs.fv = gs.pv
+
+		case op == 0x0F: // ISECT
+			in.isect()
+
+		case op >= 0x10 && op <= 0x12: // SRP0, SRP1, SRP2
+			gs.rp[op-0x10] = in.pop()
+
+		case op >= 0x13 && op <= 0x15: // SZP0, SZP1, SZP2
+			in.setZonePointer(int(op-0x13), in.pop())
+
+		case op == 0x16: // SZPS
+			k := in.pop()
+			for i := range 3 {
+				in.setZonePointer(i, k)
+			}
+
+		case op == 0x17: // SLOOP
+			n := in.pop()
+			if n < 0 {
+				fail(errors.New("negative loop count"))
+			}
+			gs.loop = n
+
+		case op == 0x18: // RTG
+			gs.round = roundToGrid
+		case op == 0x19: // RTHG
+			gs.round = roundToHalfGrid
+		case op == 0x3D: // RTDG
+			gs.round = roundToDoubleGrid
+		case op == 0x7A: // ROFF
+			gs.round = roundOff
+		case op == 0x7C: // RUTG
+			gs.round = roundUpToGrid
+		case op == 0x7D: // RDTG
+			gs.round = roundDownToGrid
+
+		case op == 0x76: // SROUND
+			in.setSuperRound(64, in.pop())
+			gs.round = roundSuper
+		case op == 0x77: // S45ROUND
+			in.setSuperRound(45, in.pop()) // 64/sqrt(2)
+			gs.round = roundSuper45
+
+		case op == 0x1A: // SMD
+			gs.minDist = in.pop()
+		case op == 0x1D: // SCVTCI
+			gs.cvtCutIn = in.pop()
+		case op == 0x1E: // SSWCI
+			gs.singleWidthCutIn = in.pop()
+		case op == 0x1F: // SSW
+			gs.singleWidth = in.scale(in.pop())
+		case op == 0x5E: // SDB
+			gs.deltaBase = in.pop()
+		case op == 0x5F: // SDS
+			gs.deltaShift = min(max(in.pop(), 0), 6)
+		case op == 0x4D: // FLIPON
+			gs.autoFlip = true
+		case op == 0x4E: // FLIPOFF
+			gs.autoFlip = false
+		case op == 0x85: // SCANCTRL
+			gs.scanControl = in.pop()
+		case op == 0x8D: // SCANTYPE
+			gs.scanType = in.pop()
+		case op == 0x7E: // SANGW
+			in.pop()
+		case op == 0x4F: // DEBUG
+			in.pop()
+
+		case op == 0x8E: // INSTCTRL
+			sel := in.pop()
+			val := in.pop()
+			if in.inPrep && sel >= 1 && sel <= 3 {
+				bit := int32(1) << (sel - 1)
+				if val != 0 {
+					gs.instructCtrl |= bit
+				} else {
+					gs.instructCtrl &^= bit
+				}
+			}
+
+		case op == 0x1B: // ELSE
+			// We only get here at the end of an IF branch which was
+			// executed.  Skip the ELSE branch.
+			next = skipConditional(code, next, false)
+
+		case op == 0x1C: // JMPR
+			next = jump(code, pc, in.pop())
+
+		case op == 0x78: // JROT
+			e := in.pop()
+			offs := in.pop()
+			if e != 0 {
+				next = jump(code, pc, offs)
+			}
+
+		case op == 0x79: // JROF
+			e := in.pop()
+			offs := in.pop()
+			if e == 0 {
+				next = jump(code, pc, offs)
+			}
+
+		case op == 0x20: // DUP
+			v := in.pop()
+			in.push(v)
+			in.push(v)
+		case op == 0x21: // POP
+			in.pop()
+		case op == 0x22: // CLEAR
+			in.stack = in.stack[:0]
+		case op == 0x23: // SWAP
+			b := in.pop()
+			a := in.pop()
+			in.push(b)
+			in.push(a)
+		case op == 0x24: // DEPTH
+			in.push(int32(len(in.stack)))
+		case op == 0x25: // CINDEX
+			k := in.pop()
+			if k <= 0 || int(k) > len(in.stack) {
+				fail(errStackUnderflow)
+			}
+			in.push(in.stack[len(in.stack)-int(k)])
+		case op == 0x26: // MINDEX
+			k := in.pop()
+			n := len(in.stack)
+			if k <= 0 || int(k) > n {
+				fail(errStackUnderflow)
+			}
+			v := in.stack[n-int(k)]
+			copy(in.stack[n-int(k):], in.stack[n-int(k)+1:])
+			in.stack[n-1] = v
+		case op == 0x8A: // ROLL
+			c := in.pop()
+			b := in.pop()
+			a := in.pop()
+			in.push(b)
+			in.push(c)
+			in.push(a)
+
+		case op == 0x27: // ALIGNPTS
+			p2 := in.pop()
+			p1 := in.pop()
+			z1 := in.point(1, p1)
+			z0 := in.point(0, p2)
+			d := in.project(sub(z0.cur[p2], z1.cur[p1])) / 2
+			in.move(z1, p1, d)
+			in.move(z0, p2, -d)
+
+		case op == 0x29: // UTP
+			p := in.pop()
+			z := in.point(0, p)
+			if gs.fv.x != 0 {
+				z.touchX[p] = false
+			}
+			if gs.fv.y != 0 {
+				z.touchY[p] = false
+			}
+
+		case op == 0x2A: // LOOPCALL
+			f := in.pop()
+			n := in.pop()
+			body := in.function(f)
+			for range n {
+				in.exec(body, pcp, depth+1)
+			}
+
+		case op == 0x2B: // CALL
+			body := in.function(in.pop())
+			in.exec(body, pcp, depth+1)
+
+		case op == 0x2C: // FDEF
+			f := in.pop()
+			end := findEndf(code, next)
+			in.funcs[f] = code[next:end]
+			next = end + 1
+
+		case op == 0x2D: // ENDF
+			// end of the current function
+			return
+
+		case op == 0x89: // IDEF
+			opc := in.pop()
+			end := findEndf(code, next)
+			in.idefs[byte(opc)] = code[next:end]
+			next = end + 1
+
+		case op == 0x2E || op == 0x2F: // MDAP[r]
+			in.mdap(op&1 != 0)
+
+		case op == 0x30 || op == 0x31: // IUP[a]
+			in.iup(op&1 != 0)
+
+		case op == 0x32 || op == 0x33: // SHP[a]
+			z, ref := in.displacementRef(op&1 != 0)
+			d := in.displacement(z, ref)
+			for range max(gs.loop, 1) {
+				p := in.pop()
+				z2 := in.point(2, p)
+				in.shift(z2, p, d, true)
+			}
+			gs.loop = 1
+
+		case op == 0x34 || op == 0x35: // SHC[a]
+			z, ref := in.displacementRef(op&1 != 0)
+			d := in.displacement(z, ref)
+			c := in.pop()
+			z2 := in.zp[2]
+			if c < 0 || int(c) >= len(z2.ends) {
+				fail(fmt.Errorf("invalid contour %d", c))
+			}
+			start := 0
+			if c > 0 {
+				start = z2.ends[c-1]
+			}
+			for i := int32(start); i < int32(z2.ends[c]); i++ {
+				if z2 != z || i != ref {
+					in.shift(z2, i, d, true)
+				}
+			}
+
+		case op == 0x36 || op == 0x37: // SHZ[a]
+			z, ref := in.displacementRef(op&1 != 0)
+			d := in.displacement(z, ref)
+			e := in.pop()
+			if e != 0 && e != 1 {
+				fail(fmt.Errorf("invalid zone %d", e))
+			}
+			target := in.zones[e]
+			n := len(target.cur)
+			if e == 1 {
+				n = target.numPoints()
+			}
+			for i := range int32(n) {
+				if target != z || i != ref {
+					in.shift(target, i, d, false)
+				}
+			}
+
+		case op == 0x38: // SHPIX
+			amount := in.pop()
+			d := vec{mul14(amount, gs.fv.x), mul14(amount, gs.fv.y)}
+			for range max(gs.loop, 1) {
+				p := in.pop()
+				z := in.point(2, p)
+				in.shift(z, p, d, true)
+			}
+			gs.loop = 1
+
+		case op == 0x39: // IP
+			in.ip()
+
+		case op == 0x3A || op == 0x3B: // MSIRP[a]
+			d := in.pop()
+			p := in.pop()
+			z1 := in.point(1, p)
+			rp0 := gs.rp[0]
+			z0 := in.point(0, rp0)
+			if gs.zp[1] == 0 {
+				z1.orig[p] = z0.orig[rp0]
+				z1.cur[p] = z1.orig[p]
+			}
+			dist := in.project(sub(z1.cur[p], z0.cur[rp0]))
+			in.move(z1, p, d-dist)
+			gs.rp[1] = rp0
+			gs.rp[2] = p
+			if op&1 != 0 {
+				gs.rp[0] = p
+			}
+
+		case op == 0x3C: // ALIGNRP
+			rp0 := gs.rp[0]
+			z0 := in.point(0, rp0)
+			for range max(gs.loop, 1) {
+				p := in.pop()
+				z1 := in.point(1, p)
+				d := in.project(sub(z1.cur[p], z0.cur[rp0]))
+				in.move(z1, p, -d)
+			}
+			gs.loop = 1
+
+		case op == 0x3E || op == 0x3F: // MIAP[r]
+			in.miap(op&1 != 0)
+
+		case op == 0x40: // NPUSHB
+			if next >= len(code) {
+				fail(errCodeOverflow)
+			}
+			n := int(code[next])
+			next = in.pushBytes(code, next+1, n)
+		case op == 0x41: // NPUSHW
+			if next >= len(code) {
+				fail(errCodeOverflow)
+			}
+			n := int(code[next])
+			next = in.pushWords(code, next+1, n)
+		case op >= 0xB0 && op <= 0xB7: // PUSHB[abc]
+			next = in.pushBytes(code, next, int(op-0xB0)+1)
+		case op >= 0xB8 && op <= 0xBF: // PUSHW[abc]
+			next = in.pushWords(code, next, int(op-0xB8)+1)
+
+		case op == 0x42: // WS
+			v := in.pop()
+			i := in.pop()
+			if i >= 0 && int(i) < len(in.storage) {
+				in.storage[i] = v
+			}
+		case op == 0x43: // RS
+			i := in.pop()
+			var v int32
+			if i >= 0 && int(i) < len(in.storage) {
+				v = in.storage[i]
+			}
+			in.push(v)
+
+		case op == 0x44: // WCVTP
+			v := in.pop()
+			i := in.pop()
+			if i >= 0 && int(i) < len(in.cvt) {
+				in.cvt[i] = v
+			}
+		case op == 0x70: // WCVTF
+			v := in.pop()
+			i := in.pop()
+			if i >= 0 && int(i) < len(in.cvt) {
+				in.cvt[i] = in.scale(v)
+			}
+		case op == 0x45: // RCVT
+			in.push(in.readCVT(in.pop()))
+
+		case op == 0x46 || op == 0x47: // GC[a]
+			p := in.pop()
+			z := in.point(2, p)
+			if op == 0x46 {
+				in.push(in.project(z.cur[p]))
+			} else {
+				in.push(in.dualProject(z.orig[p]))
+			}
+
+		case op == 0x48: // SCFS
+			k := in.pop()
+			p := in.pop()
+			z := in.point(2, p)
+			in.move(z, p, k-in.project(z.cur[p]))
+			if gs.zp[2] == 0 {
+				z.orig[p] = z.cur[p]
+			}
+
+		case op == 0x49 || op == 0x4A: // MD[a]
+			p2 := in.pop()
+			p1 := in.pop()
+			z1 := in.point(1, p2)
+			z0 := in.point(0, p1)
+			if op == 0x49 {
+				in.push(in.project(sub(z0.cur[p1], z1.cur[p2])))
+			} else {
+				in.push(in.dualProject(sub(z0.orig[p1], z1.orig[p2])))
+			}
+
+		case op == 0x4B || op == 0x4C: // MPPEM, MPS
+			in.push(in.ppem)
+
+		case op == 0x50: // LT
+			b := in.pop()
+			in.pushBool(in.pop() < b)
+		case op == 0x51: // LTEQ
+			b := in.pop()
+			in.pushBool(in.pop() <= b)
+		case op == 0x52: // GT
+			b := in.pop()
+			in.pushBool(in.pop() > b)
+		case op == 0x53: // GTEQ
+			b := in.pop()
+			in.pushBool(in.pop() >= b)
+		case op == 0x54: // EQ
+			in.pushBool(in.pop() == in.pop())
+		case op == 0x55: // NEQ
+			in.pushBool(in.pop() != in.pop())
+		case op == 0x56: // ODD
+			in.pushBool(in.round(in.pop())&127 == 64)
+		case op == 0x57: // EVEN
+			in.pushBool(in.round(in.pop())&127 == 0)
+		case op == 0x5A: // AND
+			b := in.pop()
+			a := in.pop()
+			in.pushBool(a != 0 && b != 0)
+		case op == 0x5B: // OR
+			b := in.pop()
+			a := in.pop()
+			in.pushBool(a != 0 || b != 0)
+		case op == 0x5C: // NOT
+			in.pushBool(in.pop() == 0)
+
+		case op == 0x58: // IF
+			if in.pop() == 0 {
+				next = skipConditional(code, next, true)
+			}
+		case op == 0x59: // EIF
+			// nothing to do
+
+		case op == 0x60: // ADD
+			b := in.pop()
+			in.push(in.pop() + b)
+		case op == 0x61: // SUB
+			b := in.pop()
+			in.push(in.pop() - b)
+		case op == 0x62: // DIV
+			b := in.pop()
+			a := in.pop()
+			if b == 0 {
+				fail(errDivByZero)
+			}
+			in.push(clamp32(int64(a) * 64 / int64(b)))
+		case op == 0x63: // MUL
+			b := in.pop()
+			in.push(mulDiv(in.pop(), b, 64))
+		case op == 0x64: // ABS
+			v := in.pop()
+			if v < 0 {
+				v = -v
+			}
+			in.push(v)
+		case op == 0x65: // NEG
+			in.push(-in.pop())
+		case op == 0x66: // FLOOR
+			in.push(in.pop() &^ 63)
+		case op == 0x67: // CEILING
+			in.push((in.pop() + 63) &^ 63)
+		case op == 0x8B: // MAX
+			b := in.pop()
+			in.push(max(in.pop(), b))
+		case op == 0x8C: // MIN
+			b := in.pop()
+			in.push(min(in.pop(), b))
+
+		case op >= 0x68 && op <= 0x6B: // ROUND[ab]
+			in.push(in.round(in.pop()))
+		case op >= 0x6C && op <= 0x6F: // NROUND[ab]
+			// no engine compensation is used
+
+		case op == 0x5D || op == 0x71 || op == 0x72: // DELTAP1, DELTAP2, DELTAP3
+			in.deltaP(op)
+		case op >= 0x73 && op <= 0x75: // DELTAC1, DELTAC2, DELTAC3
+			in.deltaC(op)
+
+		case op == 0x80: // FLIPPT
+			for range max(gs.loop, 1) {
+				p := in.pop()
+				z := in.point(0, p)
+				z.onCurve[p] = !z.onCurve[p]
+			}
+			gs.loop = 1
+		case op == 0x81 || op == 0x82: // FLIPRGON, FLIPRGOFF
+			hi := in.pop()
+			lo := in.pop()
+			z := in.zones[1]
+			if lo < 0 || hi < lo || int(hi) >= len(z.onCurve) {
+				fail(fmt.Errorf("invalid point range %d-%d", lo, hi))
+			}
+			for i := lo; i <= hi; i++ {
+				z.onCurve[i] = op == 0x81
+			}
+
+		case op == 0x86 || op == 0x87: // SDPVTL[a]
+			p2 := in.pop()
+			p1 := in.pop()
+			z1 := in.point(1, p1)
+			z2 := in.point(2, p2)
+			gs.dv = in.lineVector(z1.orig[p1], z2.orig[p2], op&1 != 0)
+			gs.pv = in.lineVector(z1.cur[p1], z2.cur[p2], op&1 != 0)
+
+		case op == 0x88: // GETINFO
+			sel := in.pop()
+			var res int32
+			if sel&1 != 0 {
+				res |= interpreterVersion
+			}
+			if sel&32 != 0 {
+				res |= 1 << 12 // grayscale rendering
+			}
+			in.push(res)
+
+		case op == 0x7F: // AA
+			in.pop()
+
+		case op >= 0xC0 && op <= 0xDF: // MDRP[abcde]
+			in.mdrp(op)
+		case op >= 0xE0: // MIRP[abcde]
+			in.mirp(op)
+
+		default:
+			body, ok := in.idefs[op]
+			if !ok {
+				fail(fmt.Errorf("invalid instruction 0x%02X", op))
+			}
+			in.exec(body, pcp, depth+1)
+		}
+
+		pc = next
+	}
+}
+
+// interpreterVersion is the version number returned by GETINFO.
+// This is the value used by FreeType for its classic interpreter.
+const interpreterVersion = 35
+
+func (in *Interpreter) function(f int32) []byte {
+	body, ok := in.funcs[f]
+	if !ok {
+		fail(fmt.Errorf("undefined function %d", f))
+	}
+	return body
+}
+
+func (in *Interpreter) readCVT(i int32) int32 {
+	if i < 0 || int(i) >= len(in.cvt) {
+		return 0
+	}
+	return in.cvt[i]
+}
+
+func (in *Interpreter) pushBytes(code []byte, pos, n int) int {
+	if pos+n > len(code) {
+		fail(errCodeOverflow)
+	}
+	for _, b := range code[pos : pos+n] {
+		in.push(int32(b))
+	}
+	return pos + n
+}
+
+func (in *Interpreter) pushWords(code []byte, pos, n int) int {
+	if pos+2*n > len(code) {
+		fail(errCodeOverflow)
+	}
+	for i := range n {
+		in.push(int32(int16(code[pos+2*i])<<8 | int16(code[pos+2*i+1])))
+	}
+	return pos + 2*n
+}
+
+// jump returns the target of a relative jump at position pc.
+func jump(code []byte, pc int, offs int32) int {
+	target := pc + int(offs)
+	if target < 0 || target > len(code) || offs == 0 {
+		fail(fmt.Errorf("invalid jump offset %d", offs))
+	}
+	return target
+}
+
+// instructionLen returns the length of the instruction at position pc,
+// including any inline data.
+func instructionLen(code []byte, pc int) int {
+	op := code[pc]
+	switch {
+	case op == 0x40: // NPUSHB
+		if pc+1 >= len(code) {
+			fail(errCodeOverflow)
+		}
+		return 2 + int(code[pc+1])
+	case op == 0x41: // NPUSHW
+		if pc+1 >= len(code) {
+			fail(errCodeOverflow)
+		}
+		return 2 + 2*int(code[pc+1])
+	case op >= 0xB0 && op <= 0xB7:
+		return 1 + int(op-0xB0+1)
+	case op >= 0xB8 && op <= 0xBF:
+		return 1 + 2*int(op-0xB8+1)
+	}
+	return 1
+}
+
+// skipConditional skips instructions, starting at pos, until the ELSE or
+// EIF matching an IF instruction is found.  If stopAtElse is false, only
+// EIF is accepted.  The function returns the position after the ELSE or
+// EIF instruction.
+func skipConditional(code []byte, pos int, stopAtElse bool) int {
+	level := 0
+	for pos < len(code) {
+		switch code[pos] {
+		case 0x58: // IF
+			level++
+		case 0x1B: // ELSE
+			if level == 0 && stopAtElse {
+				return pos + 1
+			}
+		case 0x59: // EIF
+			if level == 0 {
+				return pos + 1
+			}
+			level--
+		}
+		pos += instructionLen(code, pos)
+	}
+	fail(errors.New("missing EIF"))
+	return 0
+}
+
+// findEndf returns the position of the ENDF instruction which ends a
+// function definition starting at pos.
+func findEndf(code []byte, pos int) int {
+	for pos < len(code) {
+		switch code[pos] {
+		case 0x2D: // ENDF
+			return pos
+		case 0x2C, 0x89: // FDEF, IDEF
+			fail(errors.New("nested function definition"))
+		}
+		pos += instructionLen(code, pos)
+	}
+	fail(errors.New("missing ENDF"))
+	return 0
+}
@@ -0,0 +1,218 @@
+// seehuhn.de/go/sfnt - a library for reading and writing font files
+// Copyright (C) 2026  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package hinting
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"seehuhn.de/go/sfnt/glyf"
+	"seehuhn.de/go/sfnt/glyph"
+)
+
+// numPhantom is the number of phantom points appended to each glyph.
+// The first two phantom points mark the glyph origin and the advance
+// width, the other two give the vertical extent of the glyph.
+const numPhantom = 4
+
+// maxComponentDepth limits the nesting of composite glyphs.
+const maxComponentDepth = 16
+
+// loadGlyph returns a zone containing the grid-fitted points of a glyph,
+// followed by the four phantom points.
+func (in *Interpreter) loadGlyph(gid glyph.ID, depth int) (*zone, error) {
+	if depth > maxComponentDepth {
+		return nil, errComponentDepth
+	}
+	g := in.outlines.Glyphs[gid]
+	if g == nil {
+		z := &zone{}
+		z.setSize(numPhantom)
+		in.setPhantom(z, gid, nil)
+		return z, nil
+	}
+
+	switch d := g.Data.(type) {
+	case glyf.SimpleGlyph:
+		outline, err := d.Unpack()
+		if err != nil {
+			return nil, err
+		}
+		n := 0
+		for _, c := range outline.Contours {
+			n += len(c)
+		}
+		z := &zone{}
+		z.setSize(n + numPhantom)
+		i := 0
+		for _, c := range outline.Contours {
+			for _, p := range c {
+				z.orig[i] = vec{in.scale(int32(p.X)), in.scale(int32(p.Y))}
+				z.onCurve[i] = p.OnCurve
+				i++
+			}
+			z.ends = append(z.ends, i)
+		}
+		copy(z.cur, z.orig)
+		in.setPhantom(z, gid, g)
+		err = in.hint(z, gid, outline.Instructions)
+		if err != nil {
+			return nil, err
+		}
+		return z, nil
+
+	case glyf.CompositeGlyph:
+		return in.loadComposite(gid, g, d, depth)
+	}
+	return nil, fmt.Errorf("hinting: glyph %d: unknown glyph type", gid)
+}
+
+// loadComposite loads and hints a composite glyph.
+func (in *Interpreter) loadComposite(gid glyph.ID, g *glyf.Glyph, d glyf.CompositeGlyph, depth int) (*zone, error) {
+	z := &zone{}
+	var phantom []vec
+	for _, comp := range d.Components {
+		cu, err := comp.Unpack()
+		if err != nil {
+			return nil, err
+		}
+		if int(cu.Child) >= len(in.outlines.Glyphs) {
+			return nil, fmt.Errorf("hinting: glyph %d: invalid component %d", gid, cu.Child)
+		}
+		child, err := in.loadGlyph(cu.Child, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		n := len(child.cur) - numPhantom
+
+		M := cu.Trfm
+		pts := child.cur[:n]
+		if M[0] != 1 || M[1] != 0 || M[2] != 0 || M[3] != 1 {
+			pts = make([]vec, n)
+			for i, p := range child.cur[:n] {
+				pts[i] = transform(M, p)
+			}
+		}
+
+		var offs vec
+		if cu.AlignPoints {
+			ours, theirs := int(cu.OurPoint), int(cu.TheirPoint)
+			if ours >= len(z.cur) || theirs >= n {
+				return nil, fmt.Errorf("hinting: glyph %d: invalid anchor points", gid)
+			}
+			offs = sub(z.cur[ours], pts[theirs])
+		} else {
+			dx, dy := M[4], M[5]
+			if cu.ScaledComponentOffset {
+				dx, dy = M[0]*M[4]+M[2]*M[5], M[1]*M[4]+M[3]*M[5]
+			}
+			offs = vec{
+				in.scale(int32(math.Round(dx))),
+				in.scale(int32(math.Round(dy))),
+			}
+			if cu.RoundXYToGrid {
+				offs.x = (offs.x + 32) &^ 63
+				offs.y = (offs.y + 32) &^ 63
+			}
+		}
+
+		base := len(z.cur)
+		for i, p := range pts {
+			z.cur = append(z.cur, vec{p.x + offs.x, p.y + offs.y})
+			z.onCurve = append(z.onCurve, child.onCurve[i])
+		}
+		for _, end := range child.ends {
+			z.ends = append(z.ends, base+end)
+		}
+		if cu.UseMyMetrics {
+			phantom = child.cur[n:]
+		}
+	}
+
+	n := len(z.cur)
+	z.cur = append(z.cur, make([]vec, numPhantom)...)
+	z.onCurve = append(z.onCurve, make([]bool, numPhantom)...)
+	z.orig = make([]vec, len(z.cur))
+	z.touchX = make([]bool, len(z.cur))
+	z.touchY = make([]bool, len(z.cur))
+	in.setPhantom(z, gid, g)
+	if phantom != nil {
+		copy(z.cur[n:], phantom)
+	}
+
+	if err := in.hint(z, gid, d.Instructions); err != nil {
+		return nil, err
+	}
+	return z, nil
+}
+
+func transform(M [6]float64, p vec) vec {
+	x, y := float64(p.x), float64(p.y)
+	return vec{
+		x: int32(math.Round(M[0]*x + M[2]*y)),
+		y: int32(math.Round(M[1]*x + M[3]*y)),
+	}
+}
+
+// setPhantom sets the phantom points at the end of z.  The glyph origin is
+// assumed to be at the left side bearing of the glyph.
+func (in *Interpreter) setPhantom(z *zone, gid glyph.ID, g *glyf.Glyph) {
+	var advance int32
+	if int(gid) < len(in.outlines.Widths) {
+		advance = int32(in.outlines.Widths[gid])
+	}
+	var top, bottom int32
+	if g != nil {
+		top, bottom = int32(g.URy), int32(g.LLy)
+	}
+
+	n := len(z.cur) - numPhantom
+	z.cur[n] = vec{0, 0}
+	z.cur[n+1] = vec{round64(in.scale(advance)), 0}
+	z.cur[n+2] = vec{0, round64(in.scale(top))}
+	z.cur[n+3] = vec{0, round64(in.scale(bottom))}
+}
+
+// round64 rounds a 26.6 value to the nearest integer.
+func round64(x int32) int32 {
+	return (x + 32) &^ 63
+}
+
+// hint runs the glyph program for the points in z.
+func (in *Interpreter) hint(z *zone, gid glyph.ID, code []byte) error {
+	copy(z.orig, z.cur)
+	clear(z.touchX)
+	clear(z.touchY)
+	if len(code) == 0 || in.defaults.instructCtrl&1 != 0 {
+		return nil
+	}
+
+	twilight := in.twilight.clone()
+	in.zones = [2]*zone{&twilight, z}
+	copy(in.cvt, in.baseCVT)
+	copy(in.storage, in.baseStorage)
+	if in.defaults.instructCtrl&2 != 0 {
+		in.gs = defaultGraphicsState
+	} else {
+		in.gs = in.defaults
+	}
+	in.resetZonePointers()
+	return in.execute(fmt.Sprintf("glyph %d", gid), code)
+}
+
+var errComponentDepth = errors.New("hinting: composite glyphs nested too deeply")
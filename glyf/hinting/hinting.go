@@ -0,0 +1,230 @@
+// seehuhn.de/go/sfnt - a library for reading and writing font files
+// Copyright (C) 2026  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package hinting implements an interpreter for TrueType instructions.
+//
+// The interpreter executes the "fpgm" and "prep" programs of a font and the
+// instructions of individual glyphs, to produce grid-fitted glyph outlines
+// for a given pixel size.
+//
+// https://learn.microsoft.com/en-us/typography/opentype/spec/tt_instructions
+package hinting
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"golang.org/x/image/math/fixed"
+
+	"seehuhn.de/go/sfnt/glyf"
+	"seehuhn.de/go/sfnt/glyph"
+)
+
+// Interpreter executes TrueType instructions for the glyphs of a font.
+//
+// An Interpreter is not safe for concurrent use.
+type Interpreter struct {
+	outlines *glyf.Outlines
+	upem     int32
+
+	fpgm, prep []byte
+	cvtFUnits  []int16
+
+	// The following fields are set by SetPPEM.
+	ppem     int32
+	cvt      []int32 // in 26.6 pixel units
+	storage  []int32
+	funcs    map[int32][]byte
+	idefs    map[byte][]byte
+	defaults graphicsState // the graphics state after the "prep" program
+	twilight zone          // the twilight zone after the "prep" program
+
+	// baseCVT and baseStorage hold the values after the "prep" program.
+	// These are restored before each glyph program is run.
+	baseCVT     []int32
+	baseStorage []int32
+
+	// execution state
+	gs       graphicsState
+	zones    [2]*zone
+	zp       [3]*zone
+	stack    []int32
+	maxStack int
+	inPrep   bool
+	steps    int
+}
+
+// New returns a new interpreter for the given glyph outlines.
+// The argument unitsPerEm gives the number of font design units
+// per em.  [Interpreter.SetPPEM] must be called before glyphs
+// can be hinted.
+func New(outlines *glyf.Outlines, unitsPerEm uint16) *Interpreter {
+	if unitsPerEm == 0 {
+		unitsPerEm = 1000
+	}
+	in := &Interpreter{
+		outlines: outlines,
+		upem:     int32(unitsPerEm),
+		fpgm:     outlines.Tables["fpgm"],
+		prep:     outlines.Tables["prep"],
+	}
+	cvt := outlines.Tables["cvt "]
+	in.cvtFUnits = make([]int16, len(cvt)/2)
+	for i := range in.cvtFUnits {
+		in.cvtFUnits[i] = int16(cvt[2*i])<<8 | int16(cvt[2*i+1])
+	}
+
+	in.maxStack = 1024
+	if mp := outlines.Maxp; mp != nil {
+		// Many fonts underestimate the required stack size.
+		in.maxStack = max(int(mp.MaxStackElements)+32, 256)
+	}
+	return in
+}
+
+// PPEM returns the current size in pixels per em.
+func (in *Interpreter) PPEM() int {
+	return int(in.ppem)
+}
+
+// SetPPEM sets the size in pixels per em and runs the "fpgm" and "prep"
+// programs of the font.
+func (in *Interpreter) SetPPEM(ppem int) error {
+	if ppem <= 0 || ppem > 0x7FFF {
+		return fmt.Errorf("hinting: invalid ppem %d", ppem)
+	}
+	in.ppem = int32(ppem)
+
+	in.cvt = make([]int32, len(in.cvtFUnits))
+	for i, v := range in.cvtFUnits {
+		in.cvt[i] = in.scale(int32(v))
+	}
+	numStorage := 0
+	numTwilight := 0
+	if mp := in.outlines.Maxp; mp != nil {
+		numStorage = int(mp.MaxStorage)
+		numTwilight = int(mp.MaxTwilightPoints)
+	}
+	in.storage = make([]int32, numStorage)
+	in.funcs = make(map[int32][]byte)
+	in.idefs = make(map[byte][]byte)
+	in.twilight = zone{}
+	in.twilight.setSize(numTwilight)
+
+	in.gs = defaultGraphicsState
+	in.zones = [2]*zone{&in.twilight, {}}
+	in.resetZonePointers()
+	if err := in.execute("fpgm", in.fpgm); err != nil {
+		in.ppem = 0
+		return err
+	}
+
+	in.gs = defaultGraphicsState
+	in.resetZonePointers()
+	in.inPrep = true
+	err := in.execute("prep", in.prep)
+	in.inPrep = false
+	if err != nil {
+		in.ppem = 0
+		return err
+	}
+	in.defaults = in.gs
+	in.defaults.resetNonPersistent()
+	in.baseCVT = slices.Clone(in.cvt)
+	in.baseStorage = slices.Clone(in.storage)
+	return nil
+}
+
+// Glyph is a grid-fitted glyph outline.
+type Glyph struct {
+	// Contours are the contours of the glyph.  Coordinates are in pixels,
+	// relative to the glyph origin, with the y-axis pointing up.
+	Contours [][]Point
+
+	// Advance is the grid-fitted advance width of the glyph.
+	Advance fixed.Int26_6
+}
+
+// Point is a point of a grid-fitted glyph outline.
+type Point struct {
+	X, Y    fixed.Int26_6
+	OnCurve bool
+}
+
+// Glyph returns the grid-fitted outline of the glyph gid at the current
+// size.  If the glyph has no outline, the returned glyph has no contours.
+func (in *Interpreter) Glyph(gid glyph.ID) (*Glyph, error) {
+	if in.ppem == 0 {
+		return nil, errNoSize
+	}
+	if int(gid) >= len(in.outlines.Glyphs) {
+		return nil, fmt.Errorf("hinting: invalid glyph ID %d", gid)
+	}
+
+	z, err := in.loadGlyph(gid, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	n := len(z.cur) - numPhantom
+	pp1 := z.cur[n]
+	pp2 := z.cur[n+1]
+	res := &Glyph{
+		Advance: fixed.Int26_6(pp2.x - pp1.x),
+	}
+	start := 0
+	for _, end := range z.ends {
+		contour := make([]Point, end-start)
+		for i := range contour {
+			p := z.cur[start+i]
+			contour[i] = Point{
+				X:       fixed.Int26_6(p.x - pp1.x),
+				Y:       fixed.Int26_6(p.y),
+				OnCurve: z.onCurve[start+i],
+			}
+		}
+		res.Contours = append(res.Contours, contour)
+		start = end
+	}
+	return res, nil
+}
+
+// scale converts a value from font design units to 26.6 pixel units.
+func (in *Interpreter) scale(v int32) int32 {
+	return mulDiv(v, in.ppem*64, in.upem)
+}
+
+// execute runs a top-level program.
+func (in *Interpreter) execute(name string, code []byte) error {
+	in.stack = in.stack[:0]
+	in.steps = 0
+	err := in.run(code, 0)
+	if err != nil {
+		return fmt.Errorf("hinting: %s: %w", name, err)
+	}
+	return nil
+}
+
+var (
+	errNoSize         = errors.New("hinting: SetPPEM has not been called")
+	errStackOverflow  = errors.New("stack overflow")
+	errStackUnderflow = errors.New("stack underflow")
+	errTooManySteps   = errors.New("too many instructions executed")
+	errTooDeep        = errors.New("too many nested function calls")
+	errDivByZero      = errors.New("division by zero")
+	errCodeOverflow   = errors.New("unexpected end of instructions")
+)
@@ -0,0 +1,292 @@
+// seehuhn.de/go/sfnt - a library for reading and writing font files
+// Copyright (C) 2026  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package hinting
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/math/fixed"
+
+	"seehuhn.de/go/postscript/funit"
+	"seehuhn.de/go/sfnt"
+	"seehuhn.de/go/sfnt/glyf"
+	"seehuhn.de/go/sfnt/glyph"
+	"seehuhn.de/go/sfnt/maxp"
+	"seehuhn.de/go/sfnt/parser"
+)
+
+// makeOutlines returns a font with 1000 units per em, containing the given
+// glyphs and programs.  All glyphs have an advance width of 500.
+func makeOutlines(glyphs glyf.Glyphs, fpgm, prep []byte, cvt ...int16) *glyf.Outlines {
+	cvtData := make([]byte, 2*len(cvt))
+	for i, v := range cvt {
+		cvtData[2*i] = byte(v >> 8)
+		cvtData[2*i+1] = byte(v)
+	}
+	widths := make([]funit.Uint16, len(glyphs))
+	for i := range widths {
+		widths[i] = 500
+	}
+	return &glyf.Outlines{
+		Glyphs: glyphs,
+		Widths: widths,
+		Tables: map[string][]byte{
+			"fpgm": fpgm,
+			"prep": prep,
+			"cvt ": cvtData,
+		},
+		Maxp: &maxp.TTFInfo{
+			MaxStorage:        10,
+			MaxTwilightPoints: 4,
+			MaxStackElements:  16,
+		},
+	}
+}
+
+func TestPrograms(t *testing.T) {
+	fpgm := []byte{
+		0xB0, 0x00, 0x2C, // PUSHB 0, FDEF
+		0xB0, 0x2A, // PUSHB 42
+		0x2D, // ENDF
+	}
+	cases := []struct {
+		name string
+		prep []byte
+		want []int32
+	}{
+		{"ADD", []byte{0xB1, 2, 3, 0x60}, []int32{5}},
+		{"SUB", []byte{0xB1, 5, 3, 0x61}, []int32{2}},
+		{"DIV", []byte{0xB1, 0x80, 0x40, 0x62}, []int32{128}},
+		{"MUL", []byte{0xB1, 0x80, 0xC0, 0x63}, []int32{384}},
+		{"NPUSHW", []byte{0x41, 2, 0xFF, 0xFF, 0x01, 0x00}, []int32{-1, 256}},
+		{"IF true", []byte{0xB0, 1, 0x58, 0xB0, 7, 0x1B, 0xB0, 8, 0x59}, []int32{7}},
+		{"IF false", []byte{0xB0, 0, 0x58, 0xB0, 7, 0x1B, 0xB0, 8, 0x59}, []int32{8}},
+		{"nested IF", []byte{0xB0, 0, 0x58, 0xB0, 1, 0x58, 0xB0, 7, 0x59, 0x1B, 0xB0, 9, 0x59}, []int32{9}},
+		{"JMPR", []byte{0xB0, 3, 0x1C, 0xB0, 7, 0xB0, 8}, []int32{8}},
+		{"CINDEX", []byte{0xB2, 1, 2, 3, 0xB0, 3, 0x25}, []int32{1, 2, 3, 1}},
+		{"MINDEX", []byte{0xB2, 1, 2, 3, 0xB0, 3, 0x26}, []int32{2, 3, 1}},
+		{"ROLL", []byte{0xB2, 1, 2, 3, 0x8A}, []int32{2, 3, 1}},
+		{"SWAP DEPTH", []byte{0xB1, 1, 2, 0x23, 0x24}, []int32{2, 1, 2}},
+		{"RTG", []byte{0xB0, 0x60, 0x68}, []int32{128}},
+		{"RDTG", []byte{0x7D, 0xB0, 0x60, 0x68}, []int32{64}},
+		{"RUTG", []byte{0x7C, 0xB0, 0x41, 0x68}, []int32{128}},
+		{"RTHG", []byte{0x19, 0xB0, 0x50, 0x68}, []int32{96}},
+		{"SROUND", []byte{0xB0, 0x48, 0x76, 0xB0, 0x60, 0x68}, []int32{128}},
+		{"MPPEM", []byte{0x4B}, []int32{10}},
+		{"storage", []byte{0xB1, 3, 42, 0x42, 0xB0, 3, 0x43}, []int32{42}},
+		{"RCVT", []byte{0xB0, 0, 0x45}, []int32{64}},
+		{"WCVTF", []byte{0xB1, 0, 200, 0x70, 0xB0, 0, 0x45}, []int32{128}},
+		{"DELTAC1", []byte{0xB2, 0x18, 0, 1, 0x73, 0xB0, 0, 0x45}, []int32{72}},
+		{"CALL", []byte{0xB0, 0, 0x2B}, []int32{42}},
+		{"LOOPCALL", []byte{0xB1, 3, 0, 0x2A}, []int32{42, 42, 42}},
+		{"GETINFO", []byte{0xB0, 1, 0x88}, []int32{35}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			in := New(makeOutlines(nil, fpgm, c.prep, 100), 1000)
+			err := in.SetPPEM(10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d := cmp.Diff(c.want, in.stack); d != "" {
+				t.Errorf("unexpected stack (-want +got):\n%s", d)
+			}
+		})
+	}
+}
+
+func TestProgramErrors(t *testing.T) {
+	cases := []struct {
+		name string
+		prep []byte
+		want error
+	}{
+		{"underflow", []byte{0x60}, errStackUnderflow},
+		{"division by zero", []byte{0xB1, 1, 0, 0x62}, errDivByZero},
+		{"infinite loop", []byte{0xB8, 0xFF, 0xFD, 0x1C}, errTooManySteps},
+		{"truncated push", []byte{0xB2, 1}, errCodeOverflow},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			in := New(makeOutlines(nil, nil, c.prep), 1000)
+			err := in.SetPPEM(10)
+			if !errors.Is(err, c.want) {
+				t.Errorf("got error %v, want %v", err, c.want)
+			}
+		})
+	}
+}
+
+// square returns a glyph with a single rectangular contour.
+func square(x0, y0, x1, y1 funit.Int16, code ...byte) *glyf.Glyph {
+	u := &glyf.SimpleUnpacked{
+		Contours: []glyf.Contour{{
+			{X: x0, Y: y0, OnCurve: true},
+			{X: x0, Y: y1, OnCurve: true},
+			{X: x1, Y: y1, OnCurve: true},
+			{X: x1, Y: y0, OnCurve: true},
+		}},
+		Instructions: code,
+	}
+	g := u.AsGlyph()
+	return &g
+}
+
+func p(x, y int) Point {
+	return Point{X: fixed.Int26_6(x), Y: fixed.Int26_6(y), OnCurve: true}
+}
+
+func TestGlyphPrograms(t *testing.T) {
+	// At 10 ppem and 1000 units per em, one font unit is 0.64/64 pixels.
+	cases := []struct {
+		name  string
+		glyph *glyf.Glyph
+		want  []Point
+	}{
+		{
+			name:  "unhinted",
+			glyph: square(10, 10, 300, 310),
+			want:  []Point{p(6, 6), p(6, 198), p(192, 198), p(192, 6)},
+		},
+		{
+			name: "MDAP IUP",
+			glyph: square(10, 10, 300, 310,
+				0x00,       // SVTCA[y]
+				0xB0, 0x00, // PUSHB 0
+				0x2F, // MDAP[r]
+				0x30, // IUP[y]
+			),
+			want: []Point{p(6, 0), p(6, 192), p(192, 192), p(192, 0)},
+		},
+		{
+			name: "SHPIX",
+			glyph: square(10, 10, 300, 310,
+				0x01,             // SVTCA[x]
+				0xB1, 0x02, 0x40, // PUSHB 2 64
+				0x38, // SHPIX
+			),
+			want: []Point{p(6, 6), p(6, 198), p(256, 198), p(192, 6)},
+		},
+		{
+			name: "MIRP",
+			glyph: square(100, 0, 390, 300,
+				0x01,       // SVTCA[x]
+				0xB0, 0x00, // PUSHB 0
+				0x2F,             // MDAP[r]
+				0xB1, 0x03, 0x00, // PUSHB 3 0
+				0xEC, // MIRP[min, rnd]
+				0x31, // IUP[x]
+			),
+			want: []Point{p(64, 0), p(64, 192), p(256, 192), p(256, 0)},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			in := New(makeOutlines(glyf.Glyphs{c.glyph}, nil, nil, 300), 1000)
+			if err := in.SetPPEM(10); err != nil {
+				t.Fatal(err)
+			}
+			g, err := in.Glyph(0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d := cmp.Diff([][]Point{c.want}, g.Contours); d != "" {
+				t.Errorf("unexpected outline (-want +got):\n%s", d)
+			}
+			if g.Advance != 5*64 {
+				t.Errorf("wrong advance %s", g.Advance)
+			}
+		})
+	}
+}
+
+func TestComposite(t *testing.T) {
+	cu := &glyf.ComponentUnpacked{
+		Child:         0,
+		Trfm:          [6]float64{1, 0, 0, 1, 110, 0},
+		RoundXYToGrid: true,
+	}
+	comp := &glyf.Glyph{
+		Data: glyf.CompositeGlyph{
+			Components: []glyf.GlyphComponent{cu.Pack()},
+		},
+	}
+	o := makeOutlines(glyf.Glyphs{square(10, 10, 300, 310), comp}, nil, nil)
+	in := New(o, 1000)
+	if err := in.SetPPEM(10); err != nil {
+		t.Fatal(err)
+	}
+	g, err := in.Glyph(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The offset of 110 units is 70.4/64 pixels, rounded to one pixel.
+	want := [][]Point{{p(70, 6), p(70, 198), p(256, 198), p(256, 6)}}
+	if d := cmp.Diff(want, g.Contours); d != "" {
+		t.Errorf("unexpected outline (-want +got):\n%s", d)
+	}
+}
+
+// TestGoRegular hints all glyphs of the Go Regular font, which uses
+// instructions generated by ttfautohint, and checks that the baseline
+// and the x-height are aligned to the pixel grid.
+func TestGoRegular(t *testing.T) {
+	f, err := sfnt.Read(bytes.NewReader(goregular.TTF), parser.NewBudget(int64(len(goregular.TTF))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	outlines := f.Outlines.(*glyf.Outlines)
+	cmap, err := f.CMapTable.GetBest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	in := New(outlines, f.UnitsPerEm)
+	for _, ppem := range []int{7, 10, 12, 16, 23, 40} {
+		if err := in.SetPPEM(ppem); err != nil {
+			t.Fatal(err)
+		}
+		for gid := range outlines.Glyphs {
+			g, err := in.Glyph(glyph.ID(gid))
+			if err != nil {
+				t.Fatalf("ppem %d, glyph %d: %v", ppem, gid, err)
+			}
+			if g.Advance%64 != 0 {
+				t.Errorf("ppem %d, glyph %d: advance %s not rounded", ppem, gid, g.Advance)
+			}
+		}
+
+		for _, r := range "xz" {
+			g, err := in.Glyph(cmap.Lookup(r))
+			if err != nil {
+				t.Fatal(err)
+			}
+			bottom, top := g.Contours[0][0].Y, g.Contours[0][0].Y
+			for _, pt := range g.Contours[0] {
+				bottom = min(bottom, pt.Y)
+				top = max(top, pt.Y)
+			}
+			if bottom != 0 || top%64 != 0 {
+				t.Errorf("ppem %d, %q: vertical extent %s to %s not aligned", ppem, r, bottom, top)
+			}
+		}
+	}
+}
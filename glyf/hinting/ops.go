@@ -0,0 +1,425 @@
+// seehuhn.de/go/sfnt - a library for reading and writing font files
+// Copyright (C) 2026  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package hinting
+
+// This file implements the instructions which move points.
+
+// lineVector returns the unit vector pointing from p2 to p1.  If perp is
+// set, the vector is rotated counter-clockwise by 90 degrees.
+func (in *Interpreter) lineVector(p1, p2 vec, perp bool) vec {
+	d := sub(p1, p2)
+	if d.x == 0 && d.y == 0 {
+		return xAxis
+	}
+	if perp {
+		d.x, d.y = -d.y, d.x
+	}
+	return normalize(d.x, d.y)
+}
+
+// isect implements the ISECT instruction.
+func (in *Interpreter) isect() {
+	b1 := in.pop()
+	b0 := in.pop()
+	a1 := in.pop()
+	a0 := in.pop()
+	p := in.pop()
+	zb := in.point(0, b0)
+	in.point(0, b1)
+	za := in.point(1, a0)
+	in.point(1, a1)
+	z := in.point(2, p)
+
+	pa0, pa1 := za.cur[a0], za.cur[a1]
+	pb0, pb1 := zb.cur[b0], zb.cur[b1]
+	dbx, dby := int64(pb1.x-pb0.x), int64(pb1.y-pb0.y)
+	dax, day := int64(pa1.x-pa0.x), int64(pa1.y-pa0.y)
+	dx, dy := int64(pb0.x-pa0.x), int64(pb0.y-pa0.y)
+
+	disc := dax*(-dby) + day*dbx
+	dotP := dax*dbx + day*dby
+	if 19*abs64(disc) > abs64(dotP) {
+		// The lines are not (nearly) parallel.
+		val := dx*(-dby) + dy*dbx
+		z.cur[p] = vec{
+			x: pa0.x + clamp32(divRound(val*dax, disc)),
+			y: pa0.y + clamp32(divRound(val*day, disc)),
+		}
+	} else {
+		// Use the middle of the middles of the two lines.
+		z.cur[p] = vec{
+			x: int32((int64(pa0.x) + int64(pa1.x) + int64(pb0.x) + int64(pb1.x)) / 4),
+			y: int32((int64(pa0.y) + int64(pa1.y) + int64(pb0.y) + int64(pb1.y)) / 4),
+		}
+	}
+	z.touchX[p] = true
+	z.touchY[p] = true
+}
+
+func abs64(x int64) int64 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// divRound computes a/b, rounded to the nearest integer.
+func divRound(a, b int64) int64 {
+	neg := (a < 0) != (b < 0)
+	a, b = abs64(a), abs64(b)
+	q := (a + b/2) / b
+	if neg {
+		q = -q
+	}
+	return q
+}
+
+// displacementRef returns the reference point used by the SHP, SHC and
+// SHZ instructions.
+func (in *Interpreter) displacementRef(useRP1 bool) (*zone, int32) {
+	if useRP1 {
+		p := in.gs.rp[1]
+		return in.point(0, p), p
+	}
+	p := in.gs.rp[2]
+	return in.point(1, p), p
+}
+
+// displacement returns the amount by which the reference point has been
+// moved, as a vector along the freedom vector.
+func (in *Interpreter) displacement(z *zone, p int32) vec {
+	d := in.project(sub(z.cur[p], z.orig[p]))
+	fdp := in.fDotP()
+	return vec{
+		x: mulDiv(d, in.gs.fv.x, fdp),
+		y: mulDiv(d, in.gs.fv.y, fdp),
+	}
+}
+
+// mdap implements the MDAP instruction.
+func (in *Interpreter) mdap(doRound bool) {
+	p := in.pop()
+	z := in.point(0, p)
+	var d int32
+	if doRound {
+		cur := in.project(z.cur[p])
+		d = in.round(cur) - cur
+	}
+	in.move(z, p, d)
+	in.gs.rp[0] = p
+	in.gs.rp[1] = p
+}
+
+// miap implements the MIAP instruction.
+func (in *Interpreter) miap(doRound bool) {
+	gs := &in.gs
+	cvtIdx := in.pop()
+	p := in.pop()
+	z := in.point(0, p)
+	dist := in.readCVT(cvtIdx)
+
+	if gs.zp[0] == 0 {
+		z.orig[p] = vec{mul14(dist, gs.fv.x), mul14(dist, gs.fv.y)}
+		z.cur[p] = z.orig[p]
+	}
+	cur := in.project(z.cur[p])
+	if doRound {
+		if abs32(dist-cur) > gs.cvtCutIn {
+			dist = cur
+		}
+		dist = in.round(dist)
+	}
+	in.move(z, p, dist-cur)
+	gs.rp[0] = p
+	gs.rp[1] = p
+}
+
+func abs32(x int32) int32 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// applyMinDist makes sure that dist has at least the minimum distance,
+// keeping the sign of the original distance orig.
+func (in *Interpreter) applyMinDist(dist, orig int32) int32 {
+	minDist := in.gs.minDist
+	if orig >= 0 {
+		if dist < minDist {
+			dist = minDist
+		}
+	} else if dist > -minDist {
+		dist = -minDist
+	}
+	return dist
+}
+
+// singleWidth applies the single width cut-in to a distance.
+func (in *Interpreter) singleWidth(d int32) int32 {
+	gs := &in.gs
+	if abs32(abs32(d)-gs.singleWidth) < gs.singleWidthCutIn {
+		if d >= 0 {
+			return gs.singleWidth
+		}
+		return -gs.singleWidth
+	}
+	return d
+}
+
+// mdrp implements the MDRP instruction.
+func (in *Interpreter) mdrp(op byte) {
+	gs := &in.gs
+	p := in.pop()
+	z1 := in.point(1, p)
+	rp0 := gs.rp[0]
+	z0 := in.point(0, rp0)
+
+	orig := in.dualProject(sub(z1.orig[p], z0.orig[rp0]))
+	orig = in.singleWidth(orig)
+
+	dist := orig
+	if op&4 != 0 {
+		dist = in.round(orig)
+	}
+	if op&8 != 0 {
+		dist = in.applyMinDist(dist, orig)
+	}
+
+	cur := in.project(sub(z1.cur[p], z0.cur[rp0]))
+	in.move(z1, p, dist-cur)
+
+	gs.rp[1] = rp0
+	gs.rp[2] = p
+	if op&16 != 0 {
+		gs.rp[0] = p
+	}
+}
+
+// mirp implements the MIRP instruction.
+func (in *Interpreter) mirp(op byte) {
+	gs := &in.gs
+	cvtIdx := in.pop()
+	p := in.pop()
+	z1 := in.point(1, p)
+	rp0 := gs.rp[0]
+	z0 := in.point(0, rp0)
+
+	cvtDist := in.singleWidth(in.readCVT(cvtIdx))
+
+	if gs.zp[1] == 0 {
+		// In the twilight zone, the original position of the point is
+		// determined by the control value.
+		z1.orig[p] = vec{
+			x: z0.orig[rp0].x + mul14(cvtDist, gs.fv.x),
+			y: z0.orig[rp0].y + mul14(cvtDist, gs.fv.y),
+		}
+		z1.cur[p] = z1.orig[p]
+	}
+
+	orig := in.dualProject(sub(z1.orig[p], z0.orig[rp0]))
+	cur := in.project(sub(z1.cur[p], z0.cur[rp0]))
+
+	if gs.autoFlip && (orig^cvtDist) < 0 {
+		cvtDist = -cvtDist
+	}
+
+	var dist int32
+	if op&4 != 0 {
+		if gs.zp[0] == gs.zp[1] && abs32(cvtDist-orig) > gs.cvtCutIn {
+			cvtDist = orig
+		}
+		dist = in.round(cvtDist)
+	} else {
+		dist = cvtDist
+	}
+	if op&8 != 0 {
+		dist = in.applyMinDist(dist, orig)
+	}
+
+	in.move(z1, p, dist-cur)
+
+	gs.rp[1] = rp0
+	gs.rp[2] = p
+	if op&16 != 0 {
+		gs.rp[0] = p
+	}
+}
+
+// ip implements the IP instruction.
+func (in *Interpreter) ip() {
+	gs := &in.gs
+	rp1, rp2 := gs.rp[1], gs.rp[2]
+	z0 := in.point(0, rp1)
+	z1 := in.point(1, rp2)
+
+	origRange := in.dualProject(sub(z1.orig[rp2], z0.orig[rp1]))
+	curRange := in.project(sub(z1.cur[rp2], z0.cur[rp1]))
+
+	for range max(gs.loop, 1) {
+		p := in.pop()
+		z2 := in.point(2, p)
+		orig := in.dualProject(sub(z2.orig[p], z0.orig[rp1]))
+		cur := in.project(sub(z2.cur[p], z0.cur[rp1]))
+		var dist int32
+		if orig != 0 {
+			if origRange != 0 {
+				dist = mulDiv(orig, curRange, origRange)
+			} else {
+				dist = orig
+			}
+		}
+		in.move(z2, p, dist-cur)
+	}
+	gs.loop = 1
+}
+
+// iup implements the IUP instruction, which interpolates the untouched
+// points of the glyph outline.
+func (in *Interpreter) iup(xDir bool) {
+	z := in.zones[1]
+
+	var touched []bool
+	coord := func(v *vec) *int32 { return &v.y }
+	if xDir {
+		touched = z.touchX
+		coord = func(v *vec) *int32 { return &v.x }
+	} else {
+		touched = z.touchY
+	}
+
+	start := 0
+	for _, end := range z.ends {
+		first := -1
+		for i := start; i < end; i++ {
+			if touched[i] {
+				first = i
+				break
+			}
+		}
+		if first < 0 {
+			start = end
+			continue
+		}
+
+		prev := first
+		for i := first + 1; i < end; i++ {
+			if !touched[i] {
+				continue
+			}
+			if i > prev+1 {
+				iupInterpolate(z, coord, prev+1, i-1, prev, i)
+			}
+			prev = i
+		}
+		if prev == first {
+			// only one touched point: shift the whole contour
+			d := *coord(&z.cur[first]) - *coord(&z.orig[first])
+			for i := start; i < end; i++ {
+				if i != first {
+					*coord(&z.cur[i]) += d
+				}
+			}
+		} else {
+			if prev < end-1 {
+				iupInterpolate(z, coord, prev+1, end-1, prev, first)
+			}
+			if first > start {
+				iupInterpolate(z, coord, start, first-1, prev, first)
+			}
+		}
+		start = end
+	}
+}
+
+// iupInterpolate interpolates the points from p1 to p2 (inclusive) between
+// the touched points ref1 and ref2.
+func iupInterpolate(z *zone, coord func(*vec) *int32, p1, p2, ref1, ref2 int) {
+	orig1, orig2 := *coord(&z.orig[ref1]), *coord(&z.orig[ref2])
+	cur1, cur2 := *coord(&z.cur[ref1]), *coord(&z.cur[ref2])
+	if orig1 > orig2 {
+		orig1, orig2 = orig2, orig1
+		cur1, cur2 = cur2, cur1
+	}
+	d1, d2 := cur1-orig1, cur2-orig2
+
+	for i := p1; i <= p2; i++ {
+		x := *coord(&z.orig[i])
+		switch {
+		case x <= orig1:
+			x += d1
+		case x >= orig2:
+			x += d2
+		default:
+			x = cur1 + mulDiv(x-orig1, cur2-cur1, orig2-orig1)
+		}
+		*coord(&z.cur[i]) = x
+	}
+}
+
+// deltaP implements the DELTAP1, DELTAP2 and DELTAP3 instructions.
+func (in *Interpreter) deltaP(op byte) {
+	n := in.pop()
+	for range max(n, 0) {
+		p := in.pop()
+		arg := in.pop()
+		z := in.zp[0]
+		if p < 0 || int(p) >= len(z.cur) {
+			continue
+		}
+		if d, ok := in.deltaValue(op, arg); ok {
+			in.move(z, p, d)
+		}
+	}
+}
+
+// deltaC implements the DELTAC1, DELTAC2 and DELTAC3 instructions.
+func (in *Interpreter) deltaC(op byte) {
+	n := in.pop()
+	for range max(n, 0) {
+		i := in.pop()
+		arg := in.pop()
+		if i < 0 || int(i) >= len(in.cvt) {
+			continue
+		}
+		if d, ok := in.deltaValue(op, arg); ok {
+			in.cvt[i] += d
+		}
+	}
+}
+
+// deltaValue decodes the argument of a DELTA instruction.  If the
+// exception applies to the current size, the amount of the adjustment is
+// returned.
+func (in *Interpreter) deltaValue(op byte, arg int32) (int32, bool) {
+	gs := &in.gs
+	ppem := (arg>>4)&15 + gs.deltaBase
+	switch op {
+	case 0x71, 0x74: // DELTAP2, DELTAC2
+		ppem += 16
+	case 0x72, 0x75: // DELTAP3, DELTAC3
+		ppem += 32
+	}
+	if ppem != in.ppem {
+		return 0, false
+	}
+	step := arg&15 - 8
+	if step >= 0 {
+		step++
+	}
+	return step * (64 >> gs.deltaShift), true
+}
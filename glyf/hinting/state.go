@@ -0,0 +1,358 @@
+// seehuhn.de/go/sfnt - a library for reading and writing font files
+// Copyright (C) 2026  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package hinting
+
+import "math"
+
+// f26dot6 values are in 26.6 fixed-point pixel units.
+// Unit vectors use 2.14 fixed-point coordinates.
+
+// vec is a pair of 26.6 coordinates, or a 2.14 unit vector.
+type vec struct {
+	x, y int32
+}
+
+var (
+	xAxis = vec{0x4000, 0}
+	yAxis = vec{0, 0x4000}
+)
+
+// roundMode identifies the rounding function of the graphics state.
+type roundMode uint8
+
+const (
+	roundToGrid roundMode = iota
+	roundToHalfGrid
+	roundToDoubleGrid
+	roundDownToGrid
+	roundUpToGrid
+	roundOff
+	roundSuper
+	roundSuper45
+)
+
+// graphicsState holds the graphics state variables of the interpreter.
+type graphicsState struct {
+	pv, fv, dv vec // projection, freedom and dual projection vectors
+
+	rp   [3]int32 // reference points
+	zp   [3]int32 // zone pointers
+	loop int32
+
+	minDist          int32
+	cvtCutIn         int32
+	singleWidthCutIn int32
+	singleWidth      int32
+	deltaBase        int32
+	deltaShift       int32
+	autoFlip         bool
+
+	round         roundMode
+	period, phase int32
+	threshold     int32
+	scanControl   int32
+	scanType      int32
+	instructCtrl  int32
+}
+
+var defaultGraphicsState = graphicsState{
+	pv:         xAxis,
+	fv:         xAxis,
+	dv:         xAxis,
+	zp:         [3]int32{1, 1, 1},
+	loop:       1,
+	minDist:    64,
+	cvtCutIn:   68, // 17/16 pixel
+	deltaBase:  9,
+	deltaShift: 3,
+	autoFlip:   true,
+	round:      roundToGrid,
+	period:     64,
+}
+
+// resetNonPersistent resets the graphics state variables which are not
+// carried over from the "prep" program to the glyph programs.
+func (gs *graphicsState) resetNonPersistent() {
+	gs.pv, gs.fv, gs.dv = xAxis, xAxis, xAxis
+	gs.rp = [3]int32{}
+	gs.zp = [3]int32{1, 1, 1}
+	gs.loop = 1
+}
+
+// zone is a set of points.  Zone 0 is the twilight zone, zone 1 contains
+// the points of the current glyph.
+type zone struct {
+	orig    []vec // original positions, scaled to pixels
+	cur     []vec // current positions
+	touchX  []bool
+	touchY  []bool
+	onCurve []bool
+	ends    []int // end indices (exclusive) of the contours
+}
+
+func (z *zone) setSize(n int) {
+	z.orig = make([]vec, n)
+	z.cur = make([]vec, n)
+	z.touchX = make([]bool, n)
+	z.touchY = make([]bool, n)
+	z.onCurve = make([]bool, n)
+	z.ends = nil
+}
+
+func (z *zone) clone() zone {
+	return zone{
+		orig:    append([]vec(nil), z.orig...),
+		cur:     append([]vec(nil), z.cur...),
+		touchX:  append([]bool(nil), z.touchX...),
+		touchY:  append([]bool(nil), z.touchY...),
+		onCurve: append([]bool(nil), z.onCurve...),
+		ends:    append([]int(nil), z.ends...),
+	}
+}
+
+// numPoints returns the number of points in the zone, not counting
+// phantom points.
+func (z *zone) numPoints() int {
+	if len(z.ends) == 0 {
+		return 0
+	}
+	return z.ends[len(z.ends)-1]
+}
+
+// mulDiv computes a*b/c, rounded to the nearest integer.
+func mulDiv(a, b, c int32) int32 {
+	if c == 0 {
+		return 0x7FFFFFFF
+	}
+	neg := false
+	x, y, z := int64(a), int64(b), int64(c)
+	if x < 0 {
+		x, neg = -x, !neg
+	}
+	if y < 0 {
+		y, neg = -y, !neg
+	}
+	if z < 0 {
+		z, neg = -z, !neg
+	}
+	r := (x*y + z/2) / z
+	if neg {
+		r = -r
+	}
+	return clamp32(r)
+}
+
+func clamp32(x int64) int32 {
+	if x > math.MaxInt32 {
+		return math.MaxInt32
+	} else if x < math.MinInt32 {
+		return math.MinInt32
+	}
+	return int32(x)
+}
+
+// mul14 multiplies a 26.6 value by a 2.14 value.
+func mul14(a, b int32) int32 {
+	return int32((int64(a)*int64(b) + 0x2000) >> 14)
+}
+
+// dot computes the dot product of a 26.6 vector and a 2.14 unit vector.
+func dot(v, u vec) int32 {
+	return int32((int64(v.x)*int64(u.x) + int64(v.y)*int64(u.y) + 0x2000) >> 14)
+}
+
+// normalize returns the 2.14 unit vector with the direction of (x, y).
+// The zero vector is mapped to the x-axis.
+func normalize(x, y int32) vec {
+	if x == 0 && y == 0 {
+		return xAxis
+	}
+	l := math.Hypot(float64(x), float64(y))
+	return vec{
+		x: int32(math.Round(float64(x) / l * 0x4000)),
+		y: int32(math.Round(float64(y) / l * 0x4000)),
+	}
+}
+
+// project returns the length of the projection of v onto the projection
+// vector.
+func (in *Interpreter) project(v vec) int32 {
+	return dot(v, in.gs.pv)
+}
+
+// dualProject returns the length of the projection of v onto the dual
+// projection vector.
+func (in *Interpreter) dualProject(v vec) int32 {
+	return dot(v, in.gs.dv)
+}
+
+func sub(a, b vec) vec {
+	return vec{a.x - b.x, a.y - b.y}
+}
+
+// fDotP returns the cosine of the angle between the freedom and
+// projection vectors, as a 2.14 number.  If the vectors are nearly
+// orthogonal, 1 is returned instead.
+func (in *Interpreter) fDotP() int32 {
+	fv, pv := in.gs.fv, in.gs.pv
+	d := int32((int64(fv.x)*int64(pv.x) + int64(fv.y)*int64(pv.y)) >> 14)
+	if d > -0x400 && d < 0x400 {
+		d = 0x4000
+	}
+	return d
+}
+
+// move moves point p in zone z along the freedom vector, such that its
+// projection onto the projection vector changes by d.  The point is
+// marked as touched.
+func (in *Interpreter) move(z *zone, p int32, d int32) {
+	fv := in.gs.fv
+	fdp := in.fDotP()
+	if fv.x != 0 {
+		z.cur[p].x += mulDiv(d, fv.x, fdp)
+		z.touchX[p] = true
+	}
+	if fv.y != 0 {
+		z.cur[p].y += mulDiv(d, fv.y, fdp)
+		z.touchY[p] = true
+	}
+}
+
+// moveOrig is like move, but changes the original position of the point.
+func (in *Interpreter) moveOrig(z *zone, p int32, d int32) {
+	fv := in.gs.fv
+	fdp := in.fDotP()
+	if fv.x != 0 {
+		z.orig[p].x += mulDiv(d, fv.x, fdp)
+	}
+	if fv.y != 0 {
+		z.orig[p].y += mulDiv(d, fv.y, fdp)
+	}
+}
+
+// shift moves point p in zone z by (dx, dy), where only the components
+// along the freedom vector are applied.
+func (in *Interpreter) shift(z *zone, p int32, d vec, touch bool) {
+	fv := in.gs.fv
+	if fv.x != 0 {
+		z.cur[p].x += d.x
+		if touch {
+			z.touchX[p] = true
+		}
+	}
+	if fv.y != 0 {
+		z.cur[p].y += d.y
+		if touch {
+			z.touchY[p] = true
+		}
+	}
+}
+
+// round applies the current rounding mode to a distance.
+func (in *Interpreter) round(d int32) int32 {
+	gs := &in.gs
+	var r int32
+	switch gs.round {
+	case roundToGrid:
+		if d >= 0 {
+			r = max((d+32)&^63, 0)
+		} else {
+			r = min(-((-d + 32) &^ 63), 0)
+		}
+	case roundToHalfGrid:
+		if d >= 0 {
+			r = d&^63 + 32
+		} else {
+			r = -((-d)&^63 + 32)
+		}
+	case roundToDoubleGrid:
+		if d >= 0 {
+			r = max((d+16)&^31, 0)
+		} else {
+			r = min(-((-d + 16) &^ 31), 0)
+		}
+	case roundDownToGrid:
+		if d >= 0 {
+			r = d &^ 63
+		} else {
+			r = -((-d) &^ 63)
+		}
+	case roundUpToGrid:
+		if d >= 0 {
+			r = (d + 63) &^ 63
+		} else {
+			r = -((-d + 63) &^ 63)
+		}
+	case roundOff:
+		r = d
+	case roundSuper, roundSuper45:
+		r = in.roundSuper(d)
+	}
+	return r
+}
+
+func (in *Interpreter) roundSuper(d int32) int32 {
+	gs := &in.gs
+	period := max(gs.period, 1)
+	neg := d < 0
+	if neg {
+		d = -d
+	}
+	r := d - gs.phase + gs.threshold
+	q := r / period
+	if r < 0 && r%period != 0 {
+		q--
+	}
+	r = q*period + gs.phase
+	if r < 0 {
+		r = gs.phase
+	}
+	if neg {
+		r = -r
+	}
+	return r
+}
+
+// setSuperRound sets the parameters for the SROUND and S45ROUND
+// instructions.  The argument gridPeriod gives the length of one grid
+// period in 26.6 units.
+func (in *Interpreter) setSuperRound(gridPeriod, n int32) {
+	gs := &in.gs
+	switch (n >> 6) & 3 {
+	case 0:
+		gs.period = gridPeriod / 2
+	case 2:
+		gs.period = gridPeriod * 2
+	default:
+		gs.period = gridPeriod
+	}
+	switch (n >> 4) & 3 {
+	case 0:
+		gs.phase = 0
+	case 1:
+		gs.phase = gs.period / 4
+	case 2:
+		gs.phase = gs.period / 2
+	case 3:
+		gs.phase = gs.period * 3 / 4
+	}
+	if n&15 == 0 {
+		gs.threshold = gs.period - 1
+	} else {
+		gs.threshold = (n&15 - 4) * gs.period / 8
+	}
+}
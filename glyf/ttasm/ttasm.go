@@ -0,0 +1,369 @@
+// seehuhn.de/go/sfnt - a library for reading and writing font files
+// Copyright (C) 2026  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package ttasm converts TrueType instructions between their binary
+// encoding and a human-readable text form.
+//
+// In the text form, each instruction is given by its mnemonic.  Flags are
+// given as binary digits in square brackets, in the order used by the
+// OpenType specification, for example "MIRP[01101]" or "SVTCA[1]".
+// Push instructions are followed by the values to push, for example
+// "PUSHB 1 2 3".  The pseudo-instruction "PUSH" can be used to let the
+// assembler choose the shortest encoding for a sequence of values.
+// Comments start with "#" and extend to the end of the line.
+//
+// https://learn.microsoft.com/en-us/typography/opentype/spec/tt_instructions
+package ttasm
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// opDefs lists the TrueType instructions.  For instructions with flags,
+// base is the opcode where all flags are zero.
+var opDefs = []struct {
+	base byte
+	name string
+	bits int
+}{
+	{0x00, "SVTCA", 1}, {0x02, "SPVTCA", 1}, {0x04, "SFVTCA", 1},
+	{0x06, "SPVTL", 1}, {0x08, "SFVTL", 1}, {0x0A, "SPVFS", 0},
+	{0x0B, "SFVFS", 0}, {0x0C, "GPV", 0}, {0x0D, "GFV", 0},
+	{0x0E, "SFVTPV", 0}, {0x0F, "ISECT", 0},
+
+	{0x10, "SRP0", 0}, {0x11, "SRP1", 0}, {0x12, "SRP2", 0},
+	{0x13, "SZP0", 0}, {0x14, "SZP1", 0}, {0x15, "SZP2", 0},
+	{0x16, "SZPS", 0}, {0x17, "SLOOP", 0}, {0x18, "RTG", 0},
+	{0x19, "RTHG", 0}, {0x1A, "SMD", 0}, {0x1B, "ELSE", 0},
+	{0x1C, "JMPR", 0}, {0x1D, "SCVTCI", 0}, {0x1E, "SSWCI", 0},
+	{0x1F, "SSW", 0},
+
+	{0x20, "DUP", 0}, {0x21, "POP", 0}, {0x22, "CLEAR", 0},
+	{0x23, "SWAP", 0}, {0x24, "DEPTH", 0}, {0x25, "CINDEX", 0},
+	{0x26, "MINDEX", 0}, {0x27, "ALIGNPTS", 0}, {0x29, "UTP", 0},
+	{0x2A, "LOOPCALL", 0}, {0x2B, "CALL", 0}, {0x2C, "FDEF", 0},
+	{0x2D, "ENDF", 0}, {0x2E, "MDAP", 1},
+
+	{0x30, "IUP", 1}, {0x32, "SHP", 1}, {0x34, "SHC", 1},
+	{0x36, "SHZ", 1}, {0x38, "SHPIX", 0}, {0x39, "IP", 0},
+	{0x3A, "MSIRP", 1}, {0x3C, "ALIGNRP", 0}, {0x3D, "RTDG", 0},
+	{0x3E, "MIAP", 1},
+
+	{0x40, "NPUSHB", 0}, {0x41, "NPUSHW", 0}, {0x42, "WS", 0},
+	{0x43, "RS", 0}, {0x44, "WCVTP", 0}, {0x45, "RCVT", 0},
+	{0x46, "GC", 1}, {0x48, "SCFS", 0}, {0x49, "MD", 1},
+	{0x4B, "MPPEM", 0}, {0x4C, "MPS", 0}, {0x4D, "FLIPON", 0},
+	{0x4E, "FLIPOFF", 0}, {0x4F, "DEBUG", 0},
+
+	{0x50, "LT", 0}, {0x51, "LTEQ", 0}, {0x52, "GT", 0},
+	{0x53, "GTEQ", 0}, {0x54, "EQ", 0}, {0x55, "NEQ", 0},
+	{0x56, "ODD", 0}, {0x57, "EVEN", 0}, {0x58, "IF", 0},
+	{0x59, "EIF", 0}, {0x5A, "AND", 0}, {0x5B, "OR", 0},
+	{0x5C, "NOT", 0}, {0x5D, "DELTAP1", 0}, {0x5E, "SDB", 0},
+	{0x5F, "SDS", 0},
+
+	{0x60, "ADD", 0}, {0x61, "SUB", 0}, {0x62, "DIV", 0},
+	{0x63, "MUL", 0}, {0x64, "ABS", 0}, {0x65, "NEG", 0},
+	{0x66, "FLOOR", 0}, {0x67, "CEILING", 0}, {0x68, "ROUND", 2},
+	{0x6C, "NROUND", 2},
+
+	{0x70, "WCVTF", 0}, {0x71, "DELTAP2", 0}, {0x72, "DELTAP3", 0},
+	{0x73, "DELTAC1", 0}, {0x74, "DELTAC2", 0}, {0x75, "DELTAC3", 0},
+	{0x76, "SROUND", 0}, {0x77, "S45ROUND", 0}, {0x78, "JROT", 0},
+	{0x79, "JROF", 0}, {0x7A, "ROFF", 0}, {0x7C, "RUTG", 0},
+	{0x7D, "RDTG", 0}, {0x7E, "SANGW", 0}, {0x7F, "AA", 0},
+
+	{0x80, "FLIPPT", 0}, {0x81, "FLIPRGON", 0}, {0x82, "FLIPRGOFF", 0},
+	{0x85, "SCANCTRL", 0}, {0x86, "SDPVTL", 1}, {0x88, "GETINFO", 0},
+	{0x89, "IDEF", 0}, {0x8A, "ROLL", 0}, {0x8B, "MAX", 0},
+	{0x8C, "MIN", 0}, {0x8D, "SCANTYPE", 0}, {0x8E, "INSTCTRL", 0},
+	{0x91, "GETVARIATION", 0}, {0x92, "GETDATA", 0},
+
+	{0xB0, "PUSHB", 3}, {0xB8, "PUSHW", 3},
+	{0xC0, "MDRP", 5}, {0xE0, "MIRP", 5},
+}
+
+var (
+	opName  [256]string // mnemonic, including flags
+	opByKey = map[string]byte{}
+)
+
+func init() {
+	for _, def := range opDefs {
+		for flags := range 1 << def.bits {
+			op := def.base + byte(flags)
+			name := def.name
+			if def.bits > 0 && def.name != "PUSHB" && def.name != "PUSHW" {
+				name += "[" + fmt.Sprintf("%0*b", def.bits, flags) + "]"
+			}
+			opName[op] = name
+			opByKey[name] = op
+		}
+	}
+	for op := range opName {
+		if opName[op] == "" {
+			// Undefined opcodes can be given a meaning using IDEF.
+			name := fmt.Sprintf("INS_%02X", op)
+			opName[op] = name
+			opByKey[name] = byte(op)
+		}
+	}
+}
+
+// Disassemble converts TrueType instructions into text form.
+// Each instruction is written on a separate line.  The bodies of
+// function definitions and conditionals are indented.
+func Disassemble(code []byte) (string, error) {
+	b := &strings.Builder{}
+	indent := 0
+	pos := 0
+	for pos < len(code) {
+		op := code[pos]
+		pos++
+
+		switch op {
+		case 0x1B, 0x59, 0x2D: // ELSE, EIF, ENDF
+			indent = max(indent-1, 0)
+		}
+		b.WriteString(strings.Repeat("  ", indent))
+		switch op {
+		case 0x1B, 0x58, 0x2C, 0x89: // ELSE, IF, FDEF, IDEF
+			indent++
+		}
+
+		var n int
+		wide := false
+		switch {
+		case op == 0x40 || op == 0x41: // NPUSHB, NPUSHW
+			if pos >= len(code) {
+				return "", errTruncated(pos - 1)
+			}
+			n = int(code[pos])
+			pos++
+			wide = op == 0x41
+			b.WriteString(opName[op])
+		case op >= 0xB0 && op <= 0xB7: // PUSHB
+			n = int(op-0xB0) + 1
+			b.WriteString("PUSHB")
+		case op >= 0xB8 && op <= 0xBF: // PUSHW
+			n = int(op-0xB8) + 1
+			wide = true
+			b.WriteString("PUSHW")
+		default:
+			b.WriteString(opName[op])
+			b.WriteByte('\n')
+			continue
+		}
+
+		for range n {
+			var v int
+			if wide {
+				if pos+2 > len(code) {
+					return "", errTruncated(pos)
+				}
+				v = int(int16(code[pos])<<8 | int16(code[pos+1]))
+				pos += 2
+			} else {
+				if pos >= len(code) {
+					return "", errTruncated(pos)
+				}
+				v = int(code[pos])
+				pos++
+			}
+			b.WriteByte(' ')
+			b.WriteString(strconv.Itoa(v))
+		}
+		b.WriteByte('\n')
+	}
+	return b.String(), nil
+}
+
+func errTruncated(pos int) error {
+	return fmt.Errorf("ttasm: offset %d: truncated push data", pos)
+}
+
+// Assemble converts the text form of TrueType instructions into the binary
+// encoding.
+func Assemble(text string) ([]byte, error) {
+	var res []byte
+
+	type token struct {
+		text string
+		line int
+	}
+	var tokens []token
+	for i, line := range strings.Split(text, "\n") {
+		if k := strings.IndexByte(line, '#'); k >= 0 {
+			line = line[:k]
+		}
+		for _, f := range strings.Fields(line) {
+			tokens = append(tokens, token{f, i + 1})
+		}
+	}
+
+	for i := 0; i < len(tokens); {
+		tok := tokens[i]
+		i++
+
+		// collect the arguments of push instructions
+		var args []int
+		for i < len(tokens) && isNumber(tokens[i].text) {
+			v, err := strconv.ParseInt(tokens[i].text, 0, 32)
+			if err != nil {
+				return nil, fmt.Errorf("ttasm: line %d: invalid number %q", tokens[i].line, tokens[i].text)
+			}
+			args = append(args, int(v))
+			i++
+		}
+		fail := func(format string, a ...any) ([]byte, error) {
+			return nil, fmt.Errorf("ttasm: line %d: %s: %s", tok.line, tok.text, fmt.Sprintf(format, a...))
+		}
+
+		var err error
+		switch tok.text {
+		case "PUSH":
+			res, err = appendPush(res, args)
+		case "PUSHB", "NPUSHB":
+			if tok.text == "PUSHB" && (len(args) < 1 || len(args) > 8) {
+				return fail("need 1 to 8 values, got %d", len(args))
+			} else if len(args) > 255 {
+				return fail("too many values")
+			}
+			res, err = appendPushB(res, args, tok.text == "NPUSHB")
+		case "PUSHW", "NPUSHW":
+			if tok.text == "PUSHW" && (len(args) < 1 || len(args) > 8) {
+				return fail("need 1 to 8 values, got %d", len(args))
+			} else if len(args) > 255 {
+				return fail("too many values")
+			}
+			res, err = appendPushW(res, args, tok.text == "NPUSHW")
+		default:
+			op, ok := opByKey[tok.text]
+			if !ok {
+				return fail("unknown instruction")
+			}
+			if len(args) > 0 {
+				return fail("unexpected argument")
+			}
+			res = append(res, op)
+		}
+		if err != nil {
+			return fail("%v", err)
+		}
+	}
+	return res, nil
+}
+
+func isNumber(s string) bool {
+	if s != "" && (s[0] == '-' || s[0] == '+') {
+		s = s[1:]
+	}
+	return s != "" && s[0] >= '0' && s[0] <= '9'
+}
+
+func appendPushB(buf []byte, args []int, long bool) ([]byte, error) {
+	if long {
+		buf = append(buf, 0x40, byte(len(args)))
+	} else {
+		buf = append(buf, 0xB0+byte(len(args)-1))
+	}
+	for _, v := range args {
+		if v < 0 || v > 255 {
+			return nil, fmt.Errorf("value %d out of range", v)
+		}
+		buf = append(buf, byte(v))
+	}
+	return buf, nil
+}
+
+func appendPushW(buf []byte, args []int, long bool) ([]byte, error) {
+	if long {
+		buf = append(buf, 0x41, byte(len(args)))
+	} else {
+		buf = append(buf, 0xB8+byte(len(args)-1))
+	}
+	for _, v := range args {
+		if v < -32768 || v > 32767 {
+			return nil, fmt.Errorf("value %d out of range", v)
+		}
+		buf = append(buf, byte(v>>8), byte(v))
+	}
+	return buf, nil
+}
+
+// appendPush appends push instructions for the given values to buf,
+// using the shortest possible encoding.
+func appendPush(buf []byte, args []int) ([]byte, error) {
+	for _, v := range args {
+		if v < -32768 || v > 32767 {
+			return nil, fmt.Errorf("value %d out of range", v)
+		}
+	}
+
+	// cost[i] is the minimal number of bytes needed to push args[:i],
+	// and count[i] is the number of instructions used.  If several
+	// encodings have the same length, the one with fewer instructions
+	// is used.
+	n := len(args)
+	cost := make([]int, n+1)
+	count := make([]int, n+1)
+	from := make([]int, n+1)
+	wide := make([]bool, n+1)
+	for i := 1; i <= n; i++ {
+		cost[i] = -1
+		allBytes := true
+		for j := i - 1; j >= 0 && i-j <= 255; j-- {
+			v := args[j]
+			if v < 0 || v > 255 {
+				allBytes = false
+			}
+			k := i - j
+			hdr := 2
+			if k <= 8 {
+				hdr = 1
+			}
+			better := func(c int) bool {
+				return cost[i] < 0 || c < cost[i] || c == cost[i] && count[j]+1 < count[i]
+			}
+			if c := cost[j] + hdr + k; allBytes && better(c) {
+				cost[i], count[i], from[i], wide[i] = c, count[j]+1, j, false
+			}
+			if c := cost[j] + hdr + 2*k; better(c) {
+				cost[i], count[i], from[i], wide[i] = c, count[j]+1, j, true
+			}
+		}
+	}
+
+	var segments []int
+	for i := n; i > 0; i = from[i] {
+		segments = append(segments, i)
+	}
+	start := 0
+	for k := len(segments) - 1; k >= 0; k-- {
+		end := segments[k]
+		seg := args[start:end]
+		long := len(seg) > 8
+		if wide[end] {
+			buf, _ = appendPushW(buf, seg, long)
+		} else {
+			buf, _ = appendPushB(buf, seg, long)
+		}
+		start = end
+	}
+	return buf, nil
+}
@@ -0,0 +1,269 @@
+// seehuhn.de/go/sfnt - a library for reading and writing font files
+// Copyright (C) 2026  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package ttasm
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goitalic"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/goregular"
+
+	"seehuhn.de/go/sfnt"
+	"seehuhn.de/go/sfnt/glyf"
+	"seehuhn.de/go/sfnt/parser"
+)
+
+func TestDisassemble(t *testing.T) {
+	code := []byte{
+		0xB1, 0x01, 0x02, // PUSHB[001]
+		0x2C,             // FDEF
+		0x58,             // IF
+		0xB8, 0xFF, 0xFE, // PUSHW[000]
+		0x1B, // ELSE
+		0xCD, // MDRP[01101]
+		0x59, // EIF
+		0x2D, // ENDF
+		0x28, // undefined
+		0x31, // IUP[1]
+	}
+	want := `PUSHB 1 2
+FDEF
+  IF
+    PUSHW -2
+  ELSE
+    MDRP[01101]
+  EIF
+ENDF
+INS_28
+IUP[1]
+`
+	got, err := Disassemble(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := cmp.Diff(want, got); d != "" {
+		t.Error(d)
+	}
+
+	again, err := Assemble(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(again, code) {
+		t.Errorf("round trip failed: % x", again)
+	}
+}
+
+func TestDisassembleTruncated(t *testing.T) {
+	for _, code := range [][]byte{
+		{0x40},
+		{0x40, 0x02, 0x01},
+		{0xB2, 0x01},
+		{0xB8, 0x01},
+	} {
+		_, err := Disassemble(code)
+		if err == nil {
+			t.Errorf("% x: missing error", code)
+		}
+	}
+}
+
+func TestAssembleErrors(t *testing.T) {
+	for _, text := range []string{
+		"FOO",
+		"PUSHB 256",
+		"PUSHB -1",
+		"PUSHB",
+		"PUSHB 1 2 3 4 5 6 7 8 9",
+		"PUSHW 32768",
+		"PUSH 1 2 -40000",
+		"SVTCA[2]",
+		"SVTCA[0] 1",
+		"1 2 3",
+	} {
+		_, err := Assemble(text)
+		if err == nil {
+			t.Errorf("%q: missing error", text)
+		}
+	}
+}
+
+func TestAssembleComments(t *testing.T) {
+	text := `# set the vectors
+SVTCA[0]  # y-axis
+	PUSHB 0x10 7 MDAP[1]
+`
+	got, err := Assemble(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{0x00, 0xB1, 0x10, 0x07, 0x2F}
+	if !bytes.Equal(got, want) {
+		t.Errorf("got % x, want % x", got, want)
+	}
+}
+
+func TestPush(t *testing.T) {
+	type testCase struct {
+		values []int
+		want   []byte
+	}
+	cases := []testCase{
+		{nil, nil},
+		{[]int{1}, []byte{0xB0, 1}},
+		{[]int{-1}, []byte{0xB8, 0xFF, 0xFF}},
+		{[]int{1, 2, 3, 4, 5, 6, 7, 8, 9}, []byte{0x40, 9, 1, 2, 3, 4, 5, 6, 7, 8, 9}},
+		// A single word value in the middle does not justify a switch
+		// to PUSHW for all values, nor splitting into three instructions.
+		{[]int{1, 2, 300, 4}, []byte{0xB1, 1, 2, 0xB9, 0x01, 0x2C, 0, 4}},
+		{[]int{300, 1}, []byte{0xB9, 0x01, 0x2C, 0, 1}},
+	}
+	// long sequences need more than one NPUSHB
+	long := make([]int, 300)
+	longCode := []byte{0x40, 255}
+	for i := range long {
+		long[i] = i % 256
+		if i == 255 {
+			longCode = append(longCode, 0x40, 45)
+		}
+		longCode = append(longCode, byte(i%256))
+	}
+	cases = append(cases, testCase{long, longCode})
+
+	for i, c := range cases {
+		var text []string
+		for _, v := range c.values {
+			text = append(text, fmt.Sprint(v))
+		}
+		got, err := Assemble("PUSH " + strings.Join(text, " "))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, c.want) {
+			t.Errorf("%d: got % x, want % x", i, got, c.want)
+		}
+	}
+}
+
+// TestRoundTrip checks that the programs in the Go fonts survive a round trip
+// through disassembly and assembly, and that re-packing the push
+// instructions never makes the code longer.
+func TestRoundTrip(t *testing.T) {
+	fonts := map[string][]byte{
+		"goregular": goregular.TTF,
+		"gobold":    gobold.TTF,
+		"goitalic":  goitalic.TTF,
+		"gomono":    gomono.TTF,
+	}
+	for name, data := range fonts {
+		t.Run(name, func(t *testing.T) {
+			f, err := sfnt.Read(bytes.NewReader(data), parser.NewBudget(int64(len(data))))
+			if err != nil {
+				t.Fatal(err)
+			}
+			outlines := f.Outlines.(*glyf.Outlines)
+
+			programs := map[string][]byte{
+				"fpgm": outlines.Tables["fpgm"],
+				"prep": outlines.Tables["prep"],
+			}
+			for gid, g := range outlines.Glyphs {
+				if g == nil {
+					continue
+				}
+				var code []byte
+				switch d := g.Data.(type) {
+				case glyf.SimpleGlyph:
+					u, err := d.Unpack()
+					if err != nil {
+						t.Fatal(err)
+					}
+					code = u.Instructions
+				case glyf.CompositeGlyph:
+					code = d.Instructions
+				}
+				if len(code) > 0 {
+					programs[fmt.Sprintf("glyph %d", gid)] = code
+				}
+			}
+
+			for key, code := range programs {
+				text, err := Disassemble(code)
+				if err != nil {
+					t.Fatalf("%s: %v", key, err)
+				}
+				again, err := Assemble(text)
+				if err != nil {
+					t.Fatalf("%s: %v", key, err)
+				}
+				if !bytes.Equal(again, code) {
+					t.Errorf("%s: round trip failed", key)
+				}
+
+				packed, err := Assemble(repack(text))
+				if err != nil {
+					t.Fatalf("%s: %v", key, err)
+				}
+				if len(packed) > len(code) {
+					t.Errorf("%s: PUSH gives %d bytes, original has %d",
+						key, len(packed), len(code))
+				}
+				packedText, err := Disassemble(packed)
+				if err != nil {
+					t.Fatalf("%s: %v", key, err)
+				}
+				if d := cmp.Diff(repack(text), repack(packedText)); d != "" {
+					t.Errorf("%s: pushed values differ:\n%s", key, d)
+				}
+			}
+		})
+	}
+}
+
+// repack replaces all push instructions in text by the generic PUSH
+// instruction, and merges consecutive pushes.
+func repack(text string) string {
+	var lines []string
+	var values []string
+	flush := func() {
+		if len(values) > 0 {
+			lines = append(lines, "PUSH "+strings.Join(values, " "))
+			values = nil
+		}
+	}
+	for _, line := range strings.Split(text, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "PUSHB", "PUSHW", "NPUSHB", "NPUSHW":
+			values = append(values, fields[1:]...)
+		default:
+			flush()
+			lines = append(lines, line)
+		}
+	}
+	flush()
+	return strings.Join(lines, "\n")
+}
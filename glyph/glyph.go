@@ -42,6 +42,13 @@ type Info struct {
 	Advance  funit.Int16 // horizontal advance; TODO(voss): convert to float64
 	YAdvance funit.Int16 // vertical advance, used only in vertical layout
 
+	// Cluster is the byte offset in the input text of the first character
+	// represented by this glyph.  Glyphs which are formed from the same
+	// characters share the same cluster value, for example all glyphs
+	// of a multiple substitution, or a ligature and the marks which were
+	// skipped while forming it.
+	Cluster int
+
 	// LigID identifies the ligature a glyph belongs to.  It is zero for glyphs
 	// that are not part of a ligature, and a non-zero value shared by a
 	// ligature glyph and the marks attached to its components.  The value is
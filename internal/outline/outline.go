@@ -0,0 +1,495 @@
+// seehuhn.de/go/sfnt - a library for reading and writing font files
+// Copyright (C) 2026  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package outline implements geometric operations on glyph outlines.
+//
+// Outlines are represented as lists of closed contours, made up of straight
+// lines and quadratic or cubic Bézier curves.  This allows the same code to
+// be used for TrueType and CFF glyphs.
+package outline
+
+import (
+	"math"
+	"slices"
+
+	"seehuhn.de/go/geom/path"
+	"seehuhn.de/go/geom/vec"
+)
+
+// A Segment is a straight line or a Bézier curve.  The first point is the
+// start point, the last point is the end point, and any points in between
+// are control points.  Segments with two points are straight lines, segments
+// with three points are quadratic Bézier curves, and segments with four
+// points are cubic Bézier curves.
+type Segment []vec.Vec2
+
+// A Contour is a closed curve.  Each segment starts at the end point of the
+// previous segment, and the last segment ends at the start point of the
+// first segment.
+type Contour []Segment
+
+// FromPath splits a path into contours.  All contours are closed explicitly,
+// by adding a straight line where needed.
+func FromPath(p path.Path) []Contour {
+	var res []Contour
+	var cur Contour
+	var start, pos vec.Vec2
+	finish := func() {
+		if len(cur) == 0 {
+			return
+		}
+		if pos != start {
+			cur = append(cur, Segment{pos, start})
+		}
+		res = append(res, cur)
+		cur = nil
+	}
+	for cmd, pts := range p {
+		switch cmd {
+		case path.CmdMoveTo:
+			finish()
+			start, pos = pts[0], pts[0]
+		case path.CmdClose:
+			finish()
+			pos = start
+		case path.CmdLineTo, path.CmdQuadTo, path.CmdCubeTo:
+			seg := make(Segment, 0, len(pts)+1)
+			seg = append(seg, pos)
+			seg = append(seg, pts...)
+			cur = append(cur, seg)
+			pos = seg.End()
+		}
+	}
+	finish()
+	return res
+}
+
+// Start returns the start point of the segment.
+func (s Segment) Start() vec.Vec2 {
+	return s[0]
+}
+
+// End returns the end point of the segment.
+func (s Segment) End() vec.Vec2 {
+	return s[len(s)-1]
+}
+
+// IsLine reports whether the segment is a straight line.
+func (s Segment) IsLine() bool {
+	return len(s) == 2
+}
+
+// Reverse returns the segment, traversed in the opposite direction.
+func (s Segment) Reverse() Segment {
+	res := make(Segment, len(s))
+	for i, p := range s {
+		res[len(s)-1-i] = p
+	}
+	return res
+}
+
+// At returns the point on the segment at parameter t.
+func (s Segment) At(t float64) vec.Vec2 {
+	ts := make([]float64, len(s)-1)
+	for i := range ts {
+		ts[i] = t
+	}
+	return s.blossom(ts)
+}
+
+// Sub returns the part of the segment between parameters t0 and t1.
+// If t0 > t1, the result is traversed in the opposite direction.
+func (s Segment) Sub(t0, t1 float64) Segment {
+	n := len(s) - 1
+	res := make(Segment, n+1)
+	ts := make([]float64, n)
+	for k := range res {
+		for i := range ts {
+			if i < n-k {
+				ts[i] = t0
+			} else {
+				ts[i] = t1
+			}
+		}
+		res[k] = s.blossom(ts)
+	}
+	if t0 == 0 {
+		res[0] = s.Start()
+	}
+	if t1 == 1 {
+		res[n] = s.End()
+	}
+	return res
+}
+
+// blossom evaluates the polar form of the segment, using the de Casteljau
+// algorithm with a different parameter at each level.
+func (s Segment) blossom(ts []float64) vec.Vec2 {
+	var buf [4]vec.Vec2
+	pts := append(buf[:0], s...)
+	for _, t := range ts {
+		for i := 0; i < len(pts)-1; i++ {
+			pts[i] = pts[i].Add(pts[i+1].Sub(pts[i]).Mul(t))
+		}
+		pts = pts[:len(pts)-1]
+	}
+	return pts[0]
+}
+
+// extrema returns the parameter values in the open interval (0, 1) where
+// the tangent of the segment is horizontal or vertical.  The result is not
+// sorted and may contain duplicates.
+func (s Segment) extrema() []float64 {
+	var res []float64
+	add := func(t float64) {
+		if t > 0 && t < 1 {
+			res = append(res, t)
+		}
+	}
+	coord := func(p vec.Vec2, i int) float64 {
+		if i == 0 {
+			return p.X
+		}
+		return p.Y
+	}
+	for i := range 2 {
+		switch len(s) {
+		case 3:
+			// B'(t) = 2(1-t)(p1-p0) + 2t(p2-p1)
+			a := coord(s[1], i) - coord(s[0], i)
+			b := coord(s[2], i) - coord(s[1], i)
+			if a != b {
+				add(a / (a - b))
+			}
+		case 4:
+			// B'(t)/3 = (1-t)^2 a + 2t(1-t) b + t^2 c
+			a := coord(s[1], i) - coord(s[0], i)
+			b := coord(s[2], i) - coord(s[1], i)
+			c := coord(s[3], i) - coord(s[2], i)
+			qa := a - 2*b + c
+			qb := 2 * (b - a)
+			qc := a
+			if math.Abs(qa) < 1e-12 {
+				if qb != 0 {
+					add(-qc / qb)
+				}
+				continue
+			}
+			disc := qb*qb - 4*qa*qc
+			if disc < 0 {
+				continue
+			}
+			sq := math.Sqrt(disc)
+			add((-qb + sq) / (2 * qa))
+			add((-qb - sq) / (2 * qa))
+		}
+	}
+	return res
+}
+
+// numPieces returns the number of straight lines needed to approximate
+// the segment with an error of at most tol.
+func (s Segment) numPieces(tol float64) int {
+	var d float64
+	switch len(s) {
+	case 3:
+		// the error for n pieces is at most |p0 - 2p1 + p2| / (4n^2)
+		d = s[0].Sub(s[1].Mul(2)).Add(s[2]).Length() / 4
+	case 4:
+		// the error for n pieces is at most 3/4 max|second differences| / n^2
+		d1 := s[0].Sub(s[1].Mul(2)).Add(s[2]).Length()
+		d2 := s[1].Sub(s[2].Mul(2)).Add(s[3]).Length()
+		d = 0.75 * max(d1, d2)
+	default:
+		return 1
+	}
+	n := int(math.Ceil(math.Sqrt(d / tol)))
+	return min(max(n, 1), maxPieces)
+}
+
+// maxPieces limits the number of lines used to approximate a curve.
+const maxPieces = 100
+
+// flatTolerance is the maximal distance between a curve and its polygonal
+// approximation, in font design units.
+const flatTolerance = 0.1
+
+// Reverse returns the contour, traversed in the opposite direction.
+func (c Contour) Reverse() Contour {
+	res := make(Contour, len(c))
+	for i, s := range c {
+		res[len(c)-1-i] = s.Reverse()
+	}
+	return res
+}
+
+// polygon returns a polygonal approximation of the contour.
+func (c Contour) polygon() []vec.Vec2 {
+	var res []vec.Vec2
+	for _, s := range c {
+		n := s.numPieces(flatTolerance)
+		for k := range n {
+			res = append(res, s.At(float64(k)/float64(n)))
+		}
+	}
+	return res
+}
+
+// Area returns the signed area enclosed by the contour.  The area is
+// positive for counter-clockwise contours.
+func (c Contour) Area() float64 {
+	return polygonArea(c.polygon())
+}
+
+func polygonArea(poly []vec.Vec2) float64 {
+	var area float64
+	for i, p := range poly {
+		q := poly[(i+1)%len(poly)]
+		area += cross(p, q)
+	}
+	return area / 2
+}
+
+// winding returns the winding number of the polygon around the point q.
+func winding(poly []vec.Vec2, q vec.Vec2) int {
+	w := 0
+	for i, a := range poly {
+		b := poly[(i+1)%len(poly)]
+		w += crossing(a, b, q)
+	}
+	return w
+}
+
+// crossing returns the contribution of the line from a to b to the winding
+// number around q.  This is +1 if the line crosses the horizontal ray to the
+// right of q upwards, -1 if it crosses downwards, and 0 otherwise.
+func crossing(a, b, q vec.Vec2) int {
+	if a.Y <= q.Y {
+		if b.Y > q.Y && cross(b.Sub(a), q.Sub(a)) > 0 {
+			return 1
+		}
+	} else if b.Y <= q.Y && cross(b.Sub(a), q.Sub(a)) < 0 {
+		return -1
+	}
+	return 0
+}
+
+func cross(a, b vec.Vec2) float64 {
+	return a.X*b.Y - a.Y*b.X
+}
+
+// Orient changes the direction of the contours, so that outer contours run
+// counter-clockwise if ccw is true, and clockwise otherwise.  Contours
+// inside an odd number of other contours are considered to be holes, and
+// are given the opposite direction.
+func Orient(cc []Contour, ccw bool) []Contour {
+	wrong := Misoriented(cc, ccw)
+	res := make([]Contour, len(cc))
+	for i, c := range cc {
+		if wrong[i] {
+			c = c.Reverse()
+		}
+		res[i] = c
+	}
+	return res
+}
+
+// Misoriented reports for every contour whether it needs to be reversed,
+// to give the directions described in [Orient].
+func Misoriented(cc []Contour, ccw bool) []bool {
+	polys := make([][]vec.Vec2, len(cc))
+	for i, c := range cc {
+		polys[i] = c.polygon()
+	}
+
+	res := make([]bool, len(cc))
+	for i, c := range cc {
+		if len(c) == 0 {
+			continue
+		}
+		// Use a test point just inside the contour, so that contours
+		// which touch each other are classified correctly.
+		area := polygonArea(polys[i])
+		s := c[0]
+		d := s.At(0.5 + 1e-3).Sub(s.At(0.5 - 1e-3))
+		if l := d.Length(); l > 0 {
+			d = d.Mul(insideOffset / l)
+		}
+		n := vec.Vec2{X: -d.Y, Y: d.X}
+		if area < 0 {
+			n = n.Neg()
+		}
+		q := s.At(0.5).Add(n)
+
+		depth := 0
+		for j, poly := range polys {
+			if j != i && winding(poly, q) != 0 {
+				depth++
+			}
+		}
+		wantCCW := ccw == (depth%2 == 0)
+		res[i] = (area > 0) != wantCCW
+	}
+	return res
+}
+
+// insideOffset is the distance from the contour of the test point used by
+// [Misoriented], in font design units.
+const insideOffset = 0.01
+
+// RemoveDegenerate simplifies the contours by removing segments of length
+// zero, replacing curves which are straight by lines, and merging
+// consecutive lines which lie on the same straight line.  Contours which
+// enclose no area are removed.
+func RemoveDegenerate(cc []Contour) []Contour {
+	var res []Contour
+	for _, c := range cc {
+		c = cleanContour(c)
+		if len(c) < 2 || isFlat(c) {
+			continue
+		}
+		res = append(res, c)
+	}
+	return res
+}
+
+func cleanContour(c Contour) Contour {
+	var res Contour
+	for _, s := range c {
+		if isStraight(s) {
+			s = Segment{s.Start(), s.End()}
+		}
+		if s.IsLine() && s.Start() == s.End() {
+			continue
+		}
+		res = append(res, s)
+	}
+
+	// merge collinear lines, including across the start of the contour
+	for merged := true; merged; {
+		merged = false
+		n := len(res)
+		for i := 0; i < n && n > 1; i++ {
+			j := (i + 1) % n
+			a, b := res[i], res[j]
+			if a.IsLine() && b.IsLine() && onLine(a.Start(), b.End(), a.End()) {
+				res[i] = Segment{a.Start(), b.End()}
+				res = slices.Delete(res, j, j+1)
+				merged = true
+				break
+			}
+		}
+	}
+	return res
+}
+
+// isStraight reports whether all control points of a segment lie on the
+// line between the start and end point.
+func isStraight(s Segment) bool {
+	for _, p := range s[1 : len(s)-1] {
+		if !onLine(s.Start(), s.End(), p) {
+			return false
+		}
+	}
+	return true
+}
+
+// onLine reports whether p lies on the line segment from a to b.
+func onLine(a, b, p vec.Vec2) bool {
+	d := b.Sub(a)
+	l := d.Length()
+	if l == 0 {
+		return p.Sub(a).Length() <= eps
+	}
+	if math.Abs(cross(d, p.Sub(a)))/l > eps {
+		return false
+	}
+	t := d.Dot(p.Sub(a)) / (l * l)
+	return t >= -eps && t <= 1+eps
+}
+
+// isFlat reports whether all points of the contour lie on a straight line.
+func isFlat(c Contour) bool {
+	a := c[0].Start()
+	var b vec.Vec2
+	found := false
+	for _, s := range c {
+		for _, p := range s {
+			if p.Sub(a).Length() > eps {
+				b = p
+				found = true
+				break
+			}
+		}
+		if found {
+			break
+		}
+	}
+	if !found {
+		return true
+	}
+	d := b.Sub(a)
+	l := d.Length()
+	for _, s := range c {
+		for _, p := range s {
+			if math.Abs(cross(d, p.Sub(a)))/l > eps {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// eps is the tolerance used for geometric comparisons, in font design
+// units.
+const eps = 1e-6
+
+// AddExtrema splits curves at the points where the tangent is horizontal
+// or vertical, so that the extreme points of the outline are start or end
+// points of segments.  Splits closer than minExtremumDist to an existing
+// end point are omitted.
+func AddExtrema(cc []Contour) []Contour {
+	res := make([]Contour, len(cc))
+	for i, c := range cc {
+		var out Contour
+		for _, s := range c {
+			ts := s.extrema()
+			if len(ts) == 0 {
+				out = append(out, s)
+				continue
+			}
+			slices.Sort(ts)
+			prev := 0.0
+			for _, t := range ts {
+				p := s.At(t)
+				if t-prev < 1e-9 ||
+					p.Sub(s.At(prev)).Length() < minExtremumDist ||
+					p.Sub(s.End()).Length() < minExtremumDist {
+					continue
+				}
+				out = append(out, s.Sub(prev, t))
+				prev = t
+			}
+			out = append(out, s.Sub(prev, 1))
+		}
+		res[i] = out
+	}
+	return res
+}
+
+// minExtremumDist is the minimal distance between an inserted extremum
+// point and the neighbouring points on the curve, in font design units.
+const minExtremumDist = 1
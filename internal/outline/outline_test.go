@@ -0,0 +1,264 @@
+// seehuhn.de/go/sfnt - a library for reading and writing font files
+// Copyright (C) 2026  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package outline
+
+import (
+	"math"
+	"testing"
+
+	"seehuhn.de/go/geom/vec"
+)
+
+// rect returns a rectangular contour.  The contour runs counter-clockwise
+// if x0 < x1 and y0 < y1.
+func rect(x0, y0, x1, y1 float64) Contour {
+	p := []vec.Vec2{{X: x0, Y: y0}, {X: x1, Y: y0}, {X: x1, Y: y1}, {X: x0, Y: y1}}
+	var c Contour
+	for i := range p {
+		c = append(c, Segment{p[i], p[(i+1)%len(p)]})
+	}
+	return c
+}
+
+// circle returns a counter-clockwise circle, made of four cubic Bézier
+// curves.
+func circle(x, y, r float64) Contour {
+	k := r * 4 * (math.Sqrt2 - 1) / 3
+	pt := func(dx, dy float64) vec.Vec2 { return vec.Vec2{X: x + dx, Y: y + dy} }
+	return Contour{
+		{pt(r, 0), pt(r, k), pt(k, r), pt(0, r)},
+		{pt(0, r), pt(-k, r), pt(-r, k), pt(-r, 0)},
+		{pt(-r, 0), pt(-r, -k), pt(-k, -r), pt(0, -r)},
+		{pt(0, -r), pt(k, -r), pt(r, -k), pt(r, 0)},
+	}
+}
+
+func totalArea(cc []Contour) float64 {
+	var area float64
+	for _, c := range cc {
+		area += c.Area()
+	}
+	return area
+}
+
+// checkClosed verifies that all contours are closed and connected.
+func checkClosed(t *testing.T, cc []Contour) {
+	t.Helper()
+	for i, c := range cc {
+		for j, s := range c {
+			next := c[(j+1)%len(c)]
+			if s.End() != next.Start() {
+				t.Errorf("contour %d, segment %d: gap between %v and %v", i, j, s.End(), next.Start())
+			}
+		}
+	}
+}
+
+func TestUnionSquares(t *testing.T) {
+	cc := []Contour{rect(0, 0, 100, 100), rect(50, 50, 150, 150)}
+	res := Union(cc)
+	checkClosed(t, res)
+	if len(res) != 1 {
+		t.Fatalf("got %d contours, want 1", len(res))
+	}
+	if len(res[0]) != 8 {
+		t.Errorf("got %d segments, want 8", len(res[0]))
+	}
+	if area := totalArea(res); math.Abs(area-17500) > 1e-6 {
+		t.Errorf("wrong area %g", area)
+	}
+}
+
+func TestUnionHole(t *testing.T) {
+	// A clockwise hole inside a counter-clockwise square.
+	cc := []Contour{rect(0, 0, 300, 300), rect(100, 100, 200, 200).Reverse()}
+	res := Union(cc)
+	checkClosed(t, res)
+	if len(res) != 2 {
+		t.Fatalf("got %d contours, want 2", len(res))
+	}
+	if area := totalArea(res); math.Abs(area-80000) > 1e-6 {
+		t.Errorf("wrong area %g", area)
+	}
+
+	// A counter-clockwise "hole" is filled under the non-zero rule.
+	cc = []Contour{rect(0, 0, 300, 300), rect(100, 100, 200, 200)}
+	res = Union(cc)
+	if len(res) != 1 {
+		t.Fatalf("got %d contours, want 1", len(res))
+	}
+	if area := totalArea(res); math.Abs(area-90000) > 1e-6 {
+		t.Errorf("wrong area %g", area)
+	}
+}
+
+func TestUnionReversed(t *testing.T) {
+	// Clockwise input contours give counter-clockwise output.
+	res := Union([]Contour{rect(0, 0, 100, 100).Reverse()})
+	if len(res) != 1 || res[0].Area() <= 0 {
+		t.Errorf("unexpected result %v", res)
+	}
+}
+
+func TestUnionBowTie(t *testing.T) {
+	p := []vec.Vec2{{X: 0, Y: 0}, {X: 100, Y: 100}, {X: 100, Y: 0}, {X: 0, Y: 100}}
+	var c Contour
+	for i := range p {
+		c = append(c, Segment{p[i], p[(i+1)%len(p)]})
+	}
+	res := Union([]Contour{c})
+	checkClosed(t, res)
+	if len(res) != 2 {
+		t.Fatalf("got %d contours, want 2", len(res))
+	}
+	for _, c := range res {
+		if area := c.Area(); math.Abs(area-2500) > 1e-6 {
+			t.Errorf("wrong area %g", area)
+		}
+	}
+}
+
+func TestUnionCircles(t *testing.T) {
+	// A single circle is left unchanged.
+	c := circle(0, 0, 100)
+	res := Union([]Contour{c})
+	if len(res) != 1 || len(res[0]) != 4 {
+		t.Fatalf("unexpected result %v", res)
+	}
+	for _, s := range res[0] {
+		found := false
+		for _, s2 := range c {
+			if equalSegments(s, s2) {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("segment %v changed", s)
+		}
+	}
+
+	// Two overlapping circles.  The area of the intersection is
+	// 2r^2 acos(d/2r) - d/2 sqrt(4r^2 - d^2).
+	r, d := 100.0, 100.0
+	res = Union([]Contour{circle(0, 0, r), circle(d, 0, r)})
+	checkClosed(t, res)
+	if len(res) != 1 {
+		t.Fatalf("got %d contours, want 1", len(res))
+	}
+	lens := 2*r*r*math.Acos(d/(2*r)) - d/2*math.Sqrt(4*r*r-d*d)
+	want := 2*circle(0, 0, r).Area() - lens
+	if area := totalArea(res); math.Abs(area-want) > 0.01*want {
+		t.Errorf("wrong area %g, want %g", area, want)
+	}
+	for _, s := range res[0] {
+		if len(s) != 4 {
+			t.Errorf("segment %v is not a cubic curve", s)
+		}
+	}
+}
+
+func equalSegments(a, b Segment) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Sub(b[i]).Length() > 1e-6 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestOrient(t *testing.T) {
+	cc := []Contour{
+		rect(0, 0, 300, 300).Reverse(),
+		rect(100, 100, 200, 200),
+		rect(400, 0, 500, 100),
+	}
+	res := Orient(cc, true)
+	want := []bool{true, false, true}
+	for i, c := range res {
+		if c.Area() > 0 != want[i] {
+			t.Errorf("contour %d has wrong direction", i)
+		}
+	}
+
+	res = Orient(cc, false)
+	for i, c := range res {
+		if c.Area() > 0 == want[i] {
+			t.Errorf("contour %d has wrong direction", i)
+		}
+	}
+}
+
+func TestRemoveDegenerate(t *testing.T) {
+	a := vec.Vec2{X: 0, Y: 0}
+	b := vec.Vec2{X: 50, Y: 0}
+	c := vec.Vec2{X: 100, Y: 0}
+	d := vec.Vec2{X: 100, Y: 100}
+	cc := []Contour{
+		{
+			{a, b},
+			{b, b},                        // zero length
+			{b, vec.Vec2{X: 75, Y: 0}, c}, // straight curve, collinear
+			{c, d},
+			{d, a},
+		},
+		{
+			// no area
+			{a, c},
+			{c, a},
+		},
+	}
+	res := RemoveDegenerate(cc)
+	if len(res) != 1 {
+		t.Fatalf("got %d contours, want 1", len(res))
+	}
+	checkClosed(t, res)
+	if len(res[0]) != 3 {
+		t.Errorf("got %d segments, want 3: %v", len(res[0]), res[0])
+	}
+	for _, s := range res[0] {
+		if !s.IsLine() {
+			t.Errorf("segment %v is not a line", s)
+		}
+	}
+}
+
+func TestAddExtrema(t *testing.T) {
+	// A half circle from (0,0) to (100,0), with the top at t=0.5.
+	s := Segment{{X: 0, Y: 0}, {X: 0, Y: 66}, {X: 100, Y: 66}, {X: 100, Y: 0}}
+	cc := []Contour{{s, {{X: 100, Y: 0}, {X: 0, Y: 0}}}}
+	res := AddExtrema(cc)
+	checkClosed(t, res)
+	if len(res[0]) != 3 {
+		t.Fatalf("got %d segments, want 3", len(res[0]))
+	}
+	top := res[0][0].End()
+	if math.Abs(top.X-50) > 1e-9 || math.Abs(top.Y-49.5) > 1e-9 {
+		t.Errorf("wrong extremum %v", top)
+	}
+	if res[0][0][2].Y != top.Y || res[0][1][1].Y != top.Y {
+		t.Errorf("tangent at extremum is not horizontal")
+	}
+
+	// Curves without interior extrema are not changed.
+	res = AddExtrema([]Contour{circle(0, 0, 100)})
+	if len(res[0]) != 4 {
+		t.Errorf("got %d segments, want 4", len(res[0]))
+	}
+}
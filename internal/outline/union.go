@@ -0,0 +1,387 @@
+// seehuhn.de/go/sfnt - a library for reading and writing font files
+// Copyright (C) 2026  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package outline
+
+import (
+	"cmp"
+	"math"
+	"slices"
+
+	"seehuhn.de/go/geom/vec"
+)
+
+// Union returns contours which enclose the union of the areas enclosed by
+// cc, using the non-zero winding rule.  The resulting contours do not
+// intersect each other or themselves.  Outer contours run counter-clockwise
+// and holes run clockwise.
+//
+// The computation uses a polygonal approximation of the outline, but the
+// returned contours are made of (parts of) the original segments.
+func Union(cc []Contour) []Contour {
+	edges := flatten(cc)
+	edges = splitEdges(edges)
+
+	// Classify the edges, using the winding numbers immediately to the left
+	// and to the right of each edge.  Edges which occur several times are
+	// only considered once.
+	seen := make(map[[2]vec.Vec2]bool)
+	var boundary []*flatEdge
+	for _, e := range edges {
+		key := [2]vec.Vec2{e.a, e.b}
+		if less(e.b, e.a) {
+			key = [2]vec.Vec2{e.b, e.a}
+		}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		d := e.b.Sub(e.a)
+		n := vec.Vec2{X: -d.Y, Y: d.X}.Mul(testOffset / d.Length())
+		m := vec.Middle(e.a, e.b)
+		left := windingEdges(edges, m.Add(n)) != 0
+		right := windingEdges(edges, m.Sub(n)) != 0
+		switch {
+		case left && !right:
+			boundary = append(boundary, e)
+		case right && !left:
+			boundary = append(boundary, e.reverse())
+		}
+	}
+
+	return linkEdges(boundary)
+}
+
+// A flatEdge is part of the polygonal approximation of an outline.
+// The edge corresponds to the part of the segment seg between parameters
+// ta and tb.
+type flatEdge struct {
+	a, b   vec.Vec2
+	seg    Segment
+	ta, tb float64
+
+	// pos gives the position of the segment in the input, as the index
+	// of the contour and the index of the segment within the contour.
+	pos [2]int
+}
+
+func (e *flatEdge) reverse() *flatEdge {
+	return &flatEdge{a: e.b, b: e.a, seg: e.seg, ta: e.tb, tb: e.ta, pos: e.pos}
+}
+
+// cmpEdges orders edges by their position in the input.
+func cmpEdges(e, f *flatEdge) int {
+	if c := cmp.Compare(e.pos[0], f.pos[0]); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(e.pos[1], f.pos[1]); c != 0 {
+		return c
+	}
+	return cmp.Compare(min(e.ta, e.tb), min(f.ta, f.tb))
+}
+
+// flatten approximates the contours by polygons.  All vertices are
+// rounded to a fine grid.
+func flatten(cc []Contour) []*flatEdge {
+	var res []*flatEdge
+	for i, c := range cc {
+		for j, s := range c {
+			n := s.numPieces(flatTolerance)
+			prev := snap(s.Start())
+			for k := 1; k <= n; k++ {
+				var p vec.Vec2
+				if k == n {
+					p = snap(s.End())
+				} else {
+					p = snap(s.At(float64(k) / float64(n)))
+				}
+				if p != prev {
+					res = append(res, &flatEdge{
+						a:   prev,
+						b:   p,
+						seg: s,
+						ta:  float64(k-1) / float64(n),
+						tb:  float64(k) / float64(n),
+						pos: [2]int{i, j},
+					})
+				}
+				prev = p
+			}
+		}
+	}
+	return res
+}
+
+// splitEdges splits the edges at all points where they intersect or touch
+// each other.  After this, edges only meet at their end points, and edges
+// which overlap are identical.
+func splitEdges(edges []*flatEdge) []*flatEdge {
+	type split struct {
+		u float64
+		p vec.Vec2
+	}
+	splits := make([][]split, len(edges))
+	addSplit := func(i int, p vec.Vec2) {
+		e := edges[i]
+		if p == e.a || p == e.b {
+			return
+		}
+		d := e.b.Sub(e.a)
+		u := d.Dot(p.Sub(e.a)) / d.Dot(d)
+		if u <= 0 || u >= 1 {
+			return
+		}
+		splits[i] = append(splits[i], split{u, p})
+	}
+
+	// sweep over the edges from left to right
+	order := make([]int, len(edges))
+	for i := range order {
+		order[i] = i
+	}
+	minX := func(e *flatEdge) float64 { return min(e.a.X, e.b.X) }
+	slices.SortFunc(order, func(i, j int) int {
+		return cmp.Compare(minX(edges[i]), minX(edges[j]))
+	})
+	for k, i := range order {
+		e := edges[i]
+		eMaxX := max(e.a.X, e.b.X) + snapTolerance
+		eMinY := min(e.a.Y, e.b.Y) - snapTolerance
+		eMaxY := max(e.a.Y, e.b.Y) + snapTolerance
+		for _, j := range order[k+1:] {
+			f := edges[j]
+			if minX(f) > eMaxX {
+				break
+			}
+			if max(f.a.Y, f.b.Y) < eMinY || min(f.a.Y, f.b.Y) > eMaxY {
+				continue
+			}
+
+			// end points touching the other edge
+			for _, p := range []vec.Vec2{f.a, f.b} {
+				if distToEdge(e, p) < snapTolerance {
+					addSplit(i, p)
+				}
+			}
+			for _, p := range []vec.Vec2{e.a, e.b} {
+				if distToEdge(f, p) < snapTolerance {
+					addSplit(j, p)
+				}
+			}
+
+			// proper intersections
+			r := e.b.Sub(e.a)
+			s := f.b.Sub(f.a)
+			denom := cross(r, s)
+			if denom == 0 {
+				continue
+			}
+			q := f.a.Sub(e.a)
+			u := cross(q, s) / denom
+			v := cross(q, r) / denom
+			if u > 0 && u < 1 && v > 0 && v < 1 {
+				p := snap(e.a.Add(r.Mul(u)))
+				addSplit(i, p)
+				addSplit(j, p)
+			}
+		}
+	}
+
+	var res []*flatEdge
+	for i, e := range edges {
+		ss := splits[i]
+		if len(ss) == 0 {
+			res = append(res, e)
+			continue
+		}
+		slices.SortFunc(ss, func(x, y split) int { return cmp.Compare(x.u, y.u) })
+		ss = append(ss, split{1, e.b})
+		prevP, prevT := e.a, e.ta
+		for _, s := range ss {
+			if s.p == prevP {
+				continue
+			}
+			t := e.ta + s.u*(e.tb-e.ta)
+			if s.u == 1 {
+				t = e.tb
+			}
+			res = append(res, &flatEdge{a: prevP, b: s.p, seg: e.seg, ta: prevT, tb: t, pos: e.pos})
+			prevP, prevT = s.p, t
+		}
+	}
+	return res
+}
+
+// distToEdge returns the distance between the point p and the edge e.
+func distToEdge(e *flatEdge, p vec.Vec2) float64 {
+	d := e.b.Sub(e.a)
+	u := d.Dot(p.Sub(e.a)) / d.Dot(d)
+	u = min(max(u, 0), 1)
+	return p.Sub(e.a.Add(d.Mul(u))).Length()
+}
+
+// windingEdges returns the winding number of the edges around q.
+func windingEdges(edges []*flatEdge, q vec.Vec2) int {
+	w := 0
+	for _, e := range edges {
+		w += crossing(e.a, e.b, q)
+	}
+	return w
+}
+
+// linkEdges joins the boundary edges into closed contours, and replaces
+// runs of edges which come from the same segment by the corresponding part
+// of the segment.  Where possible, the contours keep the order and the
+// start points of the input.
+func linkEdges(edges []*flatEdge) []Contour {
+	out := make(map[vec.Vec2][]*flatEdge)
+	for _, e := range edges {
+		out[e.a] = append(out[e.a], e)
+	}
+	used := make(map[*flatEdge]bool)
+
+	type loopInfo struct {
+		loop  []*flatEdge
+		start int
+	}
+	var loops []loopInfo
+	for _, first := range edges {
+		if used[first] {
+			continue
+		}
+		used[first] = true
+		loop := []*flatEdge{first}
+		closed := false
+		for cur := first; ; {
+			if cur.b == first.a {
+				closed = true
+				break
+			}
+
+			// At vertices where several contours meet, turn left as
+			// much as possible.  This splits the boundary into separate
+			// loops, instead of a single self-touching one.
+			din := cur.b.Sub(cur.a)
+			var next *flatEdge
+			bestAngle := math.Inf(-1)
+			for _, e := range out[cur.b] {
+				if used[e] {
+					continue
+				}
+				dout := e.b.Sub(e.a)
+				angle := math.Atan2(cross(din, dout), din.Dot(dout))
+				if angle > bestAngle {
+					next, bestAngle = e, angle
+				}
+			}
+			if next == nil {
+				break
+			}
+			used[next] = true
+			loop = append(loop, next)
+			cur = next
+		}
+		if !closed || len(loop) < 2 {
+			continue
+		}
+		loops = append(loops, loopInfo{loop, loopStart(loop)})
+	}
+
+	slices.SortStableFunc(loops, func(a, b loopInfo) int {
+		return cmpEdges(a.loop[a.start], b.loop[b.start])
+	})
+	res := make([]Contour, 0, len(loops))
+	for _, l := range loops {
+		res = append(res, rebuild(l.loop, l.start))
+	}
+	return res
+}
+
+// continues reports whether the i-th edge of a loop continues the same
+// segment as the previous edge.
+func continues(loop []*flatEdge, i int) bool {
+	n := len(loop)
+	prev, e := loop[(i+n-1)%n], loop[i]
+	return &prev.seg[0] == &e.seg[0] && prev.tb == e.ta
+}
+
+// loopStart returns the index of the edge where the contour for a loop
+// should start.  This is the first edge of a segment, chosen to come as
+// early as possible in the input.
+func loopStart(loop []*flatEdge) int {
+	start := -1
+	for i, e := range loop {
+		if continues(loop, i) {
+			continue
+		}
+		if start < 0 || cmpEdges(e, loop[start]) < 0 {
+			start = i
+		}
+	}
+	return max(start, 0)
+}
+
+// rebuild converts a closed loop of edges into a contour, starting at the
+// given edge.
+func rebuild(loop []*flatEdge, start int) Contour {
+	n := len(loop)
+	var res Contour
+	for k := 0; k < n; {
+		i := (start + k) % n
+		j := k + 1
+		for j < n && continues(loop, (start+j)%n) {
+			j++
+		}
+		first, last := loop[i], loop[(start+j-1)%n]
+		var s Segment
+		if first.seg.IsLine() {
+			s = Segment{first.a, last.b}
+		} else {
+			s = first.seg.Sub(first.ta, last.tb)
+			s[0] = first.a
+			s[len(s)-1] = last.b
+		}
+		res = append(res, s)
+		k = j
+	}
+	return res
+}
+
+func snap(v vec.Vec2) vec.Vec2 {
+	return vec.Vec2{
+		X: math.Round(v.X*snapGrid) / snapGrid,
+		Y: math.Round(v.Y*snapGrid) / snapGrid,
+	}
+}
+
+func less(a, b vec.Vec2) bool {
+	return a.X < b.X || a.X == b.X && a.Y < b.Y
+}
+
+const (
+	// snapGrid is the number of grid points per font design unit used for
+	// the vertices of the polygonal approximation.
+	snapGrid = 1024
+
+	// snapTolerance is the distance below which a vertex is considered to
+	// lie on an edge.
+	snapTolerance = 0.5 / snapGrid
+
+	// testOffset is the distance from an edge at which the winding number
+	// is evaluated.
+	testOffset = 0.05 / snapGrid
+)
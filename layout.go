@@ -17,6 +17,7 @@
 package sfnt
 
 import (
+	"maps"
 	"math"
 
 	"golang.org/x/text/language"
@@ -31,15 +32,56 @@ import (
 // The Layouter assumes the underlying font is not mutated after the layouter
 // has been created.
 type Layouter struct {
+	// MonotonicClusters, if set, makes the Cluster values of the glyphs
+	// returned by Layout non-decreasing.  A glyph which was moved before
+	// glyphs for earlier text is merged into the cluster of these glyphs.
+	MonotonicClusters bool
+
 	font     *Font
+	lang     language.Tag
 	cmap     cmap.Subtable
+	shaper   shaper // script-specific processing, or nil
 	gsub     *gtab.Context
 	gpos     *gtab.Context
+	chars    []char
 	buf      []glyph.Info
 	advances []funit.Int16 // base advance per gid, in UnitsPerEm
+	ranged   bool          // whether the previous call used feature ranges
+}
+
+// A char is an entry of the character sequence which is constructed before
+// the text is mapped to glyphs.
+type char struct {
+	// R is the character used for the cmap lookup.
+	R rune
+
+	// Text is the text represented by the glyph for this character.  This
+	// can differ from R, for example when a shaper decomposes a character
+	// into several parts or replaces it with a presentation form.
+	Text []rune
+
+	// Cluster is the byte offset in the input text of the first character
+	// represented by this entry.
+	Cluster int
+}
+
+// A shaper implements the script-specific processing which is required
+// before the generic GSUB and GPOS lookups can be applied.
+type shaper interface {
+	// GsubFeatures returns the GSUB features required by the script, in
+	// addition to the features selected by the user.
+	GsubFeatures() []string
+
+	// Preprocess rewrites the character sequence before the characters are
+	// mapped to glyphs.
+	Preprocess(cc []char) []char
 }
 
 // NewLayouter creates a new layouter for the given cmap and lookups.
+//
+// For Korean, Thai and Lao, script-specific processing is applied in
+// addition to the selected features.  The script is determined from the
+// language tag.
 func (f *Font) NewLayouter(lang language.Tag, gsubFeatures, gposFeatures map[string]bool) (*Layouter, error) {
 	cmap, err := f.CMapTable.GetBest()
 	if err != nil {
@@ -48,10 +90,18 @@ func (f *Font) NewLayouter(lang language.Tag, gsubFeatures, gposFeatures map[str
 
 	var gsub, gpos *gtab.Context
 
+	sh := f.newShaper(lang, cmap)
+
 	if f.Gsub != nil {
 		if gsubFeatures == nil {
 			gsubFeatures = gtab.GsubDefaultFeatures
 		}
+		if sh != nil {
+			gsubFeatures = maps.Clone(gsubFeatures)
+			for _, tag := range sh.GsubFeatures() {
+				gsubFeatures[tag] = true
+			}
+		}
 		gsubLookups := f.Gsub.FindLookups(lang, gsubFeatures)
 		gsub = gtab.NewContext(f.Gsub.LookupList, f.Gdef, gsubLookups)
 	}
@@ -77,28 +127,86 @@ func (f *Font) NewLayouter(lang language.Tag, gsubFeatures, gposFeatures map[str
 
 	return &Layouter{
 		font:     f,
+		lang:     lang,
 		cmap:     cmap,
+		shaper:   sh,
 		gsub:     gsub,
 		gpos:     gpos,
 		advances: advances,
 	}, nil
 }
 
+// SetPPEM sets the size of the text in pixels per em, separately for the
+// horizontal and vertical direction.  This is used to apply the Device tables
+// in the GPOS table, which hinted fonts use for size-specific corrections.
+// A value of zero, the default, disables Device table adjustments in the
+// corresponding direction.
+func (l *Layouter) SetPPEM(x, y uint16) {
+	if l.gpos != nil {
+		l.gpos.SetPPEM(l.font.UnitsPerEm, x, y)
+	}
+}
+
+// SetTrace enables recording of the GSUB and GPOS lookups applied by
+// subsequent calls to Layout.  Records are appended to gsub and gpos,
+// respectively.  If an argument is nil, tracing is disabled for the
+// corresponding table.  The traces can be shown in human-readable form
+// using [seehuhn.de/go/sfnt/opentype/gtab/builder.ExplainTrace].
+func (l *Layouter) SetTrace(gsub, gpos *gtab.Trace) {
+	if l.gsub != nil {
+		l.gsub.SetTrace(gsub)
+	}
+	if l.gpos != nil {
+		l.gpos.SetTrace(gpos)
+	}
+}
+
 // Layout returns the glyph sequence for the given text.
 //
+// The Cluster field of each glyph gives the byte offset in s of the
+// first character the glyph represents.
+//
+// Before the text is mapped to glyphs, combining character sequences are
+// composed or decomposed, depending on which characters are supported by the
+// font.  The Text fields of the returned glyphs always hold the original
+// text.
+//
+// Features can be enabled or disabled for parts of the text using the
+// optional feature ranges.  The Start and End fields of the ranges are byte
+// offsets in s.  This overrides the features selected when the Layouter was
+// created, but only within the given ranges.
+//
 // The returned slice is owned by the Layouter and is only valid until the next
 // call to Layout.
-func (l *Layouter) Layout(s string) []glyph.Info {
+func (l *Layouter) Layout(s string, features ...gtab.FeatureRange) []glyph.Info {
+	cc := l.chars[:0]
+	for i, r := range s {
+		cc = append(cc, char{R: r, Text: []rune{r}, Cluster: i})
+	}
+	cc = normalize(cc, l.cmap)
+	if l.shaper != nil {
+		cc = l.shaper.Preprocess(cc)
+	}
+	l.chars = cc
+
+	// Only update the lookup ranges if they can have changed since the
+	// previous call.
+	ranged := len(features) > 0 || l.ranged
+	l.ranged = len(features) > 0
+
 	seq := l.buf[:0]
-	for _, r := range s {
-		gid := l.cmap.Lookup(r)
+	for _, c := range cc {
 		seq = append(seq, glyph.Info{
-			GID:  gid,
-			Text: []rune{r},
+			GID:     l.cmap.Lookup(c.R),
+			Text:    c.Text,
+			Cluster: c.Cluster,
 		})
 	}
 
 	if l.gsub != nil {
+		if ranged {
+			l.gsub.SetRanges(l.font.Gsub.FindLookupRanges(l.lang, features))
+		}
 		seq = l.gsub.Apply(seq)
 	}
 
@@ -111,9 +219,57 @@ func (l *Layouter) Layout(s string) []glyph.Info {
 	}
 
 	if l.gpos != nil {
+		if ranged {
+			l.gpos.SetRanges(l.font.Gpos.FindLookupRanges(l.lang, features))
+		}
 		seq = l.gpos.Apply(seq)
 	}
 
+	if l.MonotonicClusters {
+		mergeClusters(seq)
+	}
+
 	l.buf = seq
 	return seq
 }
+
+// mergeClusters makes the cluster values in seq non-decreasing, by merging
+// each glyph into the cluster of any later glyph with a smaller cluster value.
+func mergeClusters(seq []glyph.Info) {
+	for i := len(seq) - 2; i >= 0; i-- {
+		seq[i].Cluster = min(seq[i].Cluster, seq[i+1].Cluster)
+	}
+}
+
+// newShaper returns the script-specific shaper for the given language, or nil
+// if the generic processing is sufficient.
+func (f *Font) newShaper(lang language.Tag, cmap cmap.Subtable) shaper {
+	script, _ := lang.Script()
+	switch script.String() {
+	case "Hang", "Kore":
+		return &hangulShaper{cmap: cmap}
+	case "Thai":
+		// Fonts without GPOS mark positioning for Thai place marks using
+		// glyph variants in the Private Use Area instead.
+		return &thaiShaper{cmap: cmap, usePUA: !hasScript(f.Gpos, script)}
+	case "Laoo":
+		return &thaiShaper{cmap: cmap, lao: true}
+	default:
+		return nil
+	}
+}
+
+// hasScript reports whether the "GSUB" or "GPOS" table has an entry for the
+// given script.
+func hasScript(info *gtab.Info, script language.Script) bool {
+	if info == nil {
+		return false
+	}
+	for tag := range info.ScriptList {
+		s, _ := tag.Script()
+		if s == script {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,33 @@
+// seehuhn.de/go/sfnt - a library for reading and writing font files
+// Copyright (C) 2026  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package sfnt
+
+import (
+	"testing"
+
+	"seehuhn.de/go/sfnt/glyph"
+)
+
+func TestMergeClusters(t *testing.T) {
+	seq := []glyph.Info{{Cluster: 0}, {Cluster: 5}, {Cluster: 3}, {Cluster: 7}, {Cluster: 7}}
+	mergeClusters(seq)
+	for i, want := range []int{0, 3, 3, 7, 7} {
+		if seq[i].Cluster != want {
+			t.Errorf("glyph %d: cluster %d, want %d", i, seq[i].Cluster, want)
+		}
+	}
+}
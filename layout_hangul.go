@@ -0,0 +1,214 @@
+// seehuhn.de/go/sfnt - a library for reading and writing font files
+// Copyright (C) 2026  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package sfnt
+
+import (
+	"slices"
+
+	"seehuhn.de/go/sfnt/cmap"
+)
+
+// hangulShaper implements the processing of Korean text.
+//
+// Hangul syllables can be written either as precomposed characters or as
+// sequences of conjoining jamo.  Depending on the glyphs available in the
+// font, syllables are composed or decomposed.  Jamo which cannot be
+// composed are shaped by the "ljmo", "vjmo" and "tjmo" features.
+//
+// See https://learn.microsoft.com/en-us/typography/script-development/hangul
+type hangulShaper struct {
+	cmap cmap.Subtable
+}
+
+// Unicode ranges used for Hangul composition.
+// See section 3.12 of the Unicode standard.
+const (
+	hangulSBase  = 0xAC00
+	hangulLBase  = 0x1100
+	hangulVBase  = 0x1161
+	hangulTBase  = 0x11A7
+	hangulLCount = 19
+	hangulVCount = 21
+	hangulTCount = 28
+	hangulNCount = hangulVCount * hangulTCount
+	hangulSCount = hangulLCount * hangulNCount
+)
+
+// GsubFeatures implements the [shaper] interface.
+func (h *hangulShaper) GsubFeatures() []string {
+	return []string{"ljmo", "vjmo", "tjmo"}
+}
+
+// Preprocess implements the [shaper] interface.
+func (h *hangulShaper) Preprocess(cc []char) []char {
+	res := make([]char, 0, len(cc))
+	for i := 0; i < len(cc); i++ {
+		r := cc[i].R
+
+		switch {
+		case isHangulL(r) && i+1 < len(cc) && isHangulV(cc[i+1].R):
+			// a sequence of conjoining jamo
+			n := 2
+			if i+2 < len(cc) && isHangulT(cc[i+2].R) {
+				n = 3
+			}
+			if s, ok := h.composeJamo(cc[i : i+n]); ok {
+				res = append(res, char{R: s, Text: joinText(cc[i : i+n]), Cluster: cc[i].Cluster})
+			} else {
+				res = append(res, cc[i:i+n]...)
+			}
+			i += n - 1
+
+		case isHangulS(r):
+			sIndex := r - hangulSBase
+			hasT := sIndex%hangulTCount != 0
+			nextIsT := i+1 < len(cc) && isHangulT(cc[i+1].R)
+
+			if !hasT && nextIsT {
+				// an LV syllable, followed by a trailing consonant
+				t := cc[i+1].R
+				if isModernT(t) {
+					s := r + (t - hangulTBase)
+					if h.has(s) {
+						res = append(res, char{R: s, Text: joinText(cc[i : i+2]), Cluster: cc[i].Cluster})
+						i++
+						continue
+					}
+				}
+				// Decompose the syllable, so that the jamo features can
+				// combine the parts.
+				if dec := h.decompose(r); dec != nil && h.has(t) {
+					res = h.appendDecomposed(res, dec, cc[i])
+					continue
+				}
+			}
+
+			if !h.has(r) {
+				if dec := h.decompose(r); dec != nil {
+					res = h.appendDecomposed(res, dec, cc[i])
+					continue
+				}
+			}
+			res = append(res, cc[i])
+
+		default:
+			res = append(res, cc[i])
+		}
+	}
+	return res
+}
+
+// composeJamo tries to combine a sequence of two or three conjoining jamo
+// into a precomposed syllable which is supported by the font.
+func (h *hangulShaper) composeJamo(jamo []char) (rune, bool) {
+	l, v := jamo[0].R, jamo[1].R
+	if !isModernL(l) || !isModernV(v) {
+		return 0, false
+	}
+	s := hangulSBase + ((l-hangulLBase)*hangulVCount+(v-hangulVBase))*hangulTCount
+	if len(jamo) > 2 {
+		t := jamo[2].R
+		if !isModernT(t) {
+			// An archaic trailing consonant can only be rendered using jamo
+			// glyphs, so the leading consonant and the vowel must be kept
+			// separate as well.
+			return 0, false
+		}
+		s += t - hangulTBase
+	}
+	if !h.has(s) {
+		return 0, false
+	}
+	return s, true
+}
+
+// decompose splits a precomposed syllable into conjoining jamo.  If the font
+// does not contain all the required jamo glyphs, nil is returned.
+func (h *hangulShaper) decompose(s rune) []rune {
+	sIndex := s - hangulSBase
+	l := hangulLBase + sIndex/hangulNCount
+	v := hangulVBase + (sIndex%hangulNCount)/hangulTCount
+	t := hangulTBase + sIndex%hangulTCount
+
+	res := []rune{l, v}
+	if t != hangulTBase {
+		res = append(res, t)
+	}
+	if slices.ContainsFunc(res, func(r rune) bool { return !h.has(r) }) {
+		return nil
+	}
+	return res
+}
+
+// appendDecomposed appends the jamo of the decomposed syllable s to res.
+// The text of the syllable is attached to the first jamo.
+func (h *hangulShaper) appendDecomposed(res []char, jamo []rune, s char) []char {
+	for i, r := range jamo {
+		c := char{R: r, Cluster: s.Cluster}
+		if i == 0 {
+			c.Text = s.Text
+		}
+		res = append(res, c)
+	}
+	return res
+}
+
+func (h *hangulShaper) has(r rune) bool {
+	return h.cmap.Lookup(r) != 0
+}
+
+// joinText concatenates the text of the given characters.
+func joinText(cc []char) []rune {
+	var res []rune
+	for _, c := range cc {
+		res = append(res, c.Text...)
+	}
+	return res
+}
+
+func isHangulS(r rune) bool {
+	return r >= hangulSBase && r < hangulSBase+hangulSCount
+}
+
+// isHangulL reports whether r is a leading consonant jamo,
+// including the archaic ones.
+func isHangulL(r rune) bool {
+	return r >= 0x1100 && r <= 0x115F || r >= 0xA960 && r <= 0xA97C
+}
+
+// isHangulV reports whether r is a vowel jamo, including the archaic ones.
+func isHangulV(r rune) bool {
+	return r >= 0x1160 && r <= 0x11A7 || r >= 0xD7B0 && r <= 0xD7C6
+}
+
+// isHangulT reports whether r is a trailing consonant jamo,
+// including the archaic ones.
+func isHangulT(r rune) bool {
+	return r >= 0x11A8 && r <= 0x11FF || r >= 0xD7CB && r <= 0xD7FB
+}
+
+func isModernL(r rune) bool {
+	return r >= hangulLBase && r < hangulLBase+hangulLCount
+}
+
+func isModernV(r rune) bool {
+	return r >= hangulVBase && r < hangulVBase+hangulVCount
+}
+
+func isModernT(r rune) bool {
+	return r > hangulTBase && r < hangulTBase+hangulTCount
+}
@@ -0,0 +1,93 @@
+// seehuhn.de/go/sfnt - a library for reading and writing font files
+// Copyright (C) 2026  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package sfnt
+
+import (
+	"testing"
+
+	"seehuhn.de/go/sfnt/cmap"
+	"seehuhn.de/go/sfnt/glyph"
+)
+
+// makeCMap returns a cmap which maps the given runes to consecutive glyphs,
+// starting at glyph 1.
+func makeCMap(rr ...rune) cmap.Format12 {
+	res := cmap.Format12{}
+	for i, r := range rr {
+		res[uint32(r)] = glyph.ID(i + 1)
+	}
+	return res
+}
+
+func toChars(s string) []char {
+	var res []char
+	for _, r := range s {
+		res = append(res, char{R: r, Text: []rune{r}})
+	}
+	return res
+}
+
+// checkChars verifies the characters used for cmap lookups, and that the
+// concatenated text reproduces the input.
+func checkChars(t *testing.T, in string, got []char, want string) {
+	t.Helper()
+	var rr, text []rune
+	for _, c := range got {
+		rr = append(rr, c.R)
+		text = append(text, c.Text...)
+	}
+	if string(rr) != want {
+		t.Errorf("%q: got %U, want %U", in, rr, []rune(want))
+	}
+	if string(text) != in {
+		t.Errorf("%q: text does not round-trip, got %q", in, string(text))
+	}
+}
+
+func TestHangulPreprocess(t *testing.T) {
+	const (
+		gaJamo  = "\u1100\u1161"
+		gakJamo = "\u1100\u1161\u11A8"
+		ga      = "\uAC00"
+		gak     = "\uAC01"
+		oldT    = "\u11C3" // an archaic trailing consonant
+	)
+
+	cases := []struct {
+		name string
+		cmap cmap.Format12
+		in   string
+		want string
+	}{
+		{"compose LV", makeCMap(0xAC00), gaJamo, ga},
+		{"compose LVT", makeCMap(0xAC01), gakJamo, gak},
+		{"no syllable glyph", makeCMap(0x1100, 0x1161), gaJamo, gaJamo},
+		{"LV+T", makeCMap(0xAC00, 0xAC01), ga + "\u11A8", gak},
+		{"LV+T decomposed", makeCMap(0xAC00, 0x1100, 0x1161, 0x11A8), ga + "\u11A8", gakJamo},
+		{"decompose", makeCMap(0x1100, 0x1161, 0x11A8), gak, gakJamo},
+		{"keep syllable", makeCMap(0xAC01, 0x1100, 0x1161, 0x11A8), gak, gak},
+		{"archaic T", makeCMap(0xAC00, 0x1100, 0x1161, 0x11C3), gaJamo + oldT, gaJamo + oldT},
+		{"no glyphs", makeCMap(), gak, gak},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			h := &hangulShaper{cmap: c.cmap}
+			got := h.Preprocess(toChars(c.in))
+			checkChars(t, c.in, got, c.want)
+		})
+	}
+}
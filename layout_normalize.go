@@ -0,0 +1,162 @@
+// seehuhn.de/go/sfnt - a library for reading and writing font files
+// Copyright (C) 2026  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package sfnt
+
+import (
+	"slices"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+	"seehuhn.de/go/sfnt/cmap"
+)
+
+// normalize brings the character sequence into the form which is best
+// supported by the font.
+//
+// The text is split into clusters, consisting of a base character followed
+// by combining marks.  If the font supports all characters of the composed
+// form (NFC) of a cluster, this form is used.  Otherwise, the cluster is
+// fully decomposed and then recomposed where the font has a glyph for the
+// composed character.  This way, "e"
+// followed by U+0301 uses a precomposed glyph for "é" if available, and a
+// precomposed character which is missing from the font is rendered as base
+// plus mark.
+//
+// The text of a changed cluster is attached to the first character of the
+// cluster, so that the original text can still be recovered from the glyphs.
+// All characters of a changed cluster share the same cluster offset.
+func normalize(cc []char, cmap cmap.Subtable) []char {
+	var res []char
+	var orig []rune
+	start := 0
+	for start < len(cc) {
+		end := start + 1
+		for end < len(cc) && cccOf(cc[end].R) != 0 {
+			end++
+		}
+		cluster := cc[start:end]
+
+		if len(cluster) == 1 && cmap.Lookup(cluster[0].R) != 0 {
+			// fast path: a single character, supported by the font
+			if res != nil {
+				res = append(res, cluster[0])
+			}
+			start = end
+			continue
+		}
+
+		orig = orig[:0]
+		for _, c := range cluster {
+			orig = append(orig, c.R)
+		}
+		buf := []rune(norm.NFC.String(string(orig)))
+		if numMissing(buf, cmap) > 0 {
+			buf = recompose([]rune(norm.NFD.String(string(orig))), cmap)
+		}
+
+		// Use the normalized form only if it has fewer characters missing
+		// from the font, or if it is not longer than the original.
+		newMissing, origMissing := numMissing(buf, cmap), numMissing(orig, cmap)
+		useNew := newMissing < origMissing ||
+			newMissing == origMissing && len(buf) <= len(orig)
+		if !useNew || slices.Equal(buf, orig) {
+			if res != nil {
+				res = append(res, cluster...)
+			}
+			start = end
+			continue
+		}
+
+		if res == nil {
+			res = make([]char, start, len(cc)+len(buf))
+			copy(res, cc[:start])
+		}
+		text := joinText(cluster)
+		for i, r := range buf {
+			c := char{R: r, Cluster: cluster[0].Cluster}
+			if i == 0 {
+				c.Text = text
+			}
+			res = append(res, c)
+		}
+		start = end
+	}
+	if res == nil {
+		return cc
+	}
+	return res
+}
+
+// recompose combines the characters in the decomposed sequence rr with the
+// preceding base character, where the font has a glyph for the composed
+// character.  The result overwrites rr.
+//
+// A character can only be combined with the base, if no character of the
+// same or higher combining class is left between the two (see section 3.11
+// of the Unicode standard).
+func recompose(rr []rune, cmap cmap.Subtable) []rune {
+	out := rr[:0]
+	base := -1
+	var lastCCC uint8
+	for _, r := range rr {
+		ccc := cccOf(r)
+		blocked := base < 0 ||
+			len(out) > base+1 && (lastCCC == 0 || lastCCC >= ccc)
+		if !blocked {
+			if comp, ok := composePair(out[base], r); ok && cmap.Lookup(comp) != 0 {
+				out[base] = comp
+				continue
+			}
+		}
+		out = append(out, r)
+		if ccc == 0 {
+			base = len(out) - 1
+		}
+		lastCCC = ccc
+	}
+	return out
+}
+
+// composePair returns the canonical composition of a and b, if it exists.
+func composePair(a, b rune) (rune, bool) {
+	var buf [2 * utf8.UTFMax]byte
+	n := utf8.EncodeRune(buf[:], a)
+	n += utf8.EncodeRune(buf[n:], b)
+	s := norm.NFC.String(string(buf[:n]))
+	r, size := utf8.DecodeRuneInString(s)
+	if size != len(s) || r == a {
+		return 0, false
+	}
+	return r, true
+}
+
+func cccOf(r rune) uint8 {
+	var buf [utf8.UTFMax]byte
+	n := utf8.EncodeRune(buf[:], r)
+	return norm.NFD.Properties(buf[:n]).CCC()
+}
+
+// numMissing counts the characters which are not supported by the font.
+func numMissing(rr []rune, cmap cmap.Subtable) int {
+	count := 0
+	for _, r := range rr {
+		if cmap.Lookup(r) == 0 {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,56 @@
+// seehuhn.de/go/sfnt - a library for reading and writing font files
+// Copyright (C) 2026  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package sfnt
+
+import (
+	"testing"
+
+	"seehuhn.de/go/sfnt/cmap"
+)
+
+func TestNormalize(t *testing.T) {
+	const (
+		eAcute    = "\u00E9"
+		eComb     = "e\u0301"
+		aDotBelow = "a\u0323\u0302" // a, dot below, circumflex
+		aCircDot  = "\u1EAD"        // a with circumflex and dot below
+	)
+	cases := []struct {
+		name string
+		cmap cmap.Format12
+		in   string
+		want string
+	}{
+		{"compose", makeCMap('e', 0x0301, 0x00E9), eComb, eAcute},
+		{"keep composed", makeCMap('e', 0x0301, 0x00E9), eAcute, eAcute},
+		{"decompose", makeCMap('e', 0x0301), eAcute, eComb},
+		{"keep decomposed", makeCMap('e', 0x0301), eComb, eComb},
+		{"missing mark", makeCMap('e'), eAcute, eAcute},
+		{"no glyphs", makeCMap(), eComb, eComb},
+		{"partial", makeCMap('a', 0x1EA1, 0x0302), "\u1EAD", "\u1EA1\u0302"},
+		{"reorder", makeCMap('a', 0x1EAD), "a\u0302\u0323", aCircDot},
+		{"reorder partial", makeCMap('a', 0x0323, 0x00E2), "a\u0302\u0323", "\u00E2\u0323"},
+		{"blocked", makeCMap('a', 0x0323, 0x0302), aDotBelow, aDotBelow},
+		{"text", makeCMap('x', 'e', 0x0301, 0x00E9), "x" + eComb + "x", "x" + eAcute + "x"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := normalize(toChars(c.in), c.cmap)
+			checkChars(t, c.in, got, c.want)
+		})
+	}
+}
@@ -0,0 +1,122 @@
+// seehuhn.de/go/sfnt - a library for reading and writing font files
+// Copyright (C) 2026  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package sfnt_test
+
+import (
+	"bytes"
+	"testing"
+
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/text/language"
+
+	"seehuhn.de/go/sfnt"
+	"seehuhn.de/go/sfnt/glyph"
+	"seehuhn.de/go/sfnt/opentype/coverage"
+	"seehuhn.de/go/sfnt/opentype/gtab"
+	"seehuhn.de/go/sfnt/parser"
+)
+
+func readGoRegular(t *testing.T) *sfnt.Font {
+	t.Helper()
+	f, err := sfnt.Read(bytes.NewReader(goregular.TTF), parser.NewBudget(int64(len(goregular.TTF))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestLayoutClusters(t *testing.T) {
+	f := readGoRegular(t)
+	l, err := f.NewLayouter(language.English, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// "e" followed by a combining acute accent is composed into a single
+	// glyph, which keeps the original text and the cluster of the "e".
+	in := "xe\u0301y"
+	seq := l.Layout(in)
+	if len(seq) != 3 {
+		t.Fatalf("expected 3 glyphs, got %d", len(seq))
+	}
+	cmap, _ := f.CMapTable.GetBest()
+	if seq[1].GID != cmap.Lookup('\u00E9') {
+		t.Errorf("expected precomposed glyph, got %d", seq[1].GID)
+	}
+	var text []rune
+	for _, g := range seq {
+		text = append(text, g.Text...)
+	}
+	if string(text) != in {
+		t.Errorf("text does not round-trip: %q", string(text))
+	}
+	for i, want := range []int{0, 1, 4} {
+		if seq[i].Cluster != want {
+			t.Errorf("glyph %d: cluster %d, want %d", i, seq[i].Cluster, want)
+		}
+	}
+}
+
+func TestLayoutFeatureRanges(t *testing.T) {
+	f := readGoRegular(t)
+	cmap, err := f.CMapTable.GetBest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, b := cmap.Lookup('a'), cmap.Lookup('b')
+
+	// a "test" feature which replaces "a" with "b"
+	f.Gsub = &gtab.Info{
+		ScriptList: gtab.ScriptListInfo{
+			language.MustParse("und-Latn-x-latn"): {
+				Required: 0xFFFF,
+				Optional: []gtab.FeatureIndex{0},
+			},
+		},
+		FeatureList: []*gtab.Feature{
+			{Tag: "test", Lookups: []gtab.LookupIndex{0}},
+		},
+		LookupList: []*gtab.LookupTable{
+			{
+				Meta: &gtab.LookupMetaInfo{LookupType: 1},
+				Subtables: []gtab.Subtable{
+					&gtab.Gsub1_1{Cov: coverage.Set{a: true}, Delta: b - a},
+				},
+			},
+		},
+	}
+	l, err := f.NewLayouter(language.English, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seq := l.Layout("aaaa", gtab.FeatureRange{Tag: "test", Start: 1, End: 3, Value: 1})
+	want := []glyph.ID{a, b, b, a}
+	for i, g := range seq {
+		if g.GID != want[i] {
+			t.Errorf("glyph %d: got %d, want %d", i, g.GID, want[i])
+		}
+	}
+
+	// ranges only apply to a single call
+	seq = l.Layout("aa")
+	for i, g := range seq {
+		if g.GID != a {
+			t.Errorf("glyph %d: got %d, want %d", i, g.GID, a)
+		}
+	}
+}
@@ -0,0 +1,297 @@
+// seehuhn.de/go/sfnt - a library for reading and writing font files
+// Copyright (C) 2026  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package sfnt
+
+import (
+	"seehuhn.de/go/sfnt/cmap"
+)
+
+// thaiShaper implements the processing of Thai and Lao text.
+//
+// The vowel sign SARA AM is decomposed into NIKHAHIT and SARA AA, and the
+// NIKHAHIT is moved before any tone marks on the same base.  For Thai fonts
+// without GPOS mark positioning, marks are replaced by the shifted glyph
+// variants which legacy fonts provide in the Private Use Area.
+//
+// See https://learn.microsoft.com/en-us/typography/script-development/thai
+type thaiShaper struct {
+	cmap   cmap.Subtable
+	lao    bool
+	usePUA bool
+}
+
+// GsubFeatures implements the [shaper] interface.
+func (th *thaiShaper) GsubFeatures() []string {
+	return nil
+}
+
+// Preprocess implements the [shaper] interface.
+func (th *thaiShaper) Preprocess(cc []char) []char {
+	cc = th.decomposeSaraAm(cc)
+	if th.usePUA {
+		th.applyPUA(cc)
+	}
+	return cc
+}
+
+// decomposeSaraAm replaces each SARA AM with NIKHAHIT and SARA AA and moves
+// the NIKHAHIT before any preceding above-base marks.  The text of all
+// reordered characters is attached to the NIKHAHIT, and the reordered
+// characters are merged into one cluster.
+func (th *thaiShaper) decomposeSaraAm(cc []char) []char {
+	saraAm, nikhahit, saraAa := rune(0x0E33), rune(0x0E4D), rune(0x0E32)
+	if th.lao {
+		saraAm, nikhahit, saraAa = 0x0EB3, 0x0ECD, 0x0EB2
+	}
+
+	var res []char
+	for i, c := range cc {
+		if c.R != saraAm {
+			if res != nil {
+				res = append(res, c)
+			}
+			continue
+		}
+		if res == nil {
+			res = make([]char, i, len(cc)+1)
+			copy(res, cc[:i])
+		}
+
+		start := len(res)
+		for start > 0 && th.isAboveMark(res[start-1].R) {
+			start--
+		}
+		var text []rune
+		for _, m := range res[start:] {
+			text = append(text, m.Text...)
+		}
+		text = append(text, c.Text...)
+
+		cluster := c.Cluster
+		if start < len(res) {
+			cluster = res[start].Cluster
+		}
+
+		res = append(res, char{})
+		copy(res[start+1:], res[start:])
+		res[start] = char{R: nikhahit, Text: text, Cluster: cluster}
+		for j := start + 1; j < len(res); j++ {
+			res[j].Text = nil
+			res[j].Cluster = cluster
+		}
+		res = append(res, char{R: saraAa, Cluster: cluster})
+	}
+	if res == nil {
+		return cc
+	}
+	return res
+}
+
+// isAboveMark reports whether r is one of the marks which are placed above
+// the base, in front of which a decomposed NIKHAHIT is moved.
+func (th *thaiShaper) isAboveMark(r rune) bool {
+	if th.lao {
+		return r == 0x0EB1 || r >= 0x0EB4 && r <= 0x0EB7 || r == 0x0EBB ||
+			r >= 0x0EC8 && r <= 0x0ECD
+	}
+	return r == 0x0E31 || r >= 0x0E34 && r <= 0x0E37 || r >= 0x0E47 && r <= 0x0E4E
+}
+
+// applyPUA replaces Thai marks by their positioned variants from the Private
+// Use Area, where the font provides these.  The state machine follows the
+// one used by HarfBuzz.
+func (th *thaiShaper) applyPUA(cc []char) {
+	aboveState := thaiAboveStart[thaiNotConsonant]
+	belowState := thaiBelowStart[thaiNotConsonant]
+	base := 0
+	for i := range cc {
+		mt := thaiMarkTypeOf(cc[i].R)
+		if mt == thaiNotMark {
+			ct := thaiConsonantTypeOf(cc[i].R)
+			aboveState = thaiAboveStart[ct]
+			belowState = thaiBelowStart[ct]
+			base = i
+			continue
+		}
+
+		above := thaiAboveMachine[aboveState][mt]
+		below := thaiBelowMachine[belowState][mt]
+		cc[i].R = th.puaVariant(cc[i].R, above.action)
+		if below.action == thaiRD {
+			cc[base].R = th.puaVariant(cc[base].R, below.action)
+		} else {
+			cc[i].R = th.puaVariant(cc[i].R, below.action)
+		}
+		aboveState = above.next
+		belowState = below.next
+	}
+}
+
+// puaVariant returns the Private Use Area variant of r for the given
+// action.  The Windows variant is preferred over the Macintosh one.  If the
+// font supports neither, r is returned unchanged.
+func (th *thaiShaper) puaVariant(r rune, action thaiAction) rune {
+	for _, m := range thaiPUAMappings[action] {
+		if m.u != r {
+			continue
+		}
+		if th.cmap.Lookup(m.win) != 0 {
+			return m.win
+		}
+		if th.cmap.Lookup(m.mac) != 0 {
+			return m.mac
+		}
+		break
+	}
+	return r
+}
+
+type thaiConsonantType int
+
+const (
+	thaiNC thaiConsonantType = iota // normal consonant
+	thaiAC                          // consonant with an ascender
+	thaiRC                          // consonant with a removable descender
+	thaiDC                          // consonant with a strict descender
+	thaiNotConsonant
+)
+
+func thaiConsonantTypeOf(r rune) thaiConsonantType {
+	switch {
+	case r == 0x0E1B || r == 0x0E1D || r == 0x0E1F:
+		return thaiAC
+	case r == 0x0E0D || r == 0x0E10:
+		return thaiRC
+	case r == 0x0E0E || r == 0x0E0F:
+		return thaiDC
+	case r >= 0x0E01 && r <= 0x0E2E:
+		return thaiNC
+	default:
+		return thaiNotConsonant
+	}
+}
+
+type thaiMarkType int
+
+const (
+	thaiAV thaiMarkType = iota // above-base vowel
+	thaiBV                     // below-base vowel
+	thaiT                      // tone mark
+	thaiNotMark
+)
+
+func thaiMarkTypeOf(r rune) thaiMarkType {
+	switch {
+	case r == 0x0E31 || r >= 0x0E34 && r <= 0x0E37 || r == 0x0E47 ||
+		r == 0x0E4D || r == 0x0E4E:
+		return thaiAV
+	case r >= 0x0E38 && r <= 0x0E3A:
+		return thaiBV
+	case r >= 0x0E48 && r <= 0x0E4C:
+		return thaiT
+	default:
+		return thaiNotMark
+	}
+}
+
+type thaiAction int
+
+const (
+	thaiNOP thaiAction = iota
+	thaiSD             // shift down
+	thaiSL             // shift left
+	thaiSDL            // shift down and left
+	thaiRD             // remove descender from base
+)
+
+type thaiEdge struct {
+	action thaiAction
+	next   int
+}
+
+var (
+	thaiAboveStart = [...]int{
+		thaiNC:           0,
+		thaiAC:           1,
+		thaiRC:           0,
+		thaiDC:           0,
+		thaiNotConsonant: 3,
+	}
+	thaiAboveMachine = [4][3]thaiEdge{
+		//  AV              BV              T
+		{{thaiNOP, 3}, {thaiNOP, 0}, {thaiSD, 3}},
+		{{thaiSL, 2}, {thaiNOP, 1}, {thaiSDL, 2}},
+		{{thaiNOP, 3}, {thaiNOP, 2}, {thaiSL, 3}},
+		{{thaiNOP, 3}, {thaiNOP, 3}, {thaiNOP, 3}},
+	}
+
+	thaiBelowStart = [...]int{
+		thaiNC:           0,
+		thaiAC:           0,
+		thaiRC:           1,
+		thaiDC:           2,
+		thaiNotConsonant: 2,
+	}
+	thaiBelowMachine = [3][3]thaiEdge{
+		//  AV              BV              T
+		{{thaiNOP, 0}, {thaiNOP, 2}, {thaiNOP, 0}},
+		{{thaiNOP, 1}, {thaiRD, 2}, {thaiNOP, 1}},
+		{{thaiNOP, 2}, {thaiSD, 2}, {thaiNOP, 2}},
+	}
+)
+
+type thaiPUAMapping struct {
+	u, win, mac rune
+}
+
+var thaiPUAMappings = map[thaiAction][]thaiPUAMapping{
+	thaiSD: {
+		{0x0E48, 0xF70A, 0xF88B}, // MAI EK
+		{0x0E49, 0xF70B, 0xF88E}, // MAI THO
+		{0x0E4A, 0xF70C, 0xF891}, // MAI TRI
+		{0x0E4B, 0xF70D, 0xF894}, // MAI CHATTAWA
+		{0x0E4C, 0xF70E, 0xF897}, // THANTHAKHAT
+		{0x0E38, 0xF718, 0xF89B}, // SARA U
+		{0x0E39, 0xF719, 0xF89C}, // SARA UU
+		{0x0E3A, 0xF71A, 0xF89D}, // PHINTHU
+	},
+	thaiSDL: {
+		{0x0E48, 0xF705, 0xF88C}, // MAI EK
+		{0x0E49, 0xF706, 0xF88F}, // MAI THO
+		{0x0E4A, 0xF707, 0xF892}, // MAI TRI
+		{0x0E4B, 0xF708, 0xF895}, // MAI CHATTAWA
+		{0x0E4C, 0xF709, 0xF898}, // THANTHAKHAT
+	},
+	thaiSL: {
+		{0x0E48, 0xF713, 0xF88A}, // MAI EK
+		{0x0E49, 0xF714, 0xF88D}, // MAI THO
+		{0x0E4A, 0xF715, 0xF890}, // MAI TRI
+		{0x0E4B, 0xF716, 0xF893}, // MAI CHATTAWA
+		{0x0E4C, 0xF717, 0xF896}, // THANTHAKHAT
+		{0x0E31, 0xF710, 0xF884}, // MAI HAN-AKAT
+		{0x0E34, 0xF701, 0xF885}, // SARA I
+		{0x0E35, 0xF702, 0xF886}, // SARA II
+		{0x0E36, 0xF703, 0xF887}, // SARA UE
+		{0x0E37, 0xF704, 0xF888}, // SARA UEE
+		{0x0E47, 0xF712, 0xF889}, // MAITAIKHU
+		{0x0E4D, 0xF711, 0xF899}, // NIKHAHIT
+	},
+	thaiRD: {
+		{0x0E0D, 0xF70F, 0xF89A}, // YO YING
+		{0x0E10, 0xF700, 0xF89E}, // THO THAN
+	},
+}
@@ -0,0 +1,96 @@
+// seehuhn.de/go/sfnt - a library for reading and writing font files
+// Copyright (C) 2026  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package sfnt
+
+import (
+	"testing"
+
+	"golang.org/x/text/language"
+	"seehuhn.de/go/sfnt/opentype/gtab"
+)
+
+func TestThaiSaraAm(t *testing.T) {
+	cases := []struct {
+		lao  bool
+		in   string
+		want string
+	}{
+		{false, "\u0E01\u0E33", "\u0E01\u0E4D\u0E32"},
+		{false, "\u0E19\u0E49\u0E33", "\u0E19\u0E4D\u0E49\u0E32"},
+		{false, "\u0E01\u0E32", "\u0E01\u0E32"},
+		{true, "\u0E81\u0EB3", "\u0E81\u0ECD\u0EB2"},
+		{true, "\u0E99\u0EC9\u0EB3", "\u0E99\u0ECD\u0EC9\u0EB2"},
+	}
+	for _, c := range cases {
+		th := &thaiShaper{cmap: makeCMap(), lao: c.lao}
+		got := th.Preprocess(toChars(c.in))
+		checkChars(t, c.in, got, c.want)
+	}
+}
+
+func TestThaiPUA(t *testing.T) {
+	cmap := makeCMap(0xF70A, 0xF713, 0xF705, 0xF70F, 0xF718)
+	cases := []struct {
+		in   string
+		want string
+	}{
+		// tone mark on a normal consonant is shifted down
+		{"\u0E01\u0E48", "\u0E01\uF70A"},
+		// tone mark on an ascender consonant is shifted down and left
+		{"\u0E1B\u0E48", "\u0E1B\uF705"},
+		// tone mark after an above vowel on an ascender consonant is
+		// shifted left
+		{"\u0E1B\u0E34\u0E48", "\u0E1B\u0E34\uF713"},
+		// YO YING loses its descender before a below vowel
+		{"\u0E0D\u0E38", "\uF70F\u0E38"},
+		// below vowel on a descender consonant is shifted down
+		{"\u0E0E\u0E38", "\u0E0E\uF718"},
+		// no variant available in the font
+		{"\u0E01\u0E49", "\u0E01\u0E49"},
+	}
+	for _, c := range cases {
+		th := &thaiShaper{cmap: cmap, usePUA: true}
+		got := th.Preprocess(toChars(c.in))
+		checkChars(t, c.in, got, c.want)
+	}
+}
+
+func TestNewShaper(t *testing.T) {
+	f := &Font{}
+	cmap := makeCMap()
+	if _, ok := f.newShaper(language.Korean, cmap).(*hangulShaper); !ok {
+		t.Error("Korean: expected the Hangul shaper")
+	}
+	if s, ok := f.newShaper(language.Thai, cmap).(*thaiShaper); !ok || !s.usePUA {
+		t.Error("Thai: expected the Thai shaper with PUA fallback")
+	}
+	if s, ok := f.newShaper(language.Lao, cmap).(*thaiShaper); !ok || !s.lao || s.usePUA {
+		t.Error("Lao: expected the Lao shaper")
+	}
+	if s := f.newShaper(language.English, cmap); s != nil {
+		t.Errorf("English: expected no shaper, got %T", s)
+	}
+
+	f.Gpos = &gtab.Info{
+		ScriptList: gtab.ScriptListInfo{
+			language.MustParse("und-Thai-x-thai"): &gtab.Features{Required: 0xFFFF},
+		},
+	}
+	if s, ok := f.newShaper(language.Thai, cmap).(*thaiShaper); !ok || s.usePUA {
+		t.Error("Thai with GPOS: expected no PUA fallback")
+	}
+}
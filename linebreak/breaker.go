@@ -0,0 +1,333 @@
+// seehuhn.de/go/sfnt - a library for reading and writing font files
+// Copyright (C) 2026  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package linebreak
+
+import (
+	"slices"
+	"sort"
+	"unicode"
+	"unicode/utf8"
+
+	"seehuhn.de/go/postscript/funit"
+
+	"seehuhn.de/go/sfnt/glyph"
+	"seehuhn.de/go/sfnt/opentype/gtab"
+)
+
+// Shaper converts a string into a sequence of positioned glyphs.
+// The Cluster fields of the glyphs must give byte offsets into s.
+//
+// This interface is implemented by [seehuhn.de/go/sfnt.Layouter].
+type Shaper interface {
+	Layout(s string, features ...gtab.FeatureRange) []glyph.Info
+}
+
+// Breaker splits shaped text into lines.
+type Breaker struct {
+	shaper Shaper
+
+	// Hyphenator, if not nil, is used to find additional break
+	// opportunities inside words.
+	Hyphenator *Hyphenator
+
+	// Hyphen is the text appended to a line which ends inside a word.
+	// If this is empty, "-" is used.
+	Hyphen string
+}
+
+// NewBreaker returns a new Breaker which uses the given shaper.
+func NewBreaker(shaper Shaper) *Breaker {
+	return &Breaker{shaper: shaper}
+}
+
+// Paragraph is a shaped text, together with its line break opportunities.
+type Paragraph struct {
+	// Text is the text of the paragraph.
+	Text string
+
+	// Glyphs is the glyph sequence for the whole text, without line breaks.
+	Glyphs []glyph.Info
+
+	// Breaks lists the line break opportunities in Text.
+	Breaks []Opportunity
+
+	b        *Breaker
+	features []gtab.FeatureRange
+
+	// glyphAt maps byte offsets in Text to glyph indices.  The value is -1
+	// for positions which are not at a boundary between glyphs, for example
+	// inside a ligature.
+	glyphAt []int
+}
+
+// Line is a single line of a paragraph.
+type Line struct {
+	// Start and End give the byte range of the line in the paragraph text.
+	// Trailing white space and line break characters are included.
+	Start, End int
+
+	// Glyphs is the glyph sequence for the line.  Trailing white space is
+	// not included.  The Cluster values are byte offsets into the paragraph
+	// text.  Glyphs for an inserted hyphen have an empty Text field.
+	Glyphs []glyph.Info
+
+	// Width is the sum of the (GPOS-adjusted) glyph advances.
+	Width funit.Int
+
+	// Hyphenated is set, if the line ends inside a word.
+	Hyphenated bool
+}
+
+// Shape lays out the text and finds all line break opportunities.
+func (b *Breaker) Shape(text string, features ...gtab.FeatureRange) *Paragraph {
+	p := &Paragraph{
+		Text:     text,
+		Glyphs:   slices.Clone(b.shaper.Layout(text, features...)),
+		b:        b,
+		features: features,
+	}
+	p.Breaks = b.findBreaks(text)
+
+	// Find the positions in the text which correspond to boundaries
+	// between glyphs.
+	p.glyphAt = make([]int, len(text)+1)
+	for i := range p.glyphAt {
+		p.glyphAt[i] = -1
+	}
+	n := len(p.Glyphs)
+	minSuffix := make([]int, n+1)
+	minSuffix[n] = len(text)
+	for k := n - 1; k >= 0; k-- {
+		minSuffix[k] = min(minSuffix[k+1], p.Glyphs[k].Cluster)
+	}
+	maxPrefix := -1
+	for k := 0; k <= n; k++ {
+		if maxPrefix < minSuffix[k] && p.glyphAt[minSuffix[k]] < 0 {
+			p.glyphAt[minSuffix[k]] = k
+		}
+		if k < n {
+			maxPrefix = max(maxPrefix, p.Glyphs[k].Cluster)
+		}
+	}
+	p.glyphAt[0] = 0
+
+	return p
+}
+
+// findBreaks returns the UAX #14 break opportunities in text, together with
+// the hyphenation points.
+func (b *Breaker) findBreaks(text string) []Opportunity {
+	breaks := FindBreaks(text)
+	for i, o := range breaks {
+		if r, _ := utf8.DecodeLastRuneInString(text[:o.Pos]); r == softHyphen {
+			breaks[i].Hyphen = true
+		}
+	}
+	if b.Hyphenator == nil {
+		return breaks
+	}
+
+	var extra []Opportunity
+	wordStart := -1
+	for pos, r := range text + " " {
+		if unicode.IsLetter(r) || wordStart >= 0 && unicode.Is(unicode.Mn, r) {
+			if wordStart < 0 {
+				wordStart = pos
+			}
+			continue
+		}
+		if wordStart >= 0 {
+			for _, offs := range b.Hyphenator.Hyphenate(text[wordStart:pos]) {
+				extra = append(extra, Opportunity{Pos: wordStart + offs, Hyphen: true})
+			}
+			wordStart = -1
+		}
+	}
+	if extra == nil {
+		return breaks
+	}
+	breaks = append(breaks, extra...)
+	sort.SliceStable(breaks, func(i, j int) bool {
+		return breaks[i].Pos < breaks[j].Pos
+	})
+	return slices.CompactFunc(breaks, func(a, b Opportunity) bool {
+		return a.Pos == b.Pos
+	})
+}
+
+// Lines breaks the paragraph into lines, such that each line is at most
+// width font design units wide, where possible.  Lines are filled greedily
+// and a line is only made wider than width, if it contains no break
+// opportunity.
+func (p *Paragraph) Lines(width funit.Int) []*Line {
+	var lines []*Line
+	start := 0
+	next := 0
+	for start < len(p.Text) {
+		var best *Line
+		for i := next; i < len(p.Breaks); i++ {
+			o := p.Breaks[i]
+			line := p.Line(start, o.Pos)
+			if best != nil && line.Width > width {
+				break
+			}
+			best = line
+			next = i + 1
+			if o.Mandatory || line.Width > width {
+				break
+			}
+		}
+		lines = append(lines, best)
+		start = best.End
+	}
+	return lines
+}
+
+// Line returns the line which consists of the text between the break
+// opportunities start and end.  The value start can also be 0 for the first
+// line.  Only the glyphs close to the line boundaries are shaped again, the
+// remaining glyphs are taken from p.Glyphs.
+func (p *Paragraph) Line(start, end int) *Line {
+	startHyph := p.isHyphen(start)
+	endHyph := p.isHyphen(end)
+
+	line := &Line{
+		Start:      start,
+		End:        end,
+		Hyphenated: endHyph,
+	}
+
+	e := end
+	for e > start {
+		r, size := utf8.DecodeLastRuneInString(p.Text[:e])
+		if !isTrailingSpace(r) && !(endHyph && r == softHyphen) {
+			break
+		}
+		e -= size
+	}
+	if e <= start && !endHyph {
+		return line
+	}
+
+	// The range [a, z) of the text can use the original glyphs.
+	a := start
+	if startHyph || p.glyphAt[start] < 0 {
+		a = p.nextSafe(start, e)
+	}
+	z := e
+	if endHyph || p.glyphAt[e] < 0 {
+		z = p.prevSafe(e, a)
+		if a > start && z <= a {
+			a, z = start, start
+		}
+	}
+
+	var glyphs []glyph.Info
+	if a > start {
+		glyphs = p.reshape(glyphs, start, a, false)
+	}
+	if z > a {
+		glyphs = append(glyphs, p.Glyphs[p.glyphAt[a]:p.glyphAt[z]]...)
+	}
+	if z < e || endHyph {
+		glyphs = p.reshape(glyphs, z, e, endHyph)
+	}
+
+	line.Glyphs = glyphs
+	for _, g := range glyphs {
+		line.Width += funit.Int(g.Advance)
+	}
+	return line
+}
+
+// reshape lays out the text between the byte offsets start and end again,
+// and appends the result to glyphs.
+func (p *Paragraph) reshape(glyphs []glyph.Info, start, end int, hyphen bool) []glyph.Info {
+	text := p.Text[start:end]
+	if hyphen {
+		h := p.b.Hyphen
+		if h == "" {
+			h = "-"
+		}
+		text += h
+	}
+
+	// Features which extend to the end of the line also apply to the hyphen.
+	var features []gtab.FeatureRange
+	for _, f := range p.features {
+		if f.End <= start || f.Start >= end {
+			continue
+		}
+		f.Start = max(f.Start-start, 0)
+		if f.End >= end {
+			f.End = len(text)
+		} else {
+			f.End -= start
+		}
+		features = append(features, f)
+	}
+
+	for _, g := range p.b.shaper.Layout(text, features...) {
+		if g.Cluster >= end-start {
+			g.Text = nil
+		}
+		g.Cluster += start
+		glyphs = append(glyphs, g)
+	}
+	return glyphs
+}
+
+// nextSafe returns the first glyph boundary after pos.
+// If there is no such boundary before limit, limit is returned.
+func (p *Paragraph) nextSafe(pos, limit int) int {
+	for pos++; pos < limit; pos++ {
+		if p.glyphAt[pos] >= 0 {
+			return pos
+		}
+	}
+	return limit
+}
+
+// prevSafe returns the last glyph boundary before pos.
+// If there is no such boundary after limit, limit is returned.
+func (p *Paragraph) prevSafe(pos, limit int) int {
+	for pos--; pos > limit; pos-- {
+		if p.glyphAt[pos] >= 0 {
+			return pos
+		}
+	}
+	return limit
+}
+
+// isHyphen reports whether pos is a break opportunity which requires
+// a hyphen.
+func (p *Paragraph) isHyphen(pos int) bool {
+	i, found := slices.BinarySearchFunc(p.Breaks, pos, func(o Opportunity, pos int) int {
+		return o.Pos - pos
+	})
+	return found && p.Breaks[i].Hyphen
+}
+
+func isTrailingSpace(r rune) bool {
+	switch ClassOf(r) {
+	case SP, BK, CR, LF, NL, ZW:
+		return true
+	}
+	return false
+}
+
+const softHyphen = 0x00AD
@@ -0,0 +1,201 @@
+// seehuhn.de/go/sfnt - a library for reading and writing font files
+// Copyright (C) 2026  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package linebreak
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/text/language"
+
+	"seehuhn.de/go/postscript/funit"
+
+	"seehuhn.de/go/sfnt"
+	"seehuhn.de/go/sfnt/glyph"
+	"seehuhn.de/go/sfnt/opentype/gtab"
+	"seehuhn.de/go/sfnt/parser"
+)
+
+var _ Shaper = (*sfnt.Layouter)(nil)
+
+// testShaper is a monospaced shaper, which uses the character code as the
+// glyph ID.  The sequence "fi" is replaced by a ligature of width 150, and
+// a hyphen after "f" is kerned by -20.
+type testShaper struct {
+	calls []string
+}
+
+func (s *testShaper) Layout(text string, _ ...gtab.FeatureRange) []glyph.Info {
+	s.calls = append(s.calls, text)
+	var res []glyph.Info
+	for pos, r := range text {
+		if r == 'i' && strings.HasSuffix(text[:pos], "f") {
+			last := &res[len(res)-1]
+			last.GID = 0xFB01
+			last.Text = append(last.Text, r)
+			last.Advance = 150
+			continue
+		}
+		if r == '-' && strings.HasSuffix(text[:pos], "f") {
+			res[len(res)-1].Advance -= 20
+		}
+		res = append(res, glyph.Info{
+			GID:     glyph.ID(r),
+			Text:    []rune{r},
+			Advance: 100,
+			Cluster: pos,
+		})
+	}
+	return res
+}
+
+func lineTexts(lines []*Line) []string {
+	var res []string
+	for _, l := range lines {
+		var text []rune
+		for _, g := range l.Glyphs {
+			if g.Text == nil {
+				text = append(text, '|') // inserted hyphen
+			}
+			text = append(text, g.Text...)
+		}
+		res = append(res, string(text))
+	}
+	return res
+}
+
+func TestLines(t *testing.T) {
+	b := NewBreaker(&testShaper{})
+	p := b.Shape("the quick brown fox\njumps")
+
+	lines := p.Lines(1000)
+	want := []string{"the quick", "brown fox", "jumps"}
+	if d := cmp.Diff(want, lineTexts(lines)); d != "" {
+		t.Errorf("(-want +got)\n%s", d)
+	}
+	for _, l := range lines {
+		if l.Width != funit.Int(100*len(l.Glyphs)) {
+			t.Errorf("%d-%d: wrong width %d", l.Start, l.End, l.Width)
+		}
+	}
+	if lines[1].End != 20 {
+		t.Errorf("expected the second line to end after the newline, got %d", lines[1].End)
+	}
+
+	// An overlong word is put on a line of its own.
+	lines = p.Lines(300)
+	want = []string{"the", "quick", "brown", "fox", "jumps"}
+	if d := cmp.Diff(want, lineTexts(lines)); d != "" {
+		t.Errorf("(-want +got)\n%s", d)
+	}
+}
+
+func TestHyphenatedLine(t *testing.T) {
+	h, err := ReadPatterns(strings.NewReader("f1f f1i"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.LeftMin, h.RightMin = 1, 1
+	shaper := &testShaper{}
+	b := NewBreaker(shaper)
+	b.Hyphenator = h
+
+	p := b.Shape("a offix")
+	// glyphs: a, space, o, f, fi ligature, x
+	if len(p.Glyphs) != 6 {
+		t.Fatalf("expected 6 glyphs, got %d", len(p.Glyphs))
+	}
+
+	// A break inside the ligature.  Only the parts of the word next to
+	// the break are shaped again.
+	shaper.calls = nil
+	l1 := p.Line(0, 5)
+	l2 := p.Line(5, 7)
+	if d := cmp.Diff([]string{"a off|", "ix"}, lineTexts([]*Line{l1, l2})); d != "" {
+		t.Errorf("(-want +got)\n%s", d)
+	}
+	if d := cmp.Diff([]string{"f-", "i"}, shaper.calls); d != "" {
+		t.Errorf("reshaped text (-want +got)\n%s", d)
+	}
+	if !l1.Hyphenated || l2.Hyphenated {
+		t.Error("wrong Hyphenated flags")
+	}
+	if l1.Width != 6*100-20 {
+		t.Errorf("wrong width %d", l1.Width)
+	}
+	if l1.Glyphs[5].Cluster != 5 || l2.Glyphs[0].Cluster != 5 || l2.Glyphs[1].Cluster != 6 {
+		t.Error("wrong cluster values")
+	}
+
+	// At a break between glyphs, the first line still gets a hyphen.
+	l1 = p.Line(0, 4)
+	if d := cmp.Diff([]string{"a of|"}, lineTexts([]*Line{l1})); d != "" {
+		t.Errorf("(-want +got)\n%s", d)
+	}
+	l2 = p.Line(4, 7)
+	if d := cmp.Diff([]string{"fix"}, lineTexts([]*Line{l2})); d != "" {
+		t.Errorf("(-want +got)\n%s", d)
+	}
+	if l2.Width != 250 {
+		t.Errorf("wrong width %d", l2.Width)
+	}
+}
+
+func TestSoftHyphen(t *testing.T) {
+	b := NewBreaker(&testShaper{})
+	p := b.Shape("ab\u00ADcd")
+	lines := p.Lines(250)
+	if d := cmp.Diff([]string{"ab|", "cd"}, lineTexts(lines)); d != "" {
+		t.Errorf("(-want +got)\n%s", d)
+	}
+}
+
+func TestLayouterWidths(t *testing.T) {
+	f, err := sfnt.Read(bytes.NewReader(goregular.TTF), parser.NewBudget(int64(len(goregular.TTF))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := f.NewLayouter(language.English, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := NewBreaker(l)
+	p := b.Shape("The quick brown fox jumps over the lazy dog.")
+
+	lines := p.Lines(6000)
+	if len(lines) < 2 {
+		t.Fatalf("expected several lines, got %d", len(lines))
+	}
+	for _, line := range lines {
+		if line.Width > 6000 {
+			t.Errorf("line %d-%d is too wide: %d", line.Start, line.End, line.Width)
+		}
+
+		// The width must agree with a layout of the line on its own.
+		text := strings.TrimRight(p.Text[line.Start:line.End], " ")
+		var want funit.Int
+		for _, g := range l.Layout(text) {
+			want += funit.Int(g.Advance)
+		}
+		if line.Width != want {
+			t.Errorf("%q: width %d, want %d", text, line.Width, want)
+		}
+	}
+}
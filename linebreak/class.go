@@ -0,0 +1,213 @@
+// seehuhn.de/go/sfnt - a library for reading and writing font files
+// Copyright (C) 2026  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package linebreak
+
+import "unicode"
+
+// Class is a line breaking class, as defined in Unicode Standard Annex #14.
+type Class uint8
+
+// These are the line breaking classes used by the algorithm.
+// The classes AI, SG, XX and SA are resolved to AL, and CJ is resolved
+// to NS, as recommended in section 6.1 of UAX #14.  Hebrew letters are
+// treated as AL.
+const (
+	AL  Class = iota // alphabetic
+	BK               // mandatory break
+	CR               // carriage return
+	LF               // line feed
+	NL               // next line
+	SP               // space
+	ZW               // zero width space
+	ZWJ              // zero width joiner
+	WJ               // word joiner
+	GL               // non-breaking ("glue")
+	CM               // combining mark
+	OP               // open punctuation
+	CL               // close punctuation
+	CP               // close parenthesis
+	QU               // quotation
+	EX               // exclamation/interrogation
+	IS               // infix numeric separator
+	SY               // symbols allowing break after
+	NS               // nonstarter
+	BA               // break after
+	BB               // break before
+	HY               // hyphen
+	B2               // break opportunity before and after
+	IN               // inseparable
+	NU               // numeric
+	PR               // prefix numeric
+	PO               // postfix numeric
+	ID               // ideographic
+	CB               // contingent break opportunity
+	RI               // regional indicator
+	H2               // Hangul LV syllable
+	H3               // Hangul LVT syllable
+	JL               // Hangul L jamo
+	JV               // Hangul V jamo
+	JT               // Hangul T jamo
+)
+
+// ClassOf returns the line breaking class of r.
+//
+// The classes are derived from a table of the characters which are most
+// relevant for line breaking, together with the general category of r.
+// Characters of the complex context scripts (class SA), like Thai, are
+// treated as alphabetic, so that no breaks are found inside runs of these
+// characters.
+func ClassOf(r rune) Class {
+	if c, ok := explicitClass[r]; ok {
+		return c
+	}
+	for _, rc := range rangeClass {
+		if r >= rc.first && r <= rc.last {
+			return rc.class
+		}
+	}
+
+	switch {
+	case r >= 0xAC00 && r <= 0xD7A3:
+		if (r-0xAC00)%28 == 0 {
+			return H2
+		}
+		return H3
+	case unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc, unicode.Cc):
+		return CM
+	case unicode.Is(unicode.Ps, r):
+		return OP
+	case unicode.Is(unicode.Pe, r):
+		return CL
+	case unicode.In(r, unicode.Pi, unicode.Pf):
+		return QU
+	case unicode.Is(unicode.Nd, r):
+		if r >= 0xFF10 && r <= 0xFF19 {
+			return ID
+		}
+		return NU
+	case unicode.Is(unicode.Sc, r):
+		return PR
+	case isIdeographic(r):
+		return ID
+	}
+	return AL
+}
+
+func isIdeographic(r rune) bool {
+	switch {
+	case r >= 0x2E80 && r <= 0x2FFF, // CJK radicals, Kangxi, IDC
+		r >= 0x3000 && r <= 0x30FF, // CJK symbols, kana
+		r >= 0x3100 && r <= 0x31FF, // Bopomofo, Hangul compatibility jamo
+		r >= 0x3200 && r <= 0x4DBF, // enclosed CJK, CJK extension A
+		r >= 0x4E00 && r <= 0x9FFF, // CJK unified ideographs
+		r >= 0xA000 && r <= 0xA4CF, // Yi
+		r >= 0xF900 && r <= 0xFAFF, // CJK compatibility ideographs
+		r >= 0xFE30 && r <= 0xFE4F, // CJK compatibility forms
+		r >= 0xFF00 && r <= 0xFF60, // fullwidth forms
+		r >= 0xFFE0 && r <= 0xFFE6,
+		r >= 0x1F000 && r <= 0x1FAFF, // pictographs and emoji
+		r >= 0x20000 && r <= 0x3FFFD: // CJK extensions B and later
+		return true
+	}
+	return false
+}
+
+type classRange struct {
+	first, last rune
+	class       Class
+}
+
+var rangeClass = []classRange{
+	{0x1100, 0x115F, JL},
+	{0xA960, 0xA97C, JL},
+	{0x1160, 0x11A7, JV},
+	{0xD7B0, 0xD7C6, JV},
+	{0x11A8, 0x11FF, JT},
+	{0xD7CB, 0xD7FB, JT},
+	{0x2000, 0x2006, BA},
+	{0x2008, 0x200A, BA},
+	{0x2E0E, 0x2E15, BA},
+	{0x31F0, 0x31FF, NS},
+	{0xFF67, 0xFF70, NS},
+	{0x1F1E6, 0x1F1FF, RI},
+}
+
+var explicitClass = map[rune]Class{
+	0x0009: BA, 0x000A: LF, 0x000B: BK, 0x000C: BK, 0x000D: CR,
+	0x0085: NL, 0x2028: BK, 0x2029: BK,
+
+	0x0020: SP, 0x200B: ZW, 0x200D: ZWJ, 0x2060: WJ, 0xFEFF: WJ,
+
+	0x00A0: GL, 0x034F: GL, 0x2007: GL, 0x2011: GL, 0x202F: GL,
+	0x180E: GL, 0x0F08: GL, 0x0F0C: GL, 0x0F12: GL,
+
+	'(': OP, '[': OP, '{': OP, 0x00A1: OP, 0x00BF: OP,
+	')': CP, ']': CP, '}': CL,
+	0x3001: CL, 0x3002: CL, 0xFE50: CL, 0xFE52: CL, 0xFF0C: CL, 0xFF0E: CL,
+	0xFF61: CL, 0xFF64: CL,
+
+	'"': QU, '\'': QU, 0x201B: QU, 0x201F: QU, 0x275B: QU, 0x275C: QU,
+	0x275D: QU, 0x275E: QU, 0x2E00: QU, 0x2E01: QU,
+
+	'!': EX, '?': EX, 0x05C6: EX, 0x061B: EX, 0x061E: EX, 0x061F: EX,
+	0x06D4: EX, 0x07F9: EX, 0x0F0D: EX, 0xFE15: EX, 0xFE16: EX,
+	0xFF01: EX, 0xFF1F: EX,
+
+	',': IS, '.': IS, ':': IS, ';': IS, 0x037E: IS, 0x0589: IS,
+	0x060C: IS, 0x060D: IS, 0x07F8: IS, 0x2044: IS, 0xFE10: IS,
+	0xFE13: IS, 0xFE14: IS,
+
+	'/': SY,
+
+	0x17D6: NS, 0x203C: NS, 0x203D: NS, 0x2047: NS, 0x2048: NS,
+	0x2049: NS, 0x3005: NS, 0x301C: NS, 0x303B: NS, 0x303C: NS,
+	0x309B: NS, 0x309C: NS, 0x309D: NS, 0x309E: NS, 0x30A0: NS,
+	0x30FB: NS, 0x30FC: NS, 0x30FD: NS, 0x30FE: NS, 0xA015: NS,
+	0xFE54: NS, 0xFE55: NS, 0xFF1A: NS, 0xFF1B: NS, 0xFF65: NS,
+	0xFF9E: NS, 0xFF9F: NS,
+	// small kana (class CJ)
+	0x3041: NS, 0x3043: NS, 0x3045: NS, 0x3047: NS, 0x3049: NS,
+	0x3063: NS, 0x3083: NS, 0x3085: NS, 0x3087: NS, 0x308E: NS,
+	0x3095: NS, 0x3096: NS, 0x30A1: NS, 0x30A3: NS, 0x30A5: NS,
+	0x30A7: NS, 0x30A9: NS, 0x30C3: NS, 0x30E3: NS, 0x30E5: NS,
+	0x30E7: NS, 0x30EE: NS, 0x30F5: NS, 0x30F6: NS,
+
+	'|': BA, 0x00AD: BA, 0x058A: BA, 0x05BE: BA, 0x0964: BA,
+	0x0965: BA, 0x0F0B: BA, 0x1361: BA, 0x1680: BA, 0x17D8: BA,
+	0x17DA: BA, 0x2010: BA, 0x2012: BA, 0x2013: BA, 0x2027: BA,
+	0x205F: BA, 0x2E17: BA, 0x3000: BA,
+
+	0x00B4: BB, 0x02C8: BB, 0x02CC: BB, 0x02DF: BB, 0x0F01: BB,
+	0x0F02: BB, 0x0F03: BB, 0x0F04: BB, 0x0F06: BB, 0x0F07: BB,
+	0x0F09: BB, 0x0F0A: BB, 0x0FD0: BB, 0x0FD1: BB, 0x0FD3: BB,
+	0x1806: BB, 0x1FFD: BB, 0xA874: BB, 0xA875: BB,
+
+	'-': HY,
+
+	0x2014: B2, 0x2E3A: B2, 0x2E3B: B2,
+
+	0x2024: IN, 0x2025: IN, 0x2026: IN, 0x22EF: IN, 0xFE19: IN,
+
+	'+': PR, '\\': PR, 0x00B1: PR, 0x2116: PR, 0x2212: PR, 0x2213: PR,
+
+	'%': PO, 0x00A2: PO, 0x00B0: PO, 0x060B: PO, 0x066A: PO,
+	0x2030: PO, 0x2031: PO, 0x2032: PO, 0x2033: PO, 0x2034: PO,
+	0x2035: PO, 0x2036: PO, 0x2037: PO, 0x20A7: PO, 0x2103: PO,
+	0x2109: PO, 0xFE6A: PO, 0xFF05: PO, 0xFFE0: PO,
+
+	0xFFFC: CB,
+}
@@ -0,0 +1,28 @@
+// seehuhn.de/go/sfnt - a library for reading and writing font files
+// Copyright (C) 2026  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package linebreak splits shaped text into lines.
+//
+// There are three main entry points:
+//
+//   - [FindBreaks] finds the line break opportunities in a text, using the
+//     rules from Unicode Standard Annex #14.
+//   - The [Hyphenator] type finds hyphenation points in words, using TeX
+//     hyphenation patterns.
+//   - The [Breaker] type lays out a paragraph using a [Shaper], for example
+//     an [seehuhn.de/go/sfnt.Layouter], and splits the glyph sequence into
+//     lines of a given width.
+package linebreak
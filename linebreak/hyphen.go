@@ -0,0 +1,225 @@
+// seehuhn.de/go/sfnt - a library for reading and writing font files
+// Copyright (C) 2026  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package linebreak
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Hyphenator finds hyphenation points in words, using Liang's algorithm
+// as implemented in TeX.
+type Hyphenator struct {
+	// LeftMin and RightMin give the minimal number of characters before
+	// and after a hyphenation point.
+	LeftMin, RightMin int
+
+	patterns   map[string][]uint8
+	exceptions map[string][]int
+	maxLen     int
+}
+
+// LoadPatterns reads hyphenation patterns from the named file.
+// See [ReadPatterns] for a description of the file format.
+func LoadPatterns(fname string) (*Hyphenator, error) {
+	fd, err := os.Open(fname)
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+
+	h, err := ReadPatterns(fd)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fname, err)
+	}
+	return h, nil
+}
+
+// ReadPatterns reads hyphenation patterns in TeX format.
+//
+// The input can either be a TeX file, as distributed with the hyph-utf8
+// package, where the patterns are given inside \patterns{...} and
+// exceptions inside \hyphenation{...}, or a plain list of patterns.
+// Comments start with "%" and extend to the end of the line.
+// The input must be UTF-8 encoded.
+func ReadPatterns(r io.Reader) (*Hyphenator, error) {
+	h := &Hyphenator{
+		LeftMin:    2,
+		RightMin:   3,
+		patterns:   make(map[string][]uint8),
+		exceptions: make(map[string][]int),
+	}
+
+	isExceptions := false
+	lineNo := 0
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		lineNo++
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '%'); i >= 0 {
+			line = line[:i]
+		}
+		if !utf8.ValidString(line) {
+			return nil, fmt.Errorf("line %d: invalid UTF-8", lineNo)
+		}
+
+		for _, word := range strings.Fields(line) {
+			for word != "" {
+				switch {
+				case strings.HasPrefix(word, `\patterns{`):
+					isExceptions = false
+					word = word[len(`\patterns{`):]
+					continue
+				case strings.HasPrefix(word, `\hyphenation{`):
+					isExceptions = true
+					word = word[len(`\hyphenation{`):]
+					continue
+				case strings.HasPrefix(word, `\`):
+					// ignore other TeX commands, like \message
+					word = ""
+					continue
+				}
+
+				item := strings.TrimSuffix(word, "}")
+				word = ""
+				if item == "" {
+					continue
+				}
+				var err error
+				if isExceptions {
+					h.addException(item)
+				} else {
+					err = h.addPattern(item)
+				}
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %w", lineNo, err)
+				}
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(h.patterns) == 0 && len(h.exceptions) == 0 {
+		return nil, errNoPatterns
+	}
+	return h, nil
+}
+
+// addPattern adds a Liang pattern like ".ach4" or "1ba" to the hyphenator.
+func (h *Hyphenator) addPattern(pat string) error {
+	var letters []rune
+	var values []uint8
+	values = append(values, 0)
+	for _, r := range pat {
+		if r >= '0' && r <= '9' {
+			values[len(values)-1] = uint8(r - '0')
+			continue
+		}
+		if !unicode.IsLetter(r) && r != '.' && r != '\'' && r != 0x2019 {
+			return fmt.Errorf("invalid hyphenation pattern %q", pat)
+		}
+		letters = append(letters, unicode.ToLower(r))
+		values = append(values, 0)
+	}
+	if len(letters) == 0 {
+		return fmt.Errorf("invalid hyphenation pattern %q", pat)
+	}
+	h.patterns[string(letters)] = values
+	h.maxLen = max(h.maxLen, len(letters))
+	return nil
+}
+
+// addException adds a hyphenated word like "as-so-ciate" to the list
+// of exceptions.
+func (h *Hyphenator) addException(word string) {
+	var letters []rune
+	var points []int
+	for _, r := range word {
+		if r == '-' {
+			points = append(points, len(letters))
+			continue
+		}
+		letters = append(letters, unicode.ToLower(r))
+	}
+	h.exceptions[string(letters)] = points
+}
+
+// Hyphenate returns the positions where the given word can be hyphenated,
+// as byte offsets into word.  The word should consist of letters only;
+// punctuation must be removed by the caller.
+func (h *Hyphenator) Hyphenate(word string) []int {
+	rr := []rune(word)
+	n := len(rr)
+	if n < h.LeftMin+h.RightMin {
+		return nil
+	}
+
+	lower := make([]rune, 0, n+2)
+	lower = append(lower, '.')
+	for _, r := range rr {
+		lower = append(lower, unicode.ToLower(r))
+	}
+	lower = append(lower, '.')
+
+	// points[i] refers to the position before rr[i]
+	var points []int
+	if exc, ok := h.exceptions[string(lower[1:n+1])]; ok {
+		points = exc
+	} else {
+		values := make([]uint8, n+3)
+		for start := range lower {
+			for end := start + 1; end <= len(lower) && end-start <= h.maxLen; end++ {
+				pat, ok := h.patterns[string(lower[start:end])]
+				if !ok {
+					continue
+				}
+				for k, v := range pat {
+					values[start+k] = max(values[start+k], v)
+				}
+			}
+		}
+		// values[i+1] refers to the position before rr[i]
+		for i := 1; i < n; i++ {
+			if values[i+1]%2 == 1 {
+				points = append(points, i)
+			}
+		}
+	}
+
+	var res []int
+	offs := 0
+	k := 0
+	for i, r := range rr {
+		for k < len(points) && points[k] < i {
+			k++
+		}
+		if k < len(points) && points[k] == i && i >= h.LeftMin && n-i >= h.RightMin {
+			res = append(res, offs)
+		}
+		offs += utf8.RuneLen(r)
+	}
+	return res
+}
+
+var errNoPatterns = errors.New("no hyphenation patterns found")
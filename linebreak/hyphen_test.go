@@ -0,0 +1,72 @@
+// seehuhn.de/go/sfnt - a library for reading and writing font files
+// Copyright (C) 2026  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package linebreak
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestHyphenate(t *testing.T) {
+	h, err := LoadPatterns("testdata/hyph-test.tex")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		word string
+		want []int
+	}{
+		{"hyphenation", []int{2, 6}},
+		{"Hyphenation", []int{2, 6}},
+		{"table", []int{2}},
+		{"hen", nil},
+	}
+	for _, c := range cases {
+		got := h.Hyphenate(c.word)
+		if d := cmp.Diff(c.want, got); d != "" {
+			t.Errorf("%q: (-want +got)\n%s", c.word, d)
+		}
+	}
+
+	h.LeftMin = 3
+	if d := cmp.Diff([]int{6}, h.Hyphenate("hyphenation")); d != "" {
+		t.Errorf("LeftMin=3: (-want +got)\n%s", d)
+	}
+}
+
+func TestHyphenateUTF8(t *testing.T) {
+	h, err := ReadPatterns(strings.NewReader("\u00E41b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.LeftMin, h.RightMin = 1, 1
+	// The offset is in bytes, and the letter before the break takes two.
+	if d := cmp.Diff([]int{3}, h.Hyphenate("a\u00E4b")); d != "" {
+		t.Errorf("(-want +got)\n%s", d)
+	}
+}
+
+func TestReadPatternsErrors(t *testing.T) {
+	for _, in := range []string{"", "% only a comment", "a1-b"} {
+		if _, err := ReadPatterns(strings.NewReader(in)); err == nil {
+			t.Errorf("%q: expected an error", in)
+		}
+	}
+}
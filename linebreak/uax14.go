@@ -0,0 +1,240 @@
+// seehuhn.de/go/sfnt - a library for reading and writing font files
+// Copyright (C) 2026  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package linebreak
+
+import "unicode/utf8"
+
+// Opportunity describes a position in a text where a line may be broken.
+type Opportunity struct {
+	// Pos is the byte offset in the text of the first character
+	// after the break.
+	Pos int
+
+	// Mandatory is set for forced breaks, for example after a newline
+	// character and at the end of the text.
+	Mandatory bool
+
+	// Hyphen is set for breaks inside a word, which were found using
+	// hyphenation patterns.  A hyphen must be shown at the end of the line.
+	Hyphen bool
+}
+
+// FindBreaks returns the line break opportunities in text, using the rules
+// from Unicode Standard Annex #14.  The results are sorted by position.
+// A break before the first character is never allowed, and the last
+// element of the result is always a mandatory break at the end of the text.
+//
+// See https://www.unicode.org/reports/tr14/
+func FindBreaks(text string) []Opportunity {
+	if text == "" {
+		return nil
+	}
+
+	var res []Opportunity
+
+	r, size := utf8.DecodeRuneInString(text)
+	cur := ClassOf(r)
+	if cur == CM || cur == ZWJ {
+		cur = AL // LB10
+	}
+	prev := cur     // the class before the current position, after LB9
+	prevRaw := cur  // the class of the character before the current position
+	beforeSP := cur // the last class before a sequence of spaces
+	numRI := 0      // the number of consecutive regional indicators
+	if cur == RI {
+		numRI = 1
+	}
+	inNumber := cur == NU // inside a number, for rule LB25
+
+	for pos := size; pos < len(text); pos += size {
+		r, size = utf8.DecodeRuneInString(text[pos:])
+		cur = ClassOf(r)
+
+		brk, mandatory := decideBreak(prev, prevRaw, beforeSP, cur, numRI, inNumber)
+		if brk {
+			res = append(res, Opportunity{Pos: pos, Mandatory: mandatory})
+		}
+
+		prevRaw = cur
+		if (cur == CM || cur == ZWJ) && !brk && !isBreakOrSpace(prev) {
+			// LB9: treat X (CM|ZWJ)* as X
+			continue
+		}
+		if cur == CM || cur == ZWJ {
+			cur = AL // LB10
+		}
+
+		if cur != SP {
+			beforeSP = cur
+		}
+		if cur == RI {
+			numRI++
+		} else {
+			numRI = 0
+		}
+		switch cur {
+		case NU:
+			inNumber = true
+		case SY, IS:
+			// keep the current state
+		case CL, CP:
+			// A closing bracket can end a number, e.g. "(12)%".
+		default:
+			inNumber = false
+		}
+		prev = cur
+	}
+	res = append(res, Opportunity{Pos: len(text), Mandatory: true})
+	return res
+}
+
+func isBreakOrSpace(c Class) bool {
+	switch c {
+	case BK, CR, LF, NL, SP, ZW:
+		return true
+	}
+	return false
+}
+
+// decideBreak reports whether a line break is allowed between a character
+// of class a and a following character of class b.  The second return value
+// indicates whether the break is mandatory.
+//
+// The argument raw is the class of the character immediately before b,
+// before rule LB9 is applied.  The argument sp is the class of the last
+// character before a sequence of spaces, if a is SP, and equal to a
+// otherwise.
+func decideBreak(a, raw, sp, b Class, numRI int, inNumber bool) (brk, mandatory bool) {
+	// LB4 and LB5: always break after hard line breaks
+	switch {
+	case a == BK:
+		return true, true
+	case a == CR && b == LF:
+		return false, false
+	case a == CR || a == LF || a == NL:
+		return true, true
+	}
+
+	// LB6: do not break before hard line breaks
+	// LB7: do not break before spaces or zero width space
+	switch b {
+	case BK, CR, LF, NL, SP, ZW:
+		return false, false
+	}
+
+	// LB8: break before any character following a zero-width space,
+	// even if one or more spaces intervene
+	if a == ZW || a == SP && sp == ZW {
+		return true, false
+	}
+
+	// LB8a: do not break after a zero width joiner
+	if raw == ZWJ {
+		return false, false
+	}
+
+	// LB9: do not break a combining character sequence
+	if (b == CM || b == ZWJ) && a != SP {
+		return false, false
+	}
+	// LB10: treat any remaining combining mark as AL
+	if b == CM || b == ZWJ {
+		b = AL
+	}
+
+	switch {
+	case a == WJ || b == WJ: // LB11
+		return false, false
+	case a == GL: // LB12
+		return false, false
+	case b == GL && a != SP && a != BA && a != HY: // LB12a
+		return false, false
+	case b == CL || b == CP || b == EX || b == IS || b == SY: // LB13
+		return false, false
+	case sp == OP: // LB14
+		return false, false
+	case sp == QU && b == OP: // LB15
+		return false, false
+	case (sp == CL || sp == CP) && b == NS: // LB16
+		return false, false
+	case sp == B2 && b == B2: // LB17
+		return false, false
+	case a == SP: // LB18
+		return true, false
+	case a == QU || b == QU: // LB19
+		return false, false
+	case a == CB || b == CB: // LB20
+		return true, false
+	case b == BA || b == HY || b == NS || a == BB: // LB21
+		return false, false
+	case b == IN: // LB22
+		return false, false
+	case a == AL && b == NU || a == NU && b == AL: // LB23
+		return false, false
+	case a == PR && b == ID || a == ID && b == PO: // LB23a
+		return false, false
+	case (a == PR || a == PO) && b == AL || a == AL && (b == PR || b == PO): // LB24
+		return false, false
+	case noBreakInNumber(a, b, inNumber): // LB25
+		return false, false
+	case a == JL && (b == JL || b == JV || b == H2 || b == H3): // LB26
+		return false, false
+	case (a == JV || a == H2) && (b == JV || b == JT):
+		return false, false
+	case (a == JT || a == H3) && b == JT:
+		return false, false
+	case isKorean(a) && b == PO || a == PR && isKorean(b): // LB27
+		return false, false
+	case a == AL && b == AL: // LB28
+		return false, false
+	case a == IS && b == AL: // LB29
+		return false, false
+	case (a == AL || a == NU) && b == OP || a == CP && (b == AL || b == NU): // LB30
+		return false, false
+	case a == RI && b == RI && numRI%2 == 1: // LB30a
+		return false, false
+	}
+
+	// LB31: break everywhere else
+	return true, false
+}
+
+// noBreakInNumber implements rule LB25, which keeps numbers like "$(12.35)"
+// or "-5%" together.
+func noBreakInNumber(a, b Class, inNumber bool) bool {
+	switch {
+	case (a == PR || a == PO) && (b == OP || b == HY || b == NU):
+		return true
+	case (a == OP || a == HY) && b == NU:
+		return true
+	case a == NU && (b == NU || b == SY || b == IS):
+		return true
+	case inNumber && (a == SY || a == IS) && b == NU:
+		return true
+	case inNumber && (b == PO || b == PR):
+		return true
+	}
+	return false
+}
+
+func isKorean(c Class) bool {
+	switch c {
+	case JL, JV, JT, H2, H3:
+		return true
+	}
+	return false
+}
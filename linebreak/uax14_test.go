@@ -0,0 +1,60 @@
+// seehuhn.de/go/sfnt - a library for reading and writing font files
+// Copyright (C) 2026  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package linebreak
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFindBreaks(t *testing.T) {
+	cases := []struct {
+		in   string
+		want []int // negative values indicate mandatory breaks
+	}{
+		{"hello world", []int{6, -11}},
+		{"a  b", []int{3, -4}},
+		{"well-known", []int{5, -10}},
+		{"(a) b", []int{4, -5}},
+		{"say \"hi\" now", []int{4, 9, -12}},
+		{"a\nb", []int{-2, -3}},
+		{"a\r\nb", []int{-3, -4}},
+		{"$12.50 or -3%", []int{7, 10, -13}},
+		{"a\u00A0b c", []int{5, -6}},
+		{"x\u200By", []int{4, -5}},
+		{"e\u0301 x", []int{4, -5}},
+		{"\u65E5\u672C\u8A9E", []int{3, 6, -9}},
+		{"\u65E5\u3002\u672C", []int{6, -9}},
+		{"\uD55C\uAD6D \uB9D0", []int{3, 7, -10}},
+		{"a\u2014b", []int{1, 4, -5}},
+		{"", nil},
+	}
+	for _, c := range cases {
+		var got []int
+		for _, o := range FindBreaks(c.in) {
+			if o.Mandatory {
+				got = append(got, -o.Pos)
+			} else {
+				got = append(got, o.Pos)
+			}
+		}
+		if d := cmp.Diff(c.want, got); d != "" {
+			t.Errorf("%q: (-want +got)\n%s", c.in, d)
+		}
+	}
+}
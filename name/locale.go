@@ -348,3 +348,29 @@ var msBCP = map[uint16]string{
 	0x0478: "ii-CN",       // Yi, PRC
 	0x046a: "yo-NG",       // Yoruba, Nigeria
 }
+
+// MacLanguageID returns the Macintosh language ID which corresponds to a key
+// of [Info.Mac].  If several IDs map to the same key, the smallest one is
+// returned.
+func MacLanguageID(key string) (uint16, bool) {
+	return languageID(appleBCP, key)
+}
+
+// WindowsLanguageID returns the Windows language ID which corresponds to a
+// key of [Info.Windows].  If several IDs map to the same key, the smallest
+// one is returned.
+func WindowsLanguageID(key string) (uint16, bool) {
+	return languageID(msBCP, key)
+}
+
+func languageID(m map[uint16]string, key string) (uint16, bool) {
+	var res uint16
+	found := false
+	for id, tag := range m {
+		if tag == key && (!found || id < res) {
+			res = id
+			found = true
+		}
+	}
+	return res, found
+}
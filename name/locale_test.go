@@ -34,3 +34,20 @@ func TestLanguageTags(t *testing.T) {
 		}
 	}
 }
+
+func TestLanguageID(t *testing.T) {
+	for _, list := range []map[uint16]string{appleBCP, msBCP} {
+		for id, key := range list {
+			got, ok := languageID(list, key)
+			if !ok || list[got] != key || got > id {
+				t.Errorf("%s: got %d, %t", key, got, ok)
+			}
+		}
+	}
+	if id, ok := WindowsLanguageID("en-US"); !ok || id != 0x0409 {
+		t.Errorf("en-US: got 0x%04x, %t", id, ok)
+	}
+	if _, ok := MacLanguageID("xx-invalid"); ok {
+		t.Error("invalid key was accepted")
+	}
+}
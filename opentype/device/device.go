@@ -60,6 +60,21 @@ func (t *Table) IsVariationIndex() bool {
 	return t.DeltaFormat == VariationIndexFormat
 }
 
+// Delta returns the adjustment, in pixels, which the table specifies for
+// the given size in pixels per em.  The result is zero for VariationIndex
+// tables, for a nil table, and for sizes outside the range covered by the
+// table.
+func (t *Table) Delta(ppem uint16) int {
+	if t == nil || t.IsVariationIndex() || ppem < t.StartSize || ppem > t.EndSize {
+		return 0
+	}
+	i := int(ppem - t.StartSize)
+	if i >= len(t.Deltas) {
+		return 0
+	}
+	return int(t.Deltas[i])
+}
+
 // Read reads a Device or VariationIndex table starting at pos.
 func Read(p *parser.Parser, pos int64) (*Table, error) {
 	err := p.SeekPos(pos)
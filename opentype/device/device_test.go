@@ -244,3 +244,21 @@ func FuzzRead(f *testing.F) {
 		}
 	})
 }
+
+func TestDelta(t *testing.T) {
+	tab := &Table{StartSize: 12, EndSize: 14, Deltas: []int8{1, -1, 3}, DeltaFormat: 2}
+	for ppem, want := range map[uint16]int{11: 0, 12: 1, 13: -1, 14: 3, 15: 0} {
+		if got := tab.Delta(ppem); got != want {
+			t.Errorf("Delta(%d) = %d, want %d", ppem, got, want)
+		}
+	}
+
+	varIdx := &Table{OuterIndex: 1, InnerIndex: 2, DeltaFormat: VariationIndexFormat}
+	if got := varIdx.Delta(12); got != 0 {
+		t.Errorf("VariationIndex: Delta = %d, want 0", got)
+	}
+	var null *Table
+	if got := null.Delta(12); got != 0 {
+		t.Errorf("nil table: Delta = %d, want 0", got)
+	}
+}
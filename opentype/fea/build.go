@@ -0,0 +1,869 @@
+// seehuhn.de/go/sfnt - a library for reading and writing font files
+// Copyright (C) 2026  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package fea
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+
+	"seehuhn.de/go/sfnt/glyph"
+	"seehuhn.de/go/sfnt/opentype/anchor"
+	"seehuhn.de/go/sfnt/opentype/classdef"
+	"seehuhn.de/go/sfnt/opentype/coverage"
+	"seehuhn.de/go/sfnt/opentype/gdef"
+	"seehuhn.de/go/sfnt/opentype/gtab"
+	"seehuhn.de/go/sfnt/opentype/markarray"
+)
+
+// finish converts the collected lookups and features into layout tables.
+func (c *compiler) finish() *Tables {
+	c.buildAalt()
+
+	lookupLists := make(map[gtab.Type]gtab.LookupList)
+	tables := make(map[*lookup]*gtab.LookupTable)
+	for _, l := range c.lookups {
+		if l.table == 0 {
+			continue
+		}
+		lt := &gtab.LookupTable{
+			Meta: &gtab.LookupMetaInfo{
+				LookupType:       l.typ,
+				LookupFlags:      l.flags,
+				MarkFilteringSet: l.filterSet,
+			},
+		}
+		l.index = gtab.LookupIndex(len(lookupLists[l.table]))
+		lookupLists[l.table] = append(lookupLists[l.table], lt)
+		tables[l] = lt
+	}
+	for _, l := range c.lookups {
+		if l.table == 0 {
+			continue
+		}
+		lt := tables[l]
+		for _, group := range l.subtables {
+			if len(group) > 0 {
+				lt.Subtables = append(lt.Subtables, c.buildSubtables(l, group)...)
+			}
+		}
+	}
+
+	return &Tables{
+		Gsub:  c.makeInfo(gtab.TypeGsub, lookupLists[gtab.TypeGsub]),
+		Gpos:  c.makeInfo(gtab.TypeGpos, lookupLists[gtab.TypeGpos]),
+		Gdef:  c.makeGdef(),
+		Names: c.names,
+	}
+}
+
+// makeInfo assembles the script list and feature list for one of the
+// layout tables.
+func (c *compiler) makeInfo(table gtab.Type, lookups gtab.LookupList) *gtab.Info {
+	info := &gtab.Info{
+		ScriptList: make(gtab.ScriptListInfo),
+		LookupList: lookups,
+	}
+
+	tags := make([]string, 0, len(c.features))
+	for tag := range c.features {
+		tags = append(tags, tag)
+	}
+	slices.Sort(tags)
+
+	langFeatures := make(map[langSys]*gtab.Features)
+	for _, tag := range tags {
+		f := c.features[tag]
+
+		var params gtab.FeatureParams
+		if f.params != nil && paramsTable(tag) == table {
+			params = f.params
+		}
+		langs := f.langs
+		if params != nil {
+			for _, ls := range f.declLangs {
+				if !slices.Contains(langs, ls) {
+					langs = append(slices.Clip(langs), ls)
+				}
+			}
+		}
+
+		// A required feature without lookups, for example after "language
+		// DEU exclude_dflt required;", is kept if the feature belongs to
+		// this table.
+		inTable := params != nil
+		for _, ll := range f.lookups {
+			inTable = inTable || slices.ContainsFunc(ll, func(l *lookup) bool { return l.table == table })
+		}
+
+		featureIndex := make(map[string]gtab.FeatureIndex)
+		for _, ls := range langs {
+			var ll []gtab.LookupIndex
+			for _, l := range f.lookups[ls] {
+				if l.table == table {
+					ll = append(ll, l.index)
+				}
+			}
+			if len(ll) == 0 && params == nil && !(f.required[ls] && inTable) {
+				continue
+			}
+			slices.Sort(ll)
+			ll = slices.Compact(ll)
+
+			key := fmt.Sprint(ll)
+			fi, ok := featureIndex[key]
+			if !ok {
+				fi = gtab.FeatureIndex(len(info.FeatureList))
+				info.FeatureList = append(info.FeatureList, &gtab.Feature{
+					Tag:     tag,
+					Lookups: ll,
+					Params:  params,
+				})
+				featureIndex[key] = fi
+			}
+
+			features := langFeatures[ls]
+			if features == nil {
+				features = &gtab.Features{Required: 0xFFFF}
+				langFeatures[ls] = features
+			}
+			if f.required[ls] {
+				features.Required = fi
+			} else {
+				features.Optional = append(features.Optional, fi)
+			}
+		}
+	}
+	for ls, features := range langFeatures {
+		info.ScriptList[c.langTags[ls]] = features
+	}
+
+	if len(info.LookupList) == 0 && len(info.FeatureList) == 0 {
+		return nil
+	}
+	return info
+}
+
+// paramsTable returns the table which holds the feature parameters for
+// the given feature.
+func paramsTable(tag string) gtab.Type {
+	if tag == "size" {
+		return gtab.TypeGpos
+	}
+	return gtab.TypeGsub
+}
+
+// buildAalt replaces the lookups of the aalt feature by lookups which
+// combine the single and alternate substitutions of the features referenced
+// in the aalt feature block.
+func (c *compiler) buildAalt() {
+	f := c.features["aalt"]
+	if f == nil {
+		return
+	}
+
+	alts := make(map[glyph.ID][]glyph.ID)
+	var order []glyph.ID
+	collect := func(l *lookup) {
+		if l.table != gtab.TypeGsub || l.typ != 1 && l.typ != 3 {
+			return
+		}
+		for _, group := range l.subtables {
+			for _, r := range group {
+				r := r.(*mapRule)
+				for i, from := range r.from {
+					aa, seen := alts[from]
+					if !seen {
+						order = append(order, from)
+					}
+					for _, to := range r.to[i] {
+						if to != from && !slices.Contains(aa, to) {
+							aa = append(aa, to)
+						}
+					}
+					alts[from] = aa
+				}
+			}
+		}
+	}
+	uses := func(f *feature, l *lookup) bool {
+		for _, ll := range f.lookups {
+			if slices.Contains(ll, l) {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, l := range c.lookups {
+		if uses(f, l) {
+			collect(l)
+			if l.name == "" {
+				l.table = 0
+			}
+		}
+	}
+	for _, ref := range f.aaltRefs {
+		rf := c.features[ref.val]
+		if rf == nil {
+			c.fail(ref, "undefined feature %q", ref.val)
+		}
+		for _, l := range c.lookups {
+			if uses(rf, l) {
+				collect(l)
+			}
+		}
+	}
+
+	single := &mapRule{}
+	alternate := &mapRule{}
+	for _, from := range order {
+		switch aa := alts[from]; len(aa) {
+		case 0:
+			// nothing to do
+		case 1:
+			single.from = append(single.from, from)
+			single.to = append(single.to, aa)
+		default:
+			alternate.from = append(alternate.from, from)
+			alternate.to = append(alternate.to, aa)
+		}
+	}
+	var aaltLookups []*lookup
+	if single.from != nil {
+		aaltLookups = append(aaltLookups, &lookup{
+			table:     gtab.TypeGsub,
+			typ:       1,
+			subtables: [][]rule{{single}},
+		})
+	}
+	if alternate.from != nil {
+		aaltLookups = append(aaltLookups, &lookup{
+			table:     gtab.TypeGsub,
+			typ:       3,
+			subtables: [][]rule{{alternate}},
+		})
+	}
+	c.lookups = append(aaltLookups, c.lookups...)
+
+	f.lookups = make(map[langSys][]*lookup)
+	langs := f.langs
+	if len(langs) == 0 {
+		langs = f.declLangs
+	}
+	f.langs = slices.Clone(langs)
+	for _, ls := range langs {
+		f.lookups[ls] = aaltLookups
+	}
+}
+
+// buildSubtables converts a group of rules into lookup subtables.
+func (c *compiler) buildSubtables(l *lookup, group []rule) []gtab.Subtable {
+	switch {
+	case l.table == gtab.TypeGsub && l.typ == 1:
+		return c.buildSingleSub(group)
+	case l.table == gtab.TypeGsub && l.typ == 2:
+		cov, to := c.buildMap(group)
+		return []gtab.Subtable{&gtab.Gsub2_1{Cov: cov, Repl: to}}
+	case l.table == gtab.TypeGsub && l.typ == 3:
+		cov, to := c.buildMap(group)
+		return []gtab.Subtable{&gtab.Gsub3_1{Cov: cov, Alternates: to}}
+	case l.table == gtab.TypeGsub && l.typ == 4:
+		return c.buildLigatureSub(group)
+	case l.table == gtab.TypeGsub && l.typ == 6, l.table == gtab.TypeGpos && l.typ == 8:
+		return c.buildChain(l, group)
+	case l.table == gtab.TypeGsub && l.typ == 8:
+		return c.buildReverseSub(group)
+	case l.table == gtab.TypeGpos && l.typ == 1:
+		return c.buildSinglePos(group)
+	case l.table == gtab.TypeGpos && l.typ == 2:
+		return c.buildPairPos(group)
+	case l.table == gtab.TypeGpos && l.typ == 3:
+		return c.buildCursivePos(group)
+	case l.table == gtab.TypeGpos && (l.typ == 4 || l.typ == 5 || l.typ == 6):
+		return c.buildMarkAttach(l.typ, group)
+	}
+	panic("unreachable")
+}
+
+// buildMap combines a group of mapRules into a coverage table and
+// the corresponding replacements.
+func (c *compiler) buildMap(group []rule) (coverage.Table, [][]glyph.ID) {
+	m := make(map[glyph.ID][]glyph.ID)
+	for _, r := range group {
+		r := r.(*mapRule)
+		for i, from := range r.from {
+			if old, dup := m[from]; dup {
+				if !slices.Equal(old, r.to[i]) {
+					c.fail(r.tok, "glyph %s already has a substitution", c.glyphName(from))
+				}
+				continue
+			}
+			m[from] = r.to[i]
+		}
+	}
+
+	cov := makeCoverage(mapKeys(m))
+	to := make([][]glyph.ID, len(cov))
+	for gid, idx := range cov {
+		to[idx] = m[gid]
+	}
+	return cov, to
+}
+
+func (c *compiler) buildSingleSub(group []rule) []gtab.Subtable {
+	cov, to := c.buildMap(group)
+
+	glyphs := cov.Glyphs()
+	delta := to[cov[glyphs[0]]][0] - glyphs[0]
+	subst := make([]glyph.ID, len(to))
+	for gid, idx := range cov {
+		subst[idx] = to[idx][0]
+		if to[idx][0]-gid != delta {
+			delta = 0
+			glyphs = nil
+		}
+	}
+	if glyphs != nil {
+		return []gtab.Subtable{&gtab.Gsub1_1{Cov: cov.ToSet(), Delta: delta}}
+	}
+	return []gtab.Subtable{&gtab.Gsub1_2{Cov: cov, SubstituteGlyphIDs: subst}}
+}
+
+func (c *compiler) buildLigatureSub(group []rule) []gtab.Subtable {
+	ligs := make(map[glyph.ID][]gtab.Ligature)
+	seen := make(map[string]bool)
+	for _, r := range group {
+		r := r.(*ligatureRule)
+		for _, seq := range cartesian(r.in) {
+			key := fmt.Sprint(seq)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			ligs[seq[0]] = append(ligs[seq[0]], gtab.Ligature{In: seq[1:], Out: r.out})
+		}
+	}
+
+	cov := makeCoverage(mapKeys(ligs))
+	repl := make([][]gtab.Ligature, len(cov))
+	for gid, idx := range cov {
+		ll := ligs[gid]
+		// Longer ligatures must be tried first.
+		sort.SliceStable(ll, func(i, j int) bool {
+			return len(ll[i].In) > len(ll[j].In)
+		})
+		repl[idx] = ll
+	}
+	return []gtab.Subtable{&gtab.Gsub4_1{Cov: cov, Repl: repl}}
+}
+
+// cartesian returns all glyph sequences which can be formed by choosing
+// one glyph from each of the given classes.
+func cartesian(classes [][]glyph.ID) [][]glyph.ID {
+	res := [][]glyph.ID{{}}
+	for _, class := range classes {
+		var next [][]glyph.ID
+		for _, prefix := range res {
+			for _, gid := range class {
+				seq := make([]glyph.ID, len(prefix), len(prefix)+1)
+				copy(seq, prefix)
+				next = append(next, append(seq, gid))
+			}
+		}
+		res = next
+	}
+	return res
+}
+
+func (c *compiler) buildChain(l *lookup, group []rule) []gtab.Subtable {
+	var res []gtab.Subtable
+	for _, r := range group {
+		r := r.(*chainRule)
+		subtable := &gtab.ChainedSeqContext3{
+			Backtrack: make([]coverage.Set, len(r.backtrack)),
+			Input:     make([]coverage.Set, len(r.input)),
+			Lookahead: make([]coverage.Set, len(r.lookahead)),
+			Actions:   make([]gtab.SeqLookup, 0, len(r.actions)),
+		}
+		for i, gg := range r.backtrack {
+			// The backtrack sequence is stored in reverse order.
+			subtable.Backtrack[len(r.backtrack)-1-i] = makeCoverage(gg).ToSet()
+		}
+		for i, gg := range r.input {
+			subtable.Input[i] = makeCoverage(gg).ToSet()
+		}
+		for i, gg := range r.lookahead {
+			subtable.Lookahead[i] = makeCoverage(gg).ToSet()
+		}
+		for _, a := range r.actions {
+			if a.l.table == 0 {
+				c.fail(r.tok, "lookup %q contains no rules", a.l.name)
+			}
+			if a.l.table != l.table {
+				c.fail(r.tok, "lookup %q is not a %s lookup", a.l.name, l.table)
+			}
+			subtable.Actions = append(subtable.Actions, gtab.SeqLookup{
+				SequenceIndex:   uint16(a.pos),
+				LookupListIndex: a.l.index,
+			})
+		}
+		res = append(res, subtable)
+	}
+	return res
+}
+
+func (c *compiler) buildReverseSub(group []rule) []gtab.Subtable {
+	var res []gtab.Subtable
+	for _, r := range group {
+		r := r.(*reverseRule)
+		m := make(map[glyph.ID]glyph.ID)
+		for i, from := range r.from {
+			if _, dup := m[from]; !dup {
+				m[from] = r.to[i]
+			}
+		}
+		cov := makeCoverage(r.from)
+		subtable := &gtab.Gsub8_1{
+			Input:              cov,
+			Backtrack:          make([]coverage.Table, len(r.backtrack)),
+			Lookahead:          make([]coverage.Table, len(r.lookahead)),
+			SubstituteGlyphIDs: make([]glyph.ID, len(cov)),
+		}
+		for gid, idx := range cov {
+			subtable.SubstituteGlyphIDs[idx] = m[gid]
+		}
+		for i, gg := range r.backtrack {
+			// The backtrack sequence is stored in reverse order.
+			subtable.Backtrack[len(r.backtrack)-1-i] = makeCoverage(gg)
+		}
+		for i, gg := range r.lookahead {
+			subtable.Lookahead[i] = makeCoverage(gg)
+		}
+		res = append(res, subtable)
+	}
+	return res
+}
+
+func (c *compiler) buildSinglePos(group []rule) []gtab.Subtable {
+	m := make(map[glyph.ID]*gtab.GposValueRecord)
+	for _, r := range group {
+		r := r.(*singlePosRule)
+		for _, gid := range r.glyphs {
+			if old, dup := m[gid]; dup {
+				if !valueRecordEqual(old, r.vr) {
+					c.fail(r.tok, "glyph %s already has a position adjustment", c.glyphName(gid))
+				}
+				continue
+			}
+			m[gid] = r.vr
+		}
+	}
+
+	cov := makeCoverage(mapKeys(m))
+	adjust := make([]*gtab.GposValueRecord, len(cov))
+	allEqual := true
+	for gid, idx := range cov {
+		adjust[idx] = m[gid]
+	}
+	for _, vr := range adjust[1:] {
+		if !valueRecordEqual(vr, adjust[0]) {
+			allEqual = false
+			break
+		}
+	}
+	if allEqual {
+		return []gtab.Subtable{&gtab.Gpos1_1{Cov: cov, Adjust: adjust[0]}}
+	}
+	return []gtab.Subtable{&gtab.Gpos1_2{Cov: cov, Adjust: adjust}}
+}
+
+// buildPairPos converts pair positioning rules into subtables.  Rules for
+// individual glyphs are collected in a format 1 subtable, which comes
+// first.  Class-based rules are packed into as few format 2 subtables as
+// possible.  If several rules apply to the same pair, the first one is used.
+func (c *compiler) buildPairPos(group []rule) []gtab.Subtable {
+	var res []gtab.Subtable
+
+	pairs := make(gtab.Gpos2_1)
+	var classRules []*pairPosRule
+	for _, r := range group {
+		r := r.(*pairPosRule)
+		if r.isClass {
+			classRules = append(classRules, r)
+			continue
+		}
+		for _, left := range r.first {
+			for _, right := range r.second {
+				key := glyph.Pair{Left: left, Right: right}
+				if _, dup := pairs[key]; !dup {
+					pairs[key] = &gtab.PairAdjust{First: r.v1, Second: r.v2}
+				}
+			}
+		}
+	}
+	if len(pairs) > 0 {
+		adj := make([]*gtab.PairAdjust, 0, len(pairs))
+		for _, a := range pairs {
+			adj = append(adj, a)
+		}
+		normalizePairs(adj)
+		res = append(res, pairs)
+	}
+
+	var cur *classPairs
+	for _, r := range classRules {
+		if cur == nil || !cur.add(r) {
+			if cur != nil {
+				res = append(res, cur.subtable())
+			}
+			cur = newClassPairs()
+			cur.add(r)
+		}
+	}
+	if cur != nil {
+		res = append(res, cur.subtable())
+	}
+	return res
+}
+
+// classPairs collects the class-based pair positioning rules for a single
+// format 2 subtable.
+type classPairs struct {
+	class1, class2 classdef.Table
+	keys1, keys2   map[string]uint16
+	adjust         map[[2]uint16]*gtab.PairAdjust
+}
+
+func newClassPairs() *classPairs {
+	return &classPairs{
+		class1: make(classdef.Table),
+		class2: make(classdef.Table),
+		keys1:  make(map[string]uint16),
+		keys2:  make(map[string]uint16),
+		adjust: make(map[[2]uint16]*gtab.PairAdjust),
+	}
+}
+
+// add adds a rule to the subtable.  If the glyph classes of the rule
+// overlap with the classes used so far, false is returned and the subtable
+// is not modified.
+func (cp *classPairs) add(r *pairPosRule) bool {
+	first, key1, c1, ok1 := findClass(cp.class1, cp.keys1, r.first)
+	second, key2, c2, ok2 := findClass(cp.class2, cp.keys2, r.second)
+	if !ok1 || !ok2 {
+		return false
+	}
+	if _, seen := cp.keys1[key1]; !seen {
+		cp.keys1[key1] = c1
+		for _, gid := range first {
+			cp.class1[gid] = c1
+		}
+	}
+	if _, seen := cp.keys2[key2]; !seen {
+		cp.keys2[key2] = c2
+		for _, gid := range second {
+			cp.class2[gid] = c2
+		}
+	}
+	if _, dup := cp.adjust[[2]uint16{c1, c2}]; !dup {
+		cp.adjust[[2]uint16{c1, c2}] = &gtab.PairAdjust{First: r.v1, Second: r.v2}
+	}
+	return true
+}
+
+// findClass returns the class number for the glyphs gg.  If gg is not yet
+// a class, a new class number is returned, provided that none of the
+// glyphs is already used in a different class.
+func findClass(cd classdef.Table, keys map[string]uint16, gg []glyph.ID) ([]glyph.ID, string, uint16, bool) {
+	gg = sortedGlyphs(gg)
+	key := fmt.Sprint(gg)
+	if class, ok := keys[key]; ok {
+		return gg, key, class, true
+	}
+	for _, gid := range gg {
+		if _, used := cd[gid]; used {
+			return nil, "", 0, false
+		}
+	}
+	return gg, key, uint16(len(keys) + 1), true
+}
+
+func (cp *classPairs) subtable() *gtab.Gpos2_2 {
+	cov := make(coverage.Set)
+	for gid := range cp.class1 {
+		cov[gid] = true
+	}
+	adjust := make([][]*gtab.PairAdjust, len(cp.keys1)+1)
+	for i := range adjust {
+		row := make([]*gtab.PairAdjust, len(cp.keys2)+1)
+		for j := range row {
+			row[j] = &gtab.PairAdjust{}
+		}
+		adjust[i] = row
+	}
+	for key, adj := range cp.adjust {
+		adjust[key[0]][key[1]] = adj
+	}
+	normalizePairs(slices.Concat(adjust...))
+	return &gtab.Gpos2_2{
+		Cov:    cov,
+		Class1: cp.class1,
+		Class2: cp.class2,
+		Adjust: adjust,
+	}
+}
+
+// normalizePairs replaces nil value records by empty ones, wherever the
+// other pairs of the subtable use the corresponding value record.  This
+// gives the same representation as reading the subtable from a font file.
+func normalizePairs(adj []*gtab.PairAdjust) {
+	var hasFirst, hasSecond bool
+	for _, a := range adj {
+		hasFirst = hasFirst || a.First != nil
+		hasSecond = hasSecond || a.Second != nil
+	}
+	for _, a := range adj {
+		if hasFirst && a.First == nil {
+			a.First = &gtab.GposValueRecord{}
+		}
+		if hasSecond && a.Second == nil {
+			a.Second = &gtab.GposValueRecord{}
+		}
+	}
+}
+
+func (c *compiler) buildCursivePos(group []rule) []gtab.Subtable {
+	m := make(map[glyph.ID]gtab.EntryExitRecord)
+	for _, r := range group {
+		r := r.(*cursiveRule)
+		for _, gid := range r.glyphs {
+			if _, dup := m[gid]; dup {
+				c.fail(r.tok, "glyph %s already has cursive anchors", c.glyphName(gid))
+			}
+			m[gid] = gtab.EntryExitRecord{Entry: r.entry, Exit: r.exit}
+		}
+	}
+
+	cov := makeCoverage(mapKeys(m))
+	records := make([]gtab.EntryExitRecord, len(cov))
+	for gid, idx := range cov {
+		records[idx] = m[gid]
+	}
+	return []gtab.Subtable{&gtab.Gpos3_1{Cov: cov, Records: records}}
+}
+
+// buildMarkAttach converts mark-to-base (typ 4), mark-to-ligature (typ 5)
+// and mark-to-mark (typ 6) rules into a subtable.  Mark classes are numbered
+// in order of first use.
+func (c *compiler) buildMarkAttach(typ uint16, group []rule) []gtab.Subtable {
+	classIndex := make(map[*markClass]uint16)
+	var classes []*markClass
+	for _, r := range group {
+		r := r.(*markAttachRule)
+		for _, comp := range r.components {
+			for _, ma := range comp {
+				if _, seen := classIndex[ma.class]; !seen {
+					classIndex[ma.class] = uint16(len(classes))
+					classes = append(classes, ma.class)
+				}
+			}
+		}
+	}
+	numClasses := len(classes)
+
+	marks := make(map[glyph.ID]markarray.Record)
+	for i, mc := range classes {
+		for _, gid := range mc.glyphs {
+			if old, dup := marks[gid]; dup {
+				c.fail(group[0].where(), "glyph %s is in mark classes @%s and @%s",
+					c.glyphName(gid), classes[old.Class].name, mc.name)
+			}
+			marks[gid] = markarray.Record{Class: uint16(i), Table: *mc.anchors[gid]}
+		}
+	}
+	markCov := makeCoverage(mapKeys(marks))
+	markArray := make([]markarray.Record, len(markCov))
+	for gid, idx := range markCov {
+		markArray[idx] = marks[gid]
+	}
+
+	// bases[gid][component][class] gives the attachment anchors
+	bases := make(map[glyph.ID][][]*anchor.Table)
+	for _, r := range group {
+		r := r.(*markAttachRule)
+		for _, gid := range r.glyphs {
+			comps, seen := bases[gid]
+			if !seen {
+				comps = make([][]*anchor.Table, len(r.components))
+				for i := range comps {
+					comps[i] = make([]*anchor.Table, numClasses)
+				}
+				bases[gid] = comps
+			} else if len(comps) != len(r.components) {
+				c.fail(r.tok, "inconsistent number of ligature components for glyph %s",
+					c.glyphName(gid))
+			}
+			for i, comp := range r.components {
+				for _, ma := range comp {
+					class := classIndex[ma.class]
+					if comps[i][class] == nil {
+						comps[i][class] = ma.anchor
+					}
+				}
+			}
+		}
+	}
+	baseCov := makeCoverage(mapKeys(bases))
+
+	switch typ {
+	case 4:
+		baseArray := make([][]*anchor.Table, len(baseCov))
+		for gid, idx := range baseCov {
+			baseArray[idx] = bases[gid][0]
+		}
+		return []gtab.Subtable{&gtab.Gpos4_1{
+			MarkCov:   markCov,
+			BaseCov:   baseCov,
+			MarkArray: markArray,
+			BaseArray: baseArray,
+		}}
+	case 5:
+		ligArray := make([][][]*anchor.Table, len(baseCov))
+		for gid, idx := range baseCov {
+			ligArray[idx] = bases[gid]
+		}
+		return []gtab.Subtable{&gtab.Gpos5_1{
+			MarkCov:   markCov,
+			LigCov:    baseCov,
+			MarkArray: markArray,
+			LigArray:  ligArray,
+		}}
+	default:
+		mark2Array := make([][]*anchor.Table, len(baseCov))
+		for gid, idx := range baseCov {
+			mark2Array[idx] = bases[gid][0]
+		}
+		return []gtab.Subtable{&gtab.Gpos6_1{
+			Mark1Cov:   markCov,
+			Mark2Cov:   baseCov,
+			Mark1Array: markArray,
+			Mark2Array: mark2Array,
+		}}
+	}
+}
+
+// makeGdef constructs the GDEF table.  If the feature file has no GDEF table
+// block, the glyph classes are inferred from the mark classes and from the
+// mark attachment rules.
+func (c *compiler) makeGdef() *gdef.Table {
+	table := &gdef.Table{}
+
+	glyphClass := c.glyphClass
+	if glyphClass == nil {
+		glyphClass = c.inferGlyphClasses()
+	}
+	if len(glyphClass) > 0 {
+		table.GlyphClass = glyphClass
+	}
+
+	if len(c.attach) > 0 {
+		cov := makeCoverage(mapKeys(c.attach))
+		points := make([][]uint16, len(cov))
+		for gid, idx := range cov {
+			points[idx] = c.attach[gid]
+		}
+		table.AttachList = &gdef.AttachList{Cov: cov, Points: points}
+	}
+	if len(c.carets) > 0 {
+		cov := makeCoverage(mapKeys(c.carets))
+		carets := make([][]gdef.CaretValue, len(cov))
+		for gid, idx := range cov {
+			carets[idx] = c.carets[gid]
+		}
+		table.LigCaretList = &gdef.LigCaretList{Cov: cov, Carets: carets}
+	}
+
+	if len(c.markAttachClasses) > 0 {
+		table.MarkAttachClass = make(classdef.Table)
+		for i, class := range c.markAttachClasses {
+			for _, gid := range class {
+				table.MarkAttachClass[gid] = uint16(i + 1)
+			}
+		}
+	}
+	for _, gg := range c.markFilterSets {
+		set := make(coverage.Set)
+		for _, gid := range gg {
+			set[gid] = true
+		}
+		table.MarkGlyphSets = append(table.MarkGlyphSets, set)
+	}
+
+	if table.GlyphClass == nil && table.AttachList == nil && table.LigCaretList == nil &&
+		table.MarkAttachClass == nil && table.MarkGlyphSets == nil {
+		return nil
+	}
+	return table
+}
+
+func (c *compiler) inferGlyphClasses() classdef.Table {
+	res := make(classdef.Table)
+	for _, l := range c.lookups {
+		if l.table != gtab.TypeGpos || l.typ < 4 || l.typ > 6 {
+			continue
+		}
+		class := uint16(gdef.GlyphClassBase)
+		switch l.typ {
+		case 5:
+			class = gdef.GlyphClassLigature
+		case 6:
+			class = gdef.GlyphClassMark
+		}
+		for _, group := range l.subtables {
+			for _, r := range group {
+				for _, gid := range r.(*markAttachRule).glyphs {
+					res[gid] = class
+				}
+			}
+		}
+	}
+	for _, mc := range c.markClasses {
+		for _, gid := range mc.glyphs {
+			res[gid] = gdef.GlyphClassMark
+		}
+	}
+	return res
+}
+
+// makeCoverage returns a coverage table for the given glyphs.
+func makeCoverage(gg []glyph.ID) coverage.Table {
+	gg = sortedGlyphs(gg)
+	cov := make(coverage.Table, len(gg))
+	for i, gid := range gg {
+		cov[gid] = i
+	}
+	return cov
+}
+
+func mapKeys[V any](m map[glyph.ID]V) []glyph.ID {
+	keys := make([]glyph.ID, 0, len(m))
+	for gid := range m {
+		keys = append(keys, gid)
+	}
+	return keys
+}
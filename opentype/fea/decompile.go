@@ -0,0 +1,1227 @@
+// seehuhn.de/go/sfnt - a library for reading and writing font files
+// Copyright (C) 2026  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package fea
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"seehuhn.de/go/sfnt"
+	"seehuhn.de/go/sfnt/glyph"
+	"seehuhn.de/go/sfnt/name"
+	"seehuhn.de/go/sfnt/opentype/anchor"
+	"seehuhn.de/go/sfnt/opentype/classdef"
+	"seehuhn.de/go/sfnt/opentype/coverage"
+	"seehuhn.de/go/sfnt/opentype/device"
+	"seehuhn.de/go/sfnt/opentype/gdef"
+	"seehuhn.de/go/sfnt/opentype/gtab"
+	"seehuhn.de/go/sfnt/opentype/markarray"
+)
+
+// Decompile writes the GSUB, GPOS and GDEF tables of a font to w, in feature
+// file syntax.  The output can be compiled again using [Compile].
+//
+// Every lookup is written as a named lookup block, called GSUB_n or GPOS_n
+// after its index in the original lookup list.  Feature blocks refer to
+// these lookups by name, separately for each language system.  Glyphs
+// without a usable name are written as glyph IDs, like \123.
+//
+// An error is returned for lookups which cannot be expressed in feature
+// file syntax: contextual rules where the nested lookups are not applied in
+// order of increasing input position, or refer to positions after the end
+// of the input sequence, and ligature substitution lookups which mix
+// ligatures of one glyph with longer ligatures.
+//
+// The strings referenced by feature parameters are taken from names, which
+// should hold the decoded "name" table of the font.  If names is nil, or if
+// a string cannot be found, the corresponding name statements are omitted.
+//
+// Some information cannot be expressed in the feature file syntax and is
+// lost: VariationIndex tables, empty cells and class 0 of the second glyph
+// in class-based pair positioning subtables, language systems without
+// features, and the optional entry for a feature which is also the required
+// feature of a language system.  Contextual lookups are always written as chaining contextual
+// rules.
+func Decompile(w io.Writer, font *sfnt.Font, names *name.Info) error {
+	d := newDecompiler(font)
+	d.nameTable = names
+	err := d.run()
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, d.out.String())
+	return err
+}
+
+// decompiler holds the state while layout tables are converted into
+// feature file syntax.
+type decompiler struct {
+	font      *sfnt.Font
+	numGlyphs int
+	names     []string   // glyph names, indexed by glyph ID
+	nameTable *name.Info // strings for the feature parameters, may be nil
+
+	out  strings.Builder
+	pre  strings.Builder // definitions needed by the current lookup
+	body strings.Builder // the rules of the current lookup
+
+	done    map[lookupKey]bool
+	classes map[string]bool // named classes defined so far
+
+	markAttach map[uint16]string // GDEF mark attachment classes
+	markSets   map[uint16]string // GDEF mark glyph sets
+}
+
+type lookupKey struct {
+	table gtab.Type
+	index gtab.LookupIndex
+}
+
+func newDecompiler(font *sfnt.Font) *decompiler {
+	numGlyphs := font.NumGlyphs()
+
+	// Use the same rules as the compiler to resolve glyph names, so that
+	// every name is mapped back to the correct glyph.
+	byName := make(map[string]glyph.ID)
+	for gid := glyph.ID(0); gid < glyph.ID(numGlyphs); gid++ {
+		if name := font.GlyphName(gid); name != "" {
+			byName[name] = gid
+		}
+	}
+	names := make([]string, numGlyphs)
+	for gid := range names {
+		name := font.GlyphName(glyph.ID(gid))
+		switch {
+		case !isGlyphName(name) || byName[name] != glyph.ID(gid):
+			name = `\` + strconv.Itoa(gid)
+		case keywords[name]:
+			name = `\` + name
+		}
+		names[gid] = name
+	}
+
+	return &decompiler{
+		font:       font,
+		numGlyphs:  numGlyphs,
+		names:      names,
+		done:       make(map[lookupKey]bool),
+		classes:    make(map[string]bool),
+		markAttach: make(map[uint16]string),
+		markSets:   make(map[uint16]string),
+	}
+}
+
+// isGlyphName reports whether name can be used as a glyph name in a feature
+// file.
+func isGlyphName(name string) bool {
+	if name == "" || !isNameStart(name[0]) {
+		return false
+	}
+	for i := 1; i < len(name); i++ {
+		if !isNameChar(name[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// keywords lists the words which must be escaped when used as glyph names.
+var keywords = map[string]bool{
+	"anchor": true, "anchorDef": true, "base": true, "by": true,
+	"contourpoint": true, "cursive": true, "device": true, "enum": true,
+	"enumerate": true, "exclude_dflt": true, "excludeDFLT": true,
+	"feature": true, "from": true, "ignore": true, "include": true,
+	"include_dflt": true, "includeDFLT": true, "language": true,
+	"languagesystem": true, "ligature": true, "ligComponent": true,
+	"lookup": true, "lookupflag": true, "mark": true, "markClass": true,
+	"NULL": true, "parameters": true, "pos": true, "position": true,
+	"required": true, "reversesub": true, "rsub": true, "script": true,
+	"sub": true, "substitute": true, "subtable": true, "table": true,
+	"valueRecordDef": true,
+}
+
+func (d *decompiler) run() error {
+	gsub, gpos, gdefTable := d.font.Gsub, d.font.Gpos, d.font.Gdef
+
+	err := d.writeLanguageSystems(gsub, gpos)
+	if err != nil {
+		return err
+	}
+	d.writeGdefClasses(gdefTable)
+
+	if gsub != nil {
+		for i := range gsub.LookupList {
+			err = d.writeLookup(gtab.TypeGsub, gsub, gtab.LookupIndex(i))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if gpos != nil {
+		for i := range gpos.LookupList {
+			err = d.writeLookup(gtab.TypeGpos, gpos, gtab.LookupIndex(i))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	err = d.writeFeatures(gsub, gpos)
+	if err != nil {
+		return err
+	}
+
+	d.writeGdef(gdefTable)
+	return nil
+}
+
+// langSystems returns the language systems of a ScriptList, keyed by their
+// OpenType tags.
+func langSystems(info *gtab.Info) (map[langSys]*gtab.Features, error) {
+	res := make(map[langSys]*gtab.Features)
+	if info == nil {
+		return res, nil
+	}
+	for tag, features := range info.ScriptList {
+		script, lang, err := gtab.OpenTypeTags(tag)
+		if err != nil {
+			return nil, err
+		}
+		res[langSys{script: script, lang: lang}] = features
+	}
+	return res, nil
+}
+
+// compareLangSys orders language systems by script and language, with the
+// default script and the default language first.
+func compareLangSys(a, b langSys) int {
+	if a.script != b.script {
+		if a.script == "DFLT" || b.script == "DFLT" {
+			return boolCompare(b.script == "DFLT", a.script == "DFLT")
+		}
+		return strings.Compare(a.script, b.script)
+	}
+	if a.lang == "dflt" || b.lang == "dflt" {
+		return boolCompare(b.lang == "dflt", a.lang == "dflt")
+	}
+	return strings.Compare(a.lang, b.lang)
+}
+
+func boolCompare(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case a:
+		return 1
+	}
+	return -1
+}
+
+func (d *decompiler) writeLanguageSystems(gsub, gpos *gtab.Info) error {
+	var all []langSys
+	for _, info := range []*gtab.Info{gsub, gpos} {
+		m, err := langSystems(info)
+		if err != nil {
+			return err
+		}
+		for ls := range m {
+			if !slices.Contains(all, ls) {
+				all = append(all, ls)
+			}
+		}
+	}
+	if len(all) == 0 {
+		return nil
+	}
+	slices.SortFunc(all, compareLangSys)
+	for _, ls := range all {
+		fmt.Fprintf(&d.out, "languagesystem %s %s;\n", ls.script, ls.lang)
+	}
+	d.out.WriteString("\n")
+	return nil
+}
+
+// writeGdefClasses defines the mark attachment classes and mark glyph sets
+// from the GDEF table, for use in lookupflag statements.
+func (d *decompiler) writeGdefClasses(table *gdef.Table) {
+	if table == nil {
+		return
+	}
+	n := d.out.Len()
+	for class, gg := range table.MarkAttachClass.Glyphs() {
+		if class == 0 || len(gg) == 0 {
+			continue
+		}
+		name := fmt.Sprintf("@GDEF_MarkAttach%d", class)
+		fmt.Fprintf(&d.out, "%s = %s;\n", name, d.class(gg))
+		d.markAttach[uint16(class)] = name
+	}
+	for i, set := range table.MarkGlyphSets {
+		name := fmt.Sprintf("@GDEF_MarkSet%d", i)
+		fmt.Fprintf(&d.out, "%s = %s;\n", name, d.class(set.Glyphs()))
+		d.markSets[uint16(i)] = name
+	}
+	if d.out.Len() > n {
+		d.out.WriteString("\n")
+	}
+}
+
+func lookupName(table gtab.Type, idx gtab.LookupIndex) string {
+	if table == gtab.TypeGsub {
+		return fmt.Sprintf("GSUB_%d", idx)
+	}
+	return fmt.Sprintf("GPOS_%d", idx)
+}
+
+// writeLookup writes a named lookup block for the given lookup.  Lookups
+// used by contextual rules are written first, since feature files do not
+// allow forward references.
+func (d *decompiler) writeLookup(table gtab.Type, info *gtab.Info, idx gtab.LookupIndex) error {
+	key := lookupKey{table: table, index: idx}
+	if d.done[key] {
+		return nil
+	}
+	d.done[key] = true
+
+	l := info.LookupList[idx]
+	name := lookupName(table, idx)
+	err := checkLookup(l)
+	if err != nil {
+		return fmt.Errorf("lookup %s: %w", name, err)
+	}
+	for _, subtable := range l.Subtables {
+		for _, a := range nestedActions(subtable) {
+			if int(a.LookupListIndex) < len(info.LookupList) {
+				err := d.writeLookup(table, info, a.LookupListIndex)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	d.pre.Reset()
+	d.body.Reset()
+	d.writeFlags(l.Meta)
+	for i, subtable := range l.Subtables {
+		if i > 0 {
+			d.body.WriteString("    subtable;\n")
+		}
+		prefix := fmt.Sprintf("%s_%d", name, i)
+		d.writeSubtable(table, prefix, subtable)
+	}
+
+	if d.pre.Len() > 0 {
+		d.out.WriteString(d.pre.String())
+		d.out.WriteString("\n")
+	}
+	fmt.Fprintf(&d.out, "lookup %s {\n", name)
+	d.out.WriteString(d.body.String())
+	fmt.Fprintf(&d.out, "} %s;\n\n", name)
+	return nil
+}
+
+// checkLookup returns an error if a lookup cannot be written in feature
+// file syntax.
+//
+// In a feature file, the nested lookups of a contextual rule are attached
+// to the marked glyphs and are applied from left to right.  A ligature
+// "sub A by B;" with a single input glyph is read as a single substitution,
+// and cannot be combined with other ligatures in the same lookup.
+func checkLookup(l *gtab.LookupTable) error {
+	var short, long bool
+	for _, subtable := range l.Subtables {
+		switch l := subtable.(type) {
+		case *gtab.Gsub4_1:
+			for _, ligs := range l.Repl {
+				for _, lig := range ligs {
+					if len(lig.In) == 0 {
+						short = true
+					} else {
+						long = true
+					}
+				}
+			}
+		case *gtab.SeqContext1:
+			for _, rules := range l.Rules {
+				for _, r := range rules {
+					if err := checkActions(r.Actions, len(r.Input)+1); err != nil {
+						return err
+					}
+				}
+			}
+		case *gtab.SeqContext2:
+			for _, rules := range l.Rules {
+				for _, r := range rules {
+					if err := checkActions(r.Actions, len(r.Input)+1); err != nil {
+						return err
+					}
+				}
+			}
+		case *gtab.SeqContext3:
+			if err := checkActions(l.Actions, len(l.Input)); err != nil {
+				return err
+			}
+		case *gtab.ChainedSeqContext1:
+			for _, rules := range l.Rules {
+				for _, r := range rules {
+					if err := checkActions(r.Actions, len(r.Input)+1); err != nil {
+						return err
+					}
+				}
+			}
+		case *gtab.ChainedSeqContext2:
+			for _, rules := range l.Rules {
+				for _, r := range rules {
+					if err := checkActions(r.Actions, len(r.Input)+1); err != nil {
+						return err
+					}
+				}
+			}
+		case *gtab.ChainedSeqContext3:
+			if err := checkActions(l.Actions, len(l.Input)); err != nil {
+				return err
+			}
+		}
+	}
+	if short && long {
+		return errors.New("single-glyph ligatures mixed with longer ligatures")
+	}
+	return nil
+}
+
+// checkActions returns an error if the nested lookups of a contextual rule
+// with n input glyphs cannot be attached to the input glyphs in order.
+func checkActions(actions []gtab.SeqLookup, n int) error {
+	pos := 0
+	for _, a := range actions {
+		idx := int(a.SequenceIndex)
+		switch {
+		case idx >= n:
+			return fmt.Errorf("nested lookup at position %d after the end of the input", idx)
+		case idx < pos:
+			return fmt.Errorf("nested lookup at position %d applied after position %d", idx, pos)
+		}
+		pos = idx
+	}
+	return nil
+}
+
+// nestedActions returns the nested lookups used by a contextual subtable.
+func nestedActions(subtable gtab.Subtable) []gtab.SeqLookup {
+	var res []gtab.SeqLookup
+	switch l := subtable.(type) {
+	case *gtab.SeqContext1:
+		for _, rules := range l.Rules {
+			for _, r := range rules {
+				res = append(res, r.Actions...)
+			}
+		}
+	case *gtab.SeqContext2:
+		for _, rules := range l.Rules {
+			for _, r := range rules {
+				res = append(res, r.Actions...)
+			}
+		}
+	case *gtab.SeqContext3:
+		res = l.Actions
+	case *gtab.ChainedSeqContext1:
+		for _, rules := range l.Rules {
+			for _, r := range rules {
+				res = append(res, r.Actions...)
+			}
+		}
+	case *gtab.ChainedSeqContext2:
+		for _, rules := range l.Rules {
+			for _, r := range rules {
+				res = append(res, r.Actions...)
+			}
+		}
+	case *gtab.ChainedSeqContext3:
+		res = l.Actions
+	}
+	return res
+}
+
+func (d *decompiler) writeFlags(meta *gtab.LookupMetaInfo) {
+	flags := meta.LookupFlags
+	if flags == 0 {
+		return
+	}
+
+	var parts []string
+	for _, f := range []struct {
+		bit  gtab.LookupFlags
+		name string
+	}{
+		{gtab.RightToLeft, "RightToLeft"},
+		{gtab.IgnoreBaseGlyphs, "IgnoreBaseGlyphs"},
+		{gtab.IgnoreLigatures, "IgnoreLigatures"},
+		{gtab.IgnoreMarks, "IgnoreMarks"},
+	} {
+		if flags&f.bit != 0 {
+			parts = append(parts, f.name)
+		}
+	}
+	if class := uint16(flags&gtab.MarkAttachTypeMask) >> 8; class != 0 {
+		name, ok := d.markAttach[class]
+		if !ok {
+			fmt.Fprintf(&d.body, "    lookupflag %d;\n", flags)
+			return
+		}
+		parts = append(parts, "MarkAttachmentType", name)
+	}
+	if flags&gtab.UseMarkFilteringSet != 0 {
+		name, ok := d.markSets[meta.MarkFilteringSet]
+		if !ok {
+			fmt.Fprintf(&d.body, "    lookupflag %d;\n", flags)
+			return
+		}
+		parts = append(parts, "UseMarkFilteringSet", name)
+	}
+	fmt.Fprintf(&d.body, "    lookupflag %s;\n", strings.Join(parts, " "))
+}
+
+func (d *decompiler) rule(format string, args ...any) {
+	d.body.WriteString("    ")
+	fmt.Fprintf(&d.body, format, args...)
+	d.body.WriteString(";\n")
+}
+
+func (d *decompiler) writeSubtable(table gtab.Type, prefix string, subtable gtab.Subtable) {
+	kw := "sub"
+	if table == gtab.TypeGpos {
+		kw = "pos"
+	}
+
+	switch l := subtable.(type) {
+	case *gtab.Gsub1_1:
+		for _, gid := range l.Cov.Glyphs() {
+			d.rule("sub %s by %s", d.glyph(gid), d.glyph(gid+l.Delta))
+		}
+	case *gtab.Gsub1_2:
+		for _, gid := range l.Cov.Glyphs() {
+			d.rule("sub %s by %s", d.glyph(gid), d.glyph(l.SubstituteGlyphIDs[l.Cov[gid]]))
+		}
+	case *gtab.Gsub2_1:
+		for _, gid := range l.Cov.Glyphs() {
+			d.rule("sub %s by %s", d.glyph(gid), d.seq(l.Repl[l.Cov[gid]]))
+		}
+	case *gtab.Gsub3_1:
+		for _, gid := range l.Cov.Glyphs() {
+			d.rule("sub %s from %s", d.glyph(gid), d.list(l.Alternates[l.Cov[gid]]))
+		}
+	case *gtab.Gsub4_1:
+		for _, gid := range l.Cov.Glyphs() {
+			for _, lig := range l.Repl[l.Cov[gid]] {
+				in := append([]glyph.ID{gid}, lig.In...)
+				d.rule("sub %s by %s", d.seq(in), d.glyph(lig.Out))
+			}
+		}
+	case *gtab.Gsub8_1:
+		from := l.Input.Glyphs()
+		to := make([]glyph.ID, len(from))
+		for i, gid := range from {
+			to[i] = l.SubstituteGlyphIDs[l.Input[gid]]
+		}
+		var b strings.Builder
+		b.WriteString("rsub ")
+		for i := len(l.Backtrack) - 1; i >= 0; i-- {
+			b.WriteString(d.item(l.Backtrack[i].Glyphs()))
+			b.WriteString(" ")
+		}
+		fmt.Fprintf(&b, "%s'", d.list(from))
+		for _, cov := range l.Lookahead {
+			b.WriteString(" ")
+			b.WriteString(d.item(cov.Glyphs()))
+		}
+		fmt.Fprintf(&b, " by %s", d.list(to))
+		d.rule("%s", b.String())
+
+	case *gtab.SeqContext1:
+		for _, gid := range l.Cov.Glyphs() {
+			for _, r := range l.Rules[l.Cov[gid]] {
+				input := []string{d.glyph(gid)}
+				for _, gid := range r.Input {
+					input = append(input, d.glyph(gid))
+				}
+				d.contextRule(table, kw, nil, input, nil, r.Actions)
+			}
+		}
+	case *gtab.SeqContext2:
+		for class, rules := range l.Rules {
+			if len(rules) == 0 {
+				continue
+			}
+			first := d.firstClass(prefix+"_in", l.Cov, l.Input, uint16(class))
+			if first == "" {
+				continue
+			}
+			for _, r := range rules {
+				input := []string{first}
+				for _, c := range r.Input {
+					input = append(input, d.namedClass(prefix+"_in", l.Input, c))
+				}
+				d.contextRule(table, kw, nil, input, nil, r.Actions)
+			}
+		}
+	case *gtab.SeqContext3:
+		d.contextRule(table, kw, nil, d.sets(l.Input), nil, l.Actions)
+	case *gtab.ChainedSeqContext1:
+		for _, gid := range l.Cov.Glyphs() {
+			for _, r := range l.Rules[l.Cov[gid]] {
+				var backtrack, input, lookahead []string
+				for i := len(r.Backtrack) - 1; i >= 0; i-- {
+					backtrack = append(backtrack, d.glyph(r.Backtrack[i]))
+				}
+				input = append(input, d.glyph(gid))
+				for _, gid := range r.Input {
+					input = append(input, d.glyph(gid))
+				}
+				for _, gid := range r.Lookahead {
+					lookahead = append(lookahead, d.glyph(gid))
+				}
+				d.contextRule(table, kw, backtrack, input, lookahead, r.Actions)
+			}
+		}
+	case *gtab.ChainedSeqContext2:
+		for class, rules := range l.Rules {
+			if len(rules) == 0 {
+				continue
+			}
+			first := d.firstClass(prefix+"_in", l.Cov, l.Input, uint16(class))
+			if first == "" {
+				continue
+			}
+			for _, r := range rules {
+				var backtrack, lookahead []string
+				for i := len(r.Backtrack) - 1; i >= 0; i-- {
+					backtrack = append(backtrack, d.namedClass(prefix+"_bt", l.Backtrack, r.Backtrack[i]))
+				}
+				input := []string{first}
+				for _, c := range r.Input {
+					input = append(input, d.namedClass(prefix+"_in", l.Input, c))
+				}
+				for _, c := range r.Lookahead {
+					lookahead = append(lookahead, d.namedClass(prefix+"_la", l.Lookahead, c))
+				}
+				d.contextRule(table, kw, backtrack, input, lookahead, r.Actions)
+			}
+		}
+	case *gtab.ChainedSeqContext3:
+		backtrack := d.sets(l.Backtrack)
+		slices.Reverse(backtrack)
+		d.contextRule(table, kw, backtrack, d.sets(l.Input), d.sets(l.Lookahead), l.Actions)
+
+	case *gtab.Gpos1_1:
+		d.rule("pos %s %s", d.item(l.Cov.Glyphs()), d.valueRecord(l.Adjust))
+	case *gtab.Gpos1_2:
+		for _, gid := range l.Cov.Glyphs() {
+			d.rule("pos %s %s", d.glyph(gid), d.valueRecord(l.Adjust[l.Cov[gid]]))
+		}
+	case gtab.Gpos2_1:
+		pairs := make([]glyph.Pair, 0, len(l))
+		for pair := range l {
+			pairs = append(pairs, pair)
+		}
+		slices.SortFunc(pairs, func(a, b glyph.Pair) int {
+			if a.Left != b.Left {
+				return int(a.Left) - int(b.Left)
+			}
+			return int(a.Right) - int(b.Right)
+		})
+		for _, pair := range pairs {
+			d.pairRule(d.glyph(pair.Left), d.glyph(pair.Right), l[pair])
+		}
+	case *gtab.Gpos2_2:
+		d.writeClassPairs(prefix, l)
+	case *gtab.Gpos3_1:
+		for _, gid := range l.Cov.Glyphs() {
+			r := l.Records[l.Cov[gid]]
+			d.rule("pos cursive %s %s %s", d.glyph(gid), d.anchor(r.Entry), d.anchor(r.Exit))
+		}
+	case *gtab.Gpos4_1:
+		marks := d.markClasses(prefix, l.MarkCov, l.MarkArray)
+		for _, gid := range l.BaseCov.Glyphs() {
+			if s := d.markAnchors(marks, l.BaseArray[l.BaseCov[gid]]); s != "" {
+				d.rule("pos base %s %s", d.glyph(gid), s)
+			}
+		}
+	case *gtab.Gpos5_1:
+		marks := d.markClasses(prefix, l.MarkCov, l.MarkArray)
+		for _, gid := range l.LigCov.Glyphs() {
+			var components []string
+			for _, comp := range l.LigArray[l.LigCov[gid]] {
+				s := d.markAnchors(marks, comp)
+				if s == "" {
+					s = "<anchor NULL>"
+				}
+				components = append(components, s)
+			}
+			if len(components) > 0 {
+				d.rule("pos ligature %s %s", d.glyph(gid), strings.Join(components, " ligComponent "))
+			}
+		}
+	case *gtab.Gpos6_1:
+		marks := d.markClasses(prefix, l.Mark1Cov, l.Mark1Array)
+		for _, gid := range l.Mark2Cov.Glyphs() {
+			if s := d.markAnchors(marks, l.Mark2Array[l.Mark2Cov[gid]]); s != "" {
+				d.rule("pos mark %s %s", d.glyph(gid), s)
+			}
+		}
+
+	default:
+		fmt.Fprintf(&d.body, "    # unsupported subtable type %T\n", subtable)
+	}
+}
+
+// contextRule writes a contextual rule.  Rules without nested lookups are
+// written as ignore statements.
+func (d *decompiler) contextRule(table gtab.Type, kw string, backtrack, input, lookahead []string, actions []gtab.SeqLookup) {
+	var parts []string
+	if len(actions) == 0 {
+		parts = append(parts, "ignore", kw)
+	} else {
+		parts = append(parts, kw)
+	}
+	parts = append(parts, backtrack...)
+	for i, item := range input {
+		item += "'"
+		for _, a := range actions {
+			if int(a.SequenceIndex) == i {
+				item += " lookup " + lookupName(table, a.LookupListIndex)
+			}
+		}
+		parts = append(parts, item)
+	}
+	parts = append(parts, lookahead...)
+	d.rule("%s", strings.Join(parts, " "))
+}
+
+// firstClass returns the name of the class used for the first glyph of a
+// class-based contextual rule.  This is the intersection of the coverage
+// table and the given class.  The empty string is returned, if no glyphs
+// match.
+func (d *decompiler) firstClass(prefix string, cov coverage.Table, cd classdef.Table, class uint16) string {
+	var gg []glyph.ID
+	all := true
+	for _, gid := range classGlyphs(cd, class, d.numGlyphs) {
+		if cov.Contains(gid) {
+			gg = append(gg, gid)
+		} else {
+			all = false
+		}
+	}
+	switch {
+	case len(gg) == 0:
+		return ""
+	case all:
+		return d.namedClass(prefix, cd, class)
+	}
+	return d.defineClass(fmt.Sprintf("%s%d_first", prefix, class), gg)
+}
+
+// namedClass returns the name of a glyph class from a class definition
+// table, defining the class if needed.
+func (d *decompiler) namedClass(prefix string, cd classdef.Table, class uint16) string {
+	return d.defineClass(fmt.Sprintf("%s%d", prefix, class), classGlyphs(cd, class, d.numGlyphs))
+}
+
+func (d *decompiler) defineClass(name string, gg []glyph.ID) string {
+	name = "@" + name
+	if !d.classes[name] {
+		d.classes[name] = true
+		fmt.Fprintf(&d.pre, "%s = %s;\n", name, d.class(gg))
+	}
+	return name
+}
+
+// classGlyphs returns the glyphs in the given class, in increasing order.
+// Class 0 consists of all glyphs not listed in the class definition table.
+func classGlyphs(cd classdef.Table, class uint16, numGlyphs int) []glyph.ID {
+	var res []glyph.ID
+	for gid := glyph.ID(0); gid < glyph.ID(numGlyphs); gid++ {
+		if cd[gid] == class {
+			res = append(res, gid)
+		}
+	}
+	return res
+}
+
+func (d *decompiler) pairRule(first, second string, adj *gtab.PairAdjust) {
+	if adj == nil {
+		adj = &gtab.PairAdjust{}
+	}
+	if adj.Second == nil {
+		d.rule("pos %s %s %s", first, second, d.valueRecord(adj.First))
+		return
+	}
+	first1 := "<NULL>"
+	if adj.First != nil {
+		first1 = d.valueRecord(adj.First)
+	}
+	d.rule("pos %s %s %s %s", first, first1, second, d.valueRecord(adj.Second))
+}
+
+// writeClassPairs writes the rules for a class-based pair positioning
+// subtable.  Cells without adjustments are omitted.
+func (d *decompiler) writeClassPairs(prefix string, l *gtab.Gpos2_2) {
+	for c1, row := range l.Adjust {
+		var gg []glyph.ID
+		for _, gid := range classGlyphs(l.Class1, uint16(c1), d.numGlyphs) {
+			if l.Cov[gid] {
+				gg = append(gg, gid)
+			}
+		}
+		if len(gg) == 0 {
+			continue
+		}
+		var first string
+		for c2, adj := range row {
+			if c2 == 0 || adj == nil || isZeroRecord(adj.First) && isZeroRecord(adj.Second) {
+				continue
+			}
+			if first == "" {
+				first = d.defineClass(fmt.Sprintf("%s_first%d", prefix, c1), gg)
+			}
+			second := d.namedClass(prefix+"_second", l.Class2, uint16(c2))
+			d.pairRule(first, second, adj)
+		}
+	}
+}
+
+func isZeroRecord(vr *gtab.GposValueRecord) bool {
+	return vr == nil || *vr == gtab.GposValueRecord{}
+}
+
+// markClasses writes the markClass statements for a mark attachment
+// subtable and returns the class names, indexed by mark class.
+func (d *decompiler) markClasses(prefix string, cov coverage.Table, marks []markarray.Record) []string {
+	var res []string
+	for _, gid := range cov.Glyphs() {
+		rec := marks[cov[gid]]
+		for int(rec.Class) >= len(res) {
+			res = append(res, fmt.Sprintf("@%s_mark%d", prefix, len(res)))
+		}
+		a := rec.Table
+		fmt.Fprintf(&d.pre, "markClass %s %s %s;\n", d.glyph(gid), d.anchor(&a), res[rec.Class])
+	}
+	return res
+}
+
+// markAnchors formats a list of anchors, indexed by mark class, as used
+// in mark attachment rules.  Missing anchors are omitted.
+func (d *decompiler) markAnchors(marks []string, anchors []*anchor.Table) string {
+	var parts []string
+	for class, a := range anchors {
+		if a == nil || class >= len(marks) {
+			continue
+		}
+		parts = append(parts, d.anchor(a)+" mark "+marks[class])
+	}
+	return strings.Join(parts, " ")
+}
+
+func (d *decompiler) anchor(a *anchor.Table) string {
+	switch {
+	case a == nil:
+		return "<anchor NULL>"
+	case a.ContourPoint != nil:
+		return fmt.Sprintf("<anchor %d %d contourpoint %d>", a.X, a.Y, *a.ContourPoint)
+	case hasDevice(a.XDev, a.YDev):
+		return fmt.Sprintf("<anchor %d %d %s %s>", a.X, a.Y, deviceString(a.XDev), deviceString(a.YDev))
+	}
+	return fmt.Sprintf("<anchor %d %d>", a.X, a.Y)
+}
+
+func (d *decompiler) valueRecord(vr *gtab.GposValueRecord) string {
+	if vr == nil {
+		vr = &gtab.GposValueRecord{}
+	}
+	if !hasDevice(vr.XPlacementDev, vr.YPlacementDev, vr.XAdvanceDev, vr.YAdvanceDev) {
+		return fmt.Sprintf("<%d %d %d %d>", vr.XPlacement, vr.YPlacement, vr.XAdvance, vr.YAdvance)
+	}
+	return fmt.Sprintf("<%d %d %d %d %s %s %s %s>",
+		vr.XPlacement, vr.YPlacement, vr.XAdvance, vr.YAdvance,
+		deviceString(vr.XPlacementDev), deviceString(vr.YPlacementDev),
+		deviceString(vr.XAdvanceDev), deviceString(vr.YAdvanceDev))
+}
+
+// hasDevice reports whether any of the given device tables can be
+// represented in a feature file.
+func hasDevice(devs ...*device.Table) bool {
+	for _, dev := range devs {
+		if deviceString(dev) != "<device NULL>" {
+			return true
+		}
+	}
+	return false
+}
+
+func deviceString(dev *device.Table) string {
+	if dev == nil || dev.IsVariationIndex() {
+		return "<device NULL>"
+	}
+	var parts []string
+	for i, delta := range dev.Deltas {
+		if delta != 0 {
+			parts = append(parts, fmt.Sprintf("%d %d", int(dev.StartSize)+i, delta))
+		}
+	}
+	if parts == nil {
+		return "<device NULL>"
+	}
+	return "<device " + strings.Join(parts, ", ") + ">"
+}
+
+func (d *decompiler) glyph(gid glyph.ID) string {
+	if int(gid) >= len(d.names) {
+		return `\` + strconv.Itoa(int(gid))
+	}
+	return d.names[gid]
+}
+
+// seq formats a glyph sequence, separated by spaces.
+func (d *decompiler) seq(gg []glyph.ID) string {
+	parts := make([]string, len(gg))
+	for i, gid := range gg {
+		parts[i] = d.glyph(gid)
+	}
+	return strings.Join(parts, " ")
+}
+
+// list formats a glyph class literal, keeping the order of the glyphs.
+func (d *decompiler) list(gg []glyph.ID) string {
+	return "[" + d.seq(gg) + "]"
+}
+
+// class formats a set of glyphs as a glyph class literal.
+func (d *decompiler) class(gg []glyph.ID) string {
+	return d.list(sortedGlyphs(gg))
+}
+
+// item formats a set of glyphs as a single glyph, if possible, or as
+// a glyph class literal.
+func (d *decompiler) item(gg []glyph.ID) string {
+	if len(gg) == 1 {
+		return d.glyph(gg[0])
+	}
+	return d.class(gg)
+}
+
+func (d *decompiler) sets(sets []coverage.Set) []string {
+	res := make([]string, len(sets))
+	for i, set := range sets {
+		res[i] = d.item(set.Glyphs())
+	}
+	return res
+}
+
+// featureLangSys collects the lookups of one feature for one language
+// system.
+type featureLangSys struct {
+	lookups  []string
+	required bool
+}
+
+func (d *decompiler) writeFeatures(gsub, gpos *gtab.Info) error {
+	// Lookups from GSUB and GPOS are combined into one block per feature
+	// tag, since the language statements of a second block would remove
+	// the lookups of the first.
+	uses := make(map[string]map[langSys]*featureLangSys)
+	params := make(map[string]gtab.FeatureParams)
+	for _, table := range []gtab.Type{gtab.TypeGsub, gtab.TypeGpos} {
+		info := gsub
+		if table == gtab.TypeGpos {
+			info = gpos
+		}
+		m, err := langSystems(info)
+		if err != nil {
+			return err
+		}
+		for ls, features := range m {
+			add := func(fi gtab.FeatureIndex, required bool) {
+				if int(fi) >= len(info.FeatureList) {
+					return
+				}
+				f := info.FeatureList[fi]
+				if uses[f.Tag] == nil {
+					uses[f.Tag] = make(map[langSys]*featureLangSys)
+				}
+				u := uses[f.Tag][ls]
+				if u == nil {
+					u = &featureLangSys{}
+					uses[f.Tag][ls] = u
+				}
+				u.required = u.required || required
+				for _, idx := range f.Lookups {
+					if int(idx) >= len(info.LookupList) {
+						continue
+					}
+					name := lookupName(table, idx)
+					if !slices.Contains(u.lookups, name) {
+						u.lookups = append(u.lookups, name)
+					}
+				}
+				if f.Params != nil {
+					params[f.Tag] = f.Params
+				}
+			}
+			if features.Required != 0xFFFF {
+				add(features.Required, true)
+			}
+			for _, fi := range features.Optional {
+				add(fi, false)
+			}
+		}
+	}
+
+	tags := make([]string, 0, len(uses))
+	for tag := range uses {
+		tags = append(tags, tag)
+	}
+	slices.Sort(tags)
+	for _, tag := range tags {
+		fmt.Fprintf(&d.out, "feature %s {\n", tag)
+		d.writeParams(params[tag])
+
+		langs := make([]langSys, 0, len(uses[tag]))
+		for ls := range uses[tag] {
+			langs = append(langs, ls)
+		}
+		slices.SortFunc(langs, compareLangSys)
+		script := ""
+		for _, ls := range langs {
+			u := uses[tag][ls]
+			if ls.script != script {
+				fmt.Fprintf(&d.out, "    script %s;\n", ls.script)
+				script = ls.script
+			}
+			switch {
+			case ls.lang != "dflt":
+				fmt.Fprintf(&d.out, "    language %s exclude_dflt", ls.lang)
+				if u.required {
+					d.out.WriteString(" required")
+				}
+				d.out.WriteString(";\n")
+			case u.required:
+				d.out.WriteString("    language dflt required;\n")
+			}
+			for _, name := range u.lookups {
+				fmt.Fprintf(&d.out, "    lookup %s;\n", name)
+			}
+		}
+		fmt.Fprintf(&d.out, "} %s;\n\n", tag)
+	}
+	return nil
+}
+
+func (d *decompiler) writeParams(params gtab.FeatureParams) {
+	switch p := params.(type) {
+	case *gtab.FeatureParamsSize:
+		fmt.Fprintf(&d.out, "    parameters %s %d", decipoints(p.DesignSize), p.SubfamilyID)
+		if p.RangeStart != 0 || p.RangeEnd != 0 {
+			fmt.Fprintf(&d.out, " %s %s", decipoints(p.RangeStart), decipoints(p.RangeEnd))
+		}
+		d.out.WriteString(";\n")
+		if p.SubfamilyNameID != 0 {
+			for _, e := range d.nameEntries(p.SubfamilyNameID) {
+				fmt.Fprintf(&d.out, "    sizemenuname %s;\n", e)
+			}
+		}
+	case *gtab.FeatureParamsStylisticSet:
+		d.out.WriteString("    featureNames {\n")
+		d.writeNames("        ", p.UINameID)
+		d.out.WriteString("    };\n")
+	case *gtab.FeatureParamsCharacterVariants:
+		d.out.WriteString("    cvParameters {\n")
+		type nameBlock struct {
+			keyword string
+			nameID  uint16
+		}
+		blocks := []nameBlock{
+			{"FeatUILabelNameID", p.FeatUILabelNameID},
+			{"FeatUITooltipTextNameID", p.FeatUITooltipTextNameID},
+			{"SampleTextNameID", p.SampleTextNameID},
+		}
+		for i := range p.NumNamedParameters {
+			blocks = append(blocks,
+				nameBlock{"ParamUILabelNameID", p.FirstParamUILabelNameID + i})
+		}
+		for _, b := range blocks {
+			if b.nameID == 0 {
+				continue
+			}
+			fmt.Fprintf(&d.out, "        %s {\n", b.keyword)
+			d.writeNames("            ", b.nameID)
+			d.out.WriteString("        };\n")
+		}
+		for _, r := range p.Characters {
+			fmt.Fprintf(&d.out, "        Character 0x%04X;\n", r)
+		}
+		d.out.WriteString("    };\n")
+	}
+}
+
+// writeNames writes one name statement for every string with the given name
+// ID.
+func (d *decompiler) writeNames(indent string, nameID uint16) {
+	for _, e := range d.nameEntries(nameID) {
+		fmt.Fprintf(&d.out, "%sname %s;\n", indent, e)
+	}
+}
+
+// nameEntries returns the arguments of the name statements for the strings
+// with the given name ID, with the platform, encoding and language IDs
+// omitted where they have their default values.
+func (d *decompiler) nameEntries(nameID uint16) []string {
+	if d.nameTable == nil {
+		return nil
+	}
+
+	var res []string
+	add := func(tables name.Tables, platformID uint16, languageID func(string) (uint16, bool)) {
+		type entry struct {
+			languageID uint16
+			value      string
+		}
+		var entries []entry
+		for key, t := range tables {
+			val := t.Extra[name.ID(nameID)]
+			if val == "" {
+				continue
+			}
+			id, ok := languageID(key)
+			if !ok {
+				continue
+			}
+			entries = append(entries, entry{id, val})
+		}
+		slices.SortFunc(entries, func(a, b entry) int {
+			return int(a.languageID) - int(b.languageID)
+		})
+		for _, e := range entries {
+			val := quoteName(e.value, platformID)
+			switch {
+			case platformID == 3 && e.languageID == 0x0409:
+				res = append(res, val)
+			case platformID == 3:
+				res = append(res, fmt.Sprintf("3 1 0x%04X %s", e.languageID, val))
+			case e.languageID == 0:
+				res = append(res, "1 "+val)
+			default:
+				res = append(res, fmt.Sprintf("1 0 %d %s", e.languageID, val))
+			}
+		}
+	}
+	add(d.nameTable.Windows, 3, name.WindowsLanguageID)
+	add(d.nameTable.Mac, 1, name.MacLanguageID)
+	return res
+}
+
+// quoteName encloses s in double quotes, using escape sequences for quotes,
+// backslashes and non-ASCII characters.  Escapes have four hex digits for
+// the Windows platform and two hex digits for the Macintosh platform.
+// Characters which cannot be escaped are written as they are.
+func quoteName(s string, platformID uint16) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r >= 0x20 && r < 0x7F && r != '"' && r != '\\':
+			b.WriteRune(r)
+		case platformID == 1 && r <= 0xFF:
+			fmt.Fprintf(&b, "\\%02X", r)
+		case platformID != 1 && r <= 0xFFFF:
+			fmt.Fprintf(&b, "\\%04X", r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+func decipoints(x uint16) string {
+	return fmt.Sprintf("%d.%d", x/10, x%10)
+}
+
+func (d *decompiler) writeGdef(table *gdef.Table) {
+	if table == nil {
+		return
+	}
+
+	var lines []string
+	if len(table.GlyphClass) > 0 {
+		classes := make([]string, gdef.GlyphClassComponent)
+		for class := range classes {
+			gg := classGlyphs(table.GlyphClass, uint16(class+1), d.numGlyphs)
+			if len(gg) > 0 {
+				classes[class] = d.class(gg)
+			}
+		}
+		lines = append(lines, "GlyphClassDef "+strings.Join(classes, ", "))
+	}
+	if al := table.AttachList; al != nil {
+		for _, gid := range al.Cov.Glyphs() {
+			points := al.Points[al.Cov[gid]]
+			if len(points) == 0 {
+				continue
+			}
+			s := "Attach " + d.glyph(gid)
+			for _, p := range points {
+				s += " " + strconv.Itoa(int(p))
+			}
+			lines = append(lines, s)
+		}
+	}
+	if lc := table.LigCaretList; lc != nil {
+		for _, gid := range lc.Cov.Glyphs() {
+			carets := lc.Carets[lc.Cov[gid]]
+			if len(carets) == 0 {
+				continue
+			}
+			byIndex := carets[0].ContourPoint != nil
+			s := "LigatureCaretByPos " + d.glyph(gid)
+			if byIndex {
+				s = "LigatureCaretByIndex " + d.glyph(gid)
+			}
+			for _, c := range carets {
+				switch {
+				case byIndex && c.ContourPoint != nil:
+					s += " " + strconv.Itoa(int(*c.ContourPoint))
+				case !byIndex && c.ContourPoint == nil:
+					s += " " + strconv.Itoa(int(c.Coordinate))
+				}
+			}
+			lines = append(lines, s)
+		}
+	}
+	if len(lines) == 0 {
+		return
+	}
+
+	d.out.WriteString("table GDEF {\n")
+	for _, line := range lines {
+		fmt.Fprintf(&d.out, "    %s;\n", line)
+	}
+	d.out.WriteString("} GDEF;\n")
+}
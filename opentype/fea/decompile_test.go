@@ -0,0 +1,324 @@
+// seehuhn.de/go/sfnt - a library for reading and writing font files
+// Copyright (C) 2026  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package fea
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/text/language"
+
+	"seehuhn.de/go/sfnt/glyph"
+	"seehuhn.de/go/sfnt/name"
+	"seehuhn.de/go/sfnt/opentype/gtab"
+	"seehuhn.de/go/sfnt/opentype/gtab/testcases"
+)
+
+const decompileInput = `
+languagesystem DFLT dflt;
+languagesystem latn dflt;
+languagesystem latn TRK;
+
+@LC = [a-z];
+@UC = [A-Z];
+markClass [acute grave] <anchor 100 500> @TOP;
+markClass cedilla <anchor 100 -10> @BOTTOM;
+
+lookup SMALL {
+	sub @LC by @UC;
+} SMALL;
+
+lookup ALT {
+	sub a from [A b c];
+} ALT;
+
+lookup MULTI {
+	sub f by f i;
+	sub i by dotlessi;
+} MULTI;
+
+feature ccmp {
+	lookupflag IgnoreMarks;
+	sub f i by A;
+	sub f f i by B;
+	sub a' lookup MULTI b;
+	ignore sub x y';
+	rsub [a b] c' d by C;
+} ccmp;
+
+feature smcp {
+	script latn;
+	lookup SMALL;
+	language TRK exclude_dflt required;
+	lookup ALT;
+} smcp;
+
+feature kern {
+	pos A V -80;
+	pos T <0 0 -20 0> o <5 0 0 0>;
+	pos [T V] [a o] <0 0 -10 0 <device 11 -1, 12 -2> <device NULL> <device NULL> <device NULL>>;
+	subtable;
+	pos @UC @LC -5;
+} kern;
+
+feature mark {
+	lookupflag UseMarkFilteringSet [acute];
+	pos base [a e] <anchor 250 450> mark @TOP <anchor 250 0> mark @BOTTOM;
+	pos ligature B <anchor 200 600> mark @TOP ligComponent <anchor NULL>;
+} mark;
+
+feature mkmk {
+	lookupflag MarkAttachmentType [acute grave];
+	pos mark acute <anchor 100 700 contourpoint 3> mark @TOP;
+} mkmk;
+
+lookup kern_single {
+	pos x <1 2 3 4>;
+} kern_single;
+
+feature curs {
+	pos cursive a <anchor 0 0> <anchor 500 0>;
+	pos cursive b <anchor NULL> <anchor 400 10>;
+	pos [a b]' lookup kern_single c;
+} curs;
+
+feature size {
+	parameters 10.0 1 8.0 12.5;
+} size;
+
+table GDEF {
+	GlyphClassDef [a b e A], [B], [acute grave cedilla], ;
+	Attach a 1 5;
+	LigatureCaretByPos B 300;
+} GDEF;
+`
+
+// TestDecompile checks that decompiled feature files compile to layout
+// tables which decompile to the same feature file again.
+func TestDecompile(t *testing.T) {
+	f := loadFont(t)
+
+	text := decompileInput
+	var prev string
+	for round := range 3 {
+		tables, err := Compile(f, text)
+		if err != nil {
+			t.Fatalf("round %d: %v\n%s", round, err, text)
+		}
+		f.Gsub, f.Gpos, f.Gdef = tables.Gsub, tables.Gpos, tables.Gdef
+
+		buf := &strings.Builder{}
+		err = Decompile(buf, f, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		text = buf.String()
+		if round > 0 {
+			if d := cmp.Diff(prev, text); d != "" {
+				t.Errorf("round %d: output changed (-old +new):\n%s", round, d)
+			}
+		}
+		prev = text
+	}
+
+	for _, want := range []string{
+		"languagesystem latn TRK;\n",
+		"lookupflag IgnoreMarks;\n",
+		"    sub f i by A;\n",
+		"    language TRK exclude_dflt required;\n",
+		"parameters 10.0 1 8.0 12.5;\n",
+		"GlyphClassDef ",
+		"LigatureCaretByPos B 300;\n",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("missing %q in output:\n%s", want, text)
+		}
+	}
+}
+
+// TestDecompileGoRegular checks that the layout tables of the Go Regular
+// font survive a round trip through the feature file syntax.
+func TestDecompileGoRegular(t *testing.T) {
+	f := loadFont(t)
+	orig := f.Gsub
+
+	buf := &strings.Builder{}
+	err := Decompile(buf, f, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text1 := buf.String()
+
+	tables, err := Compile(f, text1)
+	if err != nil {
+		t.Fatalf("%v\n%s", err, text1)
+	}
+	// A required feature which is also listed as an optional feature is
+	// compiled back as a required feature only.
+	want := make(gtab.ScriptListInfo)
+	for tag, features := range orig.ScriptList {
+		optional := slices.DeleteFunc(slices.Clone(features.Optional), func(fi gtab.FeatureIndex) bool {
+			return fi == features.Required
+		})
+		if len(optional) == 0 {
+			optional = nil
+		}
+		want[tag] = &gtab.Features{Required: features.Required, Optional: optional}
+	}
+	if d := cmp.Diff(want, tables.Gsub.ScriptList); d != "" {
+		t.Errorf("script list changed (-old +new):\n%s", d)
+	}
+	f.Gsub, f.Gpos, f.Gdef = tables.Gsub, tables.Gpos, tables.Gdef
+
+	buf.Reset()
+	err = Decompile(buf, f, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text2 := buf.String()
+	if d := cmp.Diff(text1, text2); d != "" {
+		t.Errorf("output changed (-old +new):\n%s", d)
+	}
+}
+
+// TestDecompileTestcases checks that the GSUB test cases give the same
+// output after a round trip through the feature file syntax.  Lookups which
+// cannot be expressed in feature file syntax must be rejected by Decompile.
+func TestDecompileTestcases(t *testing.T) {
+	fontGen, err := testcases.NewFontGen()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for idx, test := range testcases.Gsub {
+		f, err := fontGen.GsubTestFont(idx)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		buf := &strings.Builder{}
+		err = Decompile(buf, f, nil)
+		if err != nil {
+			t.Logf("%d %s: %v", idx, test.Name, err)
+			continue
+		}
+		tables, err := Compile(f, buf.String())
+		if err != nil {
+			t.Errorf("%d %s: %v\n%s", idx, test.Name, err, buf.String())
+			continue
+		}
+
+		seq := make([]glyph.Info, len(test.In))
+		for i, r := range test.In {
+			seq[i].GID = fontGen.CMap.Lookup(r)
+			seq[i].Text = []rune{r}
+		}
+		lookups := tables.Gsub.FindLookups(language.AmericanEnglish, nil)
+		seq = gtab.NewContext(tables.Gsub.LookupList, tables.Gdef, lookups).Apply(seq)
+		var out []rune
+		for _, g := range seq {
+			out = append(out, fontGen.Rev[g.GID])
+		}
+		if string(out) != test.Out {
+			t.Errorf("%d %s: expected %q, got %q\n%s",
+				idx, test.Name, test.Out, string(out), buf.String())
+		}
+	}
+}
+
+// TestDecompileNames checks that the strings referenced by feature
+// parameters are written as name statements.
+func TestDecompileNames(t *testing.T) {
+	f := loadFont(t)
+	text := `
+feature size {
+	parameters 10.0 3 80 139;
+	sizemenuname "Text";
+	sizemenuname 1 "Text";
+} size;
+
+feature ss01 {
+	featureNames {
+		name "Alternate \0022a\0022";
+		name 3 1 0x0407 "Alternativ-\00e4";
+	};
+	sub a by A;
+} ss01;
+
+feature cv01 {
+	cvParameters {
+		FeatUILabelNameID { name "Variant"; };
+		ParamUILabelNameID { name "One"; };
+		ParamUILabelNameID { name "Two"; };
+		Character 0x61;
+	};
+	sub a from [A B];
+} cv01;
+`
+	var prev string
+	for round := range 3 {
+		tables, err := Compile(f, text)
+		if err != nil {
+			t.Fatalf("round %d: %v\n%s", round, err, text)
+		}
+		f.Gsub, f.Gpos, f.Gdef = tables.Gsub, tables.Gpos, tables.Gdef
+		names := &name.Info{Mac: name.Tables{}, Windows: name.Tables{}}
+		for _, rec := range tables.Names {
+			tt, key := names.Windows, "en-US"
+			switch {
+			case rec.PlatformID == 1:
+				tt, key = names.Mac, "en"
+			case rec.LanguageID == 0x0407:
+				key = "de-DE"
+			}
+			if tt[key] == nil {
+				tt[key] = &name.Table{Extra: map[name.ID]string{}}
+			}
+			tt[key].Extra[name.ID(rec.NameID)] = rec.Value
+		}
+
+		buf := &strings.Builder{}
+		err = Decompile(buf, f, names)
+		if err != nil {
+			t.Fatal(err)
+		}
+		text = buf.String()
+		if round > 0 {
+			if d := cmp.Diff(prev, text); d != "" {
+				t.Errorf("round %d: output changed (-old +new):\n%s", round, d)
+			}
+		}
+		prev = text
+	}
+
+	for _, want := range []string{
+		"    sizemenuname \"Text\";\n    sizemenuname 1 \"Text\";\n",
+		"    featureNames {\n" +
+			"        name 3 1 0x0407 \"Alternativ-\\00E4\";\n" +
+			"        name \"Alternate \\0022a\\0022\";\n" +
+			"    };\n",
+		"        FeatUILabelNameID {\n            name \"Variant\";\n        };\n",
+		"        ParamUILabelNameID {\n            name \"Two\";\n        };\n",
+		"        Character 0x0061;\n",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("missing %q in output:\n%s", want, text)
+		}
+	}
+}
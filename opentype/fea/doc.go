@@ -0,0 +1,45 @@
+// seehuhn.de/go/sfnt - a library for reading and writing font files
+// Copyright (C) 2026  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package fea compiles feature files in the Adobe feature file syntax
+// ("AFDKO .fea files") into OpenType GSUB, GPOS and GDEF tables.
+//
+// The following parts of the syntax are supported:
+//   - include statements, languagesystem statements, and named glyph classes,
+//     including glyph ranges like [a-z] and [\1-\20];
+//   - feature blocks with script, language (including exclude_dflt and
+//     required), lookupflag, subtable and lookup statements;
+//   - named lookup blocks, which can be used in several features or as
+//     nested lookups of contextual rules;
+//   - substitution rules of all types: single, multiple, alternate, ligature,
+//     contextual (including inline substitutions and ignore statements) and
+//     reverse chaining;
+//   - positioning rules of all types: single, pair (including enum), cursive,
+//     mark-to-base, mark-to-ligature, mark-to-mark and contextual, together
+//     with markClass, anchorDef and valueRecordDef statements;
+//   - the feature parameters of the "size", "ssXX" and "cvXX" features, and
+//     the "aalt" feature;
+//   - the GDEF table block.
+//
+// Glyphs are referred to by the names returned by [sfnt.Font.GlyphName].
+// Glyph references of the form \123 give glyph IDs.  Other table blocks,
+// like "table OS/2 { ... } OS/2;", are skipped.
+//
+// [Decompile] converts the layout tables of a font back into a feature file.
+//
+// The syntax is described at
+// https://adobe-type-tools.github.io/afdko/OpenTypeFeatureFileSpecification.html
+package fea
@@ -0,0 +1,102 @@
+// seehuhn.de/go/sfnt - a library for reading and writing font files
+// Copyright (C) 2026  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package fea
+
+import (
+	"fmt"
+	"os"
+
+	"seehuhn.de/go/sfnt"
+	"seehuhn.de/go/sfnt/opentype/gdef"
+	"seehuhn.de/go/sfnt/opentype/gtab"
+)
+
+// Tables contains the tables generated from a feature file.
+type Tables struct {
+	Gsub *gtab.Info  // nil, if the file contains no substitution rules
+	Gpos *gtab.Info  // nil, if the file contains no positioning rules
+	Gdef *gdef.Table // nil, if no GDEF information is needed
+
+	// Names lists the strings from sizemenuname, featureNames and
+	// cvParameters statements.  The name IDs are referenced by the feature
+	// parameters in Gsub and Gpos, and the caller must add these entries to
+	// the "name" table of the font.
+	Names []NameRecord
+}
+
+// NameRecord is an entry for the "name" table of a font.
+type NameRecord struct {
+	PlatformID uint16
+	EncodingID uint16
+	LanguageID uint16
+	NameID     uint16
+	Value      string
+}
+
+// Error describes a problem in a feature file.
+type Error struct {
+	File string // the file name, or "" if the input was not read from a file
+	Line int
+	Msg  string
+}
+
+func (err *Error) Error() string {
+	if err.File == "" {
+		return fmt.Sprintf("line %d: %s", err.Line, err.Msg)
+	}
+	return fmt.Sprintf("%s:%d: %s", err.File, err.Line, err.Msg)
+}
+
+// CompileFile reads the named feature file and compiles it into layout
+// tables for the given font.  Include statements are resolved relative to
+// the directory of the including file.
+func CompileFile(font *sfnt.Font, fname string) (*Tables, error) {
+	data, err := os.ReadFile(fname)
+	if err != nil {
+		return nil, err
+	}
+	return compile(font, fname, string(data))
+}
+
+// Compile compiles the feature file contents given in input into layout
+// tables for the given font.  Include statements are resolved relative to
+// the current working directory.
+//
+// If the input contains errors, the returned error is of type [*Error].
+func Compile(font *sfnt.Font, input string) (*Tables, error) {
+	return compile(font, "", input)
+}
+
+func compile(font *sfnt.Font, fname, input string) (res *Tables, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if e, ok := r.(*Error); ok {
+				res = nil
+				err = e
+				return
+			}
+			panic(r)
+		}
+	}()
+
+	l := &lexer{}
+	l.lexFile(fname, input)
+
+	c := newCompiler(font, l.tokens)
+	c.parseFile()
+	return c.finish(), nil
+}
@@ -0,0 +1,578 @@
+// seehuhn.de/go/sfnt - a library for reading and writing font files
+// Copyright (C) 2026  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package fea
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/image/font/gofont/goregular"
+
+	"seehuhn.de/go/sfnt"
+	"seehuhn.de/go/sfnt/glyph"
+	"seehuhn.de/go/sfnt/opentype/gdef"
+	"seehuhn.de/go/sfnt/opentype/gtab"
+	"seehuhn.de/go/sfnt/parser"
+)
+
+func loadFont(t *testing.T) *sfnt.Font {
+	t.Helper()
+	f, err := sfnt.Read(bytes.NewReader(goregular.TTF), parser.NewBudget(int64(len(goregular.TTF))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+// apply runs the lookups of the feature "test" on a sequence of glyphs,
+// given as a space-separated list of glyph names.
+func apply(t *testing.T, f *sfnt.Font, tables *Tables, tp gtab.Type, names string) []glyph.Info {
+	t.Helper()
+
+	byName := make(map[string]glyph.ID)
+	for gid := glyph.ID(0); gid < glyph.ID(f.NumGlyphs()); gid++ {
+		byName[f.GlyphName(gid)] = gid
+	}
+	var seq []glyph.Info
+	for _, name := range strings.Fields(names) {
+		gid, ok := byName[name]
+		if !ok {
+			t.Fatalf("unknown glyph %q", name)
+		}
+		seq = append(seq, glyph.Info{GID: gid, Advance: 500})
+	}
+
+	info := tables.Gsub
+	if tp == gtab.TypeGpos {
+		info = tables.Gpos
+	}
+	var lookups []gtab.LookupIndex
+	for _, feat := range info.FeatureList {
+		if feat.Tag == "test" {
+			lookups = append(lookups, feat.Lookups...)
+		}
+	}
+	ctx := gtab.NewContext(info.LookupList, tables.Gdef, lookups)
+	return ctx.Apply(seq)
+}
+
+func glyphNames(f *sfnt.Font, seq []glyph.Info) string {
+	var names []string
+	for _, g := range seq {
+		names = append(names, f.GlyphName(g.GID))
+	}
+	return strings.Join(names, " ")
+}
+
+func TestSubstitutions(t *testing.T) {
+	f := loadFont(t)
+
+	cases := []struct {
+		name   string
+		rules  string
+		in     string
+		out    string
+		lookup uint16 // expected type of the first lookup of the feature
+	}{
+		{"single", "sub a by b;", "a c a", "b c b", 1},
+		{"class", "sub [a-c] by [A-C];", "a b c d", "A B C d", 1},
+		{"named class", "@LC = [x y z]; sub @LC by X;", "x y a z", "X X a X", 1},
+		{"multiple", "sub f by f i;", "a f", "a f i", 2},
+		{"alternate", "sub a from [b c];", "a", "b", 3},
+		{"ligature", "sub f i by A; sub f f i by B;", "f f i f i", "B A", 4},
+		{"ligature class", "sub [f F] [i I] by A;", "F i f I", "A A", 4},
+		{"context inline", "sub a' b by c;", "a b a c", "c b a c", 6},
+		{"context ligature", "sub x a' b' by c;", "a b x a b", "a b x c", 6},
+		{"context multiple", "sub [a b] c' by x y;", "a c d c", "a x y d c", 6},
+		{"ignore", "ignore sub x a'; sub a' b by c;", "x a b a b", "x a b c b", 6},
+		{"lookup ref", "lookup L { sub a by z; } L;\nfeature test { sub a' lookup L b; } test;", "a b a", "z b a", 6},
+		{"reverse", "rsub a' b by c;", "a a b", "a c b", 8},
+		{"reverse class", "rsub [a b]' [c d] by [A B];", "a c b d a", "A c B d a", 8},
+		{
+			"lookupflag",
+			"table GDEF { GlyphClassDef [a-z], [A-Z], [acute grave], ; } GDEF;\n" +
+				"feature test { lookupflag IgnoreMarks; sub f i by A; } test;",
+			"f acute i", "A acute", 4,
+		},
+	}
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			input := test.rules
+			if !strings.Contains(input, "feature test") {
+				input = "feature test {\n" + input + "\n} test;\n"
+			}
+			tables, err := Compile(f, input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tables.Gsub == nil || tables.Gpos != nil {
+				t.Fatal("wrong tables")
+			}
+			idx := tables.Gsub.FeatureList[0].Lookups[0]
+			if tp := tables.Gsub.LookupList[idx].Meta.LookupType; tp != test.lookup {
+				t.Errorf("wrong lookup type %d", tp)
+			}
+
+			out := glyphNames(f, apply(t, f, tables, gtab.TypeGsub, test.in))
+			if out != test.out {
+				t.Errorf("got %q, want %q", out, test.out)
+			}
+		})
+	}
+}
+
+func TestPositioning(t *testing.T) {
+	f := loadFont(t)
+
+	type pos struct {
+		Advance, XOffset, YOffset int
+	}
+	cases := []struct {
+		name  string
+		rules string
+		in    string
+		out   []pos
+	}{
+		{"single", "pos a 10;", "a b", []pos{{510, 0, 0}, {500, 0, 0}}},
+		{"single record", "pos [a b] <1 2 3 0>;", "a b", []pos{{503, 1, 2}, {503, 1, 2}}},
+		{"pair", "pos A V -80;", "A V A A", []pos{{420, 0, 0}, {500, 0, 0}, {500, 0, 0}, {500, 0, 0}}},
+		{"pair both", "pos A <0 0 -10 0> V <5 0 0 0>;", "A V", []pos{{490, 0, 0}, {500, 5, 0}}},
+		{"pair class", "pos [A T] [o e] -50;", "T o A e o", []pos{{450, 0, 0}, {500, 0, 0}, {450, 0, 0}, {500, 0, 0}, {500, 0, 0}}},
+		{"pair classes", "@L = [A T]; @R = [o e]; pos @L @R -50; pos V @R -30;", "V e T o", []pos{{470, 0, 0}, {500, 0, 0}, {450, 0, 0}, {500, 0, 0}}},
+		{"pair glyph first", "pos T o -100; pos [T] [o e] -50;", "T o T e", []pos{{400, 0, 0}, {500, 0, 0}, {450, 0, 0}, {500, 0, 0}}},
+		{"enum", "enum pos [A T] o -20;", "T o", []pos{{480, 0, 0}, {500, 0, 0}}},
+		{"vertical named", "valueRecordDef <0 0 7 0> SEVEN; pos a <SEVEN>;", "a", []pos{{507, 0, 0}}},
+		{"context", "pos x a' 20 b;", "x a b a", []pos{{500, 0, 0}, {520, 0, 0}, {500, 0, 0}, {500, 0, 0}}},
+		{"context lookup", "lookup K { pos a 30; } K;\nfeature test { pos a' lookup K b; } test;", "a b a", []pos{{530, 0, 0}, {500, 0, 0}, {500, 0, 0}}},
+	}
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			input := test.rules
+			if !strings.Contains(input, "feature test") {
+				input = "feature test {\n" + input + "\n} test;\n"
+			}
+			tables, err := Compile(f, input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tables.Gpos == nil || tables.Gsub != nil {
+				t.Fatal("wrong tables")
+			}
+
+			var out []pos
+			for _, g := range apply(t, f, tables, gtab.TypeGpos, test.in) {
+				out = append(out, pos{int(g.Advance), int(g.XOffset), int(g.YOffset)})
+			}
+			if d := cmp.Diff(test.out, out); d != "" {
+				t.Error(d)
+			}
+		})
+	}
+}
+
+func TestMarkAttachment(t *testing.T) {
+	f := loadFont(t)
+	input := `
+markClass [acute grave] <anchor 100 500> @TOP;
+markClass cedilla <anchor 100 0> @BOTTOM;
+feature mark {
+	pos base [a e] <anchor 250 450> mark @TOP <anchor 250 0> mark @BOTTOM;
+	pos base c <anchor 260 460> mark @TOP;
+} mark;
+feature mkmk {
+	pos mark acute <anchor 100 700> mark @TOP;
+} mkmk;
+feature test {
+	pos ligature A <anchor 200 600> mark @TOP ligComponent <anchor NULL> ligComponent <anchor 800 600> mark @TOP;
+} test;
+feature curs {
+	pos cursive [a b] <anchor 0 100> <anchor 500 100>;
+	pos cursive c <anchor NULL> <anchor 500 120>;
+} curs;
+`
+	tables, err := Compile(f, input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gid := func(name string) glyph.ID {
+		for g := glyph.ID(0); g < glyph.ID(f.NumGlyphs()); g++ {
+			if f.GlyphName(g) == name {
+				return g
+			}
+		}
+		t.Fatalf("unknown glyph %q", name)
+		return 0
+	}
+
+	ll := tables.Gpos.LookupList
+	if len(ll) != 4 {
+		t.Fatalf("got %d lookups, want 4", len(ll))
+	}
+
+	base := ll[0].Subtables[0].(*gtab.Gpos4_1)
+	if len(base.MarkArray) != 3 || len(base.BaseArray) != 3 {
+		t.Fatal("wrong mark-to-base subtable")
+	}
+	rec := base.MarkArray[base.MarkCov[gid("cedilla")]]
+	if rec.Class != 1 || rec.X != 100 || rec.Y != 0 {
+		t.Errorf("wrong mark record %v", rec)
+	}
+	anchors := base.BaseArray[base.BaseCov[gid("c")]]
+	if anchors[0] == nil || anchors[0].X != 260 || anchors[1] != nil {
+		t.Errorf("wrong base anchors %v", anchors)
+	}
+
+	mkmk := ll[1].Subtables[0].(*gtab.Gpos6_1)
+	if len(mkmk.Mark1Array) != 2 || len(mkmk.Mark2Array) != 1 {
+		t.Error("wrong mark-to-mark subtable")
+	}
+
+	lig := ll[2].Subtables[0].(*gtab.Gpos5_1)
+	comps := lig.LigArray[lig.LigCov[gid("A")]]
+	if len(comps) != 3 || comps[0][0].X != 200 || comps[1][0] != nil || comps[2][0].X != 800 {
+		t.Errorf("wrong ligature anchors %v", comps)
+	}
+
+	curs := ll[3].Subtables[0].(*gtab.Gpos3_1)
+	r := curs.Records[curs.Cov[gid("c")]]
+	if r.Entry != nil || r.Exit == nil || r.Exit.Y != 120 {
+		t.Errorf("wrong cursive record %v", r)
+	}
+
+	if tables.Gdef == nil {
+		t.Fatal("missing GDEF table")
+	}
+	for name, class := range map[string]uint16{
+		"acute":   gdef.GlyphClassMark,
+		"cedilla": gdef.GlyphClassMark,
+		"a":       gdef.GlyphClassBase,
+		"A":       gdef.GlyphClassLigature,
+	} {
+		if got := tables.Gdef.GlyphClass[gid(name)]; got != class {
+			t.Errorf("%s: got glyph class %d, want %d", name, got, class)
+		}
+	}
+}
+
+func TestLanguageSystems(t *testing.T) {
+	f := loadFont(t)
+	input := `
+languagesystem DFLT dflt;
+languagesystem latn dflt;
+languagesystem latn TRK;
+
+feature liga {
+	sub f i by A;
+	script latn;
+	language TRK exclude_dflt;
+	sub f l by B;
+	language DEU;
+	sub f f by C;
+} liga;
+
+feature locl {
+	script latn;
+	language TRK required;
+	sub i by dotlessi;
+} locl;
+
+feature smcp {
+	sub a by A;
+	script latn;
+	language DEU exclude_dflt required;
+} smcp;
+`
+	tables, err := Compile(f, input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := tables.Gsub
+
+	var features []string
+	for _, feat := range info.FeatureList {
+		features = append(features, fmt.Sprintf("%s %v", feat.Tag, feat.Lookups))
+	}
+	wantFeatures := []string{"liga [0]", "liga [1]", "liga [0 2]", "locl [3]", "smcp [4]", "smcp []"}
+	if d := cmp.Diff(wantFeatures, features); d != "" {
+		t.Error(d)
+	}
+
+	check := func(script, lang string, required gtab.FeatureIndex, optional ...gtab.FeatureIndex) {
+		t.Helper()
+		tag, err := gtab.LanguageTag(script, lang)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := info.ScriptList[tag]
+		want := &gtab.Features{Required: required, Optional: optional}
+		if d := cmp.Diff(want, got); d != "" {
+			t.Errorf("%s/%s: %s", script, lang, d)
+		}
+	}
+	check("DFLT", "dflt", 0xFFFF, 0, 4)
+	check("latn", "dflt", 0xFFFF, 0, 4)
+	check("latn", "TRK", 3, 1, 4)
+	check("latn", "DEU", 5, 2)
+}
+
+func TestFeatureParams(t *testing.T) {
+	f := loadFont(t)
+	input := `
+feature size {
+	parameters 10.0 3 80 139;
+	sizemenuname "Text";
+	sizemenuname 1 "Text";
+} size;
+
+feature ss01 {
+	featureNames {
+		name "Alternate a";
+		name 3 1 0x0407 "Alternativ-\00e4";
+	};
+	sub a by A;
+} ss01;
+
+feature cv01 {
+	cvParameters {
+		FeatUILabelNameID { name "Variant"; };
+		ParamUILabelNameID { name "One"; };
+		ParamUILabelNameID { name "Two"; };
+		Character 0x61;
+	};
+	sub a from [A B];
+} cv01;
+`
+	tables, err := Compile(f, input)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tables.Gpos == nil || len(tables.Gpos.FeatureList) != 1 {
+		t.Fatal("missing size feature")
+	}
+	size := tables.Gpos.FeatureList[0].Params
+	wantSize := &gtab.FeatureParamsSize{
+		DesignSize:      100,
+		SubfamilyID:     3,
+		SubfamilyNameID: 256,
+		RangeStart:      80,
+		RangeEnd:        139,
+	}
+	if d := cmp.Diff(wantSize, size); d != "" {
+		t.Error(d)
+	}
+
+	var cv01, ss01 gtab.FeatureParams
+	for _, feat := range tables.Gsub.FeatureList {
+		switch feat.Tag {
+		case "cv01":
+			cv01 = feat.Params
+		case "ss01":
+			ss01 = feat.Params
+		}
+	}
+	if d := cmp.Diff(&gtab.FeatureParamsStylisticSet{UINameID: 257}, ss01); d != "" {
+		t.Error(d)
+	}
+	wantCV := &gtab.FeatureParamsCharacterVariants{
+		FeatUILabelNameID:       258,
+		NumNamedParameters:      2,
+		FirstParamUILabelNameID: 259,
+		Characters:              []rune{'a'},
+	}
+	if d := cmp.Diff(wantCV, cv01); d != "" {
+		t.Error(d)
+	}
+
+	wantNames := []NameRecord{
+		{3, 1, 0x0409, 256, "Text"},
+		{1, 0, 0, 256, "Text"},
+		{3, 1, 0x0409, 257, "Alternate a"},
+		{3, 1, 0x0407, 257, "Alternativ-\u00e4"},
+		{3, 1, 0x0409, 258, "Variant"},
+		{3, 1, 0x0409, 259, "One"},
+		{3, 1, 0x0409, 260, "Two"},
+	}
+	if d := cmp.Diff(wantNames, tables.Names); d != "" {
+		t.Error(d)
+	}
+}
+
+func TestAalt(t *testing.T) {
+	f := loadFont(t)
+	input := `
+feature aalt {
+	feature smcp;
+	feature salt;
+} aalt;
+feature smcp {
+	sub [a b] by [A B];
+} smcp;
+feature salt {
+	sub a from [Alpha Aacute];
+	sub c by C;
+} salt;
+`
+	tables, err := Compile(f, input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ll := tables.Gsub.LookupList
+	if len(ll) != 5 || ll[0].Meta.LookupType != 1 || ll[1].Meta.LookupType != 3 {
+		t.Fatal("wrong aalt lookups")
+	}
+	alt := ll[1].Subtables[0].(*gtab.Gsub3_1)
+	if len(alt.Alternates) != 1 || len(alt.Alternates[0]) != 3 {
+		t.Errorf("wrong alternates %v", alt.Alternates)
+	}
+}
+
+func TestEncode(t *testing.T) {
+	f := loadFont(t)
+	input := `
+languagesystem DFLT dflt;
+languagesystem latn dflt;
+@LC = [a-z];
+@UC = [A-Z];
+markClass [acute grave] <anchor 100 500> @TOP;
+feature smcp { sub @LC by @UC; } smcp;
+feature liga { sub f i by A; sub f f i by B; } liga;
+feature calt { sub a' [b c] by x; ignore sub x y'; } calt;
+feature kern {
+	pos A V -80;
+	pos @UC @LC <0 0 -10 0 <device 11 -1, 12 -2> <device NULL> <device NULL> <device NULL>>;
+} kern;
+feature mark {
+	lookupflag UseMarkFilteringSet [acute];
+	pos base @LC <anchor 250 450 contourpoint 3> mark @TOP;
+} mark;
+table GDEF {
+	GlyphClassDef @LC, , [acute grave], ;
+	Attach a 3 5;
+	LigatureCaretByPos A 200 400;
+} GDEF;
+`
+	tables, err := Compile(f, input)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for tp, info := range map[gtab.Type]*gtab.Info{gtab.TypeGsub: tables.Gsub, gtab.TypeGpos: tables.Gpos} {
+		data := info.Encode()
+		info2, err := gtab.Read(bytes.NewReader(data), parser.NewBudget(int64(len(data))), tp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d := cmp.Diff(info, info2); d != "" {
+			t.Errorf("%s: %s", tp, d)
+		}
+	}
+
+	data := tables.Gdef.Encode()
+	gdef2, err := gdef.Read(bytes.NewReader(data), parser.NewBudget(int64(len(data))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := cmp.Diff(tables.Gdef, gdef2); d != "" {
+		t.Error(d)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	f := loadFont(t)
+
+	dir := t.TempDir()
+	inc := filepath.Join(dir, "classes.fea")
+	err := os.WriteFile(inc, []byte("@A = [a b];\n@B = [c nosuchglyph];\n"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	main := filepath.Join(dir, "main.fea")
+	err = os.WriteFile(main, []byte("# test\ninclude(classes.fea);\n"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = CompileFile(f, main)
+	var feaErr *Error
+	if !errors.As(err, &feaErr) {
+		t.Fatalf("wrong error %v", err)
+	}
+	if feaErr.File != inc || feaErr.Line != 2 {
+		t.Errorf("wrong error location %s:%d", feaErr.File, feaErr.Line)
+	}
+
+	cases := []struct {
+		input string
+		line  int
+	}{
+		{"feature liga {\n  sub f i by fi;\n} liga;\n", 2},
+		{"feature liga {\n  sub f i by A;\n} kern;\n", 3},
+		{"lookup A {\n  sub a by b;\n  sub f i by A;\n} A;\n", 3},
+		{"feature test {\n\n  pos a b;\n} test;\n", 3},
+		{"feature test {\n  sub a' lookup X b;\n} test;\n", 2},
+		{"feature test { sub [a b] by [A B C]; } test;\n", 1},
+		{"feature test {\n  sub a by b;\n  sub a by c;\n} test;\n", 3},
+		{"languagesystem xxxx dflt;\n", 1},
+		{"feature test {\n  sub a by NULL;\n} test;\n", 2},
+		{"feature test {\n  sub a by b;\n", 3},
+	}
+	for _, test := range cases {
+		_, err := Compile(f, test.input)
+		if !errors.As(err, &feaErr) {
+			t.Errorf("%q: wrong error %v", test.input, err)
+			continue
+		}
+		if feaErr.Line != test.line {
+			t.Errorf("%q: error %q in line %d, want line %d", test.input, err, feaErr.Line, test.line)
+		}
+	}
+}
+
+func TestNameRange(t *testing.T) {
+	cases := []struct {
+		first, last string
+		want        []string
+	}{
+		{"a", "d", []string{"a", "b", "c", "d"}},
+		{"A.sc", "C.sc", []string{"A.sc", "B.sc", "C.sc"}},
+		{"cid00008", "cid00011", []string{"cid00008", "cid00009", "cid00010", "cid00011"}},
+		{"x9", "x11", []string{"x9", "x10", "x11"}},
+	}
+	for _, test := range cases {
+		got, ok := nameRange(test.first, test.last)
+		if !ok {
+			t.Errorf("%s-%s: invalid range", test.first, test.last)
+			continue
+		}
+		if d := cmp.Diff(test.want, got); d != "" {
+			t.Errorf("%s-%s: %s", test.first, test.last, d)
+		}
+	}
+
+	for _, bad := range [][2]string{{"a", "B"}, {"d", "a"}, {"ab", "cd"}} {
+		if _, ok := nameRange(bad[0], bad[1]); ok {
+			t.Errorf("%s-%s: expected error", bad[0], bad[1])
+		}
+	}
+}
@@ -0,0 +1,259 @@
+// seehuhn.de/go/sfnt - a library for reading and writing font files
+// Copyright (C) 2026  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package fea
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"seehuhn.de/go/sfnt/glyph"
+)
+
+// parseGlyphOrClass parses a single glyph, a glyph class literal, or a named
+// glyph class.  The second return value indicates whether a class was read.
+// The glyphs of a class are returned in the order given in the feature file.
+func (c *compiler) parseGlyphOrClass() ([]glyph.ID, bool) {
+	t := c.peek()
+	switch {
+	case t.isSym("["):
+		return c.parseClassLiteral(), true
+	case t.typ == tokClass:
+		c.next()
+		return c.namedClass(t), true
+	case t.typ == tokName || t.typ == tokCID:
+		c.next()
+		return []glyph.ID{c.glyph(t)}, false
+	}
+	c.fail(t, "expected glyph or glyph class, got %s", t)
+	return nil, false
+}
+
+// parseClass parses a glyph class literal or a named glyph class.
+func (c *compiler) parseClass() []glyph.ID {
+	t := c.peek()
+	if !t.isSym("[") && t.typ != tokClass {
+		c.fail(t, "expected glyph class, got %s", t)
+	}
+	gg, _ := c.parseGlyphOrClass()
+	return gg
+}
+
+func (c *compiler) namedClass(t token) []glyph.ID {
+	if gg, ok := c.classes[t.val]; ok {
+		return gg
+	}
+	if mc, ok := c.markClasses[t.val]; ok {
+		return mc.glyphs
+	}
+	c.fail(t, "undefined glyph class @%s", t.val)
+	return nil
+}
+
+func (c *compiler) parseClassLiteral() []glyph.ID {
+	c.expectSym("[")
+	var res []glyph.ID
+	for {
+		t := c.next()
+		switch {
+		case t.isSym("]"):
+			return res
+		case t.typ == tokClass:
+			res = append(res, c.namedClass(t)...)
+		case t.typ == tokName || t.typ == tokCID:
+			if c.peek().isSym("-") {
+				c.next()
+				last := c.next()
+				res = append(res, c.glyphRange(t, last)...)
+				continue
+			}
+			if t.typ == tokName && strings.Contains(t.val, "-") {
+				if _, ok := c.lookupName(t.val); !ok {
+					res = append(res, c.hyphenRange(t)...)
+					continue
+				}
+			}
+			res = append(res, c.glyph(t))
+		default:
+			c.fail(t, "unexpected %s in glyph class", t)
+		}
+	}
+}
+
+// glyph returns the glyph ID for a glyph name or a glyph ID token.
+func (c *compiler) glyph(t token) glyph.ID {
+	if t.typ == tokCID {
+		x, err := strconv.Atoi(t.val)
+		if err != nil || x >= c.numGlyphs {
+			c.fail(t, "invalid glyph ID \\%s", t.val)
+		}
+		return glyph.ID(x)
+	}
+	if t.typ != tokName {
+		c.fail(t, "expected glyph, got %s", t)
+	}
+	gid, ok := c.lookupName(t.val)
+	if !ok {
+		c.fail(t, "unknown glyph %q", strings.TrimPrefix(t.val, `\`))
+	}
+	return gid
+}
+
+func (c *compiler) lookupName(name string) (glyph.ID, bool) {
+	gid, ok := c.byName[strings.TrimPrefix(name, `\`)]
+	return gid, ok
+}
+
+// hyphenRange interprets a glyph name which contains hyphens, like "a-z",
+// as a glyph range.
+func (c *compiler) hyphenRange(t token) []glyph.ID {
+	name := t.val
+	for i := 0; i < len(name); i++ {
+		if name[i] != '-' {
+			continue
+		}
+		first, last := name[:i], name[i+1:]
+		if _, ok := c.lookupName(first); !ok {
+			continue
+		}
+		if _, ok := c.lookupName(last); !ok {
+			continue
+		}
+		return c.glyphRange(token{typ: tokName, val: first, file: t.file, line: t.line},
+			token{typ: tokName, val: last, file: t.file, line: t.line})
+	}
+	c.fail(t, "unknown glyph %q", strings.TrimPrefix(name, `\`))
+	return nil
+}
+
+// glyphRange returns the glyphs in a range like "a - z", "a.sc - z.sc",
+// "cid00010 - cid00020" or "\10 - \20".
+func (c *compiler) glyphRange(first, last token) []glyph.ID {
+	if first.typ == tokCID && last.typ == tokCID {
+		a, b := c.glyph(first), c.glyph(last)
+		if a > b {
+			c.fail(first, "invalid glyph range \\%s-\\%s", first.val, last.val)
+		}
+		var res []glyph.ID
+		for gid := a; gid <= b; gid++ {
+			res = append(res, gid)
+		}
+		return res
+	}
+	if first.typ != tokName || last.typ != tokName {
+		c.fail(first, "invalid glyph range")
+	}
+
+	names, ok := nameRange(strings.TrimPrefix(first.val, `\`), strings.TrimPrefix(last.val, `\`))
+	if !ok {
+		c.fail(first, "invalid glyph range %s-%s", first.val, last.val)
+	}
+	res := make([]glyph.ID, len(names))
+	for i, name := range names {
+		gid, ok := c.lookupName(name)
+		if !ok {
+			c.fail(first, "unknown glyph %q in range", name)
+		}
+		res[i] = gid
+	}
+	return res
+}
+
+// nameRange expands a range of glyph names.  The names must differ in a
+// single letter or in a decimal number.
+func nameRange(a, b string) ([]string, bool) {
+	p := 0
+	for p < len(a) && p < len(b) && a[p] == b[p] {
+		p++
+	}
+	s := 0
+	for s < len(a)-p && s < len(b)-p && a[len(a)-1-s] == b[len(b)-1-s] {
+		s++
+	}
+	ma, mb := a[p:len(a)-s], b[p:len(b)-s]
+
+	if len(ma) == 1 && len(mb) == 1 && ma[0] <= mb[0] &&
+		(isLower(ma[0]) && isLower(mb[0]) || isUpper(ma[0]) && isUpper(mb[0])) {
+		var res []string
+		for x := ma[0]; x <= mb[0]; x++ {
+			res = append(res, a[:p]+string(rune(x))+a[len(a)-s:])
+		}
+		return res, true
+	}
+
+	// include all digits of the number in the differing part
+	for p > 0 && isDigit(a[p-1]) {
+		p--
+	}
+	for s > 0 && isDigit(a[len(a)-s]) {
+		s--
+	}
+	ma, mb = a[p:len(a)-s], b[p:len(b)-s]
+	if !isNumber(ma) || !isNumber(mb) {
+		return nil, false
+	}
+	x, err1 := strconv.Atoi(ma)
+	y, err2 := strconv.Atoi(mb)
+	if err1 != nil || err2 != nil || x > y || y-x > 65535 {
+		return nil, false
+	}
+	width := 0
+	if len(ma) == len(mb) {
+		width = len(ma)
+	}
+	var res []string
+	for i := x; i <= y; i++ {
+		res = append(res, fmt.Sprintf("%s%0*d%s", a[:p], width, i, a[len(a)-s:]))
+	}
+	return res, true
+}
+
+func isNumber(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !isDigit(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func isLower(c byte) bool {
+	return c >= 'a' && c <= 'z'
+}
+
+func isUpper(c byte) bool {
+	return c >= 'A' && c <= 'Z'
+}
+
+// glyphName returns a printable name for a glyph, for use in error messages.
+func (c *compiler) glyphName(gid glyph.ID) string {
+	if name := c.font.GlyphName(gid); name != "" {
+		return name
+	}
+	return fmt.Sprintf("\\%d", gid)
+}
+
+// sortedGlyphs returns a sorted copy of gg, with duplicates removed.
+func sortedGlyphs(gg []glyph.ID) []glyph.ID {
+	res := slices.Clone(gg)
+	slices.Sort(res)
+	return slices.Compact(res)
+}
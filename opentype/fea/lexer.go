@@ -0,0 +1,232 @@
+// seehuhn.de/go/sfnt - a library for reading and writing font files
+// Copyright (C) 2026  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package fea
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// tokenType identifies the type of lexer tokens.
+type tokenType int
+
+const (
+	tokEOF    tokenType = iota
+	tokName             // a glyph name or keyword
+	tokCID              // a glyph given by number, like \123
+	tokClass            // a glyph class name, like @UPPERCASE
+	tokNumber           // a decimal or hexadecimal number
+	tokString           // a double-quoted string, without the quotes
+	tokSymbol           // one of the characters {}[]<>();,'=-
+)
+
+type token struct {
+	typ  tokenType
+	val  string
+	file string
+	line int
+}
+
+func (t token) String() string {
+	switch t.typ {
+	case tokEOF:
+		return "end of file"
+	case tokString:
+		return fmt.Sprintf("string %q", t.val)
+	}
+	return fmt.Sprintf("%q", t.val)
+}
+
+// maxIncludeDepth limits the nesting of include statements, to catch
+// recursive includes.
+const maxIncludeDepth = 50
+
+// lexer splits a feature file into tokens.
+type lexer struct {
+	tokens []token
+	depth  int
+}
+
+// lexFile appends the tokens of the given input to l.tokens.
+// The file name is used for error messages and to resolve include
+// statements.
+func (l *lexer) lexFile(fname, input string) {
+	line := 1
+	pos := 0
+	emit := func(typ tokenType, val string) {
+		l.tokens = append(l.tokens, token{typ: typ, val: val, file: fname, line: line})
+	}
+	fail := func(format string, args ...any) {
+		panic(&Error{File: fname, Line: line, Msg: fmt.Sprintf(format, args...)})
+	}
+
+	for pos < len(input) {
+		c := input[pos]
+		switch {
+		case c == '\n':
+			line++
+			pos++
+		case c == ' ' || c == '\t' || c == '\r':
+			pos++
+		case c == '#':
+			for pos < len(input) && input[pos] != '\n' {
+				pos++
+			}
+		case c == '"':
+			end := strings.IndexByte(input[pos+1:], '"')
+			if end < 0 {
+				fail("unterminated string")
+			}
+			val := input[pos+1 : pos+1+end]
+			emit(tokString, val)
+			line += strings.Count(val, "\n")
+			pos += end + 2
+		case c == '-' && pos+1 < len(input) && isDigit(input[pos+1]):
+			end := scanNumber(input, pos+1)
+			emit(tokNumber, input[pos:end])
+			pos = end
+		case isDigit(c):
+			end := scanNumber(input, pos)
+			emit(tokNumber, input[pos:end])
+			pos = end
+		case strings.IndexByte("{}[]<>();,'=-", c) >= 0:
+			emit(tokSymbol, input[pos:pos+1])
+			pos++
+		case c == '@':
+			end := scanName(input, pos+1)
+			if end == pos+1 {
+				fail("missing class name after \"@\"")
+			}
+			emit(tokClass, input[pos+1:end])
+			pos = end
+		case c == '\\':
+			if pos+1 < len(input) && isDigit(input[pos+1]) {
+				end := pos + 1
+				for end < len(input) && isDigit(input[end]) {
+					end++
+				}
+				emit(tokCID, input[pos+1:end])
+				pos = end
+				break
+			}
+			end := scanName(input, pos+1)
+			if end == pos+1 {
+				fail("missing glyph name after \"\\\"")
+			}
+			// An escaped name is never a keyword.  We mark this by
+			// keeping the backslash.
+			emit(tokName, input[pos:end])
+			pos = end
+		case isNameStart(c):
+			end := scanName(input, pos)
+			name := input[pos:end]
+			pos = end
+			if name == "include" {
+				pos = l.include(fname, input, pos, &line)
+				break
+			}
+			emit(tokName, name)
+		default:
+			fail("unexpected character %q", c)
+		}
+	}
+	if l.depth == 0 {
+		l.tokens = append(l.tokens, token{typ: tokEOF, file: fname, line: line})
+	}
+}
+
+// include processes an include statement.  The argument pos is the position
+// after the keyword "include".  The function returns the position after the
+// statement.
+func (l *lexer) include(fname, input string, pos int, line *int) int {
+	fail := func(format string, args ...any) {
+		panic(&Error{File: fname, Line: *line, Msg: fmt.Sprintf(format, args...)})
+	}
+
+	for pos < len(input) && (input[pos] == ' ' || input[pos] == '\t') {
+		pos++
+	}
+	if pos >= len(input) || input[pos] != '(' {
+		fail("expected \"(\" after include")
+	}
+	end := strings.IndexByte(input[pos:], ')')
+	if end < 0 {
+		fail("unterminated include statement")
+	}
+	name := strings.TrimSpace(input[pos+1 : pos+end])
+	pos += end + 1
+	for pos < len(input) && (input[pos] == ' ' || input[pos] == '\t') {
+		pos++
+	}
+	if pos < len(input) && input[pos] == ';' {
+		pos++
+	}
+
+	if l.depth >= maxIncludeDepth {
+		fail("too many nested include statements")
+	}
+	if !filepath.IsAbs(name) && fname != "" {
+		name = filepath.Join(filepath.Dir(fname), name)
+	}
+	data, err := os.ReadFile(name)
+	if err != nil {
+		fail("%v", err)
+	}
+	l.depth++
+	l.lexFile(name, string(data))
+	l.depth--
+	return pos
+}
+
+func scanNumber(input string, pos int) int {
+	if strings.HasPrefix(input[pos:], "0x") || strings.HasPrefix(input[pos:], "0X") {
+		pos += 2
+		for pos < len(input) && isHexDigit(input[pos]) {
+			pos++
+		}
+		return pos
+	}
+	for pos < len(input) && (isDigit(input[pos]) || input[pos] == '.') {
+		pos++
+	}
+	return pos
+}
+
+func scanName(input string, pos int) int {
+	for pos < len(input) && isNameChar(input[pos]) {
+		pos++
+	}
+	return pos
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isHexDigit(c byte) bool {
+	return isDigit(c) || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
+
+func isNameStart(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c == '.'
+}
+
+func isNameChar(c byte) bool {
+	return isNameStart(c) || isDigit(c) || c == '-' || c == '*' || c == '+' || c == '^' || c == '|' || c == '~' || c == '/'
+}
@@ -0,0 +1,973 @@
+// seehuhn.de/go/sfnt - a library for reading and writing font files
+// Copyright (C) 2026  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package fea
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/language"
+
+	"seehuhn.de/go/postscript/funit"
+
+	"seehuhn.de/go/sfnt"
+	"seehuhn.de/go/sfnt/glyph"
+	"seehuhn.de/go/sfnt/opentype/anchor"
+	"seehuhn.de/go/sfnt/opentype/classdef"
+	"seehuhn.de/go/sfnt/opentype/gdef"
+	"seehuhn.de/go/sfnt/opentype/gtab"
+)
+
+// langSys is an OpenType script tag together with a language system tag.
+type langSys struct {
+	script, lang string
+}
+
+// feature collects the information about one feature tag.
+type feature struct {
+	tag string
+
+	// declLangs are the language systems which were in effect at the start
+	// of the first feature block for this tag.
+	declLangs []langSys
+
+	langs    []langSys // in order of first use
+	lookups  map[langSys][]*lookup
+	required map[langSys]bool
+	params   gtab.FeatureParams
+
+	aaltRefs []token // features referenced from the aalt feature
+}
+
+func (f *feature) addLookup(langs []langSys, l *lookup) {
+	for _, ls := range langs {
+		ll, seen := f.lookups[ls]
+		if !seen {
+			f.langs = append(f.langs, ls)
+		}
+		if !slices.Contains(ll, l) {
+			f.lookups[ls] = append(ll, l)
+		}
+	}
+}
+
+// markClass is a named class of marks, together with their anchors.
+type markClass struct {
+	name    string
+	glyphs  []glyph.ID // in order of definition
+	anchors map[glyph.ID]*anchor.Table
+}
+
+// compiler holds the state while a feature file is being compiled.
+type compiler struct {
+	font      *sfnt.Font
+	numGlyphs int
+	byName    map[string]glyph.ID
+
+	tokens []token
+	pos    int
+
+	classes      map[string][]glyph.ID
+	markClasses  map[string]*markClass
+	anchors      map[string]*anchor.Table
+	valueRecords map[string]*gtab.GposValueRecord
+
+	langSystems []langSys
+	langTags    map[langSys]language.Tag
+
+	lookups      []*lookup // all lookups, in order of creation
+	namedLookups map[string]*lookup
+	features     map[string]*feature
+
+	// The following fields describe the state inside feature and lookup
+	// blocks.
+	feat      *feature  // the current feature, or nil at the top level
+	block     *lookup   // the current named lookup block, or nil
+	cur       *lookup   // the lookup which receives new rules, or nil
+	script    string    // the current script tag
+	langs     []langSys // the language systems for new lookups
+	flags     gtab.LookupFlags
+	filterSet uint16
+
+	markAttachClasses [][]glyph.ID // mark attachment class i+1
+	markFilterSets    [][]glyph.ID
+
+	glyphClass classdef.Table // from the GDEF table block, or nil
+	attach     map[glyph.ID][]uint16
+	carets     map[glyph.ID][]gdef.CaretValue
+
+	names      []NameRecord
+	nextNameID uint16
+}
+
+func newCompiler(font *sfnt.Font, tokens []token) *compiler {
+	numGlyphs := font.NumGlyphs()
+	byName := make(map[string]glyph.ID)
+	for i := glyph.ID(0); i < glyph.ID(numGlyphs); i++ {
+		glyphName := font.GlyphName(i)
+		if glyphName != "" {
+			byName[glyphName] = i
+		}
+	}
+
+	return &compiler{
+		font:      font,
+		numGlyphs: numGlyphs,
+		byName:    byName,
+
+		tokens: tokens,
+
+		classes:      make(map[string][]glyph.ID),
+		markClasses:  make(map[string]*markClass),
+		anchors:      make(map[string]*anchor.Table),
+		valueRecords: make(map[string]*gtab.GposValueRecord),
+
+		langTags: make(map[langSys]language.Tag),
+
+		namedLookups: make(map[string]*lookup),
+		features:     make(map[string]*feature),
+
+		attach: make(map[glyph.ID][]uint16),
+		carets: make(map[glyph.ID][]gdef.CaretValue),
+
+		nextNameID: 256,
+	}
+}
+
+func (t token) is(keyword string) bool {
+	return t.typ == tokName && t.val == keyword
+}
+
+func (t token) isSym(sym string) bool {
+	return t.typ == tokSymbol && t.val == sym
+}
+
+func (c *compiler) peek() token {
+	return c.tokens[c.pos]
+}
+
+func (c *compiler) next() token {
+	t := c.tokens[c.pos]
+	if t.typ != tokEOF {
+		c.pos++
+	}
+	return t
+}
+
+func (c *compiler) fail(t token, format string, args ...any) {
+	panic(&Error{File: t.file, Line: t.line, Msg: fmt.Sprintf(format, args...)})
+}
+
+func (c *compiler) expectSym(sym string) token {
+	t := c.next()
+	if !t.isSym(sym) {
+		c.fail(t, "expected %q, got %s", sym, t)
+	}
+	return t
+}
+
+func (c *compiler) expectKeyword(keyword string) token {
+	t := c.next()
+	if !t.is(keyword) {
+		c.fail(t, "expected %q, got %s", keyword, t)
+	}
+	return t
+}
+
+// expectTag reads a script, language, feature or table tag.
+func (c *compiler) expectTag() (string, token) {
+	t := c.next()
+	if t.typ != tokName || strings.HasPrefix(t.val, `\`) || len(t.val) > 4 {
+		c.fail(t, "expected tag, got %s", t)
+	}
+	return t.val, t
+}
+
+// expectEnd reads the tag or name at the end of a block, followed by a
+// semicolon.
+func (c *compiler) expectEnd(name string) {
+	t := c.next()
+	if t.typ != tokName || t.val != name {
+		c.fail(t, "expected %q at end of block, got %s", name, t)
+	}
+	c.expectSym(";")
+}
+
+func (c *compiler) expectInt() int {
+	t := c.next()
+	if t.typ != tokNumber {
+		c.fail(t, "expected number, got %s", t)
+	}
+	s := t.val
+	neg := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+	base := 10
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+		s = s[2:]
+		base = 16
+	}
+	x, err := strconv.ParseInt(s, base, 32)
+	if err != nil {
+		c.fail(t, "invalid integer %s", t)
+	}
+	if neg {
+		x = -x
+	}
+	return int(x)
+}
+
+func (c *compiler) expectInt16() funit.Int16 {
+	t := c.peek()
+	x := c.expectInt()
+	if x < math.MinInt16 || x > math.MaxInt16 {
+		c.fail(t, "value %d out of range", x)
+	}
+	return funit.Int16(x)
+}
+
+func (c *compiler) expectUint16() uint16 {
+	t := c.peek()
+	x := c.expectInt()
+	if x < 0 || x > math.MaxUint16 {
+		c.fail(t, "value %d out of range", x)
+	}
+	return uint16(x)
+}
+
+// expectDecipoints reads a point size for the size feature.  Integers are
+// interpreted as decipoints, numbers with a decimal point as points.
+func (c *compiler) expectDecipoints() uint16 {
+	t := c.next()
+	if t.typ != tokNumber {
+		c.fail(t, "expected number, got %s", t)
+	}
+	if !strings.Contains(t.val, ".") {
+		c.pos--
+		return c.expectUint16()
+	}
+	x, err := strconv.ParseFloat(t.val, 64)
+	if err != nil || x < 0 || x*10 > math.MaxUint16 {
+		c.fail(t, "invalid point size %s", t)
+	}
+	return uint16(math.Round(x * 10))
+}
+
+// parseFile parses the top-level statements of a feature file.
+func (c *compiler) parseFile() {
+	for {
+		t := c.peek()
+		switch {
+		case t.typ == tokEOF:
+			return
+		case t.isSym(";"):
+			c.next()
+		case t.is("languagesystem"):
+			c.parseLanguageSystem()
+		case t.typ == tokClass:
+			c.parseClassDef()
+		case t.is("markClass"):
+			c.parseMarkClass()
+		case t.is("anchorDef"):
+			c.parseAnchorDef()
+		case t.is("valueRecordDef"):
+			c.parseValueRecordDef()
+		case t.is("lookup"):
+			c.parseLookup()
+		case t.is("feature"):
+			c.parseFeature()
+		case t.is("table"):
+			c.parseTable()
+		default:
+			c.fail(t, "unexpected %s", t)
+		}
+	}
+}
+
+// parseStatements parses the statements inside a feature or lookup block,
+// up to the closing brace.
+func (c *compiler) parseStatements() {
+	for {
+		t := c.peek()
+		switch {
+		case t.isSym("}"):
+			return
+		case t.typ == tokEOF:
+			c.fail(t, "unexpected end of file")
+		case t.isSym(";"):
+			c.next()
+		case t.typ == tokClass:
+			c.parseClassDef()
+		case t.is("markClass"):
+			c.parseMarkClass()
+		case t.is("anchorDef"):
+			c.parseAnchorDef()
+		case t.is("valueRecordDef"):
+			c.parseValueRecordDef()
+		case t.is("script"):
+			c.parseScript()
+		case t.is("language"):
+			c.parseLanguage()
+		case t.is("lookupflag"):
+			c.parseLookupFlag()
+		case t.is("lookup"):
+			c.parseLookup()
+		case t.is("subtable"):
+			c.next()
+			c.expectSym(";")
+			if c.cur != nil {
+				c.cur.breakSubtable()
+			}
+		case t.is("sub") || t.is("substitute"):
+			c.parseSub(false)
+		case t.is("rsub") || t.is("reversesub"):
+			c.parseSub(true)
+		case t.is("pos") || t.is("position"):
+			c.parsePos(false)
+		case t.is("enum") || t.is("enumerate"):
+			c.next()
+			if t := c.peek(); !t.is("pos") && !t.is("position") {
+				c.fail(t, "expected \"pos\" after \"enum\", got %s", t)
+			}
+			c.parsePos(true)
+		case t.is("ignore"):
+			c.parseIgnore()
+		case t.is("feature"):
+			c.parseFeatureRef()
+		case t.is("parameters"):
+			c.parseSizeParameters()
+		case t.is("sizemenuname"):
+			c.parseSizeMenuName()
+		case t.is("featureNames"):
+			c.parseFeatureNames()
+		case t.is("cvParameters"):
+			c.parseCVParameters()
+		default:
+			c.fail(t, "unexpected %s", t)
+		}
+	}
+}
+
+func (c *compiler) parseLanguageSystem() {
+	t := c.next()
+	if len(c.features) > 0 {
+		c.fail(t, "languagesystem statements must precede all feature blocks")
+	}
+	script, _ := c.expectTag()
+	lang, langTok := c.expectTag()
+	c.expectSym(";")
+
+	ls := langSys{script: script, lang: lang}
+	c.checkLangSys(langTok, ls)
+	if !slices.Contains(c.langSystems, ls) {
+		c.langSystems = append(c.langSystems, ls)
+	}
+}
+
+// checkLangSys makes sure that ls can be represented in a ScriptList.
+func (c *compiler) checkLangSys(t token, ls langSys) {
+	if _, ok := c.langTags[ls]; ok {
+		return
+	}
+	tag, err := gtab.LanguageTag(ls.script, ls.lang)
+	if err != nil {
+		c.fail(t, "unsupported language system %s/%s: %v", ls.script, ls.lang, err)
+	}
+	c.langTags[ls] = tag
+}
+
+func (c *compiler) defaultLangs() []langSys {
+	if len(c.langSystems) == 0 {
+		ls := langSys{script: "DFLT", lang: "dflt"}
+		c.checkLangSys(c.peek(), ls)
+		return []langSys{ls}
+	}
+	return c.langSystems
+}
+
+func (c *compiler) parseClassDef() {
+	t := c.next()
+	c.expectSym("=")
+	gg := c.parseClass()
+	c.expectSym(";")
+
+	if _, isMark := c.markClasses[t.val]; isMark {
+		c.fail(t, "@%s is already defined as a mark class", t.val)
+	}
+	c.classes[t.val] = gg
+}
+
+func (c *compiler) parseMarkClass() {
+	c.next()
+	gg, _ := c.parseGlyphOrClass()
+	a := c.parseAnchor()
+	t := c.next()
+	if t.typ != tokClass {
+		c.fail(t, "expected mark class name, got %s", t)
+	}
+	c.expectSym(";")
+	if a == nil {
+		c.fail(t, "mark class anchors cannot be NULL")
+	}
+
+	mc := c.markClasses[t.val]
+	if mc == nil {
+		if _, isClass := c.classes[t.val]; isClass {
+			c.fail(t, "@%s is already defined as a glyph class", t.val)
+		}
+		mc = &markClass{
+			name:    t.val,
+			anchors: make(map[glyph.ID]*anchor.Table),
+		}
+		c.markClasses[t.val] = mc
+	}
+	for _, gid := range gg {
+		if old, ok := mc.anchors[gid]; ok {
+			if !anchorEqual(old, a) {
+				c.fail(t, "glyph %s is already in mark class @%s", c.glyphName(gid), t.val)
+			}
+			continue
+		}
+		mc.glyphs = append(mc.glyphs, gid)
+		mc.anchors[gid] = a
+	}
+}
+
+func (c *compiler) parseAnchorDef() {
+	c.next()
+	x := c.expectInt16()
+	y := c.expectInt16()
+	a := &anchor.Table{X: x, Y: y}
+	if c.peek().is("contourpoint") {
+		c.next()
+		cp := c.expectUint16()
+		a.ContourPoint = &cp
+	}
+	t := c.next()
+	if t.typ != tokName {
+		c.fail(t, "expected anchor name, got %s", t)
+	}
+	c.expectSym(";")
+	c.anchors[t.val] = a
+}
+
+func (c *compiler) parseValueRecordDef() {
+	c.next()
+	vr := c.parseValueRecord()
+	t := c.next()
+	if t.typ != tokName {
+		c.fail(t, "expected value record name, got %s", t)
+	}
+	c.expectSym(";")
+	c.valueRecords[t.val] = vr
+}
+
+// parseLookup parses a named lookup block, or a lookup reference inside
+// a feature block.
+func (c *compiler) parseLookup() {
+	start := c.next()
+	nameTok := c.next()
+	if nameTok.typ != tokName {
+		c.fail(nameTok, "expected lookup name, got %s", nameTok)
+	}
+	name := nameTok.val
+
+	if c.peek().isSym(";") {
+		c.next()
+		if c.feat == nil || c.block != nil {
+			c.fail(start, "lookup references are only allowed inside feature blocks")
+		}
+		c.feat.addLookup(c.langs, c.namedLookup(nameTok))
+		c.cur = nil
+		return
+	}
+
+	if c.block != nil {
+		c.fail(start, "lookup blocks cannot be nested")
+	}
+	if c.peek().is("useExtension") {
+		c.next()
+	}
+	c.expectSym("{")
+	if _, dup := c.namedLookups[name]; dup {
+		c.fail(nameTok, "duplicate lookup %q", name)
+	}
+	l := c.newLookup(name, nameTok)
+	c.namedLookups[name] = l
+
+	flags, filterSet := c.flags, c.filterSet
+	c.block, c.cur = l, l
+	c.parseStatements()
+	c.expectSym("}")
+	c.expectEnd(name)
+	c.block, c.cur = nil, nil
+	c.flags, c.filterSet = flags, filterSet
+
+	if c.feat != nil {
+		c.feat.addLookup(c.langs, l)
+	}
+}
+
+func (c *compiler) namedLookup(t token) *lookup {
+	l, ok := c.namedLookups[t.val]
+	if !ok {
+		c.fail(t, "undefined lookup %q", t.val)
+	}
+	return l
+}
+
+func (c *compiler) parseFeature() {
+	c.next()
+	tag, _ := c.expectTag()
+	if c.peek().is("useExtension") {
+		c.next()
+	}
+	c.expectSym("{")
+
+	f := c.features[tag]
+	if f == nil {
+		f = &feature{
+			tag:       tag,
+			declLangs: c.defaultLangs(),
+			lookups:   make(map[langSys][]*lookup),
+			required:  make(map[langSys]bool),
+		}
+		c.features[tag] = f
+	}
+	c.feat = f
+	c.script = "DFLT"
+	c.langs = c.defaultLangs()
+	c.flags, c.filterSet = 0, 0
+	c.cur = nil
+
+	c.parseStatements()
+	c.expectSym("}")
+	c.expectEnd(tag)
+
+	c.feat, c.cur = nil, nil
+	c.flags, c.filterSet = 0, 0
+}
+
+// inFeature makes sure that the statement starting with t is inside
+// a feature block, but not inside a lookup block.
+func (c *compiler) inFeature(t token) {
+	if c.feat == nil || c.block != nil {
+		c.fail(t, "%q is only allowed inside feature blocks", t.val)
+	}
+}
+
+func (c *compiler) parseScript() {
+	t := c.next()
+	c.inFeature(t)
+	script, scriptTok := c.expectTag()
+	c.expectSym(";")
+
+	ls := langSys{script: script, lang: "dflt"}
+	c.checkLangSys(scriptTok, ls)
+	c.script = script
+	c.langs = []langSys{ls}
+	c.flags, c.filterSet = 0, 0
+	c.cur = nil
+}
+
+func (c *compiler) parseLanguage() {
+	t := c.next()
+	c.inFeature(t)
+	lang, langTok := c.expectTag()
+	includeDflt := true
+	required := false
+	for {
+		t := c.peek()
+		if t.is("exclude_dflt") || t.is("excludeDFLT") {
+			includeDflt = false
+		} else if t.is("include_dflt") || t.is("includeDFLT") {
+			includeDflt = true
+		} else if t.is("required") {
+			required = true
+		} else {
+			break
+		}
+		c.next()
+	}
+	c.expectSym(";")
+
+	ls := langSys{script: c.script, lang: lang}
+	c.checkLangSys(langTok, ls)
+	if lang != "dflt" {
+		// Lookups registered for ls so far are replaced by the lookups of
+		// the default language system of the script, or removed.
+		var ll []*lookup
+		if includeDflt {
+			ll = slices.Clone(c.feat.lookups[langSys{script: c.script, lang: "dflt"}])
+		}
+		if _, seen := c.feat.lookups[ls]; !seen {
+			c.feat.langs = append(c.feat.langs, ls)
+		}
+		c.feat.lookups[ls] = ll
+	}
+	if required {
+		c.feat.required[ls] = true
+	}
+	c.langs = []langSys{ls}
+	c.cur = nil
+}
+
+func (c *compiler) parseLookupFlag() {
+	c.next()
+	var flags gtab.LookupFlags
+	var filterSet uint16
+	if c.peek().typ == tokNumber {
+		flags = gtab.LookupFlags(c.expectUint16())
+	} else {
+		for !c.peek().isSym(";") {
+			t := c.next()
+			switch {
+			case t.is("RightToLeft"):
+				flags |= gtab.RightToLeft
+			case t.is("IgnoreBaseGlyphs"):
+				flags |= gtab.IgnoreBaseGlyphs
+			case t.is("IgnoreLigatures"):
+				flags |= gtab.IgnoreLigatures
+			case t.is("IgnoreMarks"):
+				flags |= gtab.IgnoreMarks
+			case t.is("MarkAttachmentType"):
+				class := c.markAttachClass(c.parseClass(), t)
+				flags = flags&^gtab.MarkAttachTypeMask | gtab.LookupFlags(class)<<8
+			case t.is("UseMarkFilteringSet"):
+				flags |= gtab.UseMarkFilteringSet
+				filterSet = c.markFilterSet(c.parseClass())
+			default:
+				c.fail(t, "unknown lookup flag %s", t)
+			}
+		}
+	}
+	c.expectSym(";")
+	c.flags, c.filterSet = flags, filterSet
+}
+
+// markAttachClass returns the GDEF mark attachment class for the given
+// glyphs, allocating a new class if needed.
+func (c *compiler) markAttachClass(gg []glyph.ID, t token) uint16 {
+	gg = sortedGlyphs(gg)
+	for i, class := range c.markAttachClasses {
+		if slices.Equal(class, gg) {
+			return uint16(i + 1)
+		}
+		for _, gid := range gg {
+			if _, found := slices.BinarySearch(class, gid); found {
+				c.fail(t, "glyph %s is in more than one mark attachment class",
+					c.glyphName(gid))
+			}
+		}
+	}
+	if len(c.markAttachClasses) >= 255 {
+		c.fail(t, "too many mark attachment classes")
+	}
+	c.markAttachClasses = append(c.markAttachClasses, gg)
+	return uint16(len(c.markAttachClasses))
+}
+
+// markFilterSet returns the index of the GDEF mark glyph set for the given
+// glyphs, allocating a new set if needed.
+func (c *compiler) markFilterSet(gg []glyph.ID) uint16 {
+	gg = sortedGlyphs(gg)
+	for i, set := range c.markFilterSets {
+		if slices.Equal(set, gg) {
+			return uint16(i)
+		}
+	}
+	c.markFilterSets = append(c.markFilterSets, gg)
+	return uint16(len(c.markFilterSets) - 1)
+}
+
+// parseFeatureRef parses a "feature xxxx;" statement inside the aalt
+// feature.
+func (c *compiler) parseFeatureRef() {
+	t := c.next()
+	_, tagTok := c.expectTag()
+	c.expectSym(";")
+	if c.feat == nil || c.block != nil || c.feat.tag != "aalt" {
+		c.fail(t, "feature references are only allowed inside the aalt feature")
+	}
+	c.feat.aaltRefs = append(c.feat.aaltRefs, tagTok)
+}
+
+func (c *compiler) sizeParams(t token) *gtab.FeatureParamsSize {
+	if c.feat == nil || c.block != nil || c.feat.tag != "size" {
+		c.fail(t, "%q is only allowed inside the size feature", t.val)
+	}
+	p, _ := c.feat.params.(*gtab.FeatureParamsSize)
+	if p == nil {
+		p = &gtab.FeatureParamsSize{}
+		c.feat.params = p
+	}
+	return p
+}
+
+func (c *compiler) parseSizeParameters() {
+	t := c.next()
+	p := c.sizeParams(t)
+	p.DesignSize = c.expectDecipoints()
+	p.SubfamilyID = c.expectUint16()
+	if !c.peek().isSym(";") {
+		p.RangeStart = c.expectDecipoints()
+		p.RangeEnd = c.expectDecipoints()
+	}
+	c.expectSym(";")
+}
+
+func (c *compiler) parseSizeMenuName() {
+	t := c.next()
+	p := c.sizeParams(t)
+	if p.SubfamilyNameID == 0 {
+		p.SubfamilyNameID = c.allocNameID()
+	}
+	c.parseNameEntry(p.SubfamilyNameID)
+}
+
+func (c *compiler) parseFeatureNames() {
+	t := c.next()
+	if c.feat == nil || c.block != nil || !isStylisticSet(c.feat.tag) {
+		c.fail(t, "featureNames is only allowed inside ssXX features")
+	}
+	c.feat.params = &gtab.FeatureParamsStylisticSet{
+		UINameID: c.parseNameBlock(),
+	}
+	c.expectSym(";")
+}
+
+func (c *compiler) parseCVParameters() {
+	t := c.next()
+	if c.feat == nil || c.block != nil || !isCharacterVariant(c.feat.tag) {
+		c.fail(t, "cvParameters is only allowed inside cvXX features")
+	}
+	p := &gtab.FeatureParamsCharacterVariants{}
+	c.expectSym("{")
+	for !c.peek().isSym("}") {
+		t := c.next()
+		switch {
+		case t.isSym(";"):
+			// empty statement
+		case t.is("FeatUILabelNameID"):
+			p.FeatUILabelNameID = c.parseNameBlock()
+		case t.is("FeatUITooltipTextNameID"):
+			p.FeatUITooltipTextNameID = c.parseNameBlock()
+		case t.is("SampleTextNameID"):
+			p.SampleTextNameID = c.parseNameBlock()
+		case t.is("ParamUILabelNameID"):
+			id := c.parseNameBlock()
+			if p.NumNamedParameters == 0 {
+				p.FirstParamUILabelNameID = id
+			}
+			p.NumNamedParameters++
+		case t.is("Character"):
+			p.Characters = append(p.Characters, rune(c.expectInt()))
+		default:
+			c.fail(t, "unexpected %s in cvParameters", t)
+		}
+	}
+	c.expectSym("}")
+	c.expectSym(";")
+	c.feat.params = p
+}
+
+// parseNameBlock parses a block of name statements, enclosed in braces,
+// and returns the name ID allocated for the strings.
+func (c *compiler) parseNameBlock() uint16 {
+	c.expectSym("{")
+	id := c.allocNameID()
+	for !c.peek().isSym("}") {
+		t := c.next()
+		switch {
+		case t.isSym(";"):
+			// empty statement
+		case t.is("name"):
+			c.parseNameEntry(id)
+		default:
+			c.fail(t, "expected \"name\", got %s", t)
+		}
+	}
+	c.expectSym("}")
+	return id
+}
+
+func (c *compiler) allocNameID() uint16 {
+	id := c.nextNameID
+	c.nextNameID++
+	return id
+}
+
+// parseNameEntry parses the platform, encoding and language IDs (all
+// optional) and the string of a name statement.
+func (c *compiler) parseNameEntry(nameID uint16) {
+	rec := NameRecord{
+		PlatformID: 3,
+		EncodingID: 1,
+		LanguageID: 0x0409,
+		NameID:     nameID,
+	}
+	if c.peek().typ == tokNumber {
+		t := c.peek()
+		rec.PlatformID = c.expectUint16()
+		switch rec.PlatformID {
+		case 1:
+			rec.EncodingID, rec.LanguageID = 0, 0
+		case 3:
+			// use the defaults
+		default:
+			c.fail(t, "invalid platform ID %d", rec.PlatformID)
+		}
+		if c.peek().typ == tokNumber {
+			rec.EncodingID = c.expectUint16()
+			rec.LanguageID = c.expectUint16()
+		}
+	}
+	t := c.next()
+	if t.typ != tokString {
+		c.fail(t, "expected string, got %s", t)
+	}
+	c.expectSym(";")
+	rec.Value = c.decodeNameString(t, rec.PlatformID)
+	c.names = append(c.names, rec)
+}
+
+// decodeNameString resolves the hexadecimal escape sequences in a name
+// string.  These have four hex digits for the Windows platform and two hex
+// digits for the Macintosh platform.
+func (c *compiler) decodeNameString(t token, platformID uint16) string {
+	numDigits := 4
+	if platformID == 1 {
+		numDigits = 2
+	}
+	s := t.val
+	var b strings.Builder
+	for {
+		i := strings.IndexByte(s, '\\')
+		if i < 0 {
+			b.WriteString(s)
+			break
+		}
+		b.WriteString(s[:i])
+		if i+1+numDigits > len(s) {
+			c.fail(t, "invalid escape sequence in string")
+		}
+		x, err := strconv.ParseUint(s[i+1:i+1+numDigits], 16, 16)
+		if err != nil {
+			c.fail(t, "invalid escape sequence in string")
+		}
+		b.WriteRune(rune(x))
+		s = s[i+1+numDigits:]
+	}
+	return b.String()
+}
+
+func isStylisticSet(tag string) bool {
+	return len(tag) == 4 && tag[:2] == "ss" && isDigit(tag[2]) && isDigit(tag[3])
+}
+
+func isCharacterVariant(tag string) bool {
+	return len(tag) == 4 && tag[:2] == "cv" && isDigit(tag[2]) && isDigit(tag[3])
+}
+
+// parseTable parses a table block.  Only the GDEF table is used, the
+// contents of all other tables are skipped.
+func (c *compiler) parseTable() {
+	c.next()
+	tagTok := c.next()
+	if tagTok.typ != tokName {
+		c.fail(tagTok, "expected table tag, got %s", tagTok)
+	}
+	c.expectSym("{")
+	if tagTok.val == "GDEF" {
+		c.parseGdef()
+	} else {
+		depth := 0
+		for {
+			t := c.peek()
+			if t.typ == tokEOF {
+				c.fail(t, "unexpected end of file")
+			} else if t.isSym("{") {
+				depth++
+			} else if t.isSym("}") {
+				if depth == 0 {
+					break
+				}
+				depth--
+			}
+			c.next()
+		}
+	}
+	c.expectSym("}")
+	c.expectEnd(tagTok.val)
+}
+
+func (c *compiler) parseGdef() {
+	for !c.peek().isSym("}") {
+		t := c.next()
+		switch {
+		case t.isSym(";"):
+			// empty statement
+		case t.is("GlyphClassDef"):
+			c.glyphClass = make(classdef.Table)
+			for class := uint16(gdef.GlyphClassBase); class <= gdef.GlyphClassComponent; class++ {
+				if class > gdef.GlyphClassBase {
+					c.expectSym(",")
+				}
+				if t := c.peek(); t.isSym(",") || t.isSym(";") {
+					continue
+				}
+				for _, gid := range c.parseClass() {
+					c.glyphClass[gid] = class
+				}
+			}
+			c.expectSym(";")
+		case t.is("Attach"):
+			gg, _ := c.parseGlyphOrClass()
+			var points []uint16
+			for !c.peek().isSym(";") {
+				points = append(points, c.expectUint16())
+			}
+			c.expectSym(";")
+			for _, gid := range gg {
+				pp := append(c.attach[gid], points...)
+				slices.Sort(pp)
+				c.attach[gid] = slices.Compact(pp)
+			}
+		case t.is("LigatureCaretByPos") || t.is("LigatureCaretByIndex"):
+			gg, _ := c.parseGlyphOrClass()
+			var carets []gdef.CaretValue
+			for !c.peek().isSym(";") {
+				if t.is("LigatureCaretByPos") {
+					carets = append(carets, gdef.CaretValue{Coordinate: c.expectInt16()})
+				} else {
+					point := c.expectUint16()
+					carets = append(carets, gdef.CaretValue{ContourPoint: &point})
+				}
+			}
+			c.expectSym(";")
+			for _, gid := range gg {
+				if _, dup := c.carets[gid]; !dup {
+					c.carets[gid] = carets
+				}
+			}
+		default:
+			c.fail(t, "unexpected %s in GDEF table", t)
+		}
+	}
+}
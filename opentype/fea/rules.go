@@ -0,0 +1,588 @@
+// seehuhn.de/go/sfnt - a library for reading and writing font files
+// Copyright (C) 2026  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package fea
+
+import (
+	"seehuhn.de/go/sfnt/glyph"
+	"seehuhn.de/go/sfnt/opentype/anchor"
+	"seehuhn.de/go/sfnt/opentype/gtab"
+)
+
+// lookup collects the rules of a named or anonymous lookup.
+type lookup struct {
+	name string // empty for anonymous lookups
+	tok  token
+
+	// table is 0 until the first rule is added.
+	table     gtab.Type
+	typ       uint16
+	flags     gtab.LookupFlags
+	filterSet uint16
+
+	// subtables holds the rules, split by subtable statements.
+	subtables [][]rule
+
+	index gtab.LookupIndex
+}
+
+func (l *lookup) breakSubtable() {
+	if n := len(l.subtables); n > 0 && len(l.subtables[n-1]) > 0 {
+		l.subtables = append(l.subtables, nil)
+	}
+}
+
+func (c *compiler) newLookup(name string, t token) *lookup {
+	l := &lookup{name: name, tok: t}
+	c.lookups = append(c.lookups, l)
+	return l
+}
+
+// rule is one of the rule types below.
+type rule interface {
+	where() token
+}
+
+// mapRule maps single glyphs to glyph sequences.  This is used for single,
+// multiple and alternate substitutions.
+type mapRule struct {
+	tok  token
+	from []glyph.ID
+	to   [][]glyph.ID
+}
+
+type ligatureRule struct {
+	tok token
+	in  [][]glyph.ID
+	out glyph.ID
+}
+
+type chainRule struct {
+	tok       token
+	backtrack [][]glyph.ID // in text order
+	input     [][]glyph.ID
+	lookahead [][]glyph.ID
+	actions   []action
+}
+
+type action struct {
+	pos int
+	l   *lookup
+}
+
+type reverseRule struct {
+	tok       token
+	backtrack [][]glyph.ID // in text order
+	lookahead [][]glyph.ID
+	from, to  []glyph.ID
+}
+
+type singlePosRule struct {
+	tok    token
+	glyphs []glyph.ID
+	vr     *gtab.GposValueRecord
+}
+
+type pairPosRule struct {
+	tok           token
+	first, second []glyph.ID
+	v1, v2        *gtab.GposValueRecord
+	isClass       bool
+}
+
+type cursiveRule struct {
+	tok         token
+	glyphs      []glyph.ID
+	entry, exit *anchor.Table
+}
+
+// markAttachRule is used for mark-to-base, mark-to-ligature and
+// mark-to-mark attachment.  Mark-to-base and mark-to-mark rules have
+// a single component.
+type markAttachRule struct {
+	tok        token
+	glyphs     []glyph.ID
+	components [][]markAnchor
+}
+
+type markAnchor struct {
+	anchor *anchor.Table
+	class  *markClass
+}
+
+func (r *mapRule) where() token        { return r.tok }
+func (r *ligatureRule) where() token   { return r.tok }
+func (r *chainRule) where() token      { return r.tok }
+func (r *reverseRule) where() token    { return r.tok }
+func (r *singlePosRule) where() token  { return r.tok }
+func (r *pairPosRule) where() token    { return r.tok }
+func (r *cursiveRule) where() token    { return r.tok }
+func (r *markAttachRule) where() token { return r.tok }
+
+// addRule adds a rule to the current lookup.  If the rule does not fit
+// into the current lookup, a new anonymous lookup is started.
+func (c *compiler) addRule(table gtab.Type, typ uint16, r rule) {
+	l := c.cur
+	if l != nil && l.table != 0 &&
+		(l.table != table || l.typ != typ || l.flags != c.flags || l.filterSet != c.filterSet) {
+		if l == c.block {
+			if l.table != table || l.typ != typ {
+				c.fail(r.where(), "rule type does not match the other rules in lookup %q", l.name)
+			}
+			c.fail(r.where(), "lookupflag changed inside lookup %q", l.name)
+		}
+		l = nil
+	}
+	if l == nil {
+		l = c.newLookup("", r.where())
+		c.feat.addLookup(c.langs, l)
+		c.cur = l
+	}
+	if l.table == 0 {
+		l.table = table
+		l.typ = typ
+		l.flags = c.flags
+		l.filterSet = c.filterSet
+		l.subtables = [][]rule{nil}
+	}
+	n := len(l.subtables) - 1
+	l.subtables[n] = append(l.subtables[n], r)
+}
+
+// inlineLookup creates an anonymous lookup with a single rule, for use in
+// a contextual rule.
+func (c *compiler) inlineLookup(table gtab.Type, typ uint16, r rule) *lookup {
+	l := c.newLookup("", r.where())
+	l.table = table
+	l.typ = typ
+	l.flags = c.flags
+	l.filterSet = c.filterSet
+	l.subtables = [][]rule{{r}}
+	return l
+}
+
+// patternItem is one element of the glyph sequence in a rule.
+type patternItem struct {
+	tok     token
+	glyphs  []glyph.ID
+	isClass bool
+	marked  bool
+	lookups []*lookup
+	vr      *gtab.GposValueRecord
+	hasVR   bool
+}
+
+// parsePattern parses a sequence of glyphs and glyph classes, together with
+// marks, lookup references and (if withValues is set) value records.
+func (c *compiler) parsePattern(withValues bool) []*patternItem {
+	var items []*patternItem
+	for {
+		t := c.peek()
+		if t.isSym(";") || t.isSym(",") || t.is("by") || t.is("from") || t.typ == tokEOF {
+			break
+		}
+		gg, isClass := c.parseGlyphOrClass()
+		item := &patternItem{tok: t, glyphs: gg, isClass: isClass}
+		if c.peek().isSym("'") {
+			c.next()
+			item.marked = true
+		}
+		for c.peek().is("lookup") {
+			c.next()
+			item.lookups = append(item.lookups, c.namedLookup(c.next()))
+		}
+		if withValues && (c.peek().typ == tokNumber || c.peek().isSym("<")) {
+			item.vr = c.parseValueRecord()
+			item.hasVR = true
+		}
+		items = append(items, item)
+	}
+	if len(items) == 0 {
+		t := c.peek()
+		c.fail(t, "expected glyph or glyph class, got %s", t)
+	}
+	return items
+}
+
+// isContextual reports whether a rule contains marked glyphs or lookup
+// references.
+func isContextual(items []*patternItem) bool {
+	for _, item := range items {
+		if item.marked || item.lookups != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// splitContext splits a contextual rule into backtrack, input and lookahead
+// sequences.  If no glyphs are marked, all glyphs form the input sequence.
+func (c *compiler) splitContext(items []*patternItem) (backtrack, input, lookahead []*patternItem) {
+	first, last := -1, -1
+	for i, item := range items {
+		if !item.marked {
+			continue
+		}
+		if first < 0 {
+			first = i
+		} else if last < i-1 {
+			c.fail(item.tok, "marked glyphs must be consecutive")
+		}
+		last = i
+	}
+	if first < 0 {
+		return nil, items, nil
+	}
+	return items[:first], items[first : last+1], items[last+1:]
+}
+
+func glyphSeqs(items []*patternItem) [][]glyph.ID {
+	res := make([][]glyph.ID, len(items))
+	for i, item := range items {
+		res[i] = item.glyphs
+	}
+	return res
+}
+
+func (c *compiler) parseSub(reverse bool) {
+	start := c.next()
+	items := c.parsePattern(false)
+
+	var out []*patternItem
+	var alts []glyph.ID
+	var hasBy, isAlt bool
+	switch t := c.peek(); {
+	case t.is("by"):
+		c.next()
+		hasBy = true
+		if c.peek().is("NULL") {
+			// The gtab package drops empty replacement sequences when
+			// reading fonts, and so do we.
+			c.fail(c.peek(), "glyph deletion is not supported")
+		}
+		out = c.parsePattern(false)
+		if isContextual(out) {
+			c.fail(t, "invalid replacement sequence")
+		}
+	case t.is("from") && !reverse:
+		c.next()
+		isAlt = true
+		alts = c.parseClass()
+	}
+	c.expectSym(";")
+
+	if reverse {
+		if !hasBy || len(out) != 1 {
+			c.fail(start, "reverse chaining substitutions need a single replacement")
+		}
+		backtrack, input, lookahead := c.splitContext(items)
+		if len(input) != 1 || input[0].lookups != nil {
+			c.fail(start, "reverse chaining substitutions replace a single glyph")
+		}
+		from := input[0].glyphs
+		c.addRule(gtab.TypeGsub, 8, &reverseRule{
+			tok:       start,
+			backtrack: glyphSeqs(backtrack),
+			lookahead: glyphSeqs(lookahead),
+			from:      from,
+			to:        c.singleTargets(out[0], from),
+		})
+		return
+	}
+
+	if !isContextual(items) {
+		if !hasBy && !isAlt {
+			c.fail(start, "expected \"by\" or \"from\"")
+		}
+		typ, r := c.makeSubRule(start, items, out, isAlt, alts)
+		c.addRule(gtab.TypeGsub, typ, r)
+		return
+	}
+
+	backtrack, input, lookahead := c.splitContext(items)
+	r := &chainRule{
+		tok:       start,
+		backtrack: glyphSeqs(backtrack),
+		input:     glyphSeqs(input),
+		lookahead: glyphSeqs(lookahead),
+	}
+	if hasBy || isAlt {
+		for _, item := range items {
+			if item.lookups != nil {
+				c.fail(item.tok, "lookup references cannot be combined with \"by\" or \"from\"")
+			}
+		}
+		typ, sr := c.makeSubRule(start, input, out, isAlt, alts)
+		r.actions = []action{{pos: 0, l: c.inlineLookup(gtab.TypeGsub, typ, sr)}}
+	} else {
+		r.actions = c.lookupActions(input)
+	}
+	c.addRule(gtab.TypeGsub, 6, r)
+}
+
+func (c *compiler) lookupActions(input []*patternItem) []action {
+	var res []action
+	for i, item := range input {
+		for _, l := range item.lookups {
+			res = append(res, action{pos: i, l: l})
+		}
+	}
+	return res
+}
+
+// makeSubRule converts a non-contextual substitution into a rule for the
+// appropriate lookup type.
+func (c *compiler) makeSubRule(t token, in, out []*patternItem, isAlt bool, alts []glyph.ID) (uint16, rule) {
+	switch {
+	case len(in) == 1 && isAlt:
+		from := in[0].glyphs
+		to := make([][]glyph.ID, len(from))
+		for i := range to {
+			to[i] = alts
+		}
+		return 3, &mapRule{tok: t, from: from, to: to}
+
+	case len(in) == 1 && len(out) == 1:
+		from := in[0].glyphs
+		to := make([][]glyph.ID, len(from))
+		for i, gid := range c.singleTargets(out[0], from) {
+			to[i] = []glyph.ID{gid}
+		}
+		if l := c.cur; l != nil && l.table == gtab.TypeGsub && l.typ == 2 {
+			// Inside a lookup of multiple substitutions, single
+			// substitutions are stored as one-glyph sequences.
+			return 2, &mapRule{tok: t, from: from, to: to}
+		}
+		return 1, &mapRule{tok: t, from: from, to: to}
+
+	case len(in) == 1 && len(out) > 1:
+		seq := make([]glyph.ID, len(out))
+		for i, item := range out {
+			if item.isClass {
+				c.fail(item.tok, "the replacement of a multiple substitution must be a glyph sequence")
+			}
+			seq[i] = item.glyphs[0]
+		}
+		from := in[0].glyphs
+		to := make([][]glyph.ID, len(from))
+		for i := range to {
+			to[i] = seq
+		}
+		return 2, &mapRule{tok: t, from: from, to: to}
+
+	case len(in) > 1 && len(out) == 1:
+		if out[0].isClass {
+			c.fail(out[0].tok, "the replacement of a ligature substitution must be a single glyph")
+		}
+		return 4, &ligatureRule{tok: t, in: glyphSeqs(in), out: out[0].glyphs[0]}
+	}
+	c.fail(t, "unsupported substitution rule")
+	return 0, nil
+}
+
+// singleTargets returns the replacement glyph for each glyph in from.
+func (c *compiler) singleTargets(out *patternItem, from []glyph.ID) []glyph.ID {
+	if !out.isClass {
+		to := make([]glyph.ID, len(from))
+		for i := range to {
+			to[i] = out.glyphs[0]
+		}
+		return to
+	}
+	if len(out.glyphs) != len(from) {
+		c.fail(out.tok, "replacement class has %d glyphs, expected %d",
+			len(out.glyphs), len(from))
+	}
+	return out.glyphs
+}
+
+func (c *compiler) parseIgnore() {
+	start := c.next()
+	t := c.next()
+	var table gtab.Type
+	var typ uint16
+	switch {
+	case t.is("sub") || t.is("substitute"):
+		table, typ = gtab.TypeGsub, 6
+	case t.is("pos") || t.is("position"):
+		table, typ = gtab.TypeGpos, 8
+	default:
+		c.fail(t, "expected \"sub\" or \"pos\" after \"ignore\", got %s", t)
+	}
+	for {
+		items := c.parsePattern(false)
+		backtrack, input, lookahead := c.splitContext(items)
+		if backtrack == nil && lookahead == nil && !input[0].marked {
+			c.fail(items[0].tok, "ignore statements need marked glyphs")
+		}
+		for _, item := range items {
+			if item.lookups != nil {
+				c.fail(item.tok, "ignore statements cannot use lookups")
+			}
+		}
+		c.addRule(table, typ, &chainRule{
+			tok:       start,
+			backtrack: glyphSeqs(backtrack),
+			input:     glyphSeqs(input),
+			lookahead: glyphSeqs(lookahead),
+		})
+		if !c.peek().isSym(",") {
+			break
+		}
+		c.next()
+	}
+	c.expectSym(";")
+}
+
+func (c *compiler) parsePos(enum bool) {
+	start := c.next()
+
+	t := c.peek()
+	switch {
+	case !enum && t.is("cursive"):
+		c.next()
+		gg, _ := c.parseGlyphOrClass()
+		entry := c.parseAnchor()
+		exit := c.parseAnchor()
+		c.expectSym(";")
+		c.addRule(gtab.TypeGpos, 3, &cursiveRule{tok: start, glyphs: gg, entry: entry, exit: exit})
+		return
+
+	case !enum && (t.is("base") || t.is("ligature") || t.is("mark")):
+		c.next()
+		isLig := t.is("ligature")
+		gg, _ := c.parseGlyphOrClass()
+		var components [][]markAnchor
+		for {
+			components = append(components, c.parseMarkAnchors(isLig))
+			if !isLig || !c.peek().is("ligComponent") {
+				break
+			}
+			c.next()
+		}
+		c.expectSym(";")
+		var typ uint16
+		switch {
+		case t.is("base"):
+			typ = 4
+		case isLig:
+			typ = 5
+		default:
+			typ = 6
+		}
+		c.addRule(gtab.TypeGpos, typ, &markAttachRule{tok: start, glyphs: gg, components: components})
+		return
+	}
+
+	items := c.parsePattern(true)
+	c.expectSym(";")
+
+	if isContextual(items) {
+		if enum {
+			c.fail(start, "enum cannot be used with contextual rules")
+		}
+		backtrack, input, lookahead := c.splitContext(items)
+		for _, item := range items {
+			if item.hasVR && !item.marked {
+				c.fail(item.tok, "value records are only allowed for marked glyphs")
+			}
+		}
+		r := &chainRule{
+			tok:       start,
+			backtrack: glyphSeqs(backtrack),
+			input:     glyphSeqs(input),
+			lookahead: glyphSeqs(lookahead),
+		}
+		for i, item := range input {
+			if item.hasVR && item.vr != nil {
+				l := c.inlineLookup(gtab.TypeGpos, 1,
+					&singlePosRule{tok: item.tok, glyphs: item.glyphs, vr: item.vr})
+				r.actions = append(r.actions, action{pos: i, l: l})
+			}
+			for _, l := range item.lookups {
+				r.actions = append(r.actions, action{pos: i, l: l})
+			}
+		}
+		c.addRule(gtab.TypeGpos, 8, r)
+		return
+	}
+
+	switch len(items) {
+	case 1:
+		if !items[0].hasVR || enum {
+			c.fail(start, "invalid single positioning rule")
+		}
+		vr := items[0].vr
+		if vr == nil {
+			vr = &gtab.GposValueRecord{}
+		}
+		c.addRule(gtab.TypeGpos, 1, &singlePosRule{tok: start, glyphs: items[0].glyphs, vr: vr})
+	case 2:
+		a, b := items[0], items[1]
+		r := &pairPosRule{
+			tok:     start,
+			first:   a.glyphs,
+			second:  b.glyphs,
+			isClass: (a.isClass || b.isClass) && !enum,
+		}
+		switch {
+		case a.hasVR && b.hasVR:
+			r.v1, r.v2 = a.vr, b.vr
+		case a.hasVR:
+			r.v1 = a.vr
+		case b.hasVR:
+			r.v1 = b.vr
+		default:
+			c.fail(start, "missing value record")
+		}
+		c.addRule(gtab.TypeGpos, 2, r)
+	default:
+		c.fail(start, "unsupported positioning rule")
+	}
+}
+
+// parseMarkAnchors parses a list of "<anchor> mark @CLASS" pairs.  For
+// ligature components, the list can also consist of a single <anchor NULL>.
+func (c *compiler) parseMarkAnchors(isLig bool) []markAnchor {
+	var res []markAnchor
+	for c.peek().isSym("<") {
+		t := c.peek()
+		a := c.parseAnchor()
+		if !c.peek().is("mark") {
+			if a == nil && isLig {
+				continue
+			}
+			c.fail(t, "expected \"mark\" after anchor")
+		}
+		c.next()
+		ct := c.next()
+		if ct.typ != tokClass {
+			c.fail(ct, "expected mark class, got %s", ct)
+		}
+		mc, ok := c.markClasses[ct.val]
+		if !ok {
+			c.fail(ct, "undefined mark class @%s", ct.val)
+		}
+		if a != nil {
+			res = append(res, markAnchor{anchor: a, class: mc})
+		}
+	}
+	if res == nil && !isLig {
+		t := c.peek()
+		c.fail(t, "expected anchor, got %s", t)
+	}
+	return res
+}
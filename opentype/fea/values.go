@@ -0,0 +1,178 @@
+// seehuhn.de/go/sfnt - a library for reading and writing font files
+// Copyright (C) 2026  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package fea
+
+import (
+	"reflect"
+
+	"seehuhn.de/go/sfnt/opentype/anchor"
+	"seehuhn.de/go/sfnt/opentype/device"
+	"seehuhn.de/go/sfnt/opentype/gtab"
+)
+
+// parseValueRecord parses a value record.  This is either a single number,
+// which gives the advance adjustment, or a record in angle brackets.
+// The return value is nil for <NULL>.
+func (c *compiler) parseValueRecord() *gtab.GposValueRecord {
+	t := c.peek()
+	if t.typ == tokNumber {
+		v := c.expectInt16()
+		if c.feat != nil && isVertical(c.feat.tag) {
+			return &gtab.GposValueRecord{YAdvance: v}
+		}
+		return &gtab.GposValueRecord{XAdvance: v}
+	}
+
+	c.expectSym("<")
+	t = c.peek()
+	switch {
+	case t.is("NULL"):
+		c.next()
+		c.expectSym(">")
+		return nil
+	case t.typ == tokName:
+		c.next()
+		vr, ok := c.valueRecords[t.val]
+		if !ok {
+			c.fail(t, "undefined value record %q", t.val)
+		}
+		c.expectSym(">")
+		return vr
+	}
+
+	vr := &gtab.GposValueRecord{
+		XPlacement: c.expectInt16(),
+		YPlacement: c.expectInt16(),
+		XAdvance:   c.expectInt16(),
+		YAdvance:   c.expectInt16(),
+	}
+	if c.peek().isSym("<") {
+		vr.XPlacementDev = c.parseDevice()
+		vr.YPlacementDev = c.parseDevice()
+		vr.XAdvanceDev = c.parseDevice()
+		vr.YAdvanceDev = c.parseDevice()
+	}
+	c.expectSym(">")
+	return vr
+}
+
+// isVertical reports whether single numbers in value records adjust the
+// vertical advance in the given feature.
+func isVertical(tag string) bool {
+	switch tag {
+	case "vkrn", "vpal", "vhal", "valt":
+		return true
+	}
+	return false
+}
+
+// parseAnchor parses an anchor in angle brackets.  The return value is nil
+// for <anchor NULL>.
+func (c *compiler) parseAnchor() *anchor.Table {
+	c.expectSym("<")
+	c.expectKeyword("anchor")
+	t := c.peek()
+	switch {
+	case t.is("NULL"):
+		c.next()
+		c.expectSym(">")
+		return nil
+	case t.typ == tokName:
+		c.next()
+		a, ok := c.anchors[t.val]
+		if !ok {
+			c.fail(t, "undefined anchor %q", t.val)
+		}
+		c.expectSym(">")
+		return a
+	}
+
+	a := &anchor.Table{
+		X: c.expectInt16(),
+		Y: c.expectInt16(),
+	}
+	if c.peek().is("contourpoint") {
+		c.next()
+		cp := c.expectUint16()
+		a.ContourPoint = &cp
+	} else if c.peek().isSym("<") {
+		a.XDev = c.parseDevice()
+		a.YDev = c.parseDevice()
+	}
+	c.expectSym(">")
+	return a
+}
+
+// parseDevice parses a device table like <device 11 -1, 12 -1>.  The return
+// value is nil for <device NULL>.
+func (c *compiler) parseDevice() *device.Table {
+	c.expectSym("<")
+	c.expectKeyword("device")
+	if c.peek().is("NULL") {
+		c.next()
+		c.expectSym(">")
+		return nil
+	}
+
+	deltas := make(map[uint16]int)
+	var start, end uint16
+	for {
+		t := c.peek()
+		ppem := c.expectUint16()
+		delta := c.expectInt()
+		if delta < -128 || delta > 127 {
+			c.fail(t, "device delta %d out of range", delta)
+		}
+		if len(deltas) == 0 || ppem < start {
+			start = ppem
+		}
+		if len(deltas) == 0 || ppem > end {
+			end = ppem
+		}
+		deltas[ppem] = delta
+		if !c.peek().isSym(",") {
+			break
+		}
+		c.next()
+	}
+	c.expectSym(">")
+
+	dev := &device.Table{
+		StartSize:   start,
+		EndSize:     end,
+		Deltas:      make([]int8, int(end)-int(start)+1),
+		DeltaFormat: 1,
+	}
+	for ppem, delta := range deltas {
+		dev.Deltas[ppem-start] = int8(delta)
+		switch {
+		case delta < -8 || delta > 7:
+			dev.DeltaFormat = 3
+		case (delta < -2 || delta > 1) && dev.DeltaFormat < 2:
+			dev.DeltaFormat = 2
+		}
+	}
+	return dev
+}
+
+func anchorEqual(a, b *anchor.Table) bool {
+	return reflect.DeepEqual(a, b)
+}
+
+func valueRecordEqual(a, b *gtab.GposValueRecord) bool {
+	return reflect.DeepEqual(a, b)
+}
@@ -17,6 +17,7 @@
 package gtab
 
 import (
+	"slices"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -343,3 +344,175 @@ func TestGsub8MixedWithForwardLookup(t *testing.T) {
 		t.Errorf("unexpected result (-want +got):\n%s", d)
 	}
 }
+
+// TestClusters checks that cluster values are carried through multiple
+// substitution, ligature formation and nested lookups.
+func TestClusters(t *testing.T) {
+	lookupList := []*LookupTable{
+		{ // lookup 0: 1 -> 2 3
+			Meta: &LookupMetaInfo{LookupType: 2},
+			Subtables: []Subtable{
+				&Gsub2_1{Cov: coverage.Table{1: 0}, Repl: [][]glyph.ID{{2, 3}}},
+			},
+		},
+		{ // lookup 1: 3 4 -> 5
+			Meta: &LookupMetaInfo{LookupType: 4, LookupFlags: IgnoreMarks},
+			Subtables: []Subtable{
+				&Gsub4_1{
+					Cov:  coverage.Table{3: 0},
+					Repl: [][]Ligature{{{In: []glyph.ID{4}, Out: 5}}},
+				},
+			},
+		},
+		{ // lookup 2: in context 1 4, apply lookup 0 then lookup 1
+			Meta: &LookupMetaInfo{LookupType: 5},
+			Subtables: []Subtable{
+				&SeqContext1{
+					Cov: coverage.Table{1: 0},
+					Rules: [][]*SeqRule{{{
+						Input: []glyph.ID{4},
+						Actions: []SeqLookup{
+							{SequenceIndex: 0, LookupListIndex: 0},
+							{SequenceIndex: 1, LookupListIndex: 1},
+						},
+					}}},
+				},
+			},
+		},
+	}
+	gdefTable := &gdef.Table{GlyphClass: classdef.Table{9: gdef.GlyphClassMark}}
+
+	cases := []struct {
+		lookups []LookupIndex
+		in      []glyph.Info
+		want    []glyph.Info
+	}{
+		{ // multiple substitution
+			lookups: []LookupIndex{0},
+			in:      []glyph.Info{{GID: 1, Cluster: 0}, {GID: 7, Cluster: 2}},
+			want:    []glyph.Info{{GID: 2, Cluster: 0}, {GID: 3, Cluster: 0}, {GID: 7, Cluster: 2}},
+		},
+		{ // ligature with a skipped mark
+			lookups: []LookupIndex{1},
+			in:      []glyph.Info{{GID: 3, Cluster: 0}, {GID: 9, Cluster: 1}, {GID: 4, Cluster: 3}},
+			want:    []glyph.Info{{GID: 5, Cluster: 0}, {GID: 9, Cluster: 0}},
+		},
+		{ // nested lookups
+			lookups: []LookupIndex{2},
+			in:      []glyph.Info{{GID: 1, Cluster: 0}, {GID: 4, Cluster: 1}, {GID: 7, Cluster: 4}},
+			want:    []glyph.Info{{GID: 2, Cluster: 0}, {GID: 5, Cluster: 0}, {GID: 7, Cluster: 4}},
+		},
+	}
+	for i, c := range cases {
+		out := NewContext(lookupList, gdefTable, c.lookups).Apply(c.in)
+		if d := cmp.Diff(c.want, out, cmpopts.IgnoreFields(glyph.Info{}, "LigID", "LigComp")); d != "" {
+			t.Errorf("%d: unexpected result (-want +got):\n%s", i, d)
+		}
+	}
+}
+
+// TestLookupRanges checks that lookup ranges restrict lookups to parts of
+// the glyph sequence, and select alternates.
+func TestLookupRanges(t *testing.T) {
+	lookupList := []*LookupTable{
+		{ // lookup 0: 1 -> 2
+			Meta: &LookupMetaInfo{LookupType: 1},
+			Subtables: []Subtable{
+				&Gsub1_1{Cov: coverage.Set{1: true}, Delta: 1},
+			},
+		},
+		{ // lookup 1: 3 -> one of 4, 5, 6
+			Meta: &LookupMetaInfo{LookupType: 3},
+			Subtables: []Subtable{
+				&Gsub3_1{Cov: coverage.Table{3: 0}, Alternates: [][]glyph.ID{{4, 5, 6}}},
+			},
+		},
+	}
+
+	in := []glyph.Info{
+		{GID: 1, Cluster: 0}, {GID: 3, Cluster: 1},
+		{GID: 1, Cluster: 2}, {GID: 3, Cluster: 3},
+		{GID: 1, Cluster: 4}, {GID: 3, Cluster: 5},
+	}
+	cases := []struct {
+		lookups []LookupIndex
+		ranges  []LookupRange
+		want    []glyph.ID
+	}{
+		{nil, nil, []glyph.ID{1, 3, 1, 3, 1, 3}},
+		{[]LookupIndex{0, 1}, nil, []glyph.ID{2, 4, 2, 4, 2, 4}},
+		{ // enable lookup 0 for the middle part only
+			nil,
+			[]LookupRange{{Lookup: 0, Start: 2, End: 4, Value: 1}},
+			[]glyph.ID{1, 3, 2, 3, 1, 3},
+		},
+		{ // disable lookup 0 for the middle part
+			[]LookupIndex{0},
+			[]LookupRange{{Lookup: 0, Start: 2, End: 4, Value: 0}},
+			[]glyph.ID{2, 3, 1, 3, 2, 3},
+		},
+		{ // select alternates
+			[]LookupIndex{1},
+			[]LookupRange{
+				{Lookup: 1, Start: 0, End: 6, Value: 2},
+				{Lookup: 1, Start: 3, End: 4, Value: 3},
+				{Lookup: 1, Start: 5, End: 6, Value: 4}, // out of range
+			},
+			[]glyph.ID{1, 5, 1, 6, 1, 3},
+		},
+	}
+	for i, c := range cases {
+		ctx := NewContext(lookupList, nil, c.lookups)
+		ctx.SetRanges(c.ranges)
+		out := ctx.Apply(slices.Clone(in))
+		var got []glyph.ID
+		for _, g := range out {
+			got = append(got, g.GID)
+		}
+		if d := cmp.Diff(c.want, got); d != "" {
+			t.Errorf("%d: unexpected result (-want +got):\n%s", i, d)
+		}
+	}
+}
+
+// TestLookupOrder checks that lookups are applied in the order given to
+// NewContext, and that lookups which only have ranges are inserted by
+// lookup index.
+func TestLookupOrder(t *testing.T) {
+	lookupList := []*LookupTable{
+		{ // lookup 0: 1 -> 2
+			Meta:      &LookupMetaInfo{LookupType: 1},
+			Subtables: []Subtable{&Gsub1_1{Cov: coverage.Set{1: true}, Delta: 1}},
+		},
+		{ // lookup 1: 2 -> 3
+			Meta:      &LookupMetaInfo{LookupType: 1},
+			Subtables: []Subtable{&Gsub1_1{Cov: coverage.Set{2: true}, Delta: 1}},
+		},
+		{ // lookup 2: 3 -> 4
+			Meta:      &LookupMetaInfo{LookupType: 1},
+			Subtables: []Subtable{&Gsub1_1{Cov: coverage.Set{3: true}, Delta: 1}},
+		},
+	}
+
+	all := []LookupRange{{Lookup: 1, Start: 0, End: 1, Value: 1}}
+	cases := []struct {
+		lookups []LookupIndex
+		ranges  []LookupRange
+		want    glyph.ID
+	}{
+		{[]LookupIndex{0, 1, 2}, nil, 4},
+		{[]LookupIndex{2, 1, 0}, nil, 2},
+		{[]LookupIndex{0, 2, 1}, nil, 3},
+		{[]LookupIndex{0, 2}, all, 4},
+		{[]LookupIndex{2, 0}, all, 2},
+		{[]LookupIndex{0}, all, 3},
+	}
+	for i, c := range cases {
+		ctx := NewContext(lookupList, nil, c.lookups)
+		ctx.SetRanges(c.ranges)
+		out := ctx.Apply([]glyph.Info{{GID: 1}})
+		if len(out) != 1 || out[0].GID != c.want {
+			t.Errorf("%d: expected %d, got %v", i, c.want, out)
+		}
+	}
+}
@@ -22,6 +22,7 @@ import (
 	"slices"
 	"sort"
 	"strings"
+	"unicode"
 
 	"seehuhn.de/go/sfnt"
 	"seehuhn.de/go/sfnt/glyph"
@@ -31,7 +32,8 @@ import (
 )
 
 // ExplainGsub returns a human-readable, textual description of the lookups
-// in a GSUB table.
+// in a GSUB table.  Use [ExplainFeatures] to obtain the feature and script
+// declarations.
 func ExplainGsub(fontInfo *sfnt.Font) string {
 	ee := newExplainer(fontInfo)
 
@@ -149,7 +151,8 @@ func ExplainGsub(fontInfo *sfnt.Font) string {
 }
 
 // ExplainGpos returns a human-readable, textual description of the lookups
-// in a GPOS table.
+// in a GPOS table, one string per lookup.  Use [ExplainFeatures] to obtain
+// the feature and script declarations.
 func ExplainGpos(fontInfo *sfnt.Font) []string {
 	var res []string
 	for _, lookup := range fontInfo.Gpos.LookupList {
@@ -326,6 +329,101 @@ func ExplainGpos(fontInfo *sfnt.Font) []string {
 	return res
 }
 
+// ExplainFeatures returns the feature and script declarations for the
+// FeatureList and ScriptList of a GSUB or GPOS table, in the format
+// understood by [ParseInfo].  The result is empty if the table has no
+// features and no scripts.
+func ExplainFeatures(info *gtab.Info) string {
+	ee := &explainer{w: &strings.Builder{}}
+	ee.explainFeatures(info)
+	return ee.w.String()
+}
+
+// explainFeatures writes the feature and script declarations for the
+// FeatureList and ScriptList of a table.
+func (ee *explainer) explainFeatures(info *gtab.Info) {
+	if info == nil {
+		return
+	}
+	for _, f := range info.FeatureList {
+		ee.w.WriteString("feature ")
+		ee.writeTag(f.Tag)
+		ee.w.WriteRune(':')
+		for _, idx := range f.Lookups {
+			fmt.Fprintf(ee.w, " %d", idx)
+		}
+		switch params := f.Params.(type) {
+		case *gtab.FeatureParamsSize:
+			fmt.Fprintf(ee.w, " params size %d %d %d %d %d",
+				params.DesignSize, params.SubfamilyID, params.SubfamilyNameID,
+				params.RangeStart, params.RangeEnd)
+		case *gtab.FeatureParamsStylisticSet:
+			fmt.Fprintf(ee.w, " params ss %d %d", params.Version, params.UINameID)
+		case *gtab.FeatureParamsCharacterVariants:
+			fmt.Fprintf(ee.w, " params cv %d %d %d %d %d %d",
+				params.Format, params.FeatUILabelNameID,
+				params.FeatUITooltipTextNameID, params.SampleTextNameID,
+				params.NumNamedParameters, params.FirstParamUILabelNameID)
+			for _, r := range params.Characters {
+				fmt.Fprintf(ee.w, " %d", r)
+			}
+		}
+		ee.w.WriteRune('\n')
+	}
+
+	type langSys struct {
+		script, lang string
+		features     *gtab.Features
+	}
+	var langs []langSys
+	for tag, features := range info.ScriptList {
+		script, lang, err := gtab.OpenTypeTags(tag)
+		if err != nil {
+			fmt.Fprintf(ee.w, "# unsupported language tag %s\n", tag)
+			continue
+		}
+		langs = append(langs, langSys{script: script, lang: lang, features: features})
+	}
+	sort.Slice(langs, func(i, j int) bool {
+		a, b := langs[i], langs[j]
+		if a.script != b.script {
+			return a.script < b.script
+		}
+		return a.lang < b.lang
+	})
+	for _, ls := range langs {
+		ee.w.WriteString("script ")
+		ee.writeTag(ls.script)
+		ee.w.WriteRune(' ')
+		ee.writeTag(ls.lang)
+		ee.w.WriteRune(':')
+		if ls.features.Required != 0xFFFF {
+			fmt.Fprintf(ee.w, " required %d", ls.features.Required)
+		}
+		for _, fi := range ls.features.Optional {
+			fmt.Fprintf(ee.w, " %d", fi)
+		}
+		ee.w.WriteRune('\n')
+	}
+}
+
+// writeTag writes an OpenType tag, using a quoted string if the tag
+// is not a valid identifier.
+func (ee *explainer) writeTag(tag string) {
+	if tag == "" {
+		ee.w.WriteString(`""`)
+		return
+	}
+	for i, r := range tag {
+		isValid := unicode.IsLetter(r) || r == '.' || r == '_' || i > 0 && unicode.IsDigit(r)
+		if !isValid || r > unicode.MaxASCII {
+			fmt.Fprintf(ee.w, "%q", tag)
+			return
+		}
+	}
+	ee.w.WriteString(tag)
+}
+
 type explainer struct {
 	w      *strings.Builder
 	mapped []string
@@ -693,3 +791,76 @@ func (ee *explainer) explainChainedSeqContext3(l *gtab.ChainedSeqContext3) {
 	ee.w.WriteString(" -> ")
 	ee.explainNested(l.Actions)
 }
+
+// ExplainTrace returns a human-readable description of the steps recorded
+// in a lookup trace.  Glyphs are named in the same way as in [ExplainGsub]
+// and [ExplainGpos].  Each step is described by a header line, giving the
+// lookup and subtable index, followed by the glyph sequences before and
+// after the step.  Steps for nested lookups are indented below the step
+// which triggered them.
+func ExplainTrace(fontInfo *sfnt.Font, trace *gtab.Trace) string {
+	ee := newExplainer(fontInfo)
+
+	depth := make([]int, len(trace.Steps))
+	for i, step := range trace.Steps {
+		if step.Parent >= 0 && step.Parent < i {
+			depth[i] = depth[step.Parent] + 1
+		}
+		indent := strings.Repeat("\t", depth[i])
+
+		fmt.Fprintf(ee.w, "%s%d: lookup %d, subtable %d at %d", indent,
+			i, step.Lookup, step.Subtable, step.Pos)
+		if step.Parent < 0 {
+			fmt.Fprintf(ee.w, ", next %d", step.Next)
+		}
+		if step.Input != nil {
+			ee.w.WriteString(", input")
+			for _, pos := range step.Input {
+				fmt.Fprintf(ee.w, " %d", pos)
+			}
+		}
+		if len(step.Actions) > 0 {
+			ee.w.WriteString(", nested ")
+			ee.explainNested(step.Actions)
+		}
+		ee.w.WriteString("\n")
+
+		fmt.Fprintf(ee.w, "%s\tbefore: ", indent)
+		ee.writeTraceSeq(step.Before, nil)
+		fmt.Fprintf(ee.w, "\n%s\tafter:  ", indent)
+		ee.writeTraceSeq(step.After, step.Before)
+		ee.w.WriteString("\n")
+	}
+	return ee.w.String()
+}
+
+// writeTraceSeq writes a glyph sequence, including the glyph positions
+// which are set by GPOS lookups.  If prev has the same length as seq,
+// advance widths are only shown where they differ from prev.
+func (ee *explainer) writeTraceSeq(seq, prev []glyph.Info) {
+	for i, g := range seq {
+		if i > 0 {
+			ee.w.WriteRune(' ')
+		}
+		ee.writeGlyph(g.GID)
+
+		var parts []string
+		if g.XOffset != 0 {
+			parts = append(parts, fmt.Sprintf("x%+d", g.XOffset))
+		}
+		if g.YOffset != 0 {
+			parts = append(parts, fmt.Sprintf("y%+d", g.YOffset))
+		}
+		if len(prev) == len(seq) {
+			if g.Advance != prev[i].Advance {
+				parts = append(parts, fmt.Sprintf("w%d", g.Advance))
+			}
+			if g.YAdvance != prev[i].YAdvance {
+				parts = append(parts, fmt.Sprintf("h%d", g.YAdvance))
+			}
+		}
+		if parts != nil {
+			fmt.Fprintf(ee.w, "(%s)", strings.Join(parts, " "))
+		}
+	}
+}
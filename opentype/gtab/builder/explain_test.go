@@ -0,0 +1,79 @@
+// seehuhn.de/go/sfnt - a library for reading and writing font files
+// Copyright (C) 2026  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package builder
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"seehuhn.de/go/sfnt/glyph"
+	"seehuhn.de/go/sfnt/internal/debug"
+	"seehuhn.de/go/sfnt/opentype/gtab"
+)
+
+func TestExplainTrace(t *testing.T) {
+	fontInfo := debug.MakeSimpleFont()
+	lookups, err := Parse(fontInfo, `
+	GSUB5: "AB" -> 1@1
+	GSUB1: B->C
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cmap, err := fontInfo.CMapTable.GetBest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var seq []glyph.Info
+	for _, r := range "XAB" {
+		seq = append(seq, glyph.Info{GID: cmap.Lookup(r), Text: []rune{r}})
+	}
+
+	trace := &gtab.Trace{}
+	ctx := gtab.NewContext(lookups, nil, []gtab.LookupIndex{0})
+	ctx.SetTrace(trace)
+	ctx.Apply(seq)
+
+	if len(trace.Steps) != 2 {
+		t.Fatalf("expected 2 steps, got %d", len(trace.Steps))
+	}
+	s0, s1 := trace.Steps[0], trace.Steps[1]
+	if s0.Lookup != 0 || s0.Parent != -1 || s0.Pos != 1 || s0.Next != 3 {
+		t.Errorf("unexpected first step %+v", s0)
+	}
+	if d := cmp.Diff([]int{1, 2}, s0.Input); d != "" {
+		t.Error(d)
+	}
+	if s1.Lookup != 1 || s1.Parent != 0 || s1.Pos != 2 {
+		t.Errorf("unexpected second step %+v", s1)
+	}
+
+	fontInfo.Gsub = &gtab.Info{LookupList: lookups}
+	got := ExplainTrace(fontInfo, trace)
+	expected := `0: lookup 0, subtable 0 at 1, next 3, input 1 2, nested 1@1
+	before: X A B
+	after:  X A C
+	1: lookup 1, subtable 0 at 2
+		before: X A B
+		after:  X A C
+`
+	if d := cmp.Diff(expected, got); d != "" {
+		t.Error(d)
+	}
+}
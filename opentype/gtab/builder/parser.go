@@ -35,7 +35,69 @@ import (
 )
 
 // Parse decodes the textual description of a LookupList.
-func Parse(fontInfo *sfnt.Font, input string) (lookups gtab.LookupList, err error) {
+// Feature and script declarations in the input are checked for syntax
+// errors, but are otherwise ignored.  Use [ParseInfo] to read these
+// declarations as well.
+func Parse(fontInfo *sfnt.Font, input string) (gtab.LookupList, error) {
+	_, lookups, err := parseInput(fontInfo, input)
+	if err != nil {
+		return nil, err
+	}
+	return lookups, nil
+}
+
+// ParseInfo decodes the textual description of a GSUB or GPOS table.
+// In addition to the lookups, the description can contain feature and
+// script declarations:
+//
+//	feature liga: 0 2
+//	feature size: params size 100 0 0 80 120
+//	script DFLT dflt: 0
+//	script latn TRK: required 1 0
+//
+// A feature declaration gives the feature tag and the indices of the lookups
+// which implement the feature, optionally followed by feature parameters
+// ("params size", "params ss" or "params cv", followed by the fields of
+// the corresponding [gtab.FeatureParams] type in order; for "params cv" the
+// remaining integers give the characters).  Features are numbered in the
+// order of declaration, starting at 0.  A script declaration gives the
+// OpenType script and language tags, followed by the indices of the
+// features for this language system.  The required feature, if any,
+// is marked by the keyword "required".
+func ParseInfo(fontInfo *sfnt.Font, input string) (*gtab.Info, error) {
+	p, lookups, err := parseInput(fontInfo, input)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range p.features {
+		for _, idx := range f.Lookups {
+			if int(idx) >= len(lookups) {
+				return nil, fmt.Errorf("feature %q: invalid lookup index %d", f.Tag, idx)
+			}
+		}
+	}
+	for tag, features := range p.scripts {
+		idx := features.Optional
+		if features.Required != 0xFFFF {
+			idx = append(slices.Clip(idx), features.Required)
+		}
+		for _, fi := range idx {
+			if int(fi) >= len(p.features) {
+				return nil, fmt.Errorf("script %s: invalid feature index %d", tag, fi)
+			}
+		}
+	}
+
+	info := &gtab.Info{
+		ScriptList:  p.scripts,
+		FeatureList: p.features,
+		LookupList:  lookups,
+	}
+	return info, nil
+}
+
+func parseInput(fontInfo *sfnt.Font, input string) (p *parser, lookups gtab.LookupList, err error) {
 	numGlyphs := fontInfo.NumGlyphs()
 	byName := make(map[string]glyph.ID)
 	for i := glyph.ID(0); i < glyph.ID(numGlyphs); i++ {
@@ -47,16 +109,18 @@ func Parse(fontInfo *sfnt.Font, input string) (lookups gtab.LookupList, err erro
 
 	cmap, err := fontInfo.CMapTable.GetBest()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	_, tokens := lex(input)
-	p := &parser{
+	p = &parser{
 		tokens: tokens,
 
 		fontInfo: fontInfo,
 		cmap:     cmap,
 		byName:   byName,
+
+		scripts: make(gtab.ScriptListInfo),
 	}
 
 	defer func() {
@@ -73,7 +137,7 @@ func Parse(fontInfo *sfnt.Font, input string) (lookups gtab.LookupList, err erro
 	}()
 
 	lookups = p.parse()
-	return
+	return p, lookups, nil
 }
 
 type parser struct {
@@ -83,6 +147,9 @@ type parser struct {
 	fontInfo *sfnt.Font
 	cmap     cmap.Subtable
 	byName   map[string]glyph.ID
+
+	features []*gtab.Feature
+	scripts  gtab.ScriptListInfo
 }
 
 func (p *parser) parse() (lookups gtab.LookupList) {
@@ -128,6 +195,10 @@ func (p *parser) parse() (lookups gtab.LookupList) {
 		case isIdentifier(item, "GPOS4"):
 			l := p.readGpos4()
 			lookups = append(lookups, l)
+		case isIdentifier(item, "feature"):
+			p.readFeature()
+		case isIdentifier(item, "script"):
+			p.readScript()
 		default:
 			p.fatal("unexpected %s", item)
 		}
@@ -1140,6 +1211,112 @@ gsubLoop:
 	return lookup
 }
 
+// readFeature reads a feature declaration.
+// Syntax:
+//
+//	feature <tag>: <lookup indices> [params <type> <values>]
+func (p *parser) readFeature() {
+	tag := p.readTag()
+	if len(tag) != 4 {
+		p.fatal("invalid feature tag %q", tag)
+	}
+	p.required(itemColon, ":")
+
+	f := &gtab.Feature{Tag: tag}
+	for p.peek().typ == itemInteger {
+		f.Lookups = append(f.Lookups, gtab.LookupIndex(p.readUint16()))
+	}
+	if p.optionalIdentifier("params") {
+		f.Params = p.readFeatureParams()
+	}
+	p.features = append(p.features, f)
+}
+
+func (p *parser) readFeatureParams() gtab.FeatureParams {
+	switch tp := p.readIdentifier(); tp {
+	case "size":
+		return &gtab.FeatureParamsSize{
+			DesignSize:      p.readUint16(),
+			SubfamilyID:     p.readUint16(),
+			SubfamilyNameID: p.readUint16(),
+			RangeStart:      p.readUint16(),
+			RangeEnd:        p.readUint16(),
+		}
+	case "ss":
+		return &gtab.FeatureParamsStylisticSet{
+			Version:  p.readUint16(),
+			UINameID: p.readUint16(),
+		}
+	case "cv":
+		params := &gtab.FeatureParamsCharacterVariants{
+			Format:                  p.readUint16(),
+			FeatUILabelNameID:       p.readUint16(),
+			FeatUITooltipTextNameID: p.readUint16(),
+			SampleTextNameID:        p.readUint16(),
+			NumNamedParameters:      p.readUint16(),
+			FirstParamUILabelNameID: p.readUint16(),
+		}
+		for p.peek().typ == itemInteger {
+			r := p.readInteger()
+			if r < 0 || r > 0xFFFFFF {
+				p.fatal("invalid character %d", r)
+			}
+			params.Characters = append(params.Characters, rune(r))
+		}
+		return params
+	default:
+		p.fatal("unknown feature parameters %q", tp)
+		return nil
+	}
+}
+
+// readScript reads a script declaration.
+// Syntax:
+//
+//	script <script tag> <language tag>: [required <feature index>] <feature indices>
+func (p *parser) readScript() {
+	script := p.readTag()
+	lang := p.readTag()
+	tag, err := gtab.LanguageTag(script, lang)
+	if err != nil {
+		p.fatal("invalid language system %s %s: %v", script, lang, err)
+	}
+	if _, dup := p.scripts[tag]; dup {
+		p.fatal("duplicate language system %s %s", script, lang)
+	}
+	p.required(itemColon, ":")
+
+	features := &gtab.Features{Required: 0xFFFF}
+	if p.optionalIdentifier("required") {
+		features.Required = gtab.FeatureIndex(p.readUint16())
+		if features.Required == 0xFFFF {
+			p.fatal("invalid feature index %d", features.Required)
+		}
+	}
+	for p.peek().typ == itemInteger {
+		features.Optional = append(features.Optional, gtab.FeatureIndex(p.readUint16()))
+	}
+	p.scripts[tag] = features
+}
+
+// readTag reads an OpenType tag, given either as an identifier or as
+// a string.
+func (p *parser) readTag() string {
+	item := p.readItem()
+	switch item.typ {
+	case itemIdentifier:
+		return item.val
+	case itemString:
+		var tag []rune
+		for r := range decodeString(item.val) {
+			tag = append(tag, r)
+		}
+		return string(tag)
+	}
+	p.fatal("expected tag, got %s", item)
+	return ""
+}
+
 func (p *parser) readLookupFlags() gtab.LookupFlags {
 	var flags gtab.LookupFlags
 	for {
@@ -1150,10 +1327,12 @@ func (p *parser) readLookupFlags() gtab.LookupFlags {
 		switch which {
 		case "marks":
 			flags |= gtab.IgnoreMarks
-		case "ligs":
+		case "ligs", "lig":
 			flags |= gtab.IgnoreLigatures
 		case "base":
 			flags |= gtab.IgnoreBaseGlyphs
+		case "rtl":
+			flags |= gtab.RightToLeft
 		default:
 			p.fatal("unknown lookup flag: %s", which)
 		}
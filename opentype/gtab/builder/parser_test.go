@@ -326,3 +326,69 @@ func FuzzGpos4(f *testing.F) {
 		}
 	})
 }
+
+func TestInfoRoundTrip(t *testing.T) {
+	fontInfo := debug.MakeSimpleFont()
+
+	gsub, err := ParseInfo(fontInfo, `
+	GSUB1: A->B, M->N
+	GSUB4: -marks -lig -rtl A A A -> B, A A -> C
+	feature liga: 1
+	feature smcp: 0
+	feature ss01: 0 params ss 0 256
+	feature cv01: params cv 0 257 0 0 2 258 65 66
+	script DFLT dflt: 0 1
+	script latn dflt: 0 1 2
+	script latn TRK: required 1 0 3
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fontInfo.Gsub = gsub
+	gsub2, err := ParseInfo(fontInfo, ExplainGsub(fontInfo)+ExplainFeatures(gsub))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := cmp.Diff(gsub, gsub2); d != "" {
+		t.Error(d)
+	}
+
+	gpos, err := ParseInfo(fontInfo, `
+	GPOS1: [A-C] -> y+10
+	GPOS2: A V -> dx-100
+	feature kern: 1
+	feature size: params size 100 0 0 80 120
+	script DFLT dflt: 0 1
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fontInfo.Gpos = gpos
+	if n := len(ExplainGpos(fontInfo)); n != len(gpos.LookupList) {
+		t.Errorf("expected %d lookup descriptions, got %d", len(gpos.LookupList), n)
+	}
+	desc := strings.Join(ExplainGpos(fontInfo), "\n") + "\n" + ExplainFeatures(gpos)
+	gpos2, err := ParseInfo(fontInfo, desc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := cmp.Diff(gpos, gpos2); d != "" {
+		t.Error(d)
+	}
+}
+
+func TestInfoErrors(t *testing.T) {
+	fontInfo := debug.MakeSimpleFont()
+	for _, desc := range []string{
+		"GSUB1: A->B\nfeature liga: 1",
+		"GSUB1: A->B\nfeature liga: 0\nscript latn dflt: 1",
+		"GSUB1: A->B\nfeature liga: 0\nscript latn dflt: 0\nscript latn dflt: 0",
+		"feature toolong: 0",
+		"feature test: params xx 1",
+	} {
+		_, err := ParseInfo(fontInfo, desc)
+		if err == nil {
+			t.Errorf("%q: expected error", desc)
+		}
+	}
+}
@@ -0,0 +1,659 @@
+// seehuhn.de/go/sfnt - a library for reading and writing font files
+// Copyright (C) 2026  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package gtab
+
+import (
+	"maps"
+	"slices"
+
+	"golang.org/x/text/language"
+
+	"seehuhn.de/go/sfnt/glyph"
+	"seehuhn.de/go/sfnt/opentype/classdef"
+	"seehuhn.de/go/sfnt/opentype/coverage"
+)
+
+// Closure returns all glyphs which can result from the given glyphs, when the
+// GSUB lookups for the given language and features are applied.  The
+// required feature of the language is always included.  The result contains
+// the given glyphs and is sorted by glyph ID.
+//
+// See [Info.LookupClosure] for details.
+func (info *Info) Closure(lang language.Tag, includeFeature map[string]bool, glyphs []glyph.ID) []glyph.ID {
+	return info.LookupClosure(info.FindLookups(lang, includeFeature), glyphs)
+}
+
+// LookupClosure returns all glyphs which can result from the given glyphs,
+// when the given GSUB lookups are applied to text consisting of these
+// glyphs.  The result contains the given glyphs and is sorted by glyph ID.
+//
+// All GSUB lookup types are handled.  Nested lookups of contextual rules are
+// followed, if the context can be formed from glyphs in the closure.  Lookup
+// flags are ignored.  The result may contain glyphs which cannot actually
+// occur in the output, but no glyph which can occur is missing.
+func (info *Info) LookupClosure(lookups []LookupIndex, glyphs []glyph.ID) []glyph.ID {
+	c := newClosure(info.LookupList)
+	for _, gid := range glyphs {
+		c.seen[gid] = true
+	}
+
+	c.changed = true
+	for c.changed {
+		c.startPass()
+		for _, idx := range lookups {
+			c.closeLookup(idx, nil, nil, 0)
+		}
+	}
+
+	return slices.Sorted(maps.Keys(c.seen))
+}
+
+// GlyphClosure computes the closure of a growing set of glyphs under a
+// list of GSUB lookups, as described for [Info.LookupClosure].  When glyphs
+// are added, only the substitutions which involve new glyphs are examined.
+type GlyphClosure struct {
+	c       *closure
+	lookups []LookupIndex
+}
+
+// NewGlyphClosure returns a new GlyphClosure for the given GSUB lookups.
+// Initially, the set of glyphs is empty.
+func (info *Info) NewGlyphClosure(lookups []LookupIndex) *GlyphClosure {
+	return &GlyphClosure{
+		c:       newClosure(info.LookupList),
+		lookups: lookups,
+	}
+}
+
+// Add adds glyphs to the set and extends the closure.  The glyphs which
+// were not contained in the closure before, including the new glyphs from
+// the argument, are returned in the order in which they were found.
+func (gc *GlyphClosure) Add(glyphs ...glyph.ID) []glyph.ID {
+	c := gc.c
+	c.added = nil
+	for _, gid := range glyphs {
+		if !c.seen[gid] {
+			c.seen[gid] = true
+			c.added = append(c.added, gid)
+		}
+	}
+
+	// Every round only examines substitutions which involve at least one
+	// glyph found in the previous round.  If the results of nested lookups
+	// grew during a round, the rules examined in this round may have used
+	// incomplete results, and the round is repeated.
+	start := 0
+	for start < len(c.added) {
+		c.fresh = make(map[glyph.ID]bool, len(c.added)-start)
+		for _, gid := range c.added[start:] {
+			c.fresh[gid] = true
+		}
+		end := len(c.added)
+		c.startPass()
+		for _, idx := range gc.lookups {
+			c.closeLookup(idx, nil, nil, 0)
+		}
+		if !c.nestedChanged {
+			start = end
+		}
+	}
+	c.fresh = nil
+
+	return c.added
+}
+
+// maxClosureDepth limits the nesting of contextual lookups followed
+// by the closure computation.
+const maxClosureDepth = 16
+
+type closure struct {
+	ll      LookupList
+	seen    map[glyph.ID]bool
+	changed bool
+
+	// nested holds the glyphs produced by nested lookups, for a given
+	// lookup and set of active glyphs.  The entries only grow, and are
+	// recomputed at most once per pass over the lookups.  The values in
+	// pass are false while a lookup is being computed, and true once the
+	// computation is complete.  This avoids the exponential cost of
+	// following the same nested lookups over and over again.
+	nested        map[nestedKey]map[glyph.ID]bool
+	pass          map[nestedKey]bool
+	nestedChanged bool
+
+	// The following fields are only used by GlyphClosure.
+	added  []glyph.ID           // glyphs added to seen, in order
+	fresh  map[glyph.ID]bool    // glyphs found in the previous round
+	simple map[LookupIndex]bool // cache for isSimple
+}
+
+type nestedKey struct {
+	lookup LookupIndex
+	active string
+}
+
+func newClosure(ll LookupList) *closure {
+	return &closure{
+		ll:     ll,
+		seen:   make(map[glyph.ID]bool),
+		nested: make(map[nestedKey]map[glyph.ID]bool),
+		simple: make(map[LookupIndex]bool),
+	}
+}
+
+// startPass must be called before every pass over the lookups.
+func (c *closure) startPass() {
+	c.changed = false
+	c.nestedChanged = false
+	c.pass = make(map[nestedKey]bool)
+}
+
+// closeLookup adds the glyphs which can be produced by the given lookup to
+// the closure.  If active is not nil, only substitutions of glyphs in active
+// are considered for the current position, and all glyphs produced by the
+// lookup are also added to out.
+//
+// Nested lookups, where active is not nil, are computed at most once per
+// pass.  If a nested lookup is reached again while it is being computed,
+// the result from the previous pass is used.  Since the passes are repeated
+// until nothing changes any more, the final result is complete.
+func (c *closure) closeLookup(idx LookupIndex, active, out map[glyph.ID]bool, depth int) {
+	if int(idx) >= len(c.ll) {
+		return
+	}
+	if active == nil {
+		c.closeSubtables(idx, nil, nil, depth)
+		return
+	}
+
+	key := nestedKey{lookup: idx, active: glyphSetKey(active)}
+	_, visited := c.pass[key]
+	if !visited && depth <= maxClosureDepth {
+		c.pass[key] = false
+		produced := make(map[glyph.ID]bool)
+		c.closeSubtables(idx, active, produced, depth)
+		c.pass[key] = true
+
+		prev := c.nested[key]
+		if prev == nil {
+			prev = make(map[glyph.ID]bool)
+			c.nested[key] = prev
+		}
+		for gid := range produced {
+			if !prev[gid] {
+				prev[gid] = true
+				c.changed = true
+				c.nestedChanged = true
+			}
+		}
+	}
+	maps.Copy(out, c.nested[key])
+}
+
+// glyphSetKey returns a string which identifies a set of glyphs.
+func glyphSetKey(set map[glyph.ID]bool) string {
+	gids := slices.Sorted(maps.Keys(set))
+	b := make([]byte, 2*len(gids))
+	for i, gid := range gids {
+		b[2*i] = byte(gid >> 8)
+		b[2*i+1] = byte(gid)
+	}
+	return string(b)
+}
+
+// closeSubtables implements closeLookup for the subtables of a lookup.
+func (c *closure) closeSubtables(idx LookupIndex, active, out map[glyph.ID]bool, depth int) {
+	isActive := func(gid glyph.ID) bool {
+		return c.seen[gid] && (active == nil || active[gid])
+	}
+	emit := func(gid glyph.ID) {
+		if out != nil {
+			out[gid] = true
+		}
+		if !c.seen[gid] {
+			c.seen[gid] = true
+			c.added = append(c.added, gid)
+			c.changed = true
+		}
+	}
+
+	// For incremental updates, substitutions at the top level are only
+	// examined if they involve a new glyph.  Nested lookups are always
+	// examined, since their input is restricted by the context anyway.
+	incremental := c.fresh != nil && active == nil
+	isNew := func(gid glyph.ID) bool {
+		return !incremental || c.fresh[gid]
+	}
+	anyNew := func(seqs ...[]glyph.ID) bool {
+		if !incremental {
+			return true
+		}
+		for _, seq := range seqs {
+			if slices.ContainsFunc(seq, isNew) {
+				return true
+			}
+		}
+		return false
+	}
+	// ruleNew reports whether a contextual rule needs to be examined.
+	// Nested lookups which are not simple can depend on glyphs outside
+	// the context, so these rules are always examined.
+	ruleNew := func(actions []SeqLookup, sets ...[]map[glyph.ID]bool) bool {
+		if !incremental || !c.isSimple(actions) {
+			return true
+		}
+		for _, set := range sets {
+			for _, m := range set {
+				for gid := range m {
+					if isNew(gid) {
+						return true
+					}
+				}
+			}
+		}
+		return false
+	}
+
+	for _, subtable := range c.ll[idx].Subtables {
+		switch l := subtable.(type) {
+		case *Gsub1_1:
+			for gid := range l.Cov {
+				if isActive(gid) && isNew(gid) {
+					emit(gid + l.Delta)
+				}
+			}
+		case *Gsub1_2:
+			for gid, k := range l.Cov {
+				if isActive(gid) && isNew(gid) && k < len(l.SubstituteGlyphIDs) {
+					emit(l.SubstituteGlyphIDs[k])
+				}
+			}
+		case *Gsub2_1:
+			for gid, k := range l.Cov {
+				if isActive(gid) && isNew(gid) && k < len(l.Repl) {
+					for _, r := range l.Repl[k] {
+						emit(r)
+					}
+				}
+			}
+		case *Gsub3_1:
+			for gid, k := range l.Cov {
+				if isActive(gid) && isNew(gid) && k < len(l.Alternates) {
+					for _, r := range l.Alternates[k] {
+						emit(r)
+					}
+				}
+			}
+		case *Gsub4_1:
+			for gid, k := range l.Cov {
+				if !isActive(gid) || k >= len(l.Repl) {
+					continue
+				}
+				for _, lig := range l.Repl[k] {
+					if c.allSeen(lig.In) && anyNew([]glyph.ID{gid}, lig.In) {
+						emit(lig.Out)
+					}
+				}
+			}
+		case *Gsub8_1:
+			if !c.coversAll(l.Backtrack) || !c.coversAll(l.Lookahead) {
+				continue
+			}
+			contextNew := c.coversAny(l.Backtrack, isNew) || c.coversAny(l.Lookahead, isNew)
+			for gid, k := range l.Input {
+				if isActive(gid) && (contextNew || isNew(gid)) && k < len(l.SubstituteGlyphIDs) {
+					emit(l.SubstituteGlyphIDs[k])
+				}
+			}
+
+		case *SeqContext1:
+			for gid, k := range l.Cov {
+				if !isActive(gid) || k >= len(l.Rules) {
+					continue
+				}
+				for _, rule := range l.Rules[k] {
+					if !c.allSeen(rule.Input) {
+						continue
+					}
+					if !anyNew([]glyph.ID{gid}, rule.Input) && !ruleNew(rule.Actions) {
+						continue
+					}
+					c.applyActions(rule.Actions, glyphSets(gid, rule.Input), out, depth)
+				}
+			}
+		case *SeqContext2:
+			first := c.firstByClass(l.Cov, l.Input, isActive)
+			input := c.byClass(l.Input)
+			for cls, rules := range l.Rules {
+				if first[uint16(cls)] == nil {
+					continue
+				}
+				for _, rule := range rules {
+					sets, ok := classSets(input, rule.Input)
+					if !ok {
+						continue
+					}
+					sets = append([]map[glyph.ID]bool{maps.Clone(first[uint16(cls)])}, sets...)
+					if !ruleNew(rule.Actions, sets) {
+						continue
+					}
+					c.applyActions(rule.Actions, sets, out, depth)
+				}
+			}
+		case *SeqContext3:
+			sets, ok := c.coverageSets(l.Input, isActive)
+			if ok && ruleNew(l.Actions, sets) {
+				c.applyActions(l.Actions, sets, out, depth)
+			}
+
+		case *ChainedSeqContext1:
+			for gid, k := range l.Cov {
+				if !isActive(gid) || k >= len(l.Rules) {
+					continue
+				}
+				for _, rule := range l.Rules[k] {
+					if !c.allSeen(rule.Backtrack) || !c.allSeen(rule.Input) || !c.allSeen(rule.Lookahead) {
+						continue
+					}
+					if !anyNew([]glyph.ID{gid}, rule.Input, rule.Backtrack, rule.Lookahead) && !ruleNew(rule.Actions) {
+						continue
+					}
+					c.applyActions(rule.Actions, glyphSets(gid, rule.Input), out, depth)
+				}
+			}
+		case *ChainedSeqContext2:
+			first := c.firstByClass(l.Cov, l.Input, isActive)
+			backtrack := c.byClass(l.Backtrack)
+			input := c.byClass(l.Input)
+			lookahead := c.byClass(l.Lookahead)
+			for cls, rules := range l.Rules {
+				if first[uint16(cls)] == nil {
+					continue
+				}
+				for _, rule := range rules {
+					bt, ok := classSets(backtrack, rule.Backtrack)
+					if !ok {
+						continue
+					}
+					la, ok := classSets(lookahead, rule.Lookahead)
+					if !ok {
+						continue
+					}
+					sets, ok := classSets(input, rule.Input)
+					if !ok {
+						continue
+					}
+					sets = append([]map[glyph.ID]bool{maps.Clone(first[uint16(cls)])}, sets...)
+					if !ruleNew(rule.Actions, sets, bt, la) {
+						continue
+					}
+					c.applyActions(rule.Actions, sets, out, depth)
+				}
+			}
+		case *ChainedSeqContext3:
+			if !c.coversAllSets(l.Backtrack) || !c.coversAllSets(l.Lookahead) {
+				continue
+			}
+			sets, ok := c.coverageSets(l.Input, isActive)
+			contextNew := c.coversAnySet(l.Backtrack, isNew) || c.coversAnySet(l.Lookahead, isNew)
+			if ok && (contextNew || ruleNew(l.Actions, sets)) {
+				c.applyActions(l.Actions, sets, out, depth)
+			}
+		}
+	}
+}
+
+// applyActions follows the nested lookups of a contextual rule.  The
+// argument input gives the possible glyphs at each input position.
+// Glyphs produced by a nested lookup are added to the possible glyphs at
+// the corresponding position, for use by later actions.
+//
+// After a nested lookup which can change the length of the glyph sequence,
+// the later positions no longer correspond to the original input positions.
+// From there on, all positions are assumed to contain any of the glyphs at
+// or after the position of the nested lookup.
+func (c *closure) applyActions(actions []SeqLookup, input []map[glyph.ID]bool, out map[glyph.ID]bool, depth int) {
+	widenFrom := len(input)
+	var widened map[glyph.ID]bool // the possible glyphs at positions >= widenFrom
+	for _, a := range actions {
+		idx := int(a.SequenceIndex)
+		var active map[glyph.ID]bool
+		switch {
+		case idx >= widenFrom && widened != nil:
+			active = widened
+		case idx < len(input):
+			active = input[idx]
+		default:
+			continue
+		}
+		produced := make(map[glyph.ID]bool)
+		c.closeLookup(a.LookupListIndex, active, produced, depth+1)
+		for gid := range produced {
+			active[gid] = true
+			if out != nil {
+				out[gid] = true
+			}
+		}
+
+		if idx < widenFrom && c.changesLength(a.LookupListIndex) {
+			if widened == nil {
+				widened = make(map[glyph.ID]bool)
+			}
+			for _, set := range input[idx:widenFrom] {
+				maps.Copy(widened, set)
+			}
+			widenFrom = idx
+		}
+	}
+}
+
+// changesLength reports whether the given lookup can change the number of
+// glyphs in the glyph sequence.
+func (c *closure) changesLength(idx LookupIndex) bool {
+	if int(idx) >= len(c.ll) {
+		return false
+	}
+	for _, subtable := range c.ll[idx].Subtables {
+		switch subtable.(type) {
+		case *Gsub1_1, *Gsub1_2, *Gsub3_1, *Gsub8_1:
+			// pass
+		default:
+			return true
+		}
+	}
+	return false
+}
+
+// isSimple reports whether all nested lookups of a contextual rule only
+// consist of single, multiple and alternate substitutions.  The output of
+// these depends only on the input glyph.
+func (c *closure) isSimple(actions []SeqLookup) bool {
+	for _, a := range actions {
+		simple, ok := c.simple[a.LookupListIndex]
+		if !ok {
+			simple = c.isSimpleLookup(a.LookupListIndex)
+			c.simple[a.LookupListIndex] = simple
+		}
+		if !simple {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *closure) isSimpleLookup(idx LookupIndex) bool {
+	if int(idx) >= len(c.ll) {
+		return true
+	}
+	for _, subtable := range c.ll[idx].Subtables {
+		switch subtable.(type) {
+		case *Gsub1_1, *Gsub1_2, *Gsub2_1, *Gsub3_1:
+			// pass
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// allSeen reports whether all glyphs in seq are in the closure.
+func (c *closure) allSeen(seq []glyph.ID) bool {
+	for _, gid := range seq {
+		if !c.seen[gid] {
+			return false
+		}
+	}
+	return true
+}
+
+// coversAll reports whether every coverage table contains at least one glyph
+// of the closure.
+func (c *closure) coversAll(covs []coverage.Table) bool {
+	for _, cov := range covs {
+		found := false
+		for gid := range cov {
+			if c.seen[gid] {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// coversAny reports whether some coverage table contains a glyph of the
+// closure for which pred returns true.
+func (c *closure) coversAny(covs []coverage.Table, pred func(glyph.ID) bool) bool {
+	for _, cov := range covs {
+		for gid := range cov {
+			if c.seen[gid] && pred(gid) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// coversAnySet is like coversAny, but for coverage sets.
+func (c *closure) coversAnySet(covs []coverage.Set, pred func(glyph.ID) bool) bool {
+	for _, cov := range covs {
+		for gid := range cov {
+			if c.seen[gid] && pred(gid) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// coversAllSets is like coversAll, but for coverage sets.
+func (c *closure) coversAllSets(covs []coverage.Set) bool {
+	for _, cov := range covs {
+		found := false
+		for gid := range cov {
+			if c.seen[gid] {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// coverageSets returns the glyphs of the closure at each input position of
+// a format 3 context.  For the first position, only glyphs where isActive
+// returns true are included.  The second return value is false, if one of
+// the positions cannot be matched.
+func (c *closure) coverageSets(covs []coverage.Set, isActive func(glyph.ID) bool) ([]map[glyph.ID]bool, bool) {
+	if len(covs) == 0 {
+		return nil, false
+	}
+	sets := make([]map[glyph.ID]bool, len(covs))
+	for i, cov := range covs {
+		set := make(map[glyph.ID]bool)
+		for gid := range cov {
+			if c.seen[gid] && (i > 0 || isActive(gid)) {
+				set[gid] = true
+			}
+		}
+		if len(set) == 0 {
+			return nil, false
+		}
+		sets[i] = set
+	}
+	return sets, true
+}
+
+// byClass groups the glyphs of the closure by their class.
+func (c *closure) byClass(cd classdef.Table) map[uint16]map[glyph.ID]bool {
+	res := make(map[uint16]map[glyph.ID]bool)
+	for gid := range c.seen {
+		cls := cd[gid]
+		if res[cls] == nil {
+			res[cls] = make(map[glyph.ID]bool)
+		}
+		res[cls][gid] = true
+	}
+	return res
+}
+
+// firstByClass groups the active glyphs in cov by their class.
+func (c *closure) firstByClass(cov coverage.Table, cd classdef.Table, isActive func(glyph.ID) bool) map[uint16]map[glyph.ID]bool {
+	res := make(map[uint16]map[glyph.ID]bool)
+	for gid := range cov {
+		if !isActive(gid) {
+			continue
+		}
+		cls := cd[gid]
+		if res[cls] == nil {
+			res[cls] = make(map[glyph.ID]bool)
+		}
+		res[cls][gid] = true
+	}
+	return res
+}
+
+// classSets returns copies of the glyph sets for a sequence of classes.
+// The second return value is false, if one of the classes contains no
+// glyphs.
+func classSets(byClass map[uint16]map[glyph.ID]bool, classes []uint16) ([]map[glyph.ID]bool, bool) {
+	sets := make([]map[glyph.ID]bool, len(classes))
+	for i, cls := range classes {
+		set := byClass[cls]
+		if len(set) == 0 {
+			return nil, false
+		}
+		sets[i] = maps.Clone(set)
+	}
+	return sets, true
+}
+
+// glyphSets returns single-glyph sets for a rule of a format 1 context.
+func glyphSets(first glyph.ID, rest []glyph.ID) []map[glyph.ID]bool {
+	sets := make([]map[glyph.ID]bool, 0, len(rest)+1)
+	sets = append(sets, map[glyph.ID]bool{first: true})
+	for _, gid := range rest {
+		sets = append(sets, map[glyph.ID]bool{gid: true})
+	}
+	return sets
+}
@@ -0,0 +1,201 @@
+// seehuhn.de/go/sfnt - a library for reading and writing font files
+// Copyright (C) 2026  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package gtab_test
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/text/language"
+
+	"seehuhn.de/go/sfnt/glyph"
+	"seehuhn.de/go/sfnt/internal/debug"
+	"seehuhn.de/go/sfnt/opentype/gtab"
+	"seehuhn.de/go/sfnt/opentype/gtab/builder"
+	"seehuhn.de/go/sfnt/opentype/gtab/testcases"
+)
+
+func TestClosure(t *testing.T) {
+	fontInfo := debug.MakeSimpleFont()
+	gsub, err := builder.ParseInfo(fontInfo, `
+	GSUB1: A->B, B->F
+	GSUB4: C D -> E
+	GSUB6: F | G | H -> 3@0
+	GSUB1: G->I, K->L
+	GSUB8: [J] | M -> N | [O]
+	GSUB1: P->Q
+	feature liga: 0 1 2 4
+	feature smcp: 5
+	script DFLT dflt: 0 1
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cmap, err := fontInfo.CMapTable.GetBest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gids := func(s string) []glyph.ID {
+		var res []glyph.ID
+		for _, r := range s {
+			res = append(res, cmap.Lookup(r))
+		}
+		return res
+	}
+	text := func(gg []glyph.ID) string {
+		var res []rune
+		for _, gid := range gg {
+			res = append(res, rune(fontInfo.GlyphName(gid)[0]))
+		}
+		return string(res)
+	}
+
+	cases := []struct {
+		in       string
+		features map[string]bool
+		out      string
+	}{
+		{"A", nil, "A"},
+		{"A", map[string]bool{"liga": true}, "ABF"},
+		{"C", map[string]bool{"liga": true}, "C"},
+		{"CD", map[string]bool{"liga": true}, "CDE"},
+		{"GK", map[string]bool{"liga": true}, "GK"},
+		{"FGHK", map[string]bool{"liga": true}, "FGHIK"},
+		{"AGH", map[string]bool{"liga": true}, "ABFGHI"},
+		{"MO", map[string]bool{"liga": true}, "MO"},
+		{"JMO", map[string]bool{"liga": true}, "JMNO"},
+		{"P", map[string]bool{"liga": true}, "P"},
+		{"P", map[string]bool{"smcp": true}, "PQ"},
+	}
+	for _, test := range cases {
+		got := text(gsub.Closure(language.English, test.features, gids(test.in)))
+		if got != test.out {
+			t.Errorf("%s %v: expected %q, got %q", test.in, test.features, test.out, got)
+		}
+	}
+}
+
+// TestClosureLengthChange checks that nested lookups after a multiple
+// substitution are applied to the glyphs at the shifted positions.
+func TestClosureLengthChange(t *testing.T) {
+	fontInfo := debug.MakeSimpleFont()
+	gsub, err := builder.ParseInfo(fontInfo, `
+	GSUB5: X A Y -> 1@1 2@2
+	GSUB2: A -> "BC"
+	GSUB1: C -> D
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cmap, err := fontInfo.CMapTable.GetBest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var gids []glyph.ID
+	for _, r := range "XAY" {
+		gids = append(gids, cmap.Lookup(r))
+	}
+
+	// After the multiple substitution, the sequence is "XBCY" and the
+	// second action replaces C by D.
+	var got []rune
+	for _, gid := range gsub.LookupClosure([]gtab.LookupIndex{0}, gids) {
+		got = append(got, rune(fontInfo.GlyphName(gid)[0]))
+	}
+	if string(got) != "ABCDXY" {
+		t.Errorf("expected %q, got %q", "ABCDXY", string(got))
+	}
+}
+
+func TestGlyphClosure(t *testing.T) {
+	fontInfo := debug.MakeSimpleFont()
+	gsub, err := builder.ParseInfo(fontInfo, `
+	GSUB1: A->B, B->F
+	GSUB4: C D -> E
+	GSUB6: F | G | H -> 3@0
+	GSUB1: G->I, K->L
+	GSUB8: [J] | M -> N | [O]
+	GSUB5: X Y -> 6@1
+	GSUB4: Y Z -> W
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lookups := []gtab.LookupIndex{0, 1, 2, 4, 5}
+
+	cmap, err := fontInfo.CMapTable.GetBest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Glyphs are added one by one, in an order where later glyphs
+	// complete the contexts and ligatures of earlier ones.
+	for _, in := range []string{"HGFA", "DC", "OMJ", "ZYX", "XYZ", "KAGHZCXYDJOM"} {
+		gc := gsub.NewGlyphClosure(lookups)
+		var all, prefix []glyph.ID
+		for _, r := range in {
+			gid := cmap.Lookup(r)
+			prefix = append(prefix, gid)
+			all = append(all, gc.Add(gid)...)
+
+			got := slices.Sorted(slices.Values(all))
+			want := gsub.LookupClosure(lookups, prefix)
+			if d := cmp.Diff(want, got); d != "" {
+				t.Errorf("%s: %s", in, d)
+			}
+		}
+	}
+}
+
+// TestClosureTestcases checks that the closure contains the output of all
+// GSUB test cases, and that it is computed quickly.
+func TestClosureTestcases(t *testing.T) {
+	fontInfo := debug.MakeSimpleFont()
+	cmap, err := fontInfo.CMapTable.GetBest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range testcases.Gsub {
+		lookupList, err := builder.Parse(fontInfo, test.Desc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		info := &gtab.Info{LookupList: lookupList}
+
+		var in []glyph.ID
+		for _, r := range test.In {
+			in = append(in, cmap.Lookup(r))
+		}
+
+		start := time.Now()
+		closure := info.LookupClosure([]gtab.LookupIndex{0}, in)
+		if d := time.Since(start); d > time.Second {
+			t.Errorf("%s: closure took %v", test.Name, d)
+		}
+
+		for _, r := range test.Out {
+			if !slices.Contains(closure, cmap.Lookup(r)) {
+				t.Errorf("%s: %q missing from closure", test.Name, r)
+			}
+		}
+	}
+}
@@ -129,7 +129,7 @@ func (l *Gpos1_1) apply(ctx *Context, a, b int) int {
 		return -1
 	}
 
-	l.Adjust.Apply(&seq[a])
+	ctx.applyValue(l.Adjust, &seq[a])
 	return a + 1
 }
 
@@ -219,7 +219,7 @@ func (l *Gpos1_2) apply(ctx *Context, a, b int) int {
 	if !ok {
 		return -1
 	}
-	l.Adjust[idx].Apply(&seq[a])
+	ctx.applyValue(l.Adjust[idx], &seq[a])
 	return a + 1
 }
 
@@ -304,11 +304,11 @@ func (l Gpos2_1) apply(ctx *Context, a, b int) int {
 		return -1
 	}
 
-	adj.First.Apply(&seq[a])
+	ctx.applyValue(adj.First, &seq[a])
 	if adj.Second == nil {
 		return p
 	}
-	adj.Second.Apply(&seq[p])
+	ctx.applyValue(adj.Second, &seq[p])
 	return p + 1
 }
 
@@ -569,11 +569,11 @@ func (l *Gpos2_2) apply(ctx *Context, a, b int) int {
 	}
 	adj := row[class2]
 
-	adj.First.Apply(&seq[a])
+	ctx.applyValue(adj.First, &seq[a])
 	if adj.Second == nil {
 		return p
 	}
-	adj.Second.Apply(&seq[p])
+	ctx.applyValue(adj.Second, &seq[p])
 	return p + 1
 }
 
@@ -779,7 +779,9 @@ func (l *Gpos3_1) apply(ctx *Context, a, b int) int {
 		if ok {
 			prevRec := l.Records[prev]
 			if prevRec.Exit != nil && rec.Entry != nil {
-				seq[a].YOffset = prevGlyph.YOffset + prevRec.Exit.Y - rec.Entry.Y
+				_, exitY := ctx.anchorPos(prevRec.Exit)
+				_, entryY := ctx.anchorPos(rec.Entry)
+				seq[a].YOffset = prevGlyph.YOffset + exitY - entryY
 			}
 		}
 	}
@@ -789,7 +791,9 @@ func (l *Gpos3_1) apply(ctx *Context, a, b int) int {
 		if ok {
 			nextRec := l.Records[next]
 			if rec.Exit != nil && nextRec.Entry != nil {
-				seq[a].Advance = seq[a].XOffset + rec.Exit.X - nextGlyph.XOffset - nextRec.Entry.X
+				exitX, _ := ctx.anchorPos(rec.Exit)
+				entryX, _ := ctx.anchorPos(nextRec.Entry)
+				seq[a].Advance = seq[a].XOffset + exitX - nextGlyph.XOffset - entryX
 			}
 		}
 	}
@@ -170,8 +170,10 @@ func (l *Gpos4_1) apply(ctx *Context, a, b int) int {
 		return -1
 	}
 
-	dx := baseRecord.X - markRecord.X
-	dy := baseRecord.Y - markRecord.Y
+	baseX, baseY := ctx.anchorPos(baseRecord)
+	markX, markY := ctx.anchorPos(&markRecord.Table)
+	dx := baseX - markX
+	dy := baseY - markY
 	for i := p; i < a; i++ {
 		dx -= seq[i].Advance
 	}
@@ -211,8 +211,10 @@ func (l *Gpos5_1) apply(ctx *Context, a, b int) int {
 		return -1
 	}
 
-	dx := ligRecord.X - markRecord.X
-	dy := ligRecord.Y - markRecord.Y
+	baseX, baseY := ctx.anchorPos(ligRecord)
+	markX, markY := ctx.anchorPos(&markRecord.Table)
+	dx := baseX - markX
+	dy := baseY - markY
 	for i := p; i < a; i++ {
 		dx -= seq[i].Advance
 	}
@@ -168,8 +168,10 @@ func (l *Gpos6_1) apply(ctx *Context, a, b int) int {
 		return -1
 	}
 
-	dx := mark2Record.X - mark1Record.X
-	dy := mark2Record.Y - mark1Record.Y
+	baseX, baseY := ctx.anchorPos(mark2Record)
+	markX, markY := ctx.anchorPos(&mark1Record.Table)
+	dx := baseX - markX
+	dy := baseY - markY
 	for i := p; i < a; i++ {
 		dx -= seq[i].Advance
 	}
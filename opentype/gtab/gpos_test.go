@@ -915,3 +915,53 @@ func assertPanics(t *testing.T, fn func()) {
 	}()
 	fn()
 }
+
+func TestDeviceTables(t *testing.T) {
+	dev := &device.Table{StartSize: 10, EndSize: 12, Deltas: []int8{-1, 0, 2}, DeltaFormat: 2}
+	kern := Gpos2_1{
+		{Left: 1, Right: 2}: &PairAdjust{
+			First: &GposValueRecord{XAdvance: -50, XAdvanceDev: dev},
+		},
+	}
+	mark := &Gpos4_1{
+		MarkCov: coverage.Table{3: 0},
+		BaseCov: coverage.Table{2: 0},
+		MarkArray: []markarray.Record{
+			{Class: 0, Table: anchor.Table{X: 0, Y: 0}},
+		},
+		BaseArray: [][]*anchor.Table{
+			{{X: 100, Y: 500, YDev: dev}},
+		},
+	}
+	lookupList := []*LookupTable{
+		{Meta: &LookupMetaInfo{LookupType: 2}, Subtables: []Subtable{kern}},
+		{Meta: &LookupMetaInfo{LookupType: 4}, Subtables: []Subtable{mark}},
+	}
+
+	cases := []struct {
+		xPPEM, yPPEM     uint16
+		advance, yOffset funit.Int16
+	}{
+		{0, 0, 1000 - 50, 500},         // device tables are ignored
+		{10, 10, 1000 - 50 - 100, 400}, // one pixel is 100 units
+		{11, 11, 1000 - 50, 500},       // zero delta
+		{12, 10, 1000 - 50 + 167, 400}, // two pixels, rounded
+		{20, 20, 1000 - 50, 500},       // outside the size range
+	}
+	for _, c := range cases {
+		ctx := NewContext(lookupList, nil, []LookupIndex{0, 1})
+		ctx.SetPPEM(1000, c.xPPEM, c.yPPEM)
+		seq := []glyph.Info{
+			{GID: 1, Advance: 1000},
+			{GID: 2, Advance: 200},
+			{GID: 3},
+		}
+		out := ctx.Apply(seq)
+		if out[0].Advance != c.advance {
+			t.Errorf("ppem %d: advance = %d, want %d", c.xPPEM, out[0].Advance, c.advance)
+		}
+		if out[2].YOffset != c.yOffset {
+			t.Errorf("ppem %d: mark offset = %d, want %d", c.yPPEM, out[2].YOffset, c.yOffset)
+		}
+	}
+}
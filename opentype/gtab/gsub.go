@@ -286,7 +286,7 @@ func (l *Gsub2_1) apply(ctx *Context, a, b int) int {
 		seq = seq[:len(seq)+k-1]
 		copy(seq[a+k:], seq[a+1:])
 		for i := 1; i < k; i++ {
-			seq[a+i] = glyph.Info{GID: repl[i]}
+			seq[a+i] = glyph.Info{GID: repl[i], Cluster: seq[a].Cluster}
 		}
 		ctx.seq = seq
 
@@ -358,6 +358,9 @@ func (l *Gsub2_1) encode() []byte {
 // `Alternates` table by the coverage index of the original GID.
 // Each alternate set must have at least one glyph.
 //
+// By default the first alternate is used.  A [LookupRange] with value k
+// selects the k-th alternate instead.
+//
 // https://docs.microsoft.com/en-us/typography/opentype/spec/gsub#31-alternate-substitution-format-1
 type Gsub3_1 struct {
 	Cov        coverage.Table
@@ -433,7 +436,12 @@ func (l *Gsub3_1) apply(ctx *Context, a, b int) int {
 		return -1
 	}
 
-	seq[a].GID = l.Alternates[idx][0]
+	// The lookup value selects the alternate, see [LookupRange].
+	alt := ctx.valueAt(a)
+	if alt < 1 || alt > len(l.Alternates[idx]) {
+		return -1
+	}
+	seq[a].GID = l.Alternates[idx][alt-1]
 
 	return a + 1
 }
@@ -649,7 +657,7 @@ ligLoop:
 				p++
 			}
 
-			if p >= b || seq[p].GID != ligGid { // no match
+			if p >= b || seq[p].GID != ligGid || ctx.valueAt(p) == 0 { // no match
 				continue ligLoop
 			}
 
@@ -658,12 +666,21 @@ ligLoop:
 			p++
 		}
 
+		// The ligature and the skipped marks form a single cluster.
+		cluster := seq[a].Cluster
+		for _, p := range matchPos[1:] {
+			cluster = min(cluster, seq[p].Cluster)
+		}
+		for _, p := range skipPos {
+			cluster = min(cluster, seq[p].Cluster)
+		}
+
 		// Insert the ligature glyph.  When marks were skipped between
 		// components, tag the ligature and those marks with a shared
 		// ligature id (and the component each mark follows) so that
 		// mark-to-ligature positioning can attach each mark to the right
 		// component.
-		ligInfo := glyph.Info{GID: lig.Out, Text: text}
+		ligInfo := glyph.Info{GID: lig.Out, Text: text, Cluster: cluster}
 		var ligID uint16
 		if len(skipPos) > 0 {
 			ligID = ctx.newLigID()
@@ -676,6 +693,7 @@ ligLoop:
 			m := seq[skip]
 			m.LigID = ligID
 			m.LigComp = skipComp[i]
+			m.Cluster = cluster
 			seq[a+i+1] = m
 		}
 
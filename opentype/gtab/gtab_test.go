@@ -68,6 +68,40 @@ func TestGetLookups(t *testing.T) {
 	}
 }
 
+func TestFindLookupRanges(t *testing.T) {
+	gtabInfo := Info{
+		ScriptList: map[language.Tag]*Features{
+			language.MustParse("und-Latn"): {
+				Required: 0xFFFF,
+				Optional: []FeatureIndex{0, 1},
+			},
+		},
+		FeatureList: []*Feature{
+			{Tag: "smcp", Lookups: []LookupIndex{0, 2}},
+			{Tag: "salt", Lookups: []LookupIndex{1, 7}}, // 7 is out of range
+			{Tag: "onum", Lookups: []LookupIndex{3}},    // not in ScriptList
+		},
+		LookupList: LookupList{
+			nil, nil, nil, nil,
+		},
+	}
+
+	ranges := []FeatureRange{
+		{Tag: "smcp", Start: 0, End: 5, Value: 1},
+		{Tag: "onum", Start: 0, End: 5, Value: 1},
+		{Tag: "salt", Start: 2, End: 3, Value: 2},
+	}
+	got := gtabInfo.FindLookupRanges(language.BritishEnglish, ranges)
+	want := []LookupRange{
+		{Lookup: 0, Start: 0, End: 5, Value: 1},
+		{Lookup: 2, Start: 0, End: 5, Value: 1},
+		{Lookup: 1, Start: 2, End: 3, Value: 2},
+	}
+	if d := cmp.Diff(want, got); d != "" {
+		t.Errorf("unexpected result (-want +got):\n%s", d)
+	}
+}
+
 func FuzzGtab(f *testing.F) {
 	info := &Info{}
 	f.Add(info.Encode())
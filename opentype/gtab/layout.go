@@ -29,8 +29,18 @@ type Context struct {
 	ll      LookupList
 	gdef    *gdef.Table
 
-	seq    []glyph.Info
-	lookup *LookupTable
+	// plan lists the lookups to apply, in order, together with the
+	// range restrictions for each lookup.
+	plan []plannedLookup
+
+	seq         []glyph.Info
+	lookup      *LookupTable
+	lookupIndex LookupIndex
+
+	// value and ranges describe where the current lookup is enabled,
+	// see valueAt.
+	value  int
+	ranges []LookupRange
 
 	// keep represents the lookup flags.  Glyphs for which keep returns false
 	// must be skipped when constructing the input sequence.
@@ -44,11 +54,21 @@ type Context struct {
 	// non-zero id for each one (see newLigID).
 	nextLigID uint16
 
+	// unitsPerEm, xPPEM and yPPEM give the text size used for Device
+	// tables, see SetPPEM.
+	unitsPerEm   uint16
+	xPPEM, yPPEM uint16
+
 	// maxLen caps the length of seq during substitution.  GSUB multiple
 	// substitution and contextual lookups can grow the sequence, and a
 	// malformed or malicious font can make it grow without bound; applying
 	// lookups stops once seq reaches this limit.
 	maxLen int
+
+	// trace, if not nil, records the lookups applied.  traceParent is the
+	// index of the step which triggered the current nested lookup, or -1.
+	trace       *Trace
+	traceParent int
 }
 
 // newLigID returns a fresh, non-zero ligature id.  The id ties a ligature
@@ -83,13 +103,82 @@ type nested struct {
 	// EndPos is the position after the last glyph which can be matched
 	// by sub-lookups.
 	EndPos int
+
+	// traceStep is the index of the trace step which created this entry,
+	// if tracing is enabled.
+	traceStep int
+}
+
+// plannedLookup is a lookup scheduled for application by a [Context].
+type plannedLookup struct {
+	index LookupIndex
+
+	// value is used for glyphs outside all ranges.
+	// It is 1 for lookups passed to NewContext and 0 otherwise.
+	value int
+
+	ranges []LookupRange
+}
+
+// A LookupRange overrides the value of a lookup for the glyphs whose
+// cluster lies in the range from Start to End-1.
+//
+// A value of zero disables the lookup for these glyphs, non-zero values
+// enable it.  For alternate substitution (GSUB lookup type 3), the value
+// k selects the k-th alternate glyph.
+type LookupRange struct {
+	Lookup LookupIndex
+	Start  int
+	End    int
+	Value  int
 }
 
 // NewContext creates a new context, which can be used to apply the given
 // lookups in the given order.  The gdef parameter, if non-nil, is used to
 // resolve glyph classes.
 func NewContext(ll LookupList, gdef *gdef.Table, lookups []LookupIndex) *Context {
-	return &Context{lookups: lookups, ll: ll, gdef: gdef}
+	ctx := &Context{lookups: lookups, ll: ll, gdef: gdef}
+	ctx.SetRanges(nil)
+	return ctx
+}
+
+// SetRanges restricts lookups to parts of the glyph sequence.  This
+// replaces any ranges set by previous calls.
+//
+// Lookups which were not passed to [NewContext] are only applied inside
+// their ranges.  These lookups are inserted into the sequence of lookups
+// before the first lookup with a larger index.  If several ranges for the
+// same lookup overlap, the last one takes precedence.
+func (ctx *Context) SetRanges(ranges []LookupRange) {
+	plan := ctx.plan[:0]
+	for _, idx := range ctx.lookups {
+		plan = append(plan, plannedLookup{index: idx, value: 1})
+	}
+	var extra []plannedLookup
+	for _, r := range ranges {
+		i := slices.IndexFunc(plan, func(p plannedLookup) bool { return p.index == r.Lookup })
+		if i >= 0 {
+			plan[i].ranges = append(plan[i].ranges, r)
+			continue
+		}
+		i = slices.IndexFunc(extra, func(p plannedLookup) bool { return p.index == r.Lookup })
+		if i < 0 {
+			i = len(extra)
+			extra = append(extra, plannedLookup{index: r.Lookup})
+		}
+		extra[i].ranges = append(extra[i].ranges, r)
+	}
+	slices.SortFunc(extra, func(a, b plannedLookup) int {
+		return int(a.index) - int(b.index)
+	})
+	for _, p := range extra {
+		i := slices.IndexFunc(plan, func(q plannedLookup) bool { return q.index > p.index })
+		if i < 0 {
+			i = len(plan)
+		}
+		plan = slices.Insert(plan, i, p)
+	}
+	ctx.plan = plan
 }
 
 // Apply applies the lookups to the given sequence of glyphs.
@@ -97,14 +186,18 @@ func NewContext(ll LookupList, gdef *gdef.Table, lookups []LookupIndex) *Context
 // This is the main entry-point for external users of GSUB and GPOS tables.
 func (ctx *Context) Apply(seq []glyph.Info) []glyph.Info {
 	ctx.maxLen = max(len(seq)*seqExpansionFactor, seqExpansionMin)
-	for _, lookupIndex := range ctx.lookups {
+	for _, planned := range ctx.plan {
+		lookupIndex := planned.index
 		if int(lookupIndex) >= len(ctx.ll) {
 			continue
 		}
 
 		ctx.seq = seq
 		ctx.lookup = ctx.ll[lookupIndex]
+		ctx.lookupIndex = lookupIndex
 		ctx.keep = newKeepFunc(ctx.ll[lookupIndex].Meta, ctx.gdef)
+		ctx.value = planned.value
+		ctx.ranges = planned.ranges
 
 		if isReverseLookup(ctx.lookup) {
 			ctx.applyReverse()
@@ -116,6 +209,22 @@ func (ctx *Context) Apply(seq []glyph.Info) []glyph.Info {
 	return seq
 }
 
+// valueAt returns the value of the current lookup for the glyph at position
+// pos.  Zero means that the lookup is disabled for this glyph.
+func (ctx *Context) valueAt(pos int) int {
+	value := ctx.value
+	if len(ctx.ranges) == 0 {
+		return value
+	}
+	cluster := ctx.seq[pos].Cluster
+	for _, r := range ctx.ranges {
+		if cluster >= r.Start && cluster < r.End {
+			value = r.Value
+		}
+	}
+	return value
+}
+
 // isReverseLookup reports whether the lookup must be applied right-to-left.
 // Only GSUB type 8 (Reverse Chaining Contextual Single Substitution) has this
 // requirement.  All subtables in a lookup share the same type, so inspecting
@@ -156,7 +265,7 @@ func (ctx *Context) applyForward() {
 // decrementing by one is correct without consulting the return value.
 func (ctx *Context) applyReverse() {
 	for pos := len(ctx.seq) - 1; pos >= 0; pos-- {
-		if !ctx.keep.Keep(ctx.seq[pos].GID) {
+		if !ctx.keep.Keep(ctx.seq[pos].GID) || ctx.valueAt(pos) == 0 {
 			continue
 		}
 		ctx.applyAt(ctx.lookup.Subtables, pos, len(ctx.seq))
@@ -167,7 +276,7 @@ func (ctx *Context) applyReverse() {
 // pos.  It returns the new glyph sequence and position for the next lookup.
 func (ctx *Context) applyAtRecursively(pos int) int {
 	// Check if the lookup applies to the input sequence.
-	if !ctx.keep.Keep(ctx.seq[pos].GID) {
+	if !ctx.keep.Keep(ctx.seq[pos].GID) || ctx.valueAt(pos) == 0 {
 		return pos + 1
 	}
 	next := ctx.applyAt(ctx.lookup.Subtables, pos, len(ctx.seq))
@@ -182,6 +291,9 @@ func (ctx *Context) applyAtRecursively(pos int) int {
 			if k == 0 {
 				next = ctx.stack[0].EndPos
 			}
+			if ctx.trace != nil {
+				ctx.traceDone(ctx.stack[k].traceStep, k == 0, next)
+			}
 			ctx.scratch = ctx.stack[k].InputPos
 			ctx.stack = ctx.stack[:k]
 			continue
@@ -206,13 +318,16 @@ func (ctx *Context) applyAtRecursively(pos int) int {
 		keep := newKeepFunc(lookup.Meta, ctx.gdef)
 
 		if keep.Keep(ctx.seq[pos].GID) {
-			oldLookup := ctx.lookup
-			ctx.lookup = lookup
-			oldKeep := ctx.keep
-			ctx.keep = keep
+			// Nested lookups are applied regardless of ranges.
+			oldLookup, oldIndex, oldKeep := ctx.lookup, ctx.lookupIndex, ctx.keep
+			oldValue, oldRanges := ctx.value, ctx.ranges
+			ctx.lookup, ctx.lookupIndex, ctx.keep = lookup, lookupIndex, keep
+			ctx.value, ctx.ranges = 1, nil
+			ctx.traceParent = ctx.stack[k].traceStep
 			ctx.applyAt(lookup.Subtables, pos, end)
-			ctx.lookup = oldLookup
-			ctx.keep = oldKeep
+			ctx.traceParent = -1
+			ctx.lookup, ctx.lookupIndex, ctx.keep = oldLookup, oldIndex, oldKeep
+			ctx.value, ctx.ranges = oldValue, oldRanges
 		}
 	}
 
@@ -228,6 +343,9 @@ func (ctx *Context) applyAtRecursively(pos int) int {
 // applyAt tries the subtables one by one and applies the first one that
 // matches.  If no subtable matches, a -1 is returned.
 func (ctx *Context) applyAt(ss []Subtable, pos, b int) int {
+	if ctx.trace != nil {
+		return ctx.traceAt(ss, pos, b)
+	}
 	for _, subtable := range ss {
 		next := subtable.apply(ctx, pos, b)
 		if next >= 0 {
@@ -23,6 +23,38 @@ import (
 	"golang.org/x/text/language"
 )
 
+// LanguageTag converts an OpenType script tag and language system tag,
+// like "latn" and "TRK", into the BCP 47 tag which is used as a key in
+// [ScriptListInfo].  An empty lang or "dflt" selects the default language
+// system of the script.
+//
+// See https://learn.microsoft.com/en-us/typography/opentype/spec/scripttags
+// and https://learn.microsoft.com/en-us/typography/opentype/spec/languagetags
+func LanguageTag(script, lang string) (language.Tag, error) {
+	if lang == "dflt" {
+		lang = ""
+	}
+	for lang != "" && len(lang) < 4 {
+		lang += " "
+	}
+	return otfToBCP47(otfScript(script), otfLang(lang))
+}
+
+// OpenTypeTags converts a key of a [ScriptListInfo] into an OpenType script
+// tag and language system tag.  The language system tag is "dflt" for the
+// default language system, and trailing spaces are removed.
+func OpenTypeTags(tag language.Tag) (script, lang string, err error) {
+	s, l, err := bcp47ToOtf(tag)
+	if err != nil {
+		return "", "", err
+	}
+	lang = strings.TrimRight(string(l), " ")
+	if lang == "" {
+		lang = "dflt"
+	}
+	return string(s), lang, nil
+}
+
 func otfToBCP47(script otfScript, lang otfLang) (language.Tag, error) {
 	bcpScript, ok := scriptBcp47[script]
 	if !ok {
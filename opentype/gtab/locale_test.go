@@ -114,3 +114,27 @@ func TestLangTags(t *testing.T) {
 		}
 	}
 }
+
+func TestLanguageTag(t *testing.T) {
+	cases := []struct{ script, lang string }{
+		{"DFLT", "dflt"},
+		{"latn", "dflt"},
+		{"latn", "TRK"},
+		{"cyrl", "SRB"},
+	}
+	for _, c := range cases {
+		tag, err := LanguageTag(c.script, c.lang)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		script, lang, err := OpenTypeTags(tag)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if script != c.script || lang != c.lang {
+			t.Errorf("got %s, %s; want %s, %s", script, lang, c.script, c.lang)
+		}
+	}
+}
@@ -19,6 +19,7 @@ package gtab
 import (
 	"slices"
 	"sort"
+	"strings"
 
 	"golang.org/x/text/language"
 	"seehuhn.de/go/membudget"
@@ -580,22 +581,11 @@ func (l *extensionSubtable) encode() []byte {
 
 // FindLookups returns the lookups required to implement the given
 // features in the specified language.
+//
+// If several entries of the ScriptList match lang equally well, the entry
+// whose tag comes first in alphabetical order is used.
 func (info *Info) FindLookups(lang language.Tag, includeFeature map[string]bool) []LookupIndex {
-	if info == nil || len(info.ScriptList) == 0 {
-		return nil
-	}
-
-	tags := make([]language.Tag, 0, len(info.ScriptList))
-	for tag := range info.ScriptList {
-		tags = append(tags, tag)
-	}
-	// TODO(voss): make sure a sensible default comes first.
-	//     Maybe this could be based on the number of features supported?
-
-	matcher := language.NewMatcher(tags)
-	_, index, _ := matcher.Match(lang)
-
-	features := info.ScriptList[tags[index]]
+	features := info.langFeatures(lang)
 	if features == nil {
 		return nil
 	}
@@ -632,3 +622,76 @@ func (info *Info) FindLookups(lang language.Tag, includeFeature map[string]bool)
 	slices.Sort(ll)
 	return ll
 }
+
+// A FeatureRange sets the value of a feature for the part of the text
+// whose cluster values lie in the range from Start to End-1.
+//
+// A value of zero disables the feature, the value one enables it.  For
+// features implemented by alternate substitution, like "aalt" or "salt",
+// the value k selects the k-th alternate glyph.
+type FeatureRange struct {
+	Tag   string
+	Start int
+	End   int
+	Value int
+}
+
+// FindLookupRanges converts feature ranges into lookup ranges, for use with
+// [Context.SetRanges].  Features which are not available in the specified
+// language are ignored.
+func (info *Info) FindLookupRanges(lang language.Tag, ranges []FeatureRange) []LookupRange {
+	if len(ranges) == 0 {
+		return nil
+	}
+	features := info.langFeatures(lang)
+	if features == nil {
+		return nil
+	}
+
+	numFeatures := FeatureIndex(len(info.FeatureList))
+	numLookups := LookupIndex(len(info.LookupList))
+	var res []LookupRange
+	for _, r := range ranges {
+		for _, f := range features.Optional {
+			if f >= numFeatures || info.FeatureList[f].Tag != r.Tag {
+				continue
+			}
+			for _, l := range info.FeatureList[f].Lookups {
+				if l >= numLookups {
+					continue
+				}
+				res = append(res, LookupRange{
+					Lookup: l,
+					Start:  r.Start,
+					End:    r.End,
+					Value:  r.Value,
+				})
+			}
+		}
+	}
+	return res
+}
+
+// langFeatures returns the features for the script/language in the
+// ScriptList which best matches lang.
+func (info *Info) langFeatures(lang language.Tag) *Features {
+	if info == nil || len(info.ScriptList) == 0 {
+		return nil
+	}
+
+	tags := make([]language.Tag, 0, len(info.ScriptList))
+	for tag := range info.ScriptList {
+		tags = append(tags, tag)
+	}
+	// Sort the tags, so that repeated calls give the same result.
+	slices.SortFunc(tags, func(a, b language.Tag) int {
+		return strings.Compare(a.String(), b.String())
+	})
+	// TODO(voss): make sure a sensible default comes first.
+	//     Maybe this could be based on the number of features supported?
+
+	matcher := language.NewMatcher(tags)
+	_, index, _ := matcher.Match(lang)
+
+	return info.ScriptList[tags[index]]
+}
@@ -17,6 +17,8 @@
 package gtab
 
 import (
+	"maps"
+
 	"seehuhn.de/go/membudget"
 	"seehuhn.de/go/sfnt/glyph"
 	"seehuhn.de/go/sfnt/opentype/classdef"
@@ -644,10 +646,11 @@ func (l *SeqContext3) apply(ctx *Context, a, b int) int {
 // encodeLen implements the [Subtable] interface.
 func (l *SeqContext3) encodeLen() int {
 	total := 6 + 2*len(l.Input) + 4*len(l.Actions)
+	pool := newCoveragePool(total)
 	for _, cov := range l.Input {
-		total += cov.ToTable().EncodeLen()
+		pool.add(cov)
 	}
-	return total
+	return total + pool.len()
 }
 
 // encode implements the [Subtable] interface.
@@ -656,11 +659,12 @@ func (l *SeqContext3) encode() []byte {
 	seqLookupCount := len(l.Actions)
 
 	total := 6 + 2*len(l.Input) + 4*len(l.Actions)
+	pool := newCoveragePool(total)
 	coverageOffsets := make([]uint16, glyphCount)
 	for i, cov := range l.Input {
-		coverageOffsets[i] = uint16(total)
-		total += cov.ToTable().EncodeLen()
+		coverageOffsets[i] = pool.add(cov)
 	}
+	total += pool.len()
 
 	buf := make([]byte, 0, total)
 	buf = append(buf,
@@ -677,9 +681,7 @@ func (l *SeqContext3) encode() []byte {
 			byte(action.LookupListIndex>>8), byte(action.LookupListIndex),
 		)
 	}
-	for _, cov := range l.Input {
-		buf = append(buf, cov.ToTable().Encode()...)
-	}
+	buf = pool.append(buf)
 	return buf
 }
 
@@ -1271,9 +1273,11 @@ ruleLoop:
 func (l *ChainedSeqContext2) encodeLen() int {
 	total := 12 + 2*len(l.Rules)
 	total += l.Cov.EncodeLen()
-	total += l.Backtrack.AppendLen()
-	total += l.Input.AppendLen()
-	total += l.Lookahead.AppendLen()
+	pool := newClassDefPool(total)
+	pool.add(l.Backtrack)
+	pool.add(l.Input)
+	pool.add(l.Lookahead)
+	total += pool.len()
 	for _, rules := range l.Rules {
 		if rules == nil {
 			continue
@@ -1295,12 +1299,13 @@ func (l *ChainedSeqContext2) encode() []byte {
 	total := 12 + 2*len(l.Rules)
 	coverageOffset := total
 	total += l.Cov.EncodeLen()
-	backtrackOffset := total
-	total += l.Backtrack.AppendLen()
-	inputOffset := total
-	total += l.Input.AppendLen()
-	lookaheadOffset := total
-	total += l.Lookahead.AppendLen()
+	// Often, some of the class definition tables are identical.  These
+	// are stored only once.
+	pool := newClassDefPool(total)
+	backtrackOffset := pool.add(l.Backtrack)
+	inputOffset := pool.add(l.Input)
+	lookaheadOffset := pool.add(l.Lookahead)
+	total += pool.len()
 	chainedSeqRuleSetOffsets := make([]uint16, chainedSeqRuleSetCount)
 	for i, rr := range l.Rules {
 		if rr == nil {
@@ -1333,9 +1338,7 @@ func (l *ChainedSeqContext2) encode() []byte {
 	}
 
 	buf = append(buf, l.Cov.Encode()...)
-	buf = l.Backtrack.Append(buf)
-	buf = l.Input.Append(buf)
-	buf = l.Lookahead.Append(buf)
+	buf = pool.append(buf)
 
 	for _, rr := range l.Rules {
 		if rr == nil {
@@ -1550,19 +1553,13 @@ func (l *ChainedSeqContext3) encodeLen() int {
 	total += 2 * len(l.Input)
 	total += 2 * len(l.Lookahead)
 	total += 4 * len(l.Actions)
-	for _, set := range l.Backtrack {
-		cov := set.ToTable()
-		total += cov.EncodeLen()
-	}
-	for _, set := range l.Input {
-		cov := set.ToTable()
-		total += cov.EncodeLen()
-	}
-	for _, set := range l.Lookahead {
-		cov := set.ToTable()
-		total += cov.EncodeLen()
+	pool := newCoveragePool(total)
+	for _, sets := range [][]coverage.Set{l.Backtrack, l.Input, l.Lookahead} {
+		for _, set := range sets {
+			pool.add(set)
+		}
 	}
-	return total
+	return total + pool.len()
 }
 
 // encode implements the [Subtable] interface.
@@ -1577,24 +1574,20 @@ func (l *ChainedSeqContext3) encode() []byte {
 	total += 2 * len(l.Input)
 	total += 2 * len(l.Lookahead)
 	total += 4 * len(l.Actions)
+	pool := newCoveragePool(total)
 	backtrackCoverageOffsets := make([]uint16, backtrackGlyphCount)
 	for i, set := range l.Backtrack {
-		backtrackCoverageOffsets[i] = uint16(total)
-		cov := set.ToTable()
-		total += cov.EncodeLen()
+		backtrackCoverageOffsets[i] = pool.add(set)
 	}
 	inputCoverageOffsets := make([]uint16, inputGlyphCount)
 	for i, set := range l.Input {
-		inputCoverageOffsets[i] = uint16(total)
-		cov := set.ToTable()
-		total += cov.EncodeLen()
+		inputCoverageOffsets[i] = pool.add(set)
 	}
 	lookaheadCoverageOffsets := make([]uint16, lookaheadGlyphCount)
 	for i, set := range l.Lookahead {
-		lookaheadCoverageOffsets[i] = uint16(total)
-		cov := set.ToTable()
-		total += cov.EncodeLen()
+		lookaheadCoverageOffsets[i] = pool.add(set)
 	}
+	total += pool.len()
 
 	buf := make([]byte, 0, total)
 	buf = append(buf,
@@ -1627,17 +1620,75 @@ func (l *ChainedSeqContext3) encode() []byte {
 		)
 	}
 
-	for _, set := range l.Backtrack {
-		cov := set.ToTable()
-		buf = append(buf, cov.Encode()...)
+	buf = pool.append(buf)
+	return buf
+}
+
+// tablePool lays out the coverage or class definition tables of a single
+// subtable, storing equal tables only once.  Tables cannot be shared between
+// different subtables, since all offsets are relative to the start of the
+// subtable.
+//
+// Tables are compared as maps, without encoding them.  The encoded tables
+// are only generated once, when the subtable is written.
+type tablePool[T any] struct {
+	base   int // subtable-relative offset of the first table
+	size   int // total length of the stored tables
+	tables []T
+	offs   []uint16
+
+	equal  func(a, b T) bool
+	length func(t T) int
+	write  func(buf []byte, t T) []byte
+}
+
+// newCoveragePool returns a tablePool for coverage tables, which stores
+// the first table at offset base.
+func newCoveragePool(base int) *tablePool[coverage.Set] {
+	return &tablePool[coverage.Set]{
+		base:   base,
+		equal:  func(a, b coverage.Set) bool { return maps.Equal(a, b) },
+		length: func(t coverage.Set) int { return t.ToTable().EncodeLen() },
+		write: func(buf []byte, t coverage.Set) []byte {
+			return append(buf, t.ToTable().Encode()...)
+		},
+	}
+}
+
+// newClassDefPool returns a tablePool for class definition tables, which
+// stores the first table at offset base.
+func newClassDefPool(base int) *tablePool[classdef.Table] {
+	return &tablePool[classdef.Table]{
+		base:   base,
+		equal:  func(a, b classdef.Table) bool { return maps.Equal(a, b) },
+		length: classdef.Table.AppendLen,
+		write: func(buf []byte, t classdef.Table) []byte {
+			return t.Append(buf)
+		},
 	}
-	for _, set := range l.Input {
-		cov := set.ToTable()
-		buf = append(buf, cov.Encode()...)
+}
+
+// add registers a table and returns its subtable-relative offset.
+func (p *tablePool[T]) add(t T) uint16 {
+	for i, u := range p.tables {
+		if p.equal(t, u) {
+			return p.offs[i]
+		}
 	}
-	for _, set := range l.Lookahead {
-		cov := set.ToTable()
-		buf = append(buf, cov.Encode()...)
+	off := uint16(p.base + p.size)
+	p.tables = append(p.tables, t)
+	p.offs = append(p.offs, off)
+	p.size += p.length(t)
+	return off
+}
+
+// len returns the total length of the stored tables.
+func (p *tablePool[T]) len() int { return p.size }
+
+// append appends the encoded tables to buf, in offset order.
+func (p *tablePool[T]) append(buf []byte) []byte {
+	for _, t := range p.tables {
+		buf = p.write(buf, t)
 	}
 	return buf
 }
@@ -17,6 +17,7 @@
 package gtab
 
 import (
+	"bytes"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -24,6 +25,7 @@ import (
 	"seehuhn.de/go/sfnt/opentype/classdef"
 	"seehuhn.de/go/sfnt/opentype/coverage"
 	"seehuhn.de/go/sfnt/opentype/gdef"
+	"seehuhn.de/go/sfnt/parser"
 )
 
 // TestNestedSimple tests that the nested lookup works as expected
@@ -497,6 +499,39 @@ func TestChainedSeqContext3(t *testing.T) {
 	}
 }
 
+// TestCoverageSharing checks that equal coverage tables in a chained
+// contextual subtable are stored only once.
+func TestCoverageSharing(t *testing.T) {
+	l := &ChainedSeqContext3{
+		Backtrack: []coverage.Set{{1: true, 2: true}},
+		Input:     []coverage.Set{{3: true}, {1: true, 2: true}},
+		Lookahead: []coverage.Set{{1: true, 2: true}, {3: true}},
+		Actions:   []SeqLookup{{SequenceIndex: 1, LookupListIndex: 0}},
+	}
+
+	encoded := l.encode()
+	if len(encoded) != l.encodeLen() {
+		t.Fatalf("encode/encodeLen mismatch: %d vs %d", len(encoded), l.encodeLen())
+	}
+	// 24 bytes for the counts, offsets and actions, followed by two
+	// coverage tables of 8 and 6 bytes
+	if len(encoded) != 38 {
+		t.Errorf("wrong length %d, expected 38", len(encoded))
+	}
+
+	p := parser.New(bytes.NewReader(encoded), parser.NewBudget(int64(len(encoded))))
+	if err := p.Discard(2); err != nil {
+		t.Fatal(err)
+	}
+	got, err := readChainedSeqContext3(p, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := cmp.Diff(l, got); d != "" {
+		t.Error(d)
+	}
+}
+
 func TestChainedSeqContext1(t *testing.T) {
 	in := []glyph.Info{
 		{GID: 1}, {GID: 99}, {GID: 2}, {GID: 99}, {GID: 3}, {GID: 4}, {GID: 99}, {GID: 5},
@@ -0,0 +1,583 @@
+// seehuhn.de/go/sfnt - a library for reading and writing font files
+// Copyright (C) 2026  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package gtab
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"seehuhn.de/go/sfnt/glyph"
+	"seehuhn.de/go/sfnt/opentype/classdef"
+	"seehuhn.de/go/sfnt/opentype/coverage"
+)
+
+// Optimize rewrites the lookups in ll to reduce the size of the encoded
+// table, without changing the effect of the lookups.  The lookups are
+// modified in place.
+//
+// The following transformations are applied:
+//   - Adjacent subtables of the same type are merged.  This is done for
+//     single, multiple, alternate and ligature substitutions, and for single
+//     and glyph-pair based pair positioning.
+//   - Single substitution and single positioning subtables use the smaller
+//     of the two subtable formats.
+//   - A glyph-pair based pair positioning subtable is converted into a
+//     class-based subtable, if this reduces the size.  Suitable glyph classes
+//     are found automatically.  This is only done for the last subtable of a
+//     lookup.
+//   - Subtables which are too large to be encoded using 16-bit offsets are
+//     split into several smaller subtables.
+func (ll LookupList) Optimize() {
+	for _, lookup := range ll {
+		var merged []Subtable
+		for _, subtable := range lookup.Subtables {
+			if n := len(merged); n > 0 {
+				if m := mergeSubtables(merged[n-1], subtable); m != nil {
+					merged[n-1] = m
+					continue
+				}
+			}
+			merged = append(merged, subtable)
+		}
+
+		var res []Subtable
+		for i, subtable := range merged {
+			subtable = chooseFormat(subtable, i == len(merged)-1)
+			res = append(res, splitSubtable(subtable)...)
+		}
+		lookup.Subtables = res
+	}
+}
+
+// mergeSubtables returns a single subtable which has the same effect as
+// trying a first, and then b.  If the subtables cannot be merged, nil is
+// returned.
+func mergeSubtables(a, b Subtable) Subtable {
+	switch a := a.(type) {
+	case *Gsub1_1, *Gsub1_2:
+		m1, ok1 := singleSubst(a)
+		m2, ok2 := singleSubst(b)
+		if !ok1 || !ok2 {
+			return nil
+		}
+		for gid, repl := range m2 {
+			if _, seen := m1[gid]; !seen {
+				m1[gid] = repl
+			}
+		}
+		return newGsub1_2(m1)
+
+	case *Gsub2_1:
+		b, ok := b.(*Gsub2_1)
+		if !ok {
+			return nil
+		}
+		cov, repl := mergeCoverage(a.Cov, a.Repl, b.Cov, b.Repl)
+		return &Gsub2_1{Cov: cov, Repl: repl}
+
+	case *Gsub3_1:
+		b, ok := b.(*Gsub3_1)
+		if !ok {
+			return nil
+		}
+		cov, alt := mergeCoverage(a.Cov, a.Alternates, b.Cov, b.Alternates)
+		return &Gsub3_1{Cov: cov, Alternates: alt}
+
+	case *Gsub4_1:
+		b, ok := b.(*Gsub4_1)
+		if !ok {
+			return nil
+		}
+		// The ligatures from b are only tried if none of the ligatures
+		// from a match.
+		m := make(map[glyph.ID][]Ligature)
+		for gid, idx := range a.Cov {
+			m[gid] = append(m[gid], a.Repl[idx]...)
+		}
+		for gid, idx := range b.Cov {
+			m[gid] = append(m[gid], b.Repl[idx]...)
+		}
+		res := &Gsub4_1{Cov: make(coverage.Table, len(m))}
+		for _, gid := range slices.Sorted(maps.Keys(m)) {
+			res.Cov[gid] = len(res.Repl)
+			res.Repl = append(res.Repl, m[gid])
+		}
+		return res
+
+	case *Gpos1_1, *Gpos1_2:
+		m1, ok1 := singleAdjust(a)
+		m2, ok2 := singleAdjust(b)
+		if !ok1 || !ok2 {
+			return nil
+		}
+		for gid, adj := range m2 {
+			if _, seen := m1[gid]; !seen {
+				m1[gid] = adj
+			}
+		}
+		return newGpos1_2(m1)
+
+	case Gpos2_1:
+		b, ok := b.(Gpos2_1)
+		if !ok {
+			return nil
+		}
+		res := maps.Clone(a)
+		for pair, adj := range b {
+			if _, seen := res[pair]; !seen {
+				res[pair] = adj
+			}
+		}
+		return res
+	}
+	return nil
+}
+
+// mergeCoverage merges two coverage tables, together with the data indexed
+// by coverage index.  For glyphs covered by both tables, the data from the
+// first table is used.
+func mergeCoverage[T any](cov1 coverage.Table, data1 []T, cov2 coverage.Table, data2 []T) (coverage.Table, []T) {
+	m := make(map[glyph.ID]T, len(cov1)+len(cov2))
+	for gid, idx := range cov2 {
+		m[gid] = data2[idx]
+	}
+	for gid, idx := range cov1 {
+		m[gid] = data1[idx]
+	}
+
+	cov := make(coverage.Table, len(m))
+	data := make([]T, 0, len(m))
+	for _, gid := range slices.Sorted(maps.Keys(m)) {
+		cov[gid] = len(data)
+		data = append(data, m[gid])
+	}
+	return cov, data
+}
+
+// singleSubst returns the substitutions of a single substitution subtable.
+func singleSubst(st Subtable) (map[glyph.ID]glyph.ID, bool) {
+	switch st := st.(type) {
+	case *Gsub1_1:
+		m := make(map[glyph.ID]glyph.ID, len(st.Cov))
+		for gid := range st.Cov {
+			m[gid] = gid + st.Delta
+		}
+		return m, true
+	case *Gsub1_2:
+		m := make(map[glyph.ID]glyph.ID, len(st.Cov))
+		for gid, idx := range st.Cov {
+			m[gid] = st.SubstituteGlyphIDs[idx]
+		}
+		return m, true
+	}
+	return nil, false
+}
+
+func newGsub1_2(m map[glyph.ID]glyph.ID) *Gsub1_2 {
+	res := &Gsub1_2{Cov: make(coverage.Table, len(m))}
+	for _, gid := range slices.Sorted(maps.Keys(m)) {
+		res.Cov[gid] = len(res.SubstituteGlyphIDs)
+		res.SubstituteGlyphIDs = append(res.SubstituteGlyphIDs, m[gid])
+	}
+	return res
+}
+
+// singleAdjust returns the adjustments of a single positioning subtable.
+func singleAdjust(st Subtable) (map[glyph.ID]*GposValueRecord, bool) {
+	switch st := st.(type) {
+	case *Gpos1_1:
+		m := make(map[glyph.ID]*GposValueRecord, len(st.Cov))
+		for gid := range st.Cov {
+			m[gid] = st.Adjust
+		}
+		return m, true
+	case *Gpos1_2:
+		m := make(map[glyph.ID]*GposValueRecord, len(st.Cov))
+		for gid, idx := range st.Cov {
+			m[gid] = st.Adjust[idx]
+		}
+		return m, true
+	}
+	return nil, false
+}
+
+func newGpos1_2(m map[glyph.ID]*GposValueRecord) *Gpos1_2 {
+	res := &Gpos1_2{Cov: make(coverage.Table, len(m))}
+	for _, gid := range slices.Sorted(maps.Keys(m)) {
+		res.Cov[gid] = len(res.Adjust)
+		res.Adjust = append(res.Adjust, m[gid])
+	}
+	return res
+}
+
+// chooseFormat returns the smallest available representation of a subtable.
+// The argument isLast indicates whether st is the last subtable in its
+// lookup.
+func chooseFormat(st Subtable, isLast bool) Subtable {
+	var candidates []Subtable
+	switch st := st.(type) {
+	case *Gsub1_1, *Gsub1_2:
+		m, _ := singleSubst(st)
+		candidates = append(candidates, newGsub1_2(m))
+		if delta, ok := commonDelta(m); ok {
+			cov := make(coverage.Set, len(m))
+			for gid := range m {
+				cov[gid] = true
+			}
+			candidates = append(candidates, &Gsub1_1{Cov: cov, Delta: delta})
+		}
+
+	case *Gpos1_1, *Gpos1_2:
+		m, _ := singleAdjust(st)
+		candidates = append(candidates, newGpos1_2(m))
+		if adj, ok := commonAdjust(m); ok {
+			cov := make(coverage.Table, len(m))
+			for i, gid := range slices.Sorted(maps.Keys(m)) {
+				cov[gid] = i
+			}
+			candidates = append(candidates, &Gpos1_1{Cov: cov, Adjust: adj})
+		}
+
+	case Gpos2_1:
+		candidates = append(candidates, st)
+		if isLast {
+			if cls := pairToClasses(st); cls != nil {
+				candidates = append(candidates, cls)
+			}
+		}
+
+	default:
+		return st
+	}
+
+	best := candidates[0]
+	bestLen := best.encodeLen()
+	for _, c := range candidates[1:] {
+		if l := c.encodeLen(); l < bestLen {
+			best, bestLen = c, l
+		}
+	}
+	return best
+}
+
+// commonDelta checks whether all substitutions in m change the glyph ID by
+// the same amount.
+func commonDelta(m map[glyph.ID]glyph.ID) (glyph.ID, bool) {
+	var delta glyph.ID
+	first := true
+	for gid, repl := range m {
+		d := repl - gid
+		if first {
+			delta = d
+			first = false
+		} else if d != delta {
+			return 0, false
+		}
+	}
+	return delta, !first
+}
+
+// commonAdjust checks whether all adjustments in m are the same.
+func commonAdjust(m map[glyph.ID]*GposValueRecord) (*GposValueRecord, bool) {
+	var res *GposValueRecord
+	var key string
+	first := true
+	for _, adj := range m {
+		k := valueRecordKey(adj)
+		if first {
+			res, key = adj, k
+			first = false
+		} else if k != key {
+			return nil, false
+		}
+	}
+	return res, !first
+}
+
+// valueRecordKey returns a string which identifies the effect of a value
+// record.  A nil record has the same key as a record where all fields are
+// zero.
+func valueRecordKey(vr *GposValueRecord) string {
+	if vr == nil {
+		vr = &GposValueRecord{}
+	}
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "%d,%d,%d,%d", vr.XPlacement, vr.YPlacement, vr.XAdvance, vr.YAdvance)
+	for _, dev := range vr.deviceTables() {
+		if dev == nil {
+			b.WriteString(",-")
+		} else {
+			fmt.Fprintf(b, ",%v", *dev)
+		}
+	}
+	return b.String()
+}
+
+// pairToClasses converts a glyph-pair based pair positioning subtable into
+// an equivalent class-based subtable.  Glyphs are grouped into the same
+// class, if they have identical adjustments.  If no equivalent class-based
+// subtable exists, nil is returned.
+//
+// The result is only equivalent if the subtable is the last one in its
+// lookup, since the class-based subtable matches all pairs where the first
+// glyph is covered.
+func pairToClasses(l Gpos2_1) *Gpos2_2 {
+	if len(l) == 0 {
+		return nil
+	}
+	for _, adj := range l {
+		// If a second value record is present, the second glyph of the
+		// pair is skipped.  This cannot be represented for the pairs
+		// which are not listed in l.
+		if adj.Second != nil {
+			return nil
+		}
+	}
+
+	zeroKey := valueRecordKey(nil)
+	firstSet := make(map[glyph.ID]bool)
+	secondSet := make(map[glyph.ID]bool)
+	for pair, adj := range l {
+		firstSet[pair.Left] = true
+		if valueRecordKey(adj.First) != zeroKey {
+			secondSet[pair.Right] = true
+		}
+	}
+	firstGlyphs := slices.Sorted(maps.Keys(firstSet))
+	secondGlyphs := slices.Sorted(maps.Keys(secondSet))
+
+	// Group the second glyphs by their column in the adjustment matrix.
+	// Glyphs which are not adjusted at all use class 0.
+	class2 := classdef.Table{}
+	colGlyph := []glyph.ID{0xFFFF} // a representative glyph for each class
+	colClass := make(map[string]uint16)
+	for _, g2 := range secondGlyphs {
+		b := &strings.Builder{}
+		for _, g1 := range firstGlyphs {
+			if adj, ok := l[glyph.Pair{Left: g1, Right: g2}]; ok {
+				fmt.Fprintf(b, "%d:%s;", g1, valueRecordKey(adj.First))
+			}
+		}
+		key := b.String()
+		cls, ok := colClass[key]
+		if !ok {
+			cls = uint16(len(colGlyph))
+			colClass[key] = cls
+			colGlyph = append(colGlyph, g2)
+		}
+		class2[g2] = cls
+	}
+
+	// Group the first glyphs by their row in the adjustment matrix.
+	rowKeys := make(map[glyph.ID]string, len(firstGlyphs))
+	count := make(map[string]int)
+	for _, g1 := range firstGlyphs {
+		b := &strings.Builder{}
+		for cls := 1; cls < len(colGlyph); cls++ {
+			if adj, ok := l[glyph.Pair{Left: g1, Right: colGlyph[cls]}]; ok {
+				fmt.Fprintf(b, "%d:%s;", cls, valueRecordKey(adj.First))
+			}
+		}
+		key := b.String()
+		rowKeys[g1] = key
+		count[key]++
+	}
+	// The most frequent row is assigned class 0, so that the corresponding
+	// glyphs don't need to be listed in the class definition table.
+	var zeroRow string
+	maxCount := 0
+	for _, g1 := range firstGlyphs {
+		if key := rowKeys[g1]; count[key] > maxCount {
+			zeroRow, maxCount = key, count[key]
+		}
+	}
+	class1 := classdef.Table{}
+	var rowGlyph []glyph.ID
+	rowClass := map[string]uint16{zeroRow: 0}
+	for _, g1 := range firstGlyphs {
+		key := rowKeys[g1]
+		if key == zeroRow {
+			if len(rowGlyph) == 0 {
+				rowGlyph = append(rowGlyph, g1)
+			}
+			continue
+		}
+		cls, ok := rowClass[key]
+		if !ok {
+			cls = uint16(len(rowClass))
+			rowClass[key] = cls
+		}
+		class1[g1] = cls
+	}
+	if len(rowClass)*len(colGlyph) > 0xFFFF {
+		return nil
+	}
+	rowGlyph = slices.Grow(rowGlyph, len(rowClass)-1)[:len(rowClass)]
+	for g1, cls := range class1 {
+		rowGlyph[cls] = g1
+	}
+
+	res := &Gpos2_2{
+		Cov:    make(coverage.Set, len(firstGlyphs)),
+		Class1: class1,
+		Class2: class2,
+		Adjust: make([][]*PairAdjust, len(rowGlyph)),
+	}
+	for _, g1 := range firstGlyphs {
+		res.Cov[g1] = true
+	}
+	for c1, g1 := range rowGlyph {
+		row := make([]*PairAdjust, len(colGlyph))
+		for c2, g2 := range colGlyph {
+			adj := &PairAdjust{}
+			if c2 > 0 {
+				if orig, ok := l[glyph.Pair{Left: g1, Right: g2}]; ok {
+					adj.First = orig.First
+				}
+			}
+			row[c2] = adj
+		}
+		res.Adjust[c1] = row
+	}
+	return res
+}
+
+// maxSubtableSize is the largest subtable size for which all 16-bit offsets
+// from the start of the subtable are guaranteed to fit.
+const maxSubtableSize = 0xFFFF
+
+// splitSubtable splits a subtable which is too large to be encoded into
+// several subtables, which have the same effect when tried in order.
+// Subtables are split by partitioning the set of covered glyphs.
+func splitSubtable(st Subtable) []Subtable {
+	if st.encodeLen() <= maxSubtableSize {
+		return []Subtable{st}
+	}
+	glyphs := splitGlyphs(st)
+	if len(glyphs) < 2 {
+		return []Subtable{st}
+	}
+	mid := len(glyphs) / 2
+	a := restrictSubtable(st, glyphs[:mid])
+	b := restrictSubtable(st, glyphs[mid:])
+	return append(splitSubtable(a), splitSubtable(b)...)
+}
+
+// splitGlyphs returns the glyphs covered by a subtable, in the order used
+// for splitting.  For subtables which cannot be split, nil is returned.
+func splitGlyphs(st Subtable) []glyph.ID {
+	switch st := st.(type) {
+	case *Gsub1_2:
+		return st.Cov.Glyphs()
+	case *Gsub2_1:
+		return st.Cov.Glyphs()
+	case *Gsub3_1:
+		return st.Cov.Glyphs()
+	case *Gsub4_1:
+		return st.Cov.Glyphs()
+	case *Gpos1_2:
+		return st.Cov.Glyphs()
+	case Gpos2_1:
+		seen := make(map[glyph.ID]bool)
+		for pair := range st {
+			seen[pair.Left] = true
+		}
+		return slices.Sorted(maps.Keys(seen))
+	case *Gpos2_2:
+		// Keep glyphs from the same class together, so that the rows of
+		// the adjustment matrix are not duplicated unnecessarily.
+		glyphs := st.Cov.Glyphs()
+		slices.SortStableFunc(glyphs, func(a, b glyph.ID) int {
+			return int(st.Class1[a]) - int(st.Class1[b])
+		})
+		return glyphs
+	}
+	return nil
+}
+
+// restrictSubtable returns a copy of st which only applies if the first
+// glyph is one of the given glyphs.  The subtable must be of one of the
+// types supported by splitGlyphs.
+func restrictSubtable(st Subtable, glyphs []glyph.ID) Subtable {
+	switch st := st.(type) {
+	case *Gsub1_2:
+		cov, repl := restrictCoverage(st.Cov, st.SubstituteGlyphIDs, glyphs)
+		return &Gsub1_2{Cov: cov, SubstituteGlyphIDs: repl}
+	case *Gsub2_1:
+		cov, repl := restrictCoverage(st.Cov, st.Repl, glyphs)
+		return &Gsub2_1{Cov: cov, Repl: repl}
+	case *Gsub3_1:
+		cov, alt := restrictCoverage(st.Cov, st.Alternates, glyphs)
+		return &Gsub3_1{Cov: cov, Alternates: alt}
+	case *Gsub4_1:
+		cov, repl := restrictCoverage(st.Cov, st.Repl, glyphs)
+		return &Gsub4_1{Cov: cov, Repl: repl}
+	case *Gpos1_2:
+		cov, adj := restrictCoverage(st.Cov, st.Adjust, glyphs)
+		return &Gpos1_2{Cov: cov, Adjust: adj}
+	case Gpos2_1:
+		keep := make(map[glyph.ID]bool, len(glyphs))
+		for _, gid := range glyphs {
+			keep[gid] = true
+		}
+		res := make(Gpos2_1)
+		for pair, adj := range st {
+			if keep[pair.Left] {
+				res[pair] = adj
+			}
+		}
+		return res
+	case *Gpos2_2:
+		res := &Gpos2_2{
+			Cov:    make(coverage.Set, len(glyphs)),
+			Class1: classdef.Table{},
+			Class2: st.Class2,
+			Adjust: [][]*PairAdjust{st.Adjust[0]},
+		}
+		newClass := map[uint16]uint16{0: 0}
+		for _, gid := range glyphs {
+			res.Cov[gid] = true
+			cls := st.Class1[gid]
+			if cls == 0 {
+				continue
+			}
+			c, ok := newClass[cls]
+			if !ok {
+				c = uint16(len(res.Adjust))
+				newClass[cls] = c
+				res.Adjust = append(res.Adjust, st.Adjust[cls])
+			}
+			res.Class1[gid] = c
+		}
+		return res
+	}
+	panic("unreachable")
+}
+
+// restrictCoverage returns the part of a coverage table which covers the
+// given glyphs, together with the corresponding data.
+func restrictCoverage[T any](cov coverage.Table, data []T, glyphs []glyph.ID) (coverage.Table, []T) {
+	resCov := make(coverage.Table, len(glyphs))
+	resData := make([]T, 0, len(glyphs))
+	for _, gid := range slices.Sorted(slices.Values(glyphs)) {
+		resCov[gid] = len(resData)
+		resData = append(resData, data[cov[gid]])
+	}
+	return resCov, resData
+}
@@ -0,0 +1,200 @@
+// seehuhn.de/go/sfnt - a library for reading and writing font files
+// Copyright (C) 2026  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package gtab
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"seehuhn.de/go/postscript/funit"
+	"seehuhn.de/go/sfnt/glyph"
+	"seehuhn.de/go/sfnt/opentype/coverage"
+	"seehuhn.de/go/sfnt/parser"
+)
+
+// applyAll applies the first lookup in ll to the given glyph sequence.
+func applyAll(ll LookupList, gids []glyph.ID) []glyph.Info {
+	seq := make([]glyph.Info, len(gids))
+	for i, gid := range gids {
+		seq[i] = glyph.Info{GID: gid}
+	}
+	ctx := NewContext(ll, nil, []LookupIndex{0})
+	return ctx.Apply(seq)
+}
+
+// TestOptimizeSingle checks that adjacent single substitution subtables are
+// merged, and that the smaller subtable format is chosen.
+func TestOptimizeSingle(t *testing.T) {
+	ll := LookupList{
+		{
+			Meta: &LookupMetaInfo{LookupType: 1},
+			Subtables: []Subtable{
+				&Gsub1_2{
+					Cov:                coverage.Table{1: 0, 2: 1},
+					SubstituteGlyphIDs: []glyph.ID{11, 12},
+				},
+				&Gsub1_2{
+					Cov:                coverage.Table{2: 0, 3: 1},
+					SubstituteGlyphIDs: []glyph.ID{99, 13},
+				},
+			},
+		},
+	}
+	in := []glyph.ID{1, 2, 3, 4}
+	before := applyAll(ll, in)
+
+	ll.Optimize()
+
+	if len(ll[0].Subtables) != 1 {
+		t.Fatalf("expected 1 subtable, got %d", len(ll[0].Subtables))
+	}
+	st, ok := ll[0].Subtables[0].(*Gsub1_1)
+	if !ok {
+		t.Fatalf("expected *Gsub1_1, got %T", ll[0].Subtables[0])
+	}
+	if st.Delta != 10 {
+		t.Errorf("wrong delta %d", st.Delta)
+	}
+	after := applyAll(ll, in)
+	if d := cmp.Diff(before, after); d != "" {
+		t.Errorf("result changed (-before +after):\n%s", d)
+	}
+}
+
+// TestOptimizePairClasses checks that glyph-pair based kerning is converted
+// into class-based kerning, without changing the result.
+func TestOptimizePairClasses(t *testing.T) {
+	pairs := Gpos2_1{}
+	for g1 := glyph.ID(1); g1 <= 30; g1++ {
+		for g2 := glyph.ID(1); g2 <= 30; g2++ {
+			kern := int16(g1%3)*10 - int16(g2%4)
+			if kern == 0 {
+				continue
+			}
+			pairs[glyph.Pair{Left: g1, Right: g2}] = &PairAdjust{
+				First: &GposValueRecord{XAdvance: funit.Int16(kern)},
+			}
+		}
+	}
+	ll := LookupList{
+		{
+			Meta:      &LookupMetaInfo{LookupType: 2},
+			Subtables: []Subtable{pairs},
+		},
+	}
+
+	var in []glyph.ID
+	for g1 := glyph.ID(0); g1 <= 31; g1++ {
+		for g2 := glyph.ID(0); g2 <= 31; g2++ {
+			in = append(in, g1, g2)
+		}
+	}
+	before := applyAll(ll, in)
+	sizeBefore := ll[0].Subtables[0].encodeLen()
+
+	ll.Optimize()
+
+	st, ok := ll[0].Subtables[0].(*Gpos2_2)
+	if !ok {
+		t.Fatalf("expected *Gpos2_2, got %T", ll[0].Subtables[0])
+	}
+	// Three classes of first glyphs, and four classes of second glyphs
+	// in addition to the unused class 0.
+	if len(st.Adjust) != 3 || len(st.Adjust[0]) != 5 {
+		t.Errorf("expected 3x5 classes, got %dx%d", len(st.Adjust), len(st.Adjust[0]))
+	}
+	if st.encodeLen() >= sizeBefore {
+		t.Errorf("size not reduced: %d >= %d", st.encodeLen(), sizeBefore)
+	}
+	after := applyAll(ll, in)
+	if d := cmp.Diff(before, after); d != "" {
+		t.Errorf("result changed (-before +after):\n%s", d)
+	}
+}
+
+// TestOptimizeSplit checks that subtables which are too large to be encoded
+// are split.
+func TestOptimizeSplit(t *testing.T) {
+	st := &Gsub2_1{Cov: coverage.Table{}}
+	for gid := glyph.ID(0); gid < 1000; gid++ {
+		st.Cov[gid] = int(gid)
+		repl := make([]glyph.ID, 50)
+		for i := range repl {
+			repl[i] = gid + glyph.ID(i)
+		}
+		st.Repl = append(st.Repl, repl)
+	}
+	ll := LookupList{
+		{
+			Meta:      &LookupMetaInfo{LookupType: 2},
+			Subtables: []Subtable{st},
+		},
+	}
+	in := []glyph.ID{0, 499, 500, 999, 1000}
+	before := applyAll(ll, in)
+
+	ll.Optimize()
+
+	if len(ll[0].Subtables) < 2 {
+		t.Fatalf("subtable was not split")
+	}
+	for i, st := range ll[0].Subtables {
+		if l := st.encodeLen(); l > maxSubtableSize {
+			t.Errorf("subtable %d too large: %d bytes", i, l)
+		}
+	}
+	after := applyAll(ll, in)
+	if d := cmp.Diff(before, after); d != "" {
+		t.Errorf("result changed (-before +after):\n%s", d)
+	}
+}
+
+// TestSharedCoverage checks that identical coverage tables in a
+// ChainedSeqContext3 subtable are stored only once.
+func TestSharedCoverage(t *testing.T) {
+	set := coverage.Set{1: true, 5: true, 7: true}
+	l := &ChainedSeqContext3{
+		Backtrack: []coverage.Set{set},
+		Input:     []coverage.Set{set, {2: true}},
+		Lookahead: []coverage.Set{set},
+		Actions:   []SeqLookup{{SequenceIndex: 1, LookupListIndex: 3}},
+	}
+
+	data := l.encode()
+	if len(data) != l.encodeLen() {
+		t.Errorf("encodeLen mismatch: %d != %d", len(data), l.encodeLen())
+	}
+	other := coverage.Set{2: true}
+	want := 10 + 2*4 + 4*1 + set.ToTable().EncodeLen() + other.ToTable().EncodeLen()
+	if len(data) != want {
+		t.Errorf("wrong size: %d != %d", len(data), want)
+	}
+
+	p := parser.New(bytes.NewReader(data), parser.NewBudget(int64(len(data))))
+	format, err := p.ReadUint16()
+	if err != nil || format != 3 {
+		t.Fatalf("unexpected format %d (%v)", format, err)
+	}
+	l2, err := readChainedSeqContext3(p, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := cmp.Diff(Subtable(l), l2); d != "" {
+		t.Errorf("round trip failed (-want +got):\n%s", d)
+	}
+}
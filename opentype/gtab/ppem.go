@@ -0,0 +1,72 @@
+// seehuhn.de/go/sfnt - a library for reading and writing font files
+// Copyright (C) 2026  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package gtab
+
+import (
+	"math"
+
+	"seehuhn.de/go/postscript/funit"
+
+	"seehuhn.de/go/sfnt/glyph"
+	"seehuhn.de/go/sfnt/opentype/anchor"
+	"seehuhn.de/go/sfnt/opentype/device"
+)
+
+// SetPPEM sets the text size used to evaluate the Device tables in GPOS
+// value records and anchors.  The arguments give the number of font design
+// units per em, and the horizontal and vertical size of the em square in
+// pixels.  If xPPEM or yPPEM is zero, Device tables for the corresponding
+// direction are ignored.  This is the default.
+func (ctx *Context) SetPPEM(unitsPerEm, xPPEM, yPPEM uint16) {
+	ctx.unitsPerEm = unitsPerEm
+	ctx.xPPEM = xPPEM
+	ctx.yPPEM = yPPEM
+}
+
+// deviceDelta converts the pixel adjustment of a Device table at the given
+// size into font design units.
+func (ctx *Context) deviceDelta(t *device.Table, ppem uint16) funit.Int16 {
+	if ppem == 0 || ctx.unitsPerEm == 0 {
+		return 0
+	}
+	d := t.Delta(ppem)
+	if d == 0 {
+		return 0
+	}
+	return funit.Int16(math.Round(float64(d) * float64(ctx.unitsPerEm) / float64(ppem)))
+}
+
+// applyValue adjusts the position of a glyph according to the value record,
+// including the Device table adjustments for the current size.
+func (ctx *Context) applyValue(vr *GposValueRecord, g *glyph.Info) {
+	if vr == nil {
+		return
+	}
+	vr.Apply(g)
+	g.XOffset += ctx.deviceDelta(vr.XPlacementDev, ctx.xPPEM)
+	g.YOffset += ctx.deviceDelta(vr.YPlacementDev, ctx.yPPEM)
+	g.Advance += ctx.deviceDelta(vr.XAdvanceDev, ctx.xPPEM)
+	g.YAdvance += ctx.deviceDelta(vr.YAdvanceDev, ctx.yPPEM)
+}
+
+// anchorPos returns the coordinates of an anchor point, including the
+// Device table adjustments for the current size.
+func (ctx *Context) anchorPos(a *anchor.Table) (x, y funit.Int16) {
+	x = a.X + ctx.deviceDelta(a.XDev, ctx.xPPEM)
+	y = a.Y + ctx.deviceDelta(a.YDev, ctx.yPPEM)
+	return x, y
+}
@@ -0,0 +1,109 @@
+// seehuhn.de/go/sfnt - a library for reading and writing font files
+// Copyright (C) 2026  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package gtab
+
+import (
+	"slices"
+
+	"seehuhn.de/go/sfnt/glyph"
+)
+
+// Trace records the lookups applied by a [Context].
+// Tracing is enabled using [Context.SetTrace].
+type Trace struct {
+	// Steps lists the successful subtable applications, in the order
+	// they happened.
+	Steps []TraceStep
+}
+
+// TraceStep describes one successful application of a lookup subtable.
+type TraceStep struct {
+	// Lookup is the index of the lookup in the lookup list, and Subtable is
+	// the index of the subtable which matched.
+	Lookup   LookupIndex
+	Subtable int
+
+	// Parent is the index in Trace.Steps of the contextual lookup which
+	// triggered this step as a nested lookup, or -1 if the lookup was
+	// applied directly.
+	Parent int
+
+	// Pos is the position in the glyph sequence where the subtable was
+	// applied.  Next is the position where processing of the lookup
+	// continues after this step.  For nested lookups, Next is unused.
+	Pos, Next int
+
+	// Input gives the positions of the matched input glyphs, and Actions
+	// lists the nested lookups triggered by this step.  These fields are
+	// only set for contextual lookups.
+	Input   []int
+	Actions []SeqLookup
+
+	// Before and After give the glyph sequence before and after the step.
+	// For contextual lookups, After includes the changes made by the
+	// nested lookups.
+	Before, After []glyph.Info
+}
+
+// SetTrace enables recording of the lookups applied by subsequent calls to
+// [Context.Apply].  Records are appended to t.  If t is nil, tracing is
+// disabled.
+func (ctx *Context) SetTrace(t *Trace) {
+	ctx.trace = t
+	ctx.traceParent = -1
+}
+
+// traceAt is the version of applyAt used while tracing.
+func (ctx *Context) traceAt(ss []Subtable, pos, b int) int {
+	before := slices.Clone(ctx.seq)
+	depth := len(ctx.stack)
+	for i, subtable := range ss {
+		next := subtable.apply(ctx, pos, b)
+		if next < 0 {
+			continue
+		}
+
+		step := TraceStep{
+			Lookup:   ctx.lookupIndex,
+			Subtable: i,
+			Parent:   ctx.traceParent,
+			Pos:      pos,
+			Next:     next,
+			Before:   before,
+			After:    slices.Clone(ctx.seq),
+		}
+		if len(ctx.stack) > depth {
+			top := ctx.stack[len(ctx.stack)-1]
+			step.Input = slices.Clone(top.InputPos)
+			step.Actions = slices.Clone(top.Actions)
+			top.traceStep = len(ctx.trace.Steps)
+		}
+		ctx.trace.Steps = append(ctx.trace.Steps, step)
+		return next
+	}
+	return -1
+}
+
+// traceDone updates the trace step of a contextual lookup, after all
+// nested lookups have been applied.
+func (ctx *Context) traceDone(index int, isTop bool, next int) {
+	step := &ctx.trace.Steps[index]
+	step.After = slices.Clone(ctx.seq)
+	if isTop {
+		step.Next = next
+	}
+}
@@ -327,8 +327,9 @@ func (vr *GposValueRecord) String() string {
 }
 
 // Apply adjusts the position of a glyph according to the value record.
-// Device-table adjustments are not applied here: ppem/variation context
-// lives at the layout layer.
+// Device-table adjustments are not applied here, since these depend on the
+// size of the text.  [Context] applies these when a size has been set using
+// [Context.SetPPEM].
 func (vr *GposValueRecord) Apply(glyph *glyph.Info) {
 	if vr == nil {
 		return
@@ -0,0 +1,126 @@
+// seehuhn.de/go/sfnt - a library for reading and writing font files
+// Copyright (C) 2026  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package raster
+
+import (
+	"container/list"
+	"image"
+	"math"
+	"sync"
+
+	"seehuhn.de/go/geom/matrix"
+
+	"seehuhn.de/go/sfnt/glyph"
+)
+
+// Cache renders glyphs of one font at a fixed size, and keeps the
+// resulting masks for reuse.
+//
+// A Cache is safe for concurrent use.
+type Cache struct {
+	// Subpixels is the number of horizontal sub-pixel positions for which
+	// separate masks are rendered.  Vertical positions are rounded to
+	// whole pixels.
+	Subpixels int
+
+	// MaxEntries is the maximum number of masks kept in the cache.  If more
+	// masks are rendered, the least recently used ones are discarded.  If
+	// MaxEntries is zero, the cache size is unlimited.
+	MaxEntries int
+
+	outlines   Outlines
+	fontMatrix matrix.Matrix
+	ppem       float64
+
+	mu      sync.Mutex
+	r       Rasterizer
+	entries map[cacheKey]*list.Element
+	lru     list.List
+}
+
+// cacheKey identifies a mask.  The glyph is shifted by sub/n pixels, where n
+// is the value of Subpixels at the time the mask was rendered.
+type cacheKey struct {
+	gid glyph.ID
+	sub int
+	n   int
+}
+
+type cacheEntry struct {
+	key  cacheKey
+	mask *image.Alpha
+}
+
+// NewCache returns a new glyph cache for rendering glyphs at a size of ppem
+// pixels per em.  The font matrix fm is used as in [Rasterizer.Glyph].
+// The cache uses 4 sub-pixel positions and keeps at most 1024 masks.
+func NewCache(o Outlines, fm matrix.Matrix, ppem float64) *Cache {
+	return &Cache{
+		Subpixels:  4,
+		MaxEntries: 1024,
+		outlines:   o,
+		fontMatrix: fm,
+		ppem:       ppem,
+		entries:    make(map[cacheKey]*list.Element),
+	}
+}
+
+// Glyph returns the mask for a glyph with its origin at (x, y) in device
+// coordinates.  The mask must be drawn with its bounds translated by pos.
+//
+// The returned mask is shared between callers and must not be modified.
+func (c *Cache) Glyph(gid glyph.ID, x, y float64) (mask *image.Alpha, pos image.Point) {
+	n := max(c.Subpixels, 1)
+	ix := math.Floor(x)
+	sub := int(math.Round((x - ix) * float64(n)))
+	if sub == n {
+		ix++
+		sub = 0
+	}
+	pos = image.Pt(int(ix), int(math.Round(y)))
+
+	key := cacheKey{gid: gid, sub: sub, n: n}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		c.lru.MoveToFront(elem)
+		return elem.Value.(*cacheEntry).mask, pos
+	}
+
+	dx := float64(sub) / float64(n)
+	mask = c.r.Glyph(c.outlines, c.fontMatrix, gid, c.ppem, dx, 0)
+
+	if c.entries == nil {
+		c.entries = make(map[cacheKey]*list.Element)
+	}
+	c.entries[key] = c.lru.PushFront(&cacheEntry{key: key, mask: mask})
+	for c.MaxEntries > 0 && c.lru.Len() > c.MaxEntries {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+	return mask, pos
+}
+
+// Len returns the number of masks currently stored in the cache.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
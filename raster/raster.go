@@ -0,0 +1,153 @@
+// seehuhn.de/go/sfnt - a library for reading and writing font files
+// Copyright (C) 2026  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package raster converts glyph outlines into anti-aliased bitmaps.
+//
+// Outlines are given as [path.Path] values in font design units, as
+// returned by the Path methods of [seehuhn.de/go/sfnt/glyf.Outlines] and
+// [seehuhn.de/go/sfnt/cff.Outlines].  Both quadratic (TrueType) and cubic
+// (CFF) curves are supported.  Pixel coverage is computed using the
+// non-zero winding rule.
+//
+// The resulting masks are [image.Alpha] images in device coordinates,
+// where the y-axis points downwards and the glyph origin is at (0, 0).
+// Masks can be drawn using [image/draw.DrawMask].
+package raster
+
+import (
+	"image"
+	"image/draw"
+	"math"
+
+	"golang.org/x/image/vector"
+
+	"seehuhn.de/go/geom/matrix"
+	"seehuhn.de/go/geom/path"
+	"seehuhn.de/go/geom/vec"
+
+	"seehuhn.de/go/sfnt/glyph"
+)
+
+// Outlines gives access to glyph outlines in font design units.
+// This is implemented by [seehuhn.de/go/sfnt/glyf.Outlines] and
+// [seehuhn.de/go/sfnt/cff.Outlines].
+type Outlines interface {
+	Path(gid glyph.ID) path.Path
+}
+
+// Rasterizer renders paths into coverage masks.
+// The zero value is ready to use.
+//
+// A Rasterizer can be reused to render many paths, but it is not safe
+// for concurrent use.
+type Rasterizer struct {
+	v vector.Rasterizer
+}
+
+// Fill renders the area enclosed by p.  The matrix M maps path
+// coordinates to device coordinates.  The bounds of the returned image
+// are the smallest pixel-aligned rectangle which covers the path.
+//
+// If the path encloses no area, an empty image is returned.
+func (r *Rasterizer) Fill(p path.Path, M matrix.Matrix) *image.Alpha {
+	p = p.Transform(M)
+
+	bbox := p.BBox()
+	if bbox.IsZero() {
+		return &image.Alpha{}
+	}
+	rect := image.Rect(
+		int(math.Floor(bbox.LLx)), int(math.Floor(bbox.LLy)),
+		int(math.Ceil(bbox.URx)), int(math.Ceil(bbox.URy)),
+	)
+	if rect.Empty() {
+		return &image.Alpha{}
+	}
+
+	w, h := rect.Dx(), rect.Dy()
+	r.v.Reset(w, h)
+	r.v.DrawOp = draw.Src
+	x0, y0 := float64(rect.Min.X), float64(rect.Min.Y)
+	pt := func(v vec.Vec2) (float32, float32) {
+		return float32(v.X - x0), float32(v.Y - y0)
+	}
+	open := false
+	for cmd, pts := range p {
+		switch cmd {
+		case path.CmdMoveTo:
+			if open {
+				r.v.ClosePath()
+			}
+			r.v.MoveTo(pt(pts[0]))
+			open = true
+		case path.CmdLineTo:
+			r.v.LineTo(pt(pts[0]))
+		case path.CmdQuadTo:
+			bx, by := pt(pts[0])
+			cx, cy := pt(pts[1])
+			r.v.QuadTo(bx, by, cx, cy)
+		case path.CmdCubeTo:
+			bx, by := pt(pts[0])
+			cx, cy := pt(pts[1])
+			dx, dy := pt(pts[2])
+			r.v.CubeTo(bx, by, cx, cy, dx, dy)
+		case path.CmdClose:
+			r.v.ClosePath()
+			open = false
+		}
+	}
+	if open {
+		r.v.ClosePath()
+	}
+
+	img := image.NewAlpha(image.Rect(0, 0, w, h))
+	r.v.Draw(img, img.Rect, image.Opaque, image.Point{})
+	img.Rect = rect
+	return img
+}
+
+// Glyph renders a glyph at a size of ppem pixels per em.  The glyph origin
+// is placed at (dx, dy) in device coordinates, where dx and dy are normally
+// sub-pixel offsets in the range [0, 1).
+//
+// The font matrix fm maps font design units to text space, where one unit
+// is one em.  Normally this is the FontMatrix field of the font.  If o
+// has per-glyph font matrices, like the per-FD matrices of CID-keyed CFF
+// fonts, these are applied in addition.
+func (r *Rasterizer) Glyph(o Outlines, fm matrix.Matrix, gid glyph.ID, ppem, dx, dy float64) *image.Alpha {
+	if g, ok := o.(glyphMatrixer); ok {
+		fm = g.GlyphMatrix(fm, gid)
+	}
+	return r.Fill(o.Path(gid), GlyphMatrix(fm, ppem, dx, dy))
+}
+
+// glyphMatrixer is implemented by outlines with per-glyph font matrices,
+// like [seehuhn.de/go/sfnt/cff.Outlines].
+type glyphMatrixer interface {
+	GlyphMatrix(top matrix.Matrix, gid glyph.ID) matrix.Matrix
+}
+
+// GlyphMatrix returns the matrix which maps font design units to device
+// coordinates, for a font size of ppem pixels per em and with the glyph
+// origin placed at (x, y).  The font matrix fm maps font design units to
+// text space.  If fm is the zero matrix, 1000 design units per em are
+// assumed.
+func GlyphMatrix(fm matrix.Matrix, ppem, x, y float64) matrix.Matrix {
+	if fm == (matrix.Matrix{}) {
+		fm = matrix.Matrix{0.001, 0, 0, 0.001, 0, 0}
+	}
+	return fm.Mul(matrix.Matrix{ppem, 0, 0, -ppem, x, y})
+}
@@ -0,0 +1,282 @@
+// seehuhn.de/go/sfnt - a library for reading and writing font files
+// Copyright (C) 2026  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package raster
+
+import (
+	"bytes"
+	"image"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/image/font/gofont/goregular"
+
+	"seehuhn.de/go/geom/matrix"
+	"seehuhn.de/go/geom/path"
+	"seehuhn.de/go/geom/vec"
+
+	"seehuhn.de/go/sfnt"
+	"seehuhn.de/go/sfnt/glyph"
+	"seehuhn.de/go/sfnt/parser"
+)
+
+// rectPath returns a rectangle, oriented counter-clockwise if ccw is set.
+func rectPath(d *path.Data, x0, y0, x1, y1 float64, ccw bool) *path.Data {
+	d.MoveTo(vec.Vec2{X: x0, Y: y0})
+	if ccw {
+		d.LineTo(vec.Vec2{X: x1, Y: y0})
+		d.LineTo(vec.Vec2{X: x1, Y: y1})
+		d.LineTo(vec.Vec2{X: x0, Y: y1})
+	} else {
+		d.LineTo(vec.Vec2{X: x0, Y: y1})
+		d.LineTo(vec.Vec2{X: x1, Y: y1})
+		d.LineTo(vec.Vec2{X: x1, Y: y0})
+	}
+	return d.Close()
+}
+
+// rows returns the pixel values of img, one row per slice.
+func rows(img *image.Alpha) [][]uint8 {
+	var res [][]uint8
+	for y := img.Rect.Min.Y; y < img.Rect.Max.Y; y++ {
+		var row []uint8
+		for x := img.Rect.Min.X; x < img.Rect.Max.X; x++ {
+			row = append(row, img.AlphaAt(x, y).A)
+		}
+		res = append(res, row)
+	}
+	return res
+}
+
+func TestFillRect(t *testing.T) {
+	r := &Rasterizer{}
+
+	p := rectPath(&path.Data{}, 1, 2, 3, 3, true)
+	img := r.Fill(p.Iter(), matrix.Identity)
+	if img.Rect != image.Rect(1, 2, 3, 3) {
+		t.Errorf("wrong bounds %v", img.Rect)
+	}
+	if d := cmp.Diff([][]uint8{{255, 255}}, rows(img)); d != "" {
+		t.Error(d)
+	}
+
+	// shifted by half a pixel
+	img = r.Fill(p.Iter(), matrix.Translate(0.5, 0))
+	if img.Rect != image.Rect(1, 2, 4, 3) {
+		t.Errorf("wrong bounds %v", img.Rect)
+	}
+	got := rows(img)[0]
+	if got[0] < 127 || got[0] > 128 || got[1] != 255 || got[2] < 127 || got[2] > 128 {
+		t.Errorf("wrong coverage %v", got)
+	}
+}
+
+func TestFillEmpty(t *testing.T) {
+	r := &Rasterizer{}
+	img := r.Fill((&path.Data{}).Iter(), matrix.Identity)
+	if !img.Rect.Empty() {
+		t.Errorf("wrong bounds %v", img.Rect)
+	}
+}
+
+// TestNonZero checks that overlapping contours are filled using the
+// non-zero winding rule.
+func TestNonZero(t *testing.T) {
+	r := &Rasterizer{}
+
+	// Two overlapping squares with the same orientation:
+	// the overlap is filled.
+	d := rectPath(&path.Data{}, 0, 0, 2, 1, true)
+	d = rectPath(d, 1, 0, 3, 1, true)
+	img := r.Fill(d.Iter(), matrix.Identity)
+	if diff := cmp.Diff([][]uint8{{255, 255, 255}}, rows(img)); diff != "" {
+		t.Error(diff)
+	}
+
+	// A square with a hole.
+	d = rectPath(&path.Data{}, 0, 0, 3, 3, true)
+	d = rectPath(d, 1, 1, 2, 2, false)
+	img = r.Fill(d.Iter(), matrix.Identity)
+	want := [][]uint8{
+		{255, 255, 255},
+		{255, 0, 255},
+		{255, 255, 255},
+	}
+	if diff := cmp.Diff(want, rows(img)); diff != "" {
+		t.Error(diff)
+	}
+}
+
+// TestQuadCubic checks that quadratic curves and their cubic equivalents
+// give the same result.
+func TestQuadCubic(t *testing.T) {
+	d := &path.Data{}
+	d.MoveTo(vec.Vec2{X: 0, Y: 5})
+	d.QuadTo(vec.Vec2{X: 0, Y: 0}, vec.Vec2{X: 5, Y: 0})
+	d.QuadTo(vec.Vec2{X: 10, Y: 0}, vec.Vec2{X: 10, Y: 5})
+	d.QuadTo(vec.Vec2{X: 10, Y: 10}, vec.Vec2{X: 5, Y: 10})
+	d.QuadTo(vec.Vec2{X: 0, Y: 10}, vec.Vec2{X: 0, Y: 5})
+	d.Close()
+
+	r := &Rasterizer{}
+	M := matrix.Scale(1.3, 1.3)
+	quad := r.Fill(d.Iter(), M)
+	cubic := r.Fill(d.Iter().ToCubic(), M)
+
+	if quad.Rect != cubic.Rect {
+		t.Fatalf("bounds differ: %v vs %v", quad.Rect, cubic.Rect)
+	}
+	for i := range quad.Pix {
+		diff := int(quad.Pix[i]) - int(cubic.Pix[i])
+		if diff < -2 || diff > 2 {
+			t.Errorf("pixel %d: %d vs %d", i, quad.Pix[i], cubic.Pix[i])
+		}
+	}
+	if quad.AlphaAt(6, 6).A != 255 {
+		t.Error("center not filled")
+	}
+}
+
+func TestGlyph(t *testing.T) {
+	f, err := sfnt.Read(bytes.NewReader(goregular.TTF), parser.NewBudget(int64(len(goregular.TTF))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmap, err := f.CMapTable.GetBest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := &Rasterizer{}
+	img := r.Glyph(f.Outlines, f.FontMatrix, cmap.Lookup('o'), 40, 0, 0)
+	b := img.Rect
+	if b.Min.X < 0 || b.Max.X > 25 || b.Min.Y < -25 || b.Max.Y > 1 {
+		t.Errorf("unexpected bounds %v", b)
+	}
+	center := img.AlphaAt((b.Min.X+b.Max.X)/2, (b.Min.Y+b.Max.Y)/2).A
+	if center != 0 {
+		t.Errorf("counter is filled: %d", center)
+	}
+	left := img.AlphaAt(b.Min.X+1, (b.Min.Y+b.Max.Y)/2).A
+	if left != 255 {
+		t.Errorf("stem is not filled: %d", left)
+	}
+
+	// Sub-pixel offsets move the ink to the right.
+	shifted := r.Glyph(f.Outlines, f.FontMatrix, cmap.Lookup('l'), 40, 0.5, 0)
+	plain := r.Glyph(f.Outlines, f.FontMatrix, cmap.Lookup('l'), 40, 0, 0)
+	if sumX(shifted) <= sumX(plain) {
+		t.Error("sub-pixel offset has no effect")
+	}
+}
+
+// sumX returns the coverage-weighted sum of the x coordinates of all pixels.
+func sumX(img *image.Alpha) int {
+	total := 0
+	for y := img.Rect.Min.Y; y < img.Rect.Max.Y; y++ {
+		for x := img.Rect.Min.X; x < img.Rect.Max.X; x++ {
+			total += x * int(img.AlphaAt(x, y).A)
+		}
+	}
+	return total
+}
+
+// TestGlyphMatrix checks that the font matrix and per-glyph font matrices
+// are used when glyphs are rendered.
+func TestGlyphMatrix(t *testing.T) {
+	d := rectPath(&path.Data{}, 0, 0, 500, 500, true)
+	fm := matrix.Matrix{0.001, 0, 0, 0.001, 0, 0}
+
+	r := &Rasterizer{}
+	plain := r.Glyph(testOutlines{d}, fm, 0, 10, 0, 0)
+	if want := image.Rect(0, -5, 5, 0); plain.Rect != want {
+		t.Errorf("wrong bounds %v, expected %v", plain.Rect, want)
+	}
+	double := r.Glyph(testOutlines{d}, fm.Scale(2, 2), 0, 10, 0, 0)
+	if want := image.Rect(0, -10, 10, 0); double.Rect != want {
+		t.Errorf("wrong bounds %v, expected %v", double.Rect, want)
+	}
+	perGlyph := r.Glyph(scaledOutlines{testOutlines{d}}, fm, 1, 10, 0, 0)
+	if want := image.Rect(0, -10, 10, 0); perGlyph.Rect != want {
+		t.Errorf("wrong bounds %v, expected %v", perGlyph.Rect, want)
+	}
+}
+
+func TestCache(t *testing.T) {
+	d := rectPath(&path.Data{}, 0, 0, 500, 500, true)
+	outlines := testOutlines{d}
+
+	c := NewCache(outlines, matrix.Matrix{0.001, 0, 0, 0.001, 0, 0}, 10)
+	c.MaxEntries = 2
+
+	m1, pos := c.Glyph(0, 3.1, 4.6)
+	if pos != image.Pt(3, 5) {
+		t.Errorf("wrong position %v", pos)
+	}
+	m2, _ := c.Glyph(0, 7.05, 0)
+	if m1 != m2 {
+		t.Error("mask not shared")
+	}
+
+	// 0.9 is closer to the next pixel than to 0.75
+	_, pos = c.Glyph(0, 2.9, 0)
+	if pos != image.Pt(3, 0) {
+		t.Errorf("wrong position %v", pos)
+	}
+
+	m3, _ := c.Glyph(0, 0.5, 0)
+	if m3 == m1 {
+		t.Error("sub-pixel position ignored")
+	}
+	if c.Len() != 2 {
+		t.Errorf("wrong cache size %d", c.Len())
+	}
+	c.Glyph(0, 0.25, 0)
+	if c.Len() != 2 {
+		t.Errorf("wrong cache size %d", c.Len())
+	}
+
+	// masks rendered for a different number of sub-pixel positions are
+	// not reused
+	m4, _ := c.Glyph(0, 0.25, 0)
+	c.Subpixels = 8
+	m5, _ := c.Glyph(0, 0.125, 0)
+	if m5 == m4 {
+		t.Error("mask for a different sub-pixel position reused")
+	}
+}
+
+// testOutlines uses the same outline for all glyphs.
+type testOutlines struct {
+	d *path.Data
+}
+
+func (o testOutlines) Path(glyph.ID) path.Path {
+	return o.d.Iter()
+}
+
+// scaledOutlines doubles the size of all glyphs with odd glyph IDs, using
+// a per-glyph font matrix.
+type scaledOutlines struct {
+	testOutlines
+}
+
+func (o scaledOutlines) GlyphMatrix(top matrix.Matrix, gid glyph.ID) matrix.Matrix {
+	if gid%2 == 1 {
+		return matrix.Scale(2, 2).Mul(top)
+	}
+	return top
+}
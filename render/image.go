@@ -0,0 +1,93 @@
+// seehuhn.de/go/sfnt - a library for reading and writing font files
+// Copyright (C) 2026  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package render
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"seehuhn.de/go/sfnt"
+	"seehuhn.de/go/sfnt/glyph"
+	"seehuhn.de/go/sfnt/raster"
+)
+
+// Image renders the glyph sequence seq into a raster image, with black text
+// on a white background.  If opt is nil, default options are used.
+// The UseSymbols option is ignored.
+func Image(f *sfnt.Font, seq []glyph.Info, opt *Options) *image.RGBA {
+	if opt == nil {
+		opt = &Options{}
+	}
+	p := layout(f, seq, opt)
+
+	img := image.NewRGBA(image.Rect(0, 0, int(p.width), int(p.height)))
+	draw.Draw(img, img.Rect, image.White, image.Point{}, draw.Src)
+
+	r := &raster.Rasterizer{}
+	for _, g := range p.glyphs {
+		if g.isNull {
+			continue
+		}
+		mask := r.Glyph(f.Outlines, f.FontMatrix, g.gid, p.ppem, g.x, g.y)
+		draw.DrawMask(img, mask.Rect, image.Black, image.Point{}, mask, mask.Rect.Min, draw.Over)
+	}
+
+	if opt.Baseline {
+		y := int(math.Floor(p.baseline))
+		hLine(img, 0, img.Rect.Max.X, y, baselineColor)
+	}
+	if opt.Boxes {
+		for _, g := range p.glyphs {
+			if g.isNull {
+				continue
+			}
+			x0 := int(math.Floor(g.bbox.LLx))
+			y0 := int(math.Floor(g.bbox.LLy))
+			x1 := int(math.Ceil(g.bbox.URx))
+			y1 := int(math.Ceil(g.bbox.URy))
+			hLine(img, x0, x1, y0, boxColor)
+			hLine(img, x0, x1, y1-1, boxColor)
+			vLine(img, x0, y0, y1, boxColor)
+			vLine(img, x1-1, y0, y1, boxColor)
+		}
+	}
+	if opt.Advances {
+		tick := p.scale * float64(f.UnitsPerEm) / 8
+		y0 := int(math.Floor(p.baseline - tick))
+		y1 := int(math.Ceil(p.baseline + tick))
+		for _, g := range p.glyphs {
+			vLine(img, int(math.Floor(g.penX)), y0, y1, advanceColor)
+		}
+		vLine(img, int(math.Floor(p.endX)), y0, y1, advanceColor)
+	}
+
+	return img
+}
+
+func hLine(img *image.RGBA, x0, x1, y int, col color.RGBA) {
+	for x := x0; x < x1; x++ {
+		img.SetRGBA(x, y, col)
+	}
+}
+
+func vLine(img *image.RGBA, x, y0, y1 int, col color.RGBA) {
+	for y := y0; y < y1; y++ {
+		img.SetRGBA(x, y, col)
+	}
+}
@@ -0,0 +1,157 @@
+// seehuhn.de/go/sfnt - a library for reading and writing font files
+// Copyright (C) 2026  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package render draws shaped text, to help with debugging text layout.
+//
+// The input is a glyph sequence as returned by
+// [seehuhn.de/go/sfnt.Layouter.Layout].  The glyphs can be rendered into an
+// SVG document using [SVG], or into a raster image using [Image].  Optional
+// overlays show the glyph bounding boxes, the baseline and the glyph
+// advances.
+package render
+
+import (
+	"image/color"
+	"math"
+
+	"seehuhn.de/go/geom/matrix"
+	"seehuhn.de/go/geom/rect"
+
+	"seehuhn.de/go/sfnt"
+	"seehuhn.de/go/sfnt/cff"
+	"seehuhn.de/go/sfnt/glyph"
+)
+
+// Options controls the output of [SVG] and [Image].
+type Options struct {
+	// Size is the font size in pixels per em.  If this is zero, 48 is used.
+	Size float64
+
+	// Margin is the space around the text, in pixels.  If this is zero,
+	// half the font size is used.
+	Margin float64
+
+	// Boxes, if set, shows the bounding box of every glyph.
+	Boxes bool
+
+	// Baseline, if set, shows the baseline.
+	Baseline bool
+
+	// Advances, if set, marks the pen position before and after every
+	// glyph.
+	Advances bool
+
+	// UseSymbols, if set, makes [SVG] write every distinct glyph outline
+	// only once, as a <symbol> element, and reference it with <use>.
+	// Otherwise, every glyph is written as a separate <path> element.
+	UseSymbols bool
+}
+
+// Colors used for the debug overlays.
+var (
+	boxColor      = color.RGBA{R: 0xe4, G: 0x1a, B: 0x1c, A: 0xff}
+	baselineColor = color.RGBA{R: 0x37, G: 0x7e, B: 0xb8, A: 0xff}
+	advanceColor  = color.RGBA{R: 0x4d, G: 0xaf, B: 0x4a, A: 0xff}
+)
+
+// placed describes the position of a glyph on the page.
+// All values are in pixels, with the y-axis pointing down.
+type placed struct {
+	gid    glyph.ID
+	x, y   float64 // glyph origin
+	penX   float64 // pen position before the glyph
+	bbox   rect.Rect
+	isNull bool // the glyph has no outline
+}
+
+// page holds the result of placing a glyph sequence.
+type page struct {
+	width, height float64
+	baseline      float64
+	scale         float64 // pixels per font design unit
+	ppem          float64 // pixels per em
+	glyphs        []placed
+	endX          float64 // pen position after the last glyph
+}
+
+// layout places the glyphs on the page.
+func layout(f *sfnt.Font, seq []glyph.Info, opt *Options) *page {
+	if opt == nil {
+		opt = &Options{}
+	}
+	size := opt.Size
+	if size <= 0 {
+		size = 48
+	}
+	margin := opt.Margin
+	if margin <= 0 {
+		margin = size / 2
+	}
+	upem := float64(f.UnitsPerEm)
+	if upem == 0 {
+		upem = 1000
+	}
+	s := size / upem
+
+	p := &page{
+		scale:    s,
+		ppem:     size,
+		baseline: margin + float64(f.Ascent)*s,
+	}
+	pen := margin
+	for _, g := range seq {
+		x := pen + float64(g.XOffset)*s
+		y := p.baseline - float64(g.YOffset)*s
+
+		var bbox rect.Rect
+		isNull := f.Outlines.IsBlank(g.GID)
+		if !isNull {
+			// PDF glyph space uses 1000 units per em, and includes any
+			// per-glyph font matrix.
+			b := f.Outlines.GlyphBBoxPDF(f.FontMatrix, g.GID)
+			q := size / 1000
+			bbox = rect.Rect{
+				LLx: x + b.LLx*q,
+				LLy: y - b.URy*q,
+				URx: x + b.URx*q,
+				URy: y - b.LLy*q,
+			}
+		}
+		p.glyphs = append(p.glyphs, placed{
+			gid:    g.GID,
+			x:      x,
+			y:      y,
+			penX:   pen,
+			bbox:   bbox,
+			isNull: isNull,
+		})
+		pen += float64(g.Advance) * s
+	}
+	p.endX = pen
+
+	p.width = math.Ceil(max(pen, margin) + margin)
+	p.height = math.Ceil(p.baseline - float64(f.Descent)*s + margin)
+	return p
+}
+
+// glyphMatrix returns the font matrix for a glyph, including the per-FD
+// matrices of CID-keyed CFF fonts.
+func glyphMatrix(f *sfnt.Font, gid glyph.ID) matrix.Matrix {
+	if o, ok := f.Outlines.(*cff.Outlines); ok {
+		return o.GlyphMatrix(f.FontMatrix, gid)
+	}
+	return f.FontMatrix
+}
@@ -0,0 +1,149 @@
+// seehuhn.de/go/sfnt - a library for reading and writing font files
+// Copyright (C) 2026  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package render
+
+import (
+	"bytes"
+	"encoding/xml"
+	"image"
+	"io"
+	"testing"
+
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/text/language"
+
+	"seehuhn.de/go/sfnt"
+	"seehuhn.de/go/sfnt/glyph"
+	"seehuhn.de/go/sfnt/parser"
+)
+
+func shape(t *testing.T, text string) (*sfnt.Font, []glyph.Info) {
+	t.Helper()
+	f, err := sfnt.Read(bytes.NewReader(goregular.TTF), parser.NewBudget(int64(len(goregular.TTF))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := f.NewLayouter(language.English, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f, l.Layout(text)
+}
+
+// countElements parses an SVG document and counts the elements by name.
+func countElements(t *testing.T, data []byte) map[string]int {
+	t.Helper()
+	count := make(map[string]int)
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("invalid SVG: %v", err)
+		}
+		if se, ok := tok.(xml.StartElement); ok {
+			count[se.Name.Local]++
+		}
+	}
+	return count
+}
+
+func TestSVG(t *testing.T) {
+	f, seq := shape(t, "a a")
+
+	buf := &bytes.Buffer{}
+	err := SVG(buf, f, seq, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	count := countElements(t, buf.Bytes())
+	if count["svg"] != 1 || count["path"] != 2 || count["use"] != 0 {
+		t.Errorf("unexpected elements: %v", count)
+	}
+
+	buf.Reset()
+	err = SVG(buf, f, seq, &Options{UseSymbols: true, Boxes: true, Baseline: true, Advances: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	count = countElements(t, buf.Bytes())
+	if count["symbol"] != 1 || count["path"] != 1 || count["use"] != 2 {
+		t.Errorf("unexpected elements: %v", count)
+	}
+	// one background rectangle, and one box for each non-blank glyph
+	if count["rect"] != 3 {
+		t.Errorf("got %d rectangles, expected 3", count["rect"])
+	}
+	// one baseline, and len(seq)+1 advance marks
+	if count["line"] != 1+len(seq)+1 {
+		t.Errorf("got %d lines, expected %d", count["line"], len(seq)+2)
+	}
+}
+
+func TestImage(t *testing.T) {
+	f, seq := shape(t, "Hi")
+
+	img := Image(f, seq, &Options{Size: 20, Margin: 5})
+	p := layout(f, seq, &Options{Size: 20, Margin: 5})
+	if img.Rect != image.Rect(0, 0, int(p.width), int(p.height)) {
+		t.Errorf("unexpected bounds %v", img.Rect)
+	}
+
+	ink := 0
+	for y := img.Rect.Min.Y; y < img.Rect.Max.Y; y++ {
+		for x := img.Rect.Min.X; x < img.Rect.Max.X; x++ {
+			c := img.RGBAAt(x, y)
+			if c.R != c.G || c.G != c.B {
+				t.Fatalf("unexpected color %v", c)
+			}
+			if c.R < 128 {
+				ink++
+			}
+		}
+	}
+	if ink == 0 {
+		t.Error("no ink")
+	}
+
+	img = Image(f, seq, &Options{Size: 20, Margin: 5, Baseline: true, Boxes: true})
+	y := int(p.baseline)
+	if img.RGBAAt(0, y) != baselineColor {
+		t.Errorf("baseline not shown")
+	}
+	b := p.glyphs[0].bbox
+	if img.RGBAAt(int(b.LLx), int(b.LLy)) != boxColor {
+		t.Errorf("box not shown")
+	}
+}
+
+func TestNum(t *testing.T) {
+	cases := map[float64]string{
+		0:        "0",
+		-0.0001:  "0",
+		1:        "1",
+		-2.5:     "-2.5",
+		100:      "100",
+		1.23456:  "1.235",
+		-1000.25: "-1000.25",
+	}
+	for in, want := range cases {
+		if got := num(in); got != want {
+			t.Errorf("num(%g) = %q, expected %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+// seehuhn.de/go/sfnt - a library for reading and writing font files
+// Copyright (C) 2026  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package render
+
+import (
+	"bufio"
+	"fmt"
+	"image/color"
+	"io"
+	"strconv"
+	"strings"
+
+	"seehuhn.de/go/geom/path"
+
+	"seehuhn.de/go/sfnt"
+	"seehuhn.de/go/sfnt/glyph"
+	"seehuhn.de/go/sfnt/raster"
+)
+
+// SVG writes the glyph sequence seq as an SVG document to w.
+// If opt is nil, default options are used.
+func SVG(w io.Writer, f *sfnt.Font, seq []glyph.Info, opt *Options) error {
+	if opt == nil {
+		opt = &Options{}
+	}
+	p := layout(f, seq, opt)
+
+	out := bufio.NewWriter(w)
+	fmt.Fprintf(out, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%s\" height=\"%s\" viewBox=\"0 0 %s %s\">\n",
+		num(p.width), num(p.height), num(p.width), num(p.height))
+	fmt.Fprintf(out, "<rect width=\"100%%\" height=\"100%%\" fill=\"white\"/>\n")
+
+	if opt.UseSymbols {
+		seen := make(map[glyph.ID]bool)
+		out.WriteString("<defs>\n")
+		for _, g := range p.glyphs {
+			if g.isNull || seen[g.gid] {
+				continue
+			}
+			seen[g.gid] = true
+			fmt.Fprintf(out, "<symbol id=\"g%d\" overflow=\"visible\"><path d=\"%s\"/></symbol>\n",
+				g.gid, pathData(f.Outlines.Path(g.gid)))
+		}
+		out.WriteString("</defs>\n")
+	}
+
+	// Glyph outlines are given in font design units, and are transformed
+	// into page coordinates.
+	out.WriteString("<g fill=\"black\">\n")
+	for _, g := range p.glyphs {
+		if g.isNull {
+			continue
+		}
+		M := raster.GlyphMatrix(glyphMatrix(f, g.gid), p.ppem, g.x, g.y)
+		transform := fmt.Sprintf("matrix(%s %s %s %s %s %s)",
+			num(M[0]), num(M[1]), num(M[2]), num(M[3]), num(M[4]), num(M[5]))
+		if opt.UseSymbols {
+			fmt.Fprintf(out, "<use href=\"#g%d\" transform=\"%s\"/>\n", g.gid, transform)
+		} else {
+			fmt.Fprintf(out, "<path transform=\"%s\" d=\"%s\"/>\n", transform, pathData(f.Outlines.Path(g.gid)))
+		}
+	}
+	out.WriteString("</g>\n")
+
+	if opt.Baseline {
+		fmt.Fprintf(out, "<line x1=\"0\" y1=\"%s\" x2=\"%s\" y2=\"%s\" stroke=\"%s\" stroke-width=\"1\"/>\n",
+			num(p.baseline), num(p.width), num(p.baseline), hexColor(baselineColor))
+	}
+	if opt.Boxes {
+		fmt.Fprintf(out, "<g fill=\"none\" stroke=\"%s\" stroke-width=\"1\">\n", hexColor(boxColor))
+		for _, g := range p.glyphs {
+			if g.isNull {
+				continue
+			}
+			fmt.Fprintf(out, "<rect x=\"%s\" y=\"%s\" width=\"%s\" height=\"%s\"/>\n",
+				num(g.bbox.LLx), num(g.bbox.LLy), num(g.bbox.Dx()), num(g.bbox.Dy()))
+		}
+		out.WriteString("</g>\n")
+	}
+	if opt.Advances {
+		fmt.Fprintf(out, "<g stroke=\"%s\" stroke-width=\"1\">\n", hexColor(advanceColor))
+		tick := p.scale * float64(f.UnitsPerEm) / 8
+		for _, g := range p.glyphs {
+			advanceMark(out, g.penX, p.baseline, tick)
+		}
+		advanceMark(out, p.endX, p.baseline, tick)
+		out.WriteString("</g>\n")
+	}
+
+	out.WriteString("</svg>\n")
+	return out.Flush()
+}
+
+// advanceMark writes a vertical tick mark, centred on the baseline.
+func advanceMark(w io.Writer, x, baseline, size float64) {
+	fmt.Fprintf(w, "<line x1=\"%s\" y1=\"%s\" x2=\"%s\" y2=\"%s\"/>\n",
+		num(x), num(baseline-size), num(x), num(baseline+size))
+}
+
+// pathData returns the SVG path data for p.
+func pathData(p path.Path) string {
+	var b strings.Builder
+	for cmd, pts := range p {
+		if b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		switch cmd {
+		case path.CmdMoveTo:
+			b.WriteString("M")
+		case path.CmdLineTo:
+			b.WriteString("L")
+		case path.CmdQuadTo:
+			b.WriteString("Q")
+		case path.CmdCubeTo:
+			b.WriteString("C")
+		case path.CmdClose:
+			b.WriteString("Z")
+		}
+		for _, pt := range pts {
+			b.WriteByte(' ')
+			b.WriteString(num(pt.X))
+			b.WriteByte(' ')
+			b.WriteString(num(pt.Y))
+		}
+	}
+	return b.String()
+}
+
+// hexColor formats a color as "#rrggbb".
+func hexColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// num formats a number for use in SVG, with at most three decimal places.
+func num(x float64) string {
+	s := strconv.FormatFloat(x, 'f', 3, 64)
+	s = strings.TrimRight(s, "0")
+	s = strings.TrimSuffix(s, ".")
+	if s == "-0" {
+		s = "0"
+	}
+	return s
+}
@@ -37,20 +37,65 @@ import (
 //
 // The slice glyphs must start with glyph ID 0 to represent the notdef glyph.
 func (f *Font) Subset(glyphs []glyph.ID) *Font {
+	res, _ := f.subset(glyphs, f.Gsub, f.Gpos, nil, nil, false)
+	return res
+}
+
+// SubsetRetainGIDs returns a subset of the font, where the given glyphs keep
+// their original glyph IDs.  Like for [Font.Subset], more glyphs may be
+// included in the subset, if they occur as ligatures between the given
+// glyphs.  The notdef glyph is always included.
+//
+// All other glyphs with IDs below the largest retained glyph ID are
+// replaced by empty glyphs with zero width.  Glyphs after the last retained
+// glyph are removed.  This is useful when glyph IDs of the original font are
+// already referenced elsewhere, for example by a PDF file using an Identity
+// encoding.
+func (f *Font) SubsetRetainGIDs(glyphs []glyph.ID) *Font {
+	res, _ := f.subset(glyphs, f.Gsub, f.Gpos, nil, nil, true)
+	return res
+}
+
+// subset implements [Font.Subset] and [Font.SubsetRetainGIDs], using the
+// given GSUB and GPOS tables instead of the ones in f.  If runes is not
+// nil, only the given characters and the variation sequences in seqs are
+// kept in the cmap table.
+//
+// The second return value lists the glyph IDs of the original font, in the
+// order of the glyph IDs in the subset.  This includes glyphs which were
+// added by the subsetter.
+func (f *Font) subset(glyphs []glyph.ID, gsub, gpos *gtab.Info, runes map[rune]bool, seqs map[cmap.VariationSequence]bool, retain bool) (*Font, []glyph.ID) {
 	res := f.Clone()
 
 	s := subsetter{
-		glyphs: glyphs,
 		newGid: map[glyph.ID]glyph.ID{},
-	}
-	for newgid, oldGid := range glyphs {
-		s.newGid[oldGid] = glyph.ID(newgid)
+		runes:  runes,
+		seqs:   seqs,
+		retain: retain,
+	}
+	if retain {
+		s.getNewGid(0)
+		for _, oldGid := range glyphs {
+			s.getNewGid(oldGid)
+		}
+	} else {
+		s.glyphs = glyphs
+		for newgid, oldGid := range glyphs {
+			s.newGid[oldGid] = glyph.ID(newgid)
+		}
 	}
 
 	if f.CMapTable != nil {
 		res.CMapTable = make(cmap.Table, len(f.CMapTable))
+		if uvs, err := f.CMapTable.GetFormat14(); err == nil {
+			uni, _ := f.CMapTable.GetBest()
+			uvs = s.SubsetFormat14(uvs, uni)
+			if len(uvs.Default)+len(uvs.NonDefault) > 0 {
+				res.CMapTable[cmap.Key{PlatformID: 0, EncodingID: 5}] = uvs.Encode()
+			}
+		}
 		for key := range f.CMapTable {
-			c, err := res.CMapTable.Get(key)
+			c, err := f.CMapTable.Get(key)
 			if err != nil {
 				continue
 			}
@@ -58,9 +103,9 @@ func (f *Font) Subset(glyphs []glyph.ID) *Font {
 			res.CMapTable[key] = c.Encode(key.Language)
 		}
 	}
-	res.Gsub = s.SubsetGsub(f.Gsub)
+	res.Gsub = s.SubsetGsub(gsub)
 	// At this point we have the final list of glyphs.
-	res.Gpos = s.SubsetGpos(f.Gpos)
+	res.Gpos = s.SubsetGpos(gpos)
 	res.Gdef = s.SubsetGdef(f.Gdef)
 
 	switch outlines := f.Outlines.(type) {
@@ -70,14 +115,28 @@ func (f *Font) Subset(glyphs []glyph.ID) *Font {
 		res.Outlines = s.SubsetGlyf(outlines)
 	}
 
-	return res
+	return res, s.glyphs
 }
 
 type subsetter struct {
 	glyphs []glyph.ID
 	newGid map[glyph.ID]glyph.ID
+
+	// runes, if not nil, restricts the cmap table to the given characters
+	// and seqs to the given variation sequences.
+	runes map[rune]bool
+	seqs  map[cmap.VariationSequence]bool
+
+	// retain, if set, makes all glyphs keep their original glyph IDs.
+	// Unused positions in glyphs are filled with unusedGlyph.
+	retain bool
 }
 
+// unusedGlyph marks the positions in subsetter.glyphs which are not used,
+// when glyph IDs are retained.  The value cannot occur as a glyph ID in a
+// font, since fonts have at most 65535 glyphs.
+const unusedGlyph glyph.ID = 0xFFFF
+
 func (s *subsetter) hasOldGid(oldGid glyph.ID) bool {
 	_, ok := s.newGid[oldGid]
 	return ok
@@ -85,14 +144,34 @@ func (s *subsetter) hasOldGid(oldGid glyph.ID) bool {
 
 func (s *subsetter) getNewGid(oldGid glyph.ID) glyph.ID {
 	newGid, ok := s.newGid[oldGid]
-	if !ok {
+	if ok {
+		return newGid
+	}
+	if s.retain {
+		for len(s.glyphs) <= int(oldGid) {
+			s.glyphs = append(s.glyphs, unusedGlyph)
+		}
+		newGid = oldGid
+		s.glyphs[newGid] = oldGid
+	} else {
 		newGid = glyph.ID(len(s.glyphs))
 		s.glyphs = append(s.glyphs, oldGid)
-		s.newGid[oldGid] = newGid
 	}
+	s.newGid[oldGid] = newGid
 	return newGid
 }
 
+// source returns the glyph ID in the original font, from which the outline
+// data for newGid is taken.  The second return value is false for unused
+// glyphs, which must be replaced by empty glyphs.
+func (s *subsetter) source(newGid int) (glyph.ID, bool) {
+	oldGid := s.glyphs[newGid]
+	if oldGid == unusedGlyph {
+		return glyph.ID(newGid), false
+	}
+	return oldGid, true
+}
+
 func (s *subsetter) SubsetCMap(c cmap.Subtable) cmap.Subtable {
 	if c == nil {
 		return nil
@@ -103,7 +182,7 @@ func (s *subsetter) SubsetCMap(c cmap.Subtable) cmap.Subtable {
 		res := cmap.Format4{}
 		for key, oldGid := range c {
 			newGid, ok := s.newGid[oldGid]
-			if !ok {
+			if !ok || s.runes != nil && !s.runes[rune(key)] {
 				continue
 			}
 			res[key] = newGid
@@ -113,7 +192,7 @@ func (s *subsetter) SubsetCMap(c cmap.Subtable) cmap.Subtable {
 		res := cmap.Format12{}
 		for key, oldGid := range c {
 			newGid, ok := s.newGid[oldGid]
-			if !ok {
+			if !ok || s.runes != nil && !s.runes[rune(key)] {
 				continue
 			}
 			res[key] = newGid
@@ -124,6 +203,33 @@ func (s *subsetter) SubsetCMap(c cmap.Subtable) cmap.Subtable {
 	}
 }
 
+// SubsetFormat14 returns the variation sequences which are kept in the
+// subset.  The argument uni is the Unicode cmap subtable of the original
+// font, which gives the glyphs for the default variation sequences.
+func (s *subsetter) SubsetFormat14(c *cmap.Format14, uni cmap.Subtable) *cmap.Format14 {
+	keep := func(seq cmap.VariationSequence) bool {
+		return s.runes == nil || s.seqs[seq]
+	}
+	res := &cmap.Format14{
+		Default:    make(map[cmap.VariationSequence]bool),
+		NonDefault: make(map[cmap.VariationSequence]glyph.ID),
+	}
+	for seq := range c.Default {
+		if !keep(seq) || uni == nil || s.runes != nil && !s.runes[seq.Base] {
+			continue
+		}
+		if gid := uni.Lookup(seq.Base); gid != 0 && s.hasOldGid(gid) {
+			res.Default[seq] = true
+		}
+	}
+	for seq, oldGid := range c.NonDefault {
+		if newGid, ok := s.newGid[oldGid]; ok && keep(seq) {
+			res.NonDefault[seq] = newGid
+		}
+	}
+	return res
+}
+
 // TODO(voss): This is incomplete.  Finish this!
 func (s *subsetter) SubsetGsub(old *gtab.Info) *gtab.Info {
 	if old == nil {
@@ -464,6 +570,7 @@ func (s *subsetter) SubsetGsub(old *gtab.Info) *gtab.Info {
 	}
 
 	remapContextualLookupIndices(res.LookupList, oldToNew)
+	res.ScriptList, res.FeatureList = subsetFeatures(old, oldToNew)
 
 	return &res
 }
@@ -668,6 +775,7 @@ func (s *subsetter) SubsetGpos(old *gtab.Info) *gtab.Info {
 	}
 
 	remapContextualLookupIndices(res.LookupList, oldToNew)
+	res.ScriptList, res.FeatureList = subsetFeatures(old, oldToNew)
 
 	return &res
 }
@@ -709,15 +817,23 @@ func (s *subsetter) SubsetGdef(old *gdef.Table) *gdef.Table {
 }
 
 func (s *subsetter) SubsetCFF(oldOutlines *cff.Outlines) *cff.Outlines {
-	newOutlines := &cff.Outlines{}
+	newOutlines := &cff.Outlines{
+		Subroutinize: oldOutlines.Subroutinize,
+	}
 
 	newOutlines.Glyphs = make([]*cff.Glyph, len(s.glyphs))
-	for i, oldGid := range s.glyphs {
-		newOutlines.Glyphs[i] = oldOutlines.Glyphs[oldGid]
+	for i := range s.glyphs {
+		oldGid, used := s.source(i)
+		if used {
+			newOutlines.Glyphs[i] = oldOutlines.Glyphs[oldGid]
+		} else {
+			newOutlines.Glyphs[i] = cff.NewGlyph(oldOutlines.Glyphs[oldGid].Name, 0)
+		}
 	}
 
 	pIdxMap := make(map[int]int)
-	for _, oldGid := range s.glyphs {
+	for i := range s.glyphs {
+		oldGid, _ := s.source(i)
 		oldPIdx := oldOutlines.FDSelect(oldGid)
 		if _, ok := pIdxMap[oldPIdx]; !ok {
 			newPIdx := len(newOutlines.Private)
@@ -732,7 +848,8 @@ func (s *subsetter) SubsetCFF(oldOutlines *cff.Outlines) *cff.Outlines {
 		newOutlines.FDSelect = func(glyph.ID) int { return 0 }
 	} else {
 		fdSel := make([]int, len(s.glyphs))
-		for newgid, oldGid := range s.glyphs {
+		for newgid := range s.glyphs {
+			oldGid, _ := s.source(newgid)
 			fdSel[newgid] = pIdxMap[oldOutlines.FDSelect(oldGid)]
 		}
 		newOutlines.FDSelect = func(gid glyph.ID) int { return fdSel[gid] }
@@ -743,6 +860,10 @@ func (s *subsetter) SubsetCFF(oldOutlines *cff.Outlines) *cff.Outlines {
 		for i, oldGid := range oldOutlines.Encoding {
 			if newGid, ok := s.newGid[oldGid]; ok {
 				newOutlines.Encoding[i] = newGid
+			} else if s.retain && int(oldGid) < len(s.glyphs) {
+				// Keep the codes of the empty glyphs, since CFF
+				// encodings must cover a contiguous range of glyphs.
+				newOutlines.Encoding[i] = oldGid
 			}
 		}
 	}
@@ -751,7 +872,8 @@ func (s *subsetter) SubsetCFF(oldOutlines *cff.Outlines) *cff.Outlines {
 
 	if oldOutlines.GIDToCID != nil {
 		newOutlines.GIDToCID = make([]cid.CID, len(s.glyphs))
-		for newGid, oldGid := range s.glyphs {
+		for newGid := range s.glyphs {
+			oldGid, _ := s.source(newGid)
 			newOutlines.GIDToCID[newGid] = oldOutlines.GIDToCID[oldGid]
 		}
 	}
@@ -767,7 +889,9 @@ func (s *subsetter) SubsetGlyf(oldOutlines *glyf.Outlines) *glyf.Outlines {
 
 	todo := make(map[glyph.ID]bool, len(s.glyphs))
 	for _, oldGid := range s.glyphs {
-		todo[oldGid] = true
+		if oldGid != unusedGlyph {
+			todo[oldGid] = true
+		}
 	}
 	for len(todo) > 0 {
 		oldGid := pop(todo)
@@ -776,26 +900,26 @@ func (s *subsetter) SubsetGlyf(oldOutlines *glyf.Outlines) *glyf.Outlines {
 			if _, ok := s.newGid[componentGidOld]; ok {
 				continue
 			}
-			componendGidNew := glyph.ID(len(s.glyphs))
-			s.glyphs = append(s.glyphs, componentGidOld)
-			s.newGid[componentGidOld] = componendGidNew
+			s.getNewGid(componentGidOld)
 			todo[componentGidOld] = true
 		}
 	}
 
 	newOutlines.Glyphs = make([]*glyf.Glyph, len(s.glyphs))
-	for newGid, oldGid := range s.glyphs {
-		newOutlines.Glyphs[newGid] = oldOutlines.Glyphs[oldGid].FixComponents(s.newGid)
-	}
-
 	newOutlines.Widths = make([]funit.Uint16, len(s.glyphs))
-	for newGid, oldGid := range s.glyphs {
+	for newGid := range s.glyphs {
+		oldGid, used := s.source(newGid)
+		if !used {
+			continue
+		}
+		newOutlines.Glyphs[newGid] = oldOutlines.Glyphs[oldGid].FixComponents(s.newGid)
 		newOutlines.Widths[newGid] = oldOutlines.Widths[oldGid]
 	}
 
 	if oldOutlines.Names != nil {
 		newOutlines.Names = make([]string, len(s.glyphs))
-		for newGid, oldGid := range s.glyphs {
+		for newGid := range s.glyphs {
+			oldGid, _ := s.source(newGid)
 			newOutlines.Names[newGid] = oldOutlines.Names[oldGid]
 		}
 	}
@@ -31,6 +31,9 @@ import (
 func (s *subsetter) aliveClasses(cd classdef.Table) map[uint16]bool {
 	alive := map[uint16]bool{}
 	for _, oldGid := range s.glyphs {
+		if oldGid == unusedGlyph {
+			continue
+		}
 		if cls, ok := cd[oldGid]; ok {
 			alive[cls] = true
 		} else {
@@ -436,3 +439,89 @@ func remapContextualLookupIndices(lookupList gtab.LookupList, oldToNew []int) {
 		}
 	}
 }
+
+// subsetFeatures updates the ScriptList and FeatureList of a GSUB or GPOS
+// table after the lookups have been subsetted.  The slice oldToNew maps old
+// lookup indices to new ones, with -1 for removed lookups.  Features which
+// have neither lookups nor parameters left are removed, and so are
+// languages without features.
+func subsetFeatures(old *gtab.Info, oldToNew []int) (gtab.ScriptListInfo, gtab.FeatureListInfo) {
+	var featureList gtab.FeatureListInfo
+	featureMap := make([]gtab.FeatureIndex, len(old.FeatureList))
+	for i, f := range old.FeatureList {
+		featureMap[i] = 0xFFFF
+		var lookups []gtab.LookupIndex
+		for _, l := range f.Lookups {
+			if int(l) < len(oldToNew) && oldToNew[l] >= 0 {
+				lookups = append(lookups, gtab.LookupIndex(oldToNew[l]))
+			}
+		}
+		if lookups == nil && f.Params == nil {
+			continue
+		}
+		featureMap[i] = gtab.FeatureIndex(len(featureList))
+		featureList = append(featureList, &gtab.Feature{
+			Tag:     f.Tag,
+			Lookups: lookups,
+			Params:  f.Params,
+		})
+	}
+
+	var scriptList gtab.ScriptListInfo
+	for tag, features := range old.ScriptList {
+		newFeatures := &gtab.Features{Required: 0xFFFF}
+		if int(features.Required) < len(featureMap) {
+			newFeatures.Required = featureMap[features.Required]
+		}
+		for _, f := range features.Optional {
+			if int(f) < len(featureMap) && featureMap[f] != 0xFFFF {
+				newFeatures.Optional = append(newFeatures.Optional, featureMap[f])
+			}
+		}
+		if newFeatures.Required == 0xFFFF && newFeatures.Optional == nil {
+			continue
+		}
+		if scriptList == nil {
+			scriptList = make(gtab.ScriptListInfo)
+		}
+		scriptList[tag] = newFeatures
+	}
+	return scriptList, featureList
+}
+
+// nestedActions returns the nested lookups used by a contextual subtable,
+// or nil for other subtables.
+func nestedActions(sub gtab.Subtable) []gtab.SeqLookup {
+	var res []gtab.SeqLookup
+	switch s := sub.(type) {
+	case *gtab.SeqContext1:
+		for _, rs := range s.Rules {
+			for _, r := range rs {
+				res = append(res, r.Actions...)
+			}
+		}
+	case *gtab.SeqContext2:
+		for _, rs := range s.Rules {
+			for _, r := range rs {
+				res = append(res, r.Actions...)
+			}
+		}
+	case *gtab.SeqContext3:
+		res = s.Actions
+	case *gtab.ChainedSeqContext1:
+		for _, rs := range s.Rules {
+			for _, r := range rs {
+				res = append(res, r.Actions...)
+			}
+		}
+	case *gtab.ChainedSeqContext2:
+		for _, rs := range s.Rules {
+			for _, r := range rs {
+				res = append(res, r.Actions...)
+			}
+		}
+	case *gtab.ChainedSeqContext3:
+		res = s.Actions
+	}
+	return res
+}
@@ -0,0 +1,161 @@
+// seehuhn.de/go/sfnt - a library for reading and writing font files
+// Copyright (C) 2026  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package sfnt
+
+import (
+	"maps"
+	"slices"
+
+	"seehuhn.de/go/sfnt/glyf"
+	"seehuhn.de/go/sfnt/glyph"
+	"seehuhn.de/go/sfnt/opentype/gtab"
+)
+
+// IncrementalSubsetter builds subsets of a font, where glyphs are added
+// over time.  Glyphs keep their glyph ID in the subset, once it has been
+// assigned.  New glyph IDs are assigned in the order in which the glyphs are
+// first used.
+//
+// This is useful for documents where the set of used glyphs grows while
+// the document is written, for example page by page.
+type IncrementalSubsetter struct {
+	font *Font
+
+	// closure holds the GSUB closure of the glyphs added so far, for the
+	// lookups used by any feature.  This is nil, if the font has no GSUB
+	// table.
+	closure *gtab.GlyphClosure
+
+	// glyphs lists the glyph IDs of the original font, in the order of
+	// the glyph IDs in the subset.
+	glyphs []glyph.ID
+	newGid map[glyph.ID]glyph.ID
+
+	// numClosed is the number of glyphs at the start of glyphs which have
+	// been added to the closure and whose components have been added.
+	numClosed int
+}
+
+// NewIncrementalSubsetter returns a new IncrementalSubsetter for the font.
+// Initially, the subset only contains the notdef glyph.
+func (f *Font) NewIncrementalSubsetter() *IncrementalSubsetter {
+	s := &IncrementalSubsetter{
+		font:   f,
+		glyphs: []glyph.ID{0},
+		newGid: map[glyph.ID]glyph.ID{0: 0},
+	}
+	if f.Gsub != nil {
+		seen := make(map[gtab.LookupIndex]bool)
+		for _, feature := range f.Gsub.FeatureList {
+			for _, l := range feature.Lookups {
+				seen[l] = true
+			}
+		}
+		s.closure = f.Gsub.NewGlyphClosure(slices.Sorted(maps.Keys(seen)))
+	}
+	return s
+}
+
+// Add adds glyphs to the subset and returns their glyph IDs in the subset.
+// Glyph IDs which are not valid for the font are mapped to 0.
+//
+// Glyphs which can be produced from the glyphs in the subset by GSUB
+// lookups, and the components of composite glyphs, are added
+// automatically.  Their new glyph IDs follow the glyph IDs of the
+// requested glyphs.
+func (s *IncrementalSubsetter) Add(glyphs ...glyph.ID) []glyph.ID {
+	numGlyphs := s.font.NumGlyphs()
+
+	res := make([]glyph.ID, len(glyphs))
+	for i, gid := range glyphs {
+		if int(gid) >= numGlyphs {
+			continue
+		}
+		res[i] = s.add(gid)
+	}
+
+	// GSUB lookups can produce composite glyphs, and the components of
+	// composite glyphs can be input to GSUB lookups.  Both steps are
+	// repeated until no new glyphs are found.  Only the new glyphs are
+	// used as the starting point.
+	outlines, _ := s.font.Outlines.(*glyf.Outlines)
+	for s.numClosed < len(s.glyphs) {
+		start, end := s.numClosed, len(s.glyphs)
+		if s.closure != nil {
+			for _, gid := range s.closure.Add(s.glyphs[start:end]...) {
+				s.add(gid)
+			}
+		}
+		if outlines != nil {
+			for i := start; i < len(s.glyphs); i++ {
+				for _, gid := range outlines.Glyphs[s.glyphs[i]].Components() {
+					s.add(gid)
+				}
+			}
+		}
+		s.numClosed = end
+	}
+
+	return res
+}
+
+func (s *IncrementalSubsetter) add(gid glyph.ID) glyph.ID {
+	newGid, ok := s.newGid[gid]
+	if !ok {
+		newGid = glyph.ID(len(s.glyphs))
+		s.glyphs = append(s.glyphs, gid)
+		s.newGid[gid] = newGid
+	}
+	return newGid
+}
+
+// NewGID returns the glyph ID in the subset for a glyph of the original
+// font.  The second return value is false, if the glyph is not included in
+// the subset.
+func (s *IncrementalSubsetter) NewGID(gid glyph.ID) (glyph.ID, bool) {
+	newGid, ok := s.newGid[gid]
+	return newGid, ok
+}
+
+// GIDMap returns the map from glyph IDs of the original font to glyph
+// IDs in the subset.  The returned map is a copy and can be modified by
+// the caller.
+func (s *IncrementalSubsetter) GIDMap() map[glyph.ID]glyph.ID {
+	return maps.Clone(s.newGid)
+}
+
+// Glyphs returns the glyph IDs of the original font, in the order of the
+// glyph IDs in the subset.
+func (s *IncrementalSubsetter) Glyphs() []glyph.ID {
+	return slices.Clone(s.glyphs)
+}
+
+// Subset returns the subset font for the glyphs added so far.  Further
+// glyphs can be added after this has been called, and the glyph IDs in
+// later subsets are consistent with the ones in earlier subsets.
+func (s *IncrementalSubsetter) Subset() *Font {
+	res, glyphs := s.font.subset(slices.Clone(s.glyphs), s.font.Gsub, s.font.Gpos, nil, nil, false)
+
+	// The subsetter may include a few more glyphs than the closure
+	// computed by Add.  These are recorded, so that they keep their
+	// glyph IDs in later subsets.  The next call to Add also adds them to
+	// the closure.
+	for _, gid := range glyphs[len(s.glyphs):] {
+		s.add(gid)
+	}
+	return res
+}
@@ -0,0 +1,100 @@
+// seehuhn.de/go/sfnt - a library for reading and writing font files
+// Copyright (C) 2026  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package sfnt_test
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+
+	"seehuhn.de/go/sfnt/glyph"
+	"seehuhn.de/go/sfnt/internal/debug"
+	"seehuhn.de/go/sfnt/opentype/gtab/builder"
+)
+
+func TestIncrementalSubsetter(t *testing.T) {
+	font := debug.MakeSimpleFont()
+	gsub, err := builder.ParseInfo(font, `
+	GSUB4: F I -> X, C D -> Y
+	feature liga: 0
+	script DFLT dflt: 0
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	font.Gsub = gsub
+
+	cmap, err := font.CMapTable.GetBest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gids := func(s string) []glyph.ID {
+		var res []glyph.ID
+		for _, r := range s {
+			res = append(res, cmap.Lookup(r))
+		}
+		return res
+	}
+
+	s := font.NewIncrementalSubsetter()
+
+	if got := s.Add(gids("BA")...); !slices.Equal(got, []glyph.ID{1, 2}) {
+		t.Errorf("unexpected glyph IDs %v", got)
+	}
+	sub1 := s.Subset()
+	if sub1.NumGlyphs() != 3 {
+		t.Errorf("expected 3 glyphs, got %d", sub1.NumGlyphs())
+	}
+
+	if got := s.Add(gids("AFI")...); !slices.Equal(got, []glyph.ID{2, 3, 4}) {
+		t.Errorf("unexpected glyph IDs %v", got)
+	}
+	if newGid, ok := s.NewGID(cmap.Lookup('X')); !ok || newGid != 5 {
+		t.Errorf("ligature glyph: got %d, %t", newGid, ok)
+	}
+	if _, ok := s.NewGID(cmap.Lookup('Z')); ok {
+		t.Error("unexpected glyph Z in subset")
+	}
+	sub2 := s.Subset()
+	if sub2.NumGlyphs() != 6 {
+		t.Errorf("expected 6 glyphs, got %d", sub2.NumGlyphs())
+	}
+
+	for oldGid, newGid := range s.GIDMap() {
+		if got := sub2.GlyphName(newGid); got != font.GlyphName(oldGid) {
+			t.Errorf("glyph %d: expected %q, got %q", newGid, font.GlyphName(oldGid), got)
+		}
+		if int(newGid) < sub1.NumGlyphs() && sub1.GlyphName(newGid) != sub2.GlyphName(newGid) {
+			t.Errorf("glyph %d changed between subsets", newGid)
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	if _, err := sub2.Write(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	// ligatures are found when the components are added in different calls
+	s.Add(gids("C")...)
+	if _, ok := s.NewGID(cmap.Lookup('Y')); ok {
+		t.Error("unexpected glyph Y in subset")
+	}
+	s.Add(gids("D")...)
+	if _, ok := s.NewGID(cmap.Lookup('Y')); !ok {
+		t.Error("ligature glyph Y missing from subset")
+	}
+}
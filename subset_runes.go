@@ -0,0 +1,231 @@
+// seehuhn.de/go/sfnt - a library for reading and writing font files
+// Copyright (C) 2026  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package sfnt
+
+import (
+	"slices"
+
+	"golang.org/x/text/language"
+
+	"seehuhn.de/go/sfnt/cmap"
+	"seehuhn.de/go/sfnt/glyph"
+	"seehuhn.de/go/sfnt/opentype/gtab"
+)
+
+// SubsetOptions controls which layout information is kept by
+// [Font.SubsetRunes].
+type SubsetOptions struct {
+	// Features lists the OpenType feature tags to keep in the GSUB and GPOS
+	// tables.  If this is nil, all features are kept.
+	Features []string
+
+	// DropFeatures lists feature tags to remove from the GSUB and GPOS
+	// tables.  This takes precedence over Features.
+	DropFeatures []string
+
+	// Languages restricts the script/language systems of the GSUB and GPOS
+	// tables to the given languages.  For each language, the matching
+	// language system and the default language system of its script are
+	// kept.  The "DFLT" script is always kept.  If this is nil, all
+	// language systems are kept.
+	Languages []language.Tag
+
+	// RetainGIDs, if set, makes all glyphs keep their original glyph IDs.
+	// See [Font.SubsetRetainGIDs] for details.
+	RetainGIDs bool
+}
+
+// SubsetRunes returns a subset of the font, which contains the glyphs
+// for the given characters, together with all glyphs which can be
+// produced from these by the retained GSUB features.  The cmap table of
+// the subset only maps the given characters.
+//
+// A character followed by a variation selector in runes forms a Unicode
+// variation sequence.  The glyphs for such sequences are found using the
+// format 14 cmap subtable, and the variation sequences are kept in the
+// format 14 subtable of the subset.
+//
+// Features and language systems which are not selected by opt are
+// removed from the GSUB and GPOS tables, together with the lookups which
+// are no longer used.  If opt is nil, all layout features are kept.
+//
+// Required features of the retained language systems are always kept.
+func (f *Font) SubsetRunes(runes []rune, opt *SubsetOptions) *Font {
+	if opt == nil {
+		opt = &SubsetOptions{}
+	}
+
+	keepRune := make(map[rune]bool, len(runes))
+	keepSeq := make(map[cmap.VariationSequence]bool)
+	glyphs := []glyph.ID{0}
+	uni, _ := f.CMapTable.GetBest()
+	uvs, _ := f.CMapTable.GetFormat14()
+	for i, r := range runes {
+		if i > 0 && cmap.IsVariationSelector(r) {
+			seq := cmap.VariationSequence{Base: runes[i-1], Selector: r}
+			keepSeq[seq] = true
+			if uvs != nil {
+				if gid, ok := uvs.Lookup(seq, uni); ok && gid != 0 {
+					glyphs = append(glyphs, gid)
+				}
+			}
+		}
+
+		keepRune[r] = true
+		if uni == nil {
+			continue
+		}
+		if gid := uni.Lookup(r); gid != 0 {
+			glyphs = append(glyphs, gid)
+		}
+	}
+
+	gsub := pruneLayout(f.Gsub, opt)
+	gpos := pruneLayout(f.Gpos, opt)
+	if gsub != nil {
+		var lookups []gtab.LookupIndex
+		for _, feature := range gsub.FeatureList {
+			lookups = append(lookups, feature.Lookups...)
+		}
+		glyphs = gsub.LookupClosure(lookups, glyphs)
+	} else {
+		slices.Sort(glyphs)
+		glyphs = slices.Compact(glyphs)
+	}
+
+	res, _ := f.subset(glyphs, gsub, gpos, keepRune, keepSeq, opt.RetainGIDs)
+	return res
+}
+
+// pruneLayout returns a copy of a GSUB or GPOS table, where the language
+// systems and features not selected by opt are removed.  Lookups which are
+// no longer used are replaced by empty lookups, so that lookup indices stay
+// unchanged.  The empty lookups are removed by the subsetter.
+func pruneLayout(info *gtab.Info, opt *SubsetOptions) *gtab.Info {
+	if info == nil {
+		return nil
+	}
+
+	keepTag := func(tag string) bool {
+		if slices.Contains(opt.DropFeatures, tag) {
+			return false
+		}
+		return opt.Features == nil || slices.Contains(opt.Features, tag)
+	}
+
+	numFeatures := gtab.FeatureIndex(len(info.FeatureList))
+	keepFeature := make([]bool, numFeatures)
+	scripts := make(map[language.Tag]*gtab.Features)
+	for tag, features := range info.ScriptList {
+		if !keepLanguage(tag, opt.Languages) {
+			continue
+		}
+		newFeatures := &gtab.Features{Required: 0xFFFF}
+		if features.Required < numFeatures {
+			// Required features are kept, even if they are not selected.
+			newFeatures.Required = features.Required
+			keepFeature[features.Required] = true
+		}
+		for _, f := range features.Optional {
+			if f < numFeatures && keepTag(info.FeatureList[f].Tag) {
+				newFeatures.Optional = append(newFeatures.Optional, f)
+				keepFeature[f] = true
+			}
+		}
+		scripts[tag] = newFeatures
+	}
+
+	res := &gtab.Info{}
+	featureMap := make([]gtab.FeatureIndex, numFeatures)
+	numLookups := gtab.LookupIndex(len(info.LookupList))
+	useLookup := make([]bool, numLookups)
+	var todo []gtab.LookupIndex
+	for i, f := range info.FeatureList {
+		if !keepFeature[i] {
+			continue
+		}
+		featureMap[i] = gtab.FeatureIndex(len(res.FeatureList))
+		res.FeatureList = append(res.FeatureList, f)
+		for _, l := range f.Lookups {
+			if l < numLookups && !useLookup[l] {
+				useLookup[l] = true
+				todo = append(todo, l)
+			}
+		}
+	}
+	for len(todo) > 0 {
+		l := todo[len(todo)-1]
+		todo = todo[:len(todo)-1]
+		for _, sub := range info.LookupList[l].Subtables {
+			for _, a := range nestedActions(sub) {
+				if a.LookupListIndex < numLookups && !useLookup[a.LookupListIndex] {
+					useLookup[a.LookupListIndex] = true
+					todo = append(todo, a.LookupListIndex)
+				}
+			}
+		}
+	}
+
+	for tag, features := range scripts {
+		if features.Required != 0xFFFF {
+			features.Required = featureMap[features.Required]
+		}
+		for i, f := range features.Optional {
+			features.Optional[i] = featureMap[f]
+		}
+		if res.ScriptList == nil {
+			res.ScriptList = make(gtab.ScriptListInfo)
+		}
+		res.ScriptList[tag] = features
+	}
+
+	res.LookupList = make(gtab.LookupList, numLookups)
+	for i, lookup := range info.LookupList {
+		if useLookup[i] {
+			res.LookupList[i] = lookup
+		} else {
+			res.LookupList[i] = &gtab.LookupTable{Meta: lookup.Meta}
+		}
+	}
+
+	return res
+}
+
+// keepLanguage reports whether the ScriptList entry with the given key is
+// selected by langs.  See [SubsetOptions.Languages] for the rules.
+func keepLanguage(key language.Tag, langs []language.Tag) bool {
+	if langs == nil {
+		return true
+	}
+	keyScript, _ := key.Script()
+	if keyScript.String() == "Zzzz" {
+		return true
+	}
+	keyBase, conf := key.Base()
+	isDefault := conf != language.Exact
+	for _, lang := range langs {
+		script, _ := lang.Script()
+		if script != keyScript {
+			continue
+		}
+		base, _ := lang.Base()
+		if isDefault || base == keyBase {
+			return true
+		}
+	}
+	return false
+}
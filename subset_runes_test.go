@@ -0,0 +1,173 @@
+// seehuhn.de/go/sfnt - a library for reading and writing font files
+// Copyright (C) 2026  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package sfnt_test
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+
+	"golang.org/x/text/language"
+
+	"seehuhn.de/go/sfnt"
+	"seehuhn.de/go/sfnt/cmap"
+	"seehuhn.de/go/sfnt/glyph"
+	"seehuhn.de/go/sfnt/internal/debug"
+	"seehuhn.de/go/sfnt/opentype/gtab/builder"
+)
+
+func TestSubsetRunes(t *testing.T) {
+	font := debug.MakeSimpleFont()
+	gsub, err := builder.ParseInfo(font, `
+	GSUB4: F I -> X
+	GSUB1: A -> Y
+	GSUB5: "BC" -> 3@1
+	GSUB1: C -> Z
+	feature liga: 0 2
+	feature smcp: 1
+	script DFLT dflt: 0 1
+	script latn TRK: 1
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	font.Gsub = gsub
+
+	glyphNames := func(f *sfnt.Font) string {
+		var res []byte
+		for i := 1; i < f.NumGlyphs(); i++ {
+			res = append(res, f.GlyphName(glyph.ID(i))[0])
+		}
+		slices.Sort(res)
+		return string(res)
+	}
+	featureTags := func(f *sfnt.Font) []string {
+		var res []string
+		for _, feature := range f.Gsub.FeatureList {
+			res = append(res, feature.Tag)
+		}
+		return res
+	}
+
+	cases := []struct {
+		text     string
+		opt      *sfnt.SubsetOptions
+		glyphs   string
+		features []string
+		scripts  int
+	}{
+		{"FI", nil, "FIX", []string{"liga"}, 1},
+		{"AFI", nil, "AFIXY", []string{"liga", "smcp"}, 2},
+		{"AFI", &sfnt.SubsetOptions{Features: []string{"smcp"}}, "AFIY", []string{"smcp"}, 2},
+		{"AFI", &sfnt.SubsetOptions{DropFeatures: []string{"smcp"}}, "AFIX", []string{"liga"}, 1},
+		{"AFI", &sfnt.SubsetOptions{Languages: []language.Tag{language.German}}, "AFIXY", []string{"liga", "smcp"}, 1},
+		{"BC", &sfnt.SubsetOptions{Features: []string{"smcp"}}, "BC", nil, 0},
+		{"BC", nil, "BCZ", []string{"liga"}, 1},
+	}
+	for _, test := range cases {
+		sub := font.SubsetRunes([]rune(test.text), test.opt)
+
+		if got := glyphNames(sub); got != test.glyphs {
+			t.Errorf("%s: expected glyphs %q, got %q", test.text, test.glyphs, got)
+		}
+		if got := featureTags(sub); !slices.Equal(got, test.features) {
+			t.Errorf("%s: expected features %q, got %q", test.text, test.features, got)
+		}
+		if got := len(sub.Gsub.ScriptList); got != test.scripts {
+			t.Errorf("%s: expected %d scripts, got %d", test.text, test.scripts, got)
+		}
+
+		cmap, err := sub.CMapTable.GetBest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, r := range "ABCFIXYZ" {
+			gid := cmap.Lookup(r)
+			if want := slices.Contains([]rune(test.text), r); (gid != 0) != want {
+				t.Errorf("%s: unexpected cmap entry for %q", test.text, r)
+			}
+		}
+
+		buf := &bytes.Buffer{}
+		if _, err := sub.Write(buf); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSubsetRunesVariationSequences(t *testing.T) {
+	font := debug.MakeSimpleFont()
+	uni, err := font.CMapTable.GetBest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	uvs := &cmap.Format14{
+		Default: map[cmap.VariationSequence]bool{
+			{Base: 'B', Selector: 0xFE00}: true,
+			{Base: 'C', Selector: 0xFE00}: true,
+		},
+		NonDefault: map[cmap.VariationSequence]glyph.ID{
+			{Base: 'A', Selector: 0xFE01}: uni.Lookup('Q'),
+			{Base: 'C', Selector: 0xFE01}: uni.Lookup('R'),
+		},
+	}
+	font.CMapTable[cmap.Key{PlatformID: 0, EncodingID: 5}] = uvs.Encode()
+
+	sub := font.SubsetRunes([]rune("A\uFE01B\uFE00"), nil)
+
+	var names []string
+	for i := 1; i < sub.NumGlyphs(); i++ {
+		names = append(names, sub.GlyphName(glyph.ID(i)))
+	}
+	slices.Sort(names)
+	if want := []string{"A", "B", "Q"}; !slices.Equal(names, want) {
+		t.Errorf("expected glyphs %q, got %q", want, names)
+	}
+
+	subUni, err := sub.CMapTable.GetBest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	subUVS, err := sub.CMapTable.GetFormat14()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		seq  cmap.VariationSequence
+		name string
+	}{
+		{cmap.VariationSequence{Base: 'A', Selector: 0xFE01}, "Q"},
+		{cmap.VariationSequence{Base: 'B', Selector: 0xFE00}, "B"},
+		{cmap.VariationSequence{Base: 'C', Selector: 0xFE00}, ""},
+		{cmap.VariationSequence{Base: 'C', Selector: 0xFE01}, ""},
+	}
+	for _, c := range cases {
+		gid, ok := subUVS.Lookup(c.seq, subUni)
+		if c.name == "" {
+			if ok {
+				t.Errorf("%v: unexpected glyph %d", c.seq, gid)
+			}
+		} else if !ok || sub.GlyphName(gid) != c.name {
+			t.Errorf("%v: expected %q, got %d, %t", c.seq, c.name, gid, ok)
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	if _, err := sub.Write(buf); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -22,13 +22,20 @@ import (
 	"math"
 	"testing"
 
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/text/language"
+
 	"seehuhn.de/go/geom/matrix"
 	"seehuhn.de/go/postscript/cid"
 	"seehuhn.de/go/postscript/type1"
 	"seehuhn.de/go/sfnt/cff"
+	"seehuhn.de/go/sfnt/cmap"
+	"seehuhn.de/go/sfnt/glyf"
 	"seehuhn.de/go/sfnt/glyph"
 	"seehuhn.de/go/sfnt/header"
 	"seehuhn.de/go/sfnt/hmtx"
+	"seehuhn.de/go/sfnt/opentype/coverage"
+	"seehuhn.de/go/sfnt/opentype/gtab"
 	"seehuhn.de/go/sfnt/parser"
 )
 
@@ -244,3 +251,143 @@ func TestIsFixedPitchCIDPerFDMatrix(t *testing.T) {
 		t.Errorf("IsFixedPitch=true, want false (gid 2 advances 0.6 em)")
 	}
 }
+
+func TestSubsetRetainGIDs(t *testing.T) {
+	font, err := Read(bytes.NewReader(goregular.TTF), parser.NewBudget(int64(len(goregular.TTF))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmap, err := font.CMapTable.GetBest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	outlines := font.Outlines.(*glyf.Outlines)
+
+	var keep []glyph.ID
+	for _, r := range "xA" {
+		keep = append(keep, cmap.Lookup(r))
+	}
+
+	sub := font.SubsetRetainGIDs(keep)
+
+	// check that the subset can be written and read back
+	buf := &bytes.Buffer{}
+	if _, err := sub.Write(buf); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	sub, err = Read(bytes.NewReader(data), parser.NewBudget(int64(len(data))))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	used := map[glyph.ID]bool{0: true}
+	maxGid := glyph.ID(0)
+	for _, gid := range keep {
+		used[gid] = true
+		maxGid = max(maxGid, gid)
+	}
+	if sub.NumGlyphs() != int(maxGid)+1 {
+		t.Errorf("expected %d glyphs, got %d", maxGid+1, sub.NumGlyphs())
+	}
+
+	subOutlines := sub.Outlines.(*glyf.Outlines)
+	for gid := range glyph.ID(sub.NumGlyphs()) {
+		if used[gid] {
+			if subOutlines.Widths[gid] != outlines.Widths[gid] {
+				t.Errorf("glyph %d: wrong width", gid)
+			}
+			if (subOutlines.Glyphs[gid] == nil) != (outlines.Glyphs[gid] == nil) {
+				t.Errorf("glyph %d: outline changed", gid)
+			}
+		} else if subOutlines.Glyphs[gid] != nil || subOutlines.Widths[gid] != 0 {
+			t.Errorf("glyph %d: expected an empty glyph", gid)
+		}
+	}
+
+	subCmap, err := sub.CMapTable.GetBest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range "xA" {
+		if subCmap.Lookup(r) != cmap.Lookup(r) {
+			t.Errorf("wrong cmap entry for %q", r)
+		}
+	}
+	if subCmap.Lookup('y') != 0 {
+		t.Error("unexpected cmap entry for 'y'")
+	}
+}
+
+func TestSubsetRetainGIDsCFF(t *testing.T) {
+	o := &cff.Outlines{
+		Private: []*type1.PrivateDict{{}},
+		FDSelect: func(glyph.ID) int {
+			return 0
+		},
+	}
+	for _, name := range []string{".notdef", "A", "F", "I", "X"} {
+		g := cff.NewGlyph(name, 500)
+		g.MoveTo(0, 0)
+		g.LineTo(400, 0)
+		g.LineTo(400, 700)
+		g.LineTo(0, 700)
+		o.Glyphs = append(o.Glyphs, g)
+	}
+	font := &Font{
+		FamilyName: "Test",
+		UnitsPerEm: 1000,
+		FontMatrix: matrix.Matrix{0.001, 0, 0, 0.001, 0, 0},
+		Ascent:     800,
+		Descent:    -200,
+		Outlines:   o,
+	}
+	font.InstallCMap(cmap.Format4{'A': 1, 'F': 2, 'I': 3, 'X': 4})
+	font.Gsub = &gtab.Info{
+		ScriptList: gtab.ScriptListInfo{
+			language.MustParse("und-Zzzz"): {
+				Required: 0xFFFF,
+				Optional: []gtab.FeatureIndex{0},
+			},
+		},
+		FeatureList: gtab.FeatureListInfo{
+			{Tag: "liga", Lookups: []gtab.LookupIndex{0}},
+		},
+		LookupList: gtab.LookupList{
+			{
+				Meta: &gtab.LookupMetaInfo{LookupType: 4},
+				Subtables: []gtab.Subtable{
+					&gtab.Gsub4_1{
+						Cov:  coverage.Table{2: 0},
+						Repl: [][]gtab.Ligature{{{In: []glyph.ID{3}, Out: 4}}},
+					},
+				},
+			},
+		},
+	}
+
+	sub := font.SubsetRunes([]rune("FI"), &SubsetOptions{RetainGIDs: true})
+
+	if sub.NumGlyphs() != 5 {
+		t.Fatalf("expected 5 glyphs, got %d", sub.NumGlyphs())
+	}
+	for gid := range glyph.ID(sub.NumGlyphs()) {
+		if sub.GlyphName(gid) != font.GlyphName(gid) {
+			t.Errorf("glyph %d: name changed", gid)
+		}
+	}
+	if sub.GlyphWidth(1) != 0 {
+		t.Error("glyph 1: expected zero width")
+	}
+
+	// the ligature must still map to the original glyph IDs
+	lig := sub.Gsub.LookupList[0].Subtables[0].(*gtab.Gsub4_1)
+	if _, ok := lig.Cov[2]; !ok || lig.Repl[0][0].Out != 4 {
+		t.Error("wrong ligature in subset")
+	}
+
+	buf := &bytes.Buffer{}
+	if _, err := sub.Write(buf); err != nil {
+		t.Fatal(err)
+	}
+}
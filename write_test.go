@@ -20,8 +20,10 @@ import (
 	"bytes"
 	"testing"
 
+	"github.com/google/go-cmp/cmp"
 	"golang.org/x/image/font/gofont/goregular"
 
+	"seehuhn.de/go/sfnt/cff"
 	"seehuhn.de/go/sfnt/glyf"
 	"seehuhn.de/go/sfnt/parser"
 )
@@ -105,3 +107,39 @@ func TestWriteTrueTypePDFOmitsNamelessPost(t *testing.T) {
 		t.Errorf("expected nil glyph names, got %d entries", len(names))
 	}
 }
+
+// TestWriteSubroutinizedCFF checks that a subroutinized CFF font is smaller
+// than the plain version, and that the glyph outlines survive a round trip.
+func TestWriteSubroutinizedCFF(t *testing.T) {
+	src, err := Read(bytes.NewReader(goregular.TTF), parser.NewBudget(int64(len(goregular.TTF))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain := src.ConvertToCFF()
+
+	subr := plain.Clone()
+	outlines := *plain.Outlines.(*cff.Outlines)
+	outlines.Subroutinize = true
+	subr.Outlines = &outlines
+
+	var plainBuf, subrBuf bytes.Buffer
+	if _, err := plain.Write(&plainBuf); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := subr.Write(&subrBuf); err != nil {
+		t.Fatal(err)
+	}
+	if subrBuf.Len() >= plainBuf.Len() {
+		t.Errorf("subroutinized size %d >= plain size %d", subrBuf.Len(), plainBuf.Len())
+	}
+
+	dst, err := Read(bytes.NewReader(subrBuf.Bytes()), parser.NewBudget(int64(subrBuf.Len())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := plain.Outlines.(*cff.Outlines).Glyphs
+	got := dst.Outlines.(*cff.Outlines).Glyphs
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("glyphs differ (-want +got):\n%s", diff)
+	}
+}